@@ -0,0 +1,70 @@
+package directory
+
+import (
+	"io"
+	"sort"
+	"sync"
+
+	"github.com/manderson5192/memfs/fserrors"
+	"github.com/manderson5192/memfs/inode"
+	"github.com/pkg/errors"
+)
+
+// DirReader reads a directory's entries a page at a time, like an *os.File for a directory
+type DirReader interface {
+	// ReadDir returns up to n of the directory's remaining entries, in lexical order by Name.  If n
+	// is positive and no entries remain, then it returns an empty slice and io.EOF.  If n is zero or
+	// negative, then it returns all of the remaining entries and a nil error.
+	ReadDir(n int) ([]DirectoryEntry, error)
+}
+
+type dirReader struct {
+	dirInode *inode.DirectoryInode
+	mutex    sync.Mutex // synchronizes access to names
+	// names are the sorted names of the entries that have not yet been returned
+	names []string
+}
+
+// newDirReader creates a DirReader for dirInode.  The names of dirInode's entries are captured up
+// front, so entries that are created afterwards are never returned.  Entries that are removed
+// before they are read are skipped.
+func newDirReader(dirInode *inode.DirectoryInode) DirReader {
+	names := dirInode.EntryNames()
+	sort.Strings(names)
+	return &dirReader{
+		dirInode: dirInode,
+		names:    names,
+	}
+}
+
+func (r *dirReader) ReadDir(n int) ([]DirectoryEntry, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if n > 0 && len(r.names) == 0 {
+		return []DirectoryEntry{}, io.EOF
+	}
+	capacity := len(r.names)
+	if n > 0 && n < capacity {
+		capacity = n
+	}
+	toReturn := make([]DirectoryEntry, 0, capacity)
+	for len(r.names) > 0 && (n <= 0 || len(toReturn) < n) {
+		name := r.names[0]
+		r.names = r.names[1:]
+		entryInode, err := r.dirInode.InodeEntry(name)
+		if errors.Is(err, fserrors.ENoEnt) {
+			// The entry was removed after the reader was opened
+			continue
+		} else if err != nil {
+			return toReturn, errors.Wrapf(err, "could not read entry '%s'", name)
+		}
+		toReturn = append(toReturn, DirectoryEntry{
+			Name: name,
+			Type: directoryEntryTypeFromInodeType(entryInode.InodeType()),
+		})
+	}
+	if n > 0 && len(toReturn) == 0 {
+		return toReturn, io.EOF
+	}
+	return toReturn, nil
+}
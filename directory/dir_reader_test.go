@@ -0,0 +1,88 @@
+package directory_test
+
+import (
+	"io"
+
+	"github.com/manderson5192/memfs/directory"
+	"github.com/manderson5192/memfs/fserrors"
+	"github.com/stretchr/testify/assert"
+)
+
+// populateCSubdir adds five entries to /a/b/c
+func (s *DirectoryTestSuite) populateCSubdir() {
+	for _, name := range []string{"e", "b", "d"} {
+		_, err := s.CSubdir.CreateFile(name)
+		assert.Nil(s.T(), err)
+	}
+	for _, name := range []string{"c", "a"} {
+		_, err := s.CSubdir.Mkdir(name)
+		assert.Nil(s.T(), err)
+	}
+}
+
+func (s *DirectoryTestSuite) TestOpenDirPages() {
+	s.populateCSubdir()
+	reader, err := s.RootDir.OpenDir("a/b/c")
+	assert.Nil(s.T(), err)
+
+	union := []directory.DirectoryEntry{}
+	pageSizes := []int{}
+	for {
+		page, err := reader.ReadDir(2)
+		if err == io.EOF {
+			assert.Empty(s.T(), page)
+			break
+		}
+		assert.Nil(s.T(), err)
+		pageSizes = append(pageSizes, len(page))
+		union = append(union, page...)
+	}
+	assert.Equal(s.T(), []int{2, 2, 1}, pageSizes)
+
+	expected, err := s.RootDir.ReadDirSorted("a/b/c")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), expected, union)
+}
+
+func (s *DirectoryTestSuite) TestOpenDirReadAll() {
+	s.populateCSubdir()
+	reader, err := s.CSubdir.OpenDir("")
+	assert.Nil(s.T(), err)
+	first, err := reader.ReadDir(1)
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []directory.DirectoryEntry{{Name: "a", Type: directory.DirectoryType}}, first)
+
+	// A non-positive n returns everything that remains, and a nil error even once exhausted
+	rest, err := reader.ReadDir(0)
+	assert.Nil(s.T(), err)
+	assert.Len(s.T(), rest, 4)
+	rest, err = reader.ReadDir(-1)
+	assert.Nil(s.T(), err)
+	assert.Empty(s.T(), rest)
+}
+
+func (s *DirectoryTestSuite) TestOpenDirConcurrentMutation() {
+	s.populateCSubdir()
+	reader, err := s.CSubdir.OpenDir("")
+	assert.Nil(s.T(), err)
+
+	// Entries created after opening are not returned, and entries removed before being read are
+	// skipped
+	_, err = s.CSubdir.CreateFile("f")
+	assert.Nil(s.T(), err)
+	assert.Nil(s.T(), s.CSubdir.DeleteFile("b"))
+	entries, err := reader.ReadDir(0)
+	assert.Nil(s.T(), err)
+	names := []string{}
+	for _, entry := range entries {
+		names = append(names, entry.Name)
+	}
+	assert.Equal(s.T(), []string{"a", "c", "d", "e"}, names)
+}
+
+func (s *DirectoryTestSuite) TestOpenDirErrors() {
+	_, err := s.RootDir.OpenDir("a/noexist")
+	assert.ErrorIs(s.T(), err, fserrors.ENoEnt)
+	_, err = s.RootDir.OpenDir("/a")
+	assert.NotNil(s.T(), err)
+}
@@ -3,11 +3,15 @@ package directory
 import (
 	"encoding/json"
 	"fmt"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/manderson5192/memfs/file"
 	"github.com/manderson5192/memfs/filepath"
 	"github.com/manderson5192/memfs/fserrors"
 	"github.com/manderson5192/memfs/inode"
+	"github.com/manderson5192/memfs/notify"
 	"github.com/manderson5192/memfs/os"
 	"github.com/pkg/errors"
 )
@@ -56,19 +60,87 @@ type DirectoryEntry struct {
 	Type DirectoryEntryType `json:"type"`
 }
 
+type byEntry []DirectoryEntry
+
+func (b byEntry) Len() int           { return len(b) }
+func (b byEntry) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+func (b byEntry) Less(i, j int) bool { return b[i].Name < b[j].Name }
+
+// SortEntries sorts entries in place, in lexical order by Name
+func SortEntries(entries []DirectoryEntry) {
+	sort.Sort(byEntry(entries))
+}
+
 // FileInfo represents information about a single file or directory.  If Type indicates a directory,
 // then Size will be the number of directory entries.  If Type indicates a file, then Size will be
 // the file's size in bytes
 type FileInfo struct {
 	Size int
 	Type DirectoryEntryType
+	// Name is the base name of the file or directory, i.e. the name of its entry in its parent
+	// directory.  By convention, the name of the root directory (including a Chroot() root) is "/".
+	Name string
+	// Ino is the inode number of the file or directory (see inode.Inode.Ino()).  Two FileInfos
+	// describe the same file or directory if and only if their Inos are equal.
+	Ino uint64
+	// ModTime, AccessTime, and ChangeTime are the file or directory's timestamps (see
+	// inode.TimesInode)
+	ModTime    time.Time
+	AccessTime time.Time
+	ChangeTime time.Time
+	// Uid and Gid identify the file or directory's owner (see inode.OwnerInode)
+	Uid int
+	Gid int
+	// Perm holds the file or directory's permission bits (see inode.ModeInode)
+	Perm os.FileMode
 }
 
 type Directory interface {
 	// Equals returns true if the other Directory references the same inode, false otherwise
 	Equals(other Directory) bool
-	// ReversePathLookup returns a valid absolute path for the directory or an error
+	// IsValid returns false if the directory has been deleted (e.g. by Rmdir() or by being replaced
+	// by a rename), and true otherwise.  Operations on an invalid Directory that would create
+	// entries in it fail with ENOENT.
+	IsValid() bool
+	// OpenHandle returns a new Directory for the same directory that holds an open handle on it
+	// until it is closed with Close(), like a file descriptor for a directory.  While a directory
+	// has open handles, RmdirOpen() may remove it even if it is not empty.  It is then unlinked but
+	// open: it can no longer be reached by path (so ReversePathLookup() fails with ENOENT), but its
+	// entries remain reachable through the open handles, just as a deleted file remains readable
+	// through its open Files.  Its subtree is unlinked once the last handle is closed.
+	OpenHandle() Directory
+	// Close closes a Directory that was returned by OpenHandle().  It returns EINVAL if the
+	// Directory was already closed, and does nothing for a Directory that holds no open handle.
+	Close() error
+	// ReversePathLookup returns a valid absolute path for the directory or an error.  If the
+	// Directory was derived from a Chroot() Directory, then the path is relative to that root.
 	ReversePathLookup() (string, error)
+	// Chroot returns a Directory for the same directory that treats it as the filesystem's root, like
+	// chroot(2).  Every Directory that is derived from the returned Directory (e.g. by
+	// LookupSubdirectory() or Mkdir()) shares its root: a ".." entry in the root refers to the root
+	// itself, so paths can't escape from the root's subtree, and ReversePathLookup() reports paths
+	// relative to the root.
+	Chroot() Directory
+	// WithUmask returns a Directory for the same directory whose umask is mask: the permission bits
+	// of every file or directory that is created through it are masked by mask, like umask(2).
+	// Every Directory that is derived from the returned Directory shares its umask.  A Directory
+	// returned by NewDirectory() has umask os.DefaultUmask.
+	WithUmask(mask os.FileMode) Directory
+	// Umask returns the Directory's umask (see WithUmask())
+	Umask() os.FileMode
+	// WithMutationCounter returns a Directory for the same directory whose mutations are also
+	// counted in c (see inode.Superblock.CountMutation()), as are those of every Directory and File
+	// that is derived from or opened through it.  A Directory returned by NewDirectory() has no
+	// MutationCounter, so its mutations only count in the filesystem's total.
+	WithMutationCounter(c *inode.MutationCounter) Directory
+	// Parser returns the Parser for the syntax of the paths that the Directory accepts and reports
+	// (see filepath.Parser).  Watch() events always report paths in the default syntax.
+	Parser() *filepath.Parser
+	// FindEntries returns the relative paths of every entry called name in the Directory's subtree,
+	// in the order in which Walk()'ing the subtree would visit them, using the filesystem's index
+	// instead of walking (see filesys.NewIndexedFileSystem()).  It returns false if the index can't
+	// answer, e.g. because the filesystem isn't indexed, in which case the caller must walk.
+	FindEntries(name string) ([]string, bool)
 	// LookupSubdirectory returns the Directory for the subdirectory of the current directory, or an
 	// error.  If subdirectory is empty, then this Directory itself will be returned.
 	LookupSubdirectory(subdirectory string) (Directory, error)
@@ -76,39 +148,130 @@ type Directory interface {
 	// directory, or returns an error.  It will return an error if a path component does not exist
 	// or is not a directory.  It will return an error if the specified subdirectory already exists.
 	Mkdir(subdirectory string) (Directory, error)
+	// MkdirMode behaves like Mkdir, except that the new directory's permission bits are perm (masked
+	// by the Directory's umask; see WithUmask()) instead of os.ModePerm, like os.Mkdir(name, perm)
+	MkdirMode(subdirectory string, perm os.FileMode) (Directory, error)
 	// ReadDir returns an array of DirectoryEntry for the specified subdirectory of the current
 	// directory, or returns an error.  It will return an error if a path component does not exist
 	// or is not a directory.
 	ReadDir(subdirectory string) ([]DirectoryEntry, error)
-	// Rmdir removes the specified subdirectory of the current directory, or returns an error
+	// ReadDirSorted behaves like ReadDir, except that the entries are guaranteed to be sorted in
+	// lexical order by Name.  (ReadDir returns entries in an unspecified order, which may differ
+	// between calls.)
+	ReadDirSorted(subdirectory string) ([]DirectoryEntry, error)
+	// OpenDir returns a DirReader for the specified subdirectory of the current directory, which
+	// returns the subdirectory's entries a page at a time instead of all at once.  If subdirectory
+	// is empty, then the DirReader will be for this Directory itself.
+	OpenDir(subdirectory string) (DirReader, error)
+	// Rmdir removes the specified subdirectory of the current directory, or returns an error.  The
+	// subdirectory must be empty (ENOTEMPTY), whether or not it has open handles.
 	Rmdir(subdirectory string) error
+	// RmdirOpen behaves like Rmdir(), except that a non-empty subdirectory may also be removed if it
+	// has open handles (see OpenHandle()).  Its entries then remain reachable through those
+	// handles until the last one is closed.
+	RmdirOpen(subdirectory string) error
 	// CreateFile creates a new file at the specified relative path, or returns an error
 	CreateFile(relativePath string) (file.File, error)
+	// CreateExclusive opens the file at the specified relative path in O_RDWR mode, creating it if
+	// it does not exist.  It also reports whether the file was newly created (true) or already
+	// existed (false).  The existence check and the creation happen atomically.
+	CreateExclusive(relativePath string) (file.File, bool, error)
 	// OpenFile returns a reference to the specified relative path in the specified mode, or returns
 	// an error
 	OpenFile(relativePath string, mode int) (file.File, error)
+	// OpenFileWithLimit behaves like OpenFile, except that writes through the returned File cannot
+	// grow the file beyond maxBytes bytes (see file.NewFileWithLimit())
+	OpenFileWithLimit(relativePath string, mode int, maxBytes int64) (file.File, error)
+	// OpenRingFile opens the file at the specified relative path for reading and appending, creating
+	// it if it does not exist, as a ring file that retains only the last maxBytes bytes written to
+	// it (see file.NewRingFile()).  If the file is already longer than maxBytes, then its oldest
+	// bytes are evicted immediately.  It returns EINVAL if maxBytes is not positive.
+	OpenRingFile(relativePath string, maxBytes int) (file.File, error)
+	// OpenFileBuffered behaves like OpenFile, except that the returned File buffers up to bufSize
+	// bytes of writes before flushing them to the file (see file.NewWriteBackFile()).  It returns
+	// EINVAL if bufSize is not positive.
+	OpenFileBuffered(relativePath string, mode int, bufSize int) (file.File, error)
 	// DeleteFile removes the specified file, which must be at a path relative to the current
 	// directory.  It returns an error if it is unsuccessful
 	DeleteFile(relativePath string) error
 	// Rename moves the file or directory at the specified relative src path to the specified
 	// relative dst path.  If an entry already exists at the dst path, then this operation will
-	// attempt to atomically replace it.  Returns an error if unsuccessful
+	// attempt to atomically replace it, subject to the same restrictions as rename(2) (see
+	// process.ProcessFilesystemContext.Rename()).  Returns an error if unsuccessful
 	Rename(srcPath, dstPath string) error
+	// RenameNoReplace behaves like Rename, except that it returns EEXIST and leaves both entries
+	// unchanged if an entry already exists at the dst path (see inode.MoveEntryNoReplace())
+	RenameNoReplace(srcPath, dstPath string) error
+	// RenameExchange atomically swaps the entries at the two relative paths, both of which must
+	// exist (see inode.ExchangeEntries())
+	RenameExchange(path1, path2 string) error
+	// CanRename returns the error that Rename would return for the same paths, or nil if Rename
+	// would succeed, without moving anything (see inode.CheckMoveEntry())
+	CanRename(srcPath, dstPath string) error
 	// Stat returns a FileInfo for the file or directory at the indicated path.  If relativePath is
 	// empty, then the indicated path will for the receiver Directory object
 	Stat(relativePath string) (*FileInfo, error)
+	// StatEntries returns a FileInfo for each entry of the specified subdirectory of the current
+	// directory, sorted by Name.  The entries are taken from a single consistent snapshot of the
+	// subdirectory (see inode.DirectoryInode.Snapshot()), so unlike calling Stat() on each entry
+	// returned by ReadDir(), it never observes an entry that was removed or replaced in between.
+	StatEntries(subdirectory string) ([]*FileInfo, error)
+	// Chtimes sets the access and modification times of the file or directory at the indicated
+	// path, like os.Chtimes()
+	Chtimes(relativePath string, accessTime, modTime time.Time) error
+	// Chown sets the user and group ids that own the file or directory at the indicated path, like
+	// os.Chown().  Ownership is recorded but not enforced.
+	Chown(relativePath string, uid, gid int) error
+	// Chmod sets the permission bits of the file or directory at the indicated path to those of mode,
+	// like os.Chmod().  The umask doesn't apply.  Permissions are recorded but not enforced.
+	Chmod(relativePath string, mode os.FileMode) error
+	// SetXattr creates or replaces the named extended attribute on the file or directory at the
+	// indicated path (see inode.XattrInode)
+	SetXattr(relativePath, name string, value []byte) error
+	// GetXattr returns the value of the named extended attribute on the file or directory at the
+	// indicated path, or ENODATA if it is not set
+	GetXattr(relativePath, name string) ([]byte, error)
+	// ListXattr returns the names of the extended attributes on the file or directory at the
+	// indicated path, in lexical order
+	ListXattr(relativePath string) ([]string, error)
+	// RemoveXattr removes the named extended attribute from the file or directory at the indicated
+	// path, or returns ENODATA if it is not set
+	RemoveXattr(relativePath, name string) error
+	// Watch registers a watcher for changes to the file or directory at the specified relative path
+	// and, if it is a directory, to everything beneath it.  It returns a channel of events and a
+	// function that stops the watch and closes the channel.  Events carry absolute paths, and they
+	// are dropped rather than delivered if the channel is full (see notify.Registry.Watch()).
+	Watch(relativePath string) (<-chan notify.Event, func(), error)
 }
 
 type directory struct {
 	*inode.DirectoryInode
+	// root is the directory that this Directory treats as the filesystem's root (see Chroot()), or
+	// nil if it is the filesystem's actual root directory
+	root *inode.DirectoryInode
+	// opened is true if this Directory holds a handle on its inode (see OpenHandle()), and closed
+	// is set to 1 once that handle has been released
+	opened bool
+	closed int32
+	// umask masks the permission bits of entries that are created through this Directory (see
+	// WithUmask())
+	umask os.FileMode
+	// mutations also counts the mutations that are made through this Directory, or is nil (see
+	// WithMutationCounter())
+	mutations *inode.MutationCounter
 }
 
 func NewDirectory(inode *inode.DirectoryInode) Directory {
 	return &directory{
 		DirectoryInode: inode,
+		umask:          os.DefaultUmask,
 	}
 }
 
+func (d *directory) IsValid() bool {
+	return !d.IsDeleted()
+}
+
 // Equals compares two directories on the basis of their underlying inode struct's address in memory
 func (d *directory) Equals(other Directory) bool {
 	if d == nil || other == nil {
@@ -121,23 +284,129 @@ func (d *directory) Equals(other Directory) bool {
 	return d.DirectoryInode == otherDir.DirectoryInode
 }
 
-// ReversePathLookup determines the absolute path of the receiver directory `d` by iteratively
-// fetching the parent directory inode (the special ".." entry) and doing a reverse lookup for the
-// child directory inode
+// ReversePathLookup determines the absolute path of the receiver directory `d` (see
+// inode.DirectoryInode.PathWithin())
 func (d *directory) ReversePathLookup() (string, error) {
-	pathParts := []string{}
-	currentDirInode := d.DirectoryInode
-	for !currentDirInode.IsRootDirectoryInode() {
-		parentDirInode := currentDirInode.Parent()
-		pathPart, err := parentDirInode.ReverseLookupEntry(currentDirInode)
+	path, err := d.DirectoryInode.PathWithin(d.root)
+	if err != nil {
+		return "", err
+	}
+	return d.Parser().FromSlash(path), nil
+}
+
+func (d *directory) Chroot() Directory {
+	return &directory{
+		DirectoryInode: d.DirectoryInode,
+		root:           d.DirectoryInode,
+		umask:          d.umask,
+		mutations:      d.mutations,
+	}
+}
+
+// withInode returns a Directory for dirInode that has the same root, umask, and MutationCounter as
+// d
+func (d *directory) withInode(dirInode *inode.DirectoryInode) Directory {
+	return &directory{
+		DirectoryInode: dirInode,
+		root:           d.root,
+		umask:          d.umask,
+		mutations:      d.mutations,
+	}
+}
+
+func (d *directory) WithMutationCounter(c *inode.MutationCounter) Directory {
+	return &directory{
+		DirectoryInode: d.DirectoryInode,
+		root:           d.root,
+		umask:          d.umask,
+		mutations:      c,
+	}
+}
+
+// countMutation records that a mutation is about to be made through d, and returns a function that
+// records that it is over (see inode.Superblock.CountMutation())
+func (d *directory) countMutation() func() {
+	return d.Superblock().CountMutation(d.mutations)
+}
+
+func (d *directory) Parser() *filepath.Parser {
+	return d.Superblock().Parser()
+}
+
+func (d *directory) FindEntries(name string) ([]string, bool) {
+	paths, ok := d.DirectoryInode.FindEntries(name)
+	for idx, path := range paths {
+		paths[idx] = d.Parser().FromSlash(path)
+	}
+	return paths, ok
+}
+
+// parsePath parses path, which is in the filesystem's path syntax, into a PathInfo in the default
+// syntax.  It returns EINVAL if path can't be converted to the default syntax (see
+// filepath.Parser.CheckPath()).
+func (d *directory) parsePath(path string) (*filepath.PathInfo, error) {
+	slashPath, err := d.toSlash(path)
+	if err != nil {
+		return nil, err
+	}
+	return filepath.ParsePath(slashPath), nil
+}
+
+// toSlash converts path from the filesystem's path syntax to the default syntax, or returns EINVAL
+// if it can't be converted (see filepath.Parser.CheckPath())
+func (d *directory) toSlash(path string) (string, error) {
+	parser := d.Parser()
+	if err := parser.CheckPath(path); err != nil {
+		return "", err
+	}
+	return parser.ToSlash(path), nil
+}
+
+// isRelativePath returns true if path, which is in the filesystem's path syntax, is relative
+func (d *directory) isRelativePath(path string) bool {
+	return filepath.IsRelativePath(d.Parser().ToSlash(path))
+}
+
+// lookupSubdirectory looks up the DirectoryInode for subdirectory, which is in the filesystem's path
+// syntax, without escaping from d's root
+func (d *directory) lookupSubdirectory(subdirectory string) (*inode.DirectoryInode, error) {
+	slashPath, err := d.toSlash(subdirectory)
+	if err != nil {
+		return nil, err
+	}
+	return d.DirectoryInode.LookupSubdirectoryWithin(slashPath, d.root)
+}
+
+// lookupParent looks up the DirectoryInode for the directory that contains pathInfo's entry, without
+// escaping from d's root.  pathInfo must have been returned by parsePath().
+func (d *directory) lookupParent(pathInfo *filepath.PathInfo) (*inode.DirectoryInode, error) {
+	return d.DirectoryInode.LookupSubdirectoryWithin(pathInfo.ParentPath, d.root)
+}
+
+// entryInode returns the inode for the final entry of pathInfo, which was parsed from relativePath,
+// given parentInode, the directory that contains it.  A final ".." entry is resolved along with the
+// rest of relativePath, so that it leads back out of a bind mount (see inode.BindMount()).
+func (d *directory) entryInode(parentInode *inode.DirectoryInode, pathInfo *filepath.PathInfo, relativePath string) (inode.Inode, error) {
+	if pathInfo.Entry == filepath.ParentDirectoryEntry {
+		dirInode, err := d.lookupSubdirectory(relativePath)
 		if err != nil {
-			return "", errors.Wrapf(err, "could not complete reverse path lookup")
+			return nil, err
 		}
-		pathParts = append([]string{pathPart}, pathParts...)
-		currentDirInode = parentDirInode
+		return dirInode, nil
+	}
+	if err := parentInode.CheckLookupDepth(pathInfo.Entry); err != nil {
+		return nil, err
 	}
-	path := filepath.Join(pathParts...)
-	return "/" + path, nil
+	return parentInode.InodeEntry(d.clampEntry(parentInode, pathInfo.Entry))
+}
+
+// clampEntry returns entry, unless entry is ".." and parent is d's root, in which case it returns
+// "." so that looking up the entry in parent doesn't escape from the root
+func (d *directory) clampEntry(parent *inode.DirectoryInode, entry string) string {
+	if entry == filepath.ParentDirectoryEntry && parent == d.root {
+		return filepath.SelfDirectoryEntry
+	}
+	return entry
 }
 
 // LookupSubdirectory will return a directory for the specified subdirectory relative to this
@@ -145,67 +414,118 @@ func (d *directory) ReversePathLookup() (string, error) {
 // separator character.  If the specified subdirectory can't be found, or if any named directory
 // entry along its path is not a directory (e.g. if it is a file), then it will return an error
 func (d *directory) LookupSubdirectory(subdirectory string) (Directory, error) {
-	subdirInode, err := d.DirectoryInode.LookupSubdirectory(subdirectory)
+	subdirInode, err := d.lookupSubdirectory(subdirectory)
 	if err != nil {
 		return nil, err
 	}
-	return NewDirectory(subdirInode), nil
+	return d.withInode(subdirInode), nil
 }
 
 func (d *directory) Mkdir(subdirectory string) (Directory, error) {
-	pathInfo := filepath.ParsePath(subdirectory)
+	return d.MkdirMode(subdirectory, os.ModePerm)
+}
+
+func (d *directory) MkdirMode(subdirectory string, perm os.FileMode) (Directory, error) {
+	pathInfo, err := d.parsePath(subdirectory)
+	if err != nil {
+		return nil, err
+	}
 	if !pathInfo.IsRelative {
 		return nil, fmt.Errorf("'%s' is not a relative path", subdirectory)
 	}
 	// Lookup the directory that will be parent to the subdirectory
-	subdirInode, err := d.DirectoryInode.LookupSubdirectory(pathInfo.ParentPath)
+	subdirInode, err := d.lookupParent(pathInfo)
 	if err != nil {
 		return nil, errors.Wrapf(err, "could not create %s", subdirectory)
 	}
+	if err := injectFault(subdirInode, pathInfo.Entry, inode.FaultMkdir); err != nil {
+		return nil, errors.Wrapf(err, "could not create %s", subdirectory)
+	}
 	// Create the directory
+	defer d.countMutation()()
 	newDirInode, err := subdirInode.AddDirectory(pathInfo.Entry)
 	if err != nil {
 		return nil, errors.Wrapf(err, "could not create %s", subdirectory)
 	}
-	return NewDirectory(newDirInode), nil
+	d.applyPerm(newDirInode, perm)
+	publish(subdirInode, pathInfo.Entry, notify.Create)
+	return d.withInode(newDirInode), nil
 }
 
 func (d *directory) ReadDir(subdirectory string) ([]DirectoryEntry, error) {
 	// Validate that the path is relative
-	if !filepath.IsRelativePath(subdirectory) {
+	if !d.isRelativePath(subdirectory) {
 		return nil, fmt.Errorf("'%s' is not a relative path", subdirectory)
 	}
 	// Lookup the DirectoryInode for the subdirectory
-	dirInode, err := d.DirectoryInode.LookupSubdirectory(subdirectory)
+	dirInode, err := d.lookupSubdirectory(subdirectory)
 	if err != nil {
 		return nil, errors.Wrapf(err, "could not list entries in '%s'", subdirectory)
 	}
-	// Get the directory inode entries
-	inodeEntries := dirInode.InodeEntries()
-	toReturn := make([]DirectoryEntry, 0, len(inodeEntries))
-	for _, entry := range inodeEntries {
+	// Get a consistent snapshot of the directory inode entries
+	snapshot := dirInode.Snapshot()
+	toReturn := make([]DirectoryEntry, 0, snapshot.Len())
+	for _, entry := range snapshot.Entries() {
 		toReturn = append(toReturn, DirectoryEntry{
 			Name: entry.Name,
-			Type: directoryEntryTypeFromInodeType(entry.Type),
+			Type: directoryEntryTypeFromInodeType(entry.Inode.InodeType()),
 		})
 	}
 	return toReturn, nil
 }
 
+func (d *directory) ReadDirSorted(subdirectory string) ([]DirectoryEntry, error) {
+	entries, err := d.ReadDir(subdirectory)
+	if err != nil {
+		return nil, err
+	}
+	SortEntries(entries)
+	return entries, nil
+}
+
+func (d *directory) OpenDir(subdirectory string) (DirReader, error) {
+	if !d.isRelativePath(subdirectory) {
+		return nil, fmt.Errorf("'%s' is not a relative path", subdirectory)
+	}
+	dirInode, err := d.lookupSubdirectory(subdirectory)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not open '%s'", subdirectory)
+	}
+	return newDirReader(dirInode), nil
+}
+
 func (d *directory) Rmdir(subdirectory string) error {
-	pathInfo := filepath.ParsePath(subdirectory)
+	return d.rmdir(subdirectory, false)
+}
+
+func (d *directory) RmdirOpen(subdirectory string) error {
+	return d.rmdir(subdirectory, true)
+}
+
+// rmdir implements Rmdir() and RmdirOpen()
+func (d *directory) rmdir(subdirectory string, allowOpen bool) error {
+	pathInfo, err := d.parsePath(subdirectory)
+	if err != nil {
+		return err
+	}
 	if !pathInfo.IsRelative {
 		return fmt.Errorf("'%s' is not a relative path", subdirectory)
 	}
 	// Lookup the directory that is parent to the subdirectory
-	subdirInode, err := d.DirectoryInode.LookupSubdirectory(pathInfo.ParentPath)
+	subdirInode, err := d.lookupParent(pathInfo)
 	if err != nil {
 		return errors.Wrapf(err, "could not delete '%s'", subdirectory)
 	}
 	// Remove the directory
-	if err := subdirInode.DeleteDirectory(pathInfo.Entry); err != nil {
+	defer d.countMutation()()
+	deleteDirectory := subdirInode.DeleteDirectory
+	if allowOpen {
+		deleteDirectory = subdirInode.DeleteOpenDirectory
+	}
+	if err := deleteDirectory(pathInfo.Entry); err != nil {
 		return errors.Wrapf(err, "could not delete '%s'", subdirectory)
 	}
+	publish(subdirInode, pathInfo.Entry, notify.Remove)
 	return nil
 }
 
@@ -222,8 +542,92 @@ func (d *directory) CreateFile(relativePath string) (file.File, error) {
 	return f, nil
 }
 
+func (d *directory) CreateExclusive(relativePath string) (file.File, bool, error) {
+	pathInfo, err := d.parsePath(relativePath)
+	if err != nil {
+		return nil, false, err
+	}
+	if !pathInfo.IsRelative {
+		return nil, false, fmt.Errorf("'%s' is not a relative path", relativePath)
+	}
+	if pathInfo.MustBeDir {
+		return nil, false, errors.Wrapf(fserrors.EInval, "path specifies a directory")
+	}
+	// Lookup the directory that is parent to the relativePath
+	subdirInode, err := d.lookupParent(pathInfo)
+	if err != nil {
+		return nil, false, errors.Wrapf(err, "could not create '%s'", relativePath)
+	}
+	if err := injectFault(subdirInode, pathInfo.Entry, inode.FaultOpen); err != nil {
+		return nil, false, errors.Wrapf(err, "could not create '%s'", relativePath)
+	}
+	defer d.countMutation()()
+	fileInode, created, err := subdirInode.GetOrCreateFileInodeEntry(pathInfo.Entry)
+	if err != nil {
+		return nil, false, errors.Wrapf(err, "could not create '%s'", relativePath)
+	}
+	if created {
+		d.applyUmask(fileInode)
+		publish(subdirInode, pathInfo.Entry, notify.Create)
+	}
+	subdirInode.Superblock().CountOpen()
+	return d.newFile(file.NewFile(fileInode, os.O_RDWR)), created, nil
+}
+
 func (d *directory) OpenFile(relativePath string, mode int) (file.File, error) {
-	pathInfo := filepath.ParsePath(relativePath)
+	return d.OpenFileWithLimit(relativePath, mode, -1)
+}
+
+func (d *directory) OpenFileWithLimit(relativePath string, mode int, maxBytes int64) (file.File, error) {
+	fileInode, err := d.openFileInode(relativePath, mode)
+	if err != nil {
+		return nil, err
+	}
+	return d.newFile(file.NewFileWithLimit(fileInode, mode, maxBytes)), nil
+}
+
+func (d *directory) OpenRingFile(relativePath string, maxBytes int) (file.File, error) {
+	if maxBytes <= 0 {
+		return nil, errors.Wrapf(fserrors.EInval, "ring size must be positive, not %d", maxBytes)
+	}
+	mode := os.CombineModes(os.O_RDWR, os.O_CREATE, os.O_APPEND)
+	fileInode, err := d.openFileInode(relativePath, mode)
+	if err != nil {
+		return nil, err
+	}
+	// Evict any existing data beyond the ring's size
+	if _, err := fileInode.AppendRing([]byte{}, maxBytes); err != nil {
+		return nil, errors.Wrapf(err, "could not open '%s'", relativePath)
+	}
+	return d.newFile(file.NewRingFile(fileInode, mode, maxBytes)), nil
+}
+
+func (d *directory) OpenFileBuffered(relativePath string, mode int, bufSize int) (file.File, error) {
+	if bufSize <= 0 {
+		return nil, errors.Wrapf(fserrors.EInval, "buffer size must be positive, not %d", bufSize)
+	}
+	fileInode, err := d.openFileInode(relativePath, mode)
+	if err != nil {
+		return nil, err
+	}
+	return d.newFile(file.NewWriteBackFile(fileInode, mode, bufSize)), nil
+}
+
+// newFile returns f, which was just opened through d, after making it count its writes in d's
+// MutationCounter (see file.WithMutationCounter())
+func (d *directory) newFile(f file.File) file.File {
+	if d.mutations == nil {
+		return f
+	}
+	return file.WithMutationCounter(f, d.mutations)
+}
+
+// openFileInode returns the FileInode at relativePath, creating or truncating it as mode specifies
+func (d *directory) openFileInode(relativePath string, mode int) (*inode.FileInode, error) {
+	pathInfo, err := d.parsePath(relativePath)
+	if err != nil {
+		return nil, err
+	}
 	if !pathInfo.IsRelative {
 		return nil, fmt.Errorf("'%s' is not a relative path", relativePath)
 	}
@@ -231,20 +635,36 @@ func (d *directory) OpenFile(relativePath string, mode int) (file.File, error) {
 		return nil, errors.Wrapf(fserrors.EInval, "path specifies a directory")
 	}
 	// Lookup the directory that is parent to the relativePath
-	subdirInode, err := d.DirectoryInode.LookupSubdirectory(pathInfo.ParentPath)
+	subdirInode, err := d.lookupParent(pathInfo)
 	if err != nil {
 		return nil, errors.Wrapf(err, "could not open '%s'", relativePath)
 	}
+	if err := injectFault(subdirInode, pathInfo.Entry, inode.FaultOpen); err != nil {
+		return nil, errors.Wrapf(err, "could not open '%s'", relativePath)
+	}
+	if err := subdirInode.CheckLookupDepth(pathInfo.Entry); err != nil {
+		return nil, errors.Wrapf(err, "could not open '%s'", relativePath)
+	}
 	// Get the file, creating it if necessary
+	if os.IsCreateMode(mode) || os.IsTruncateMode(mode) {
+		defer d.countMutation()()
+	}
 	var fileInode *inode.FileInode
-	if os.IsCreateMode(mode) {
-		fileInode, err = subdirInode.CreateFileInodeEntry(pathInfo.Entry, os.IsExclusiveMode(mode))
+	created := false
+	if os.IsCreateMode(mode) && os.IsExclusiveMode(mode) {
+		fileInode, err = subdirInode.CreateFileInodeEntry(pathInfo.Entry, true)
+		created = err == nil
+	} else if os.IsCreateMode(mode) {
+		fileInode, created, err = subdirInode.GetOrCreateFileInodeEntry(pathInfo.Entry)
 	} else {
 		fileInode, err = subdirInode.FileInodeEntry(pathInfo.Entry)
 	}
 	if err != nil {
 		return nil, errors.Wrapf(err, "could not open %s", relativePath)
 	}
+	if created {
+		d.applyUmask(fileInode)
+	}
 	// Truncate the file if the mode says to do so
 	if os.IsTruncateMode(mode) {
 		err := fileInode.TruncateAndWriteAll(make([]byte, 0))
@@ -252,45 +672,120 @@ func (d *directory) OpenFile(relativePath string, mode int) (file.File, error) {
 			return nil, errors.Wrapf(err, "could not truncate %s on open", relativePath)
 		}
 	}
-	return file.NewFile(fileInode, mode), nil
+	if created {
+		publish(subdirInode, pathInfo.Entry, notify.Create)
+	} else if os.IsTruncateMode(mode) {
+		publish(subdirInode, pathInfo.Entry, notify.Write)
+	}
+	subdirInode.Superblock().CountOpen()
+	return fileInode, nil
 }
 
 func (d *directory) Stat(relativePath string) (*FileInfo, error) {
-	pathInfo := filepath.ParsePath(relativePath)
+	pathInfo, err := d.parsePath(relativePath)
+	if err != nil {
+		return nil, err
+	}
 	if !pathInfo.IsRelative {
 		return nil, fmt.Errorf("'%s' is not a relative path", relativePath)
 	}
 	// Lookup the directory that is parent to the relativePath
-	subdirInode, err := d.DirectoryInode.LookupSubdirectory(pathInfo.ParentPath)
+	subdirInode, err := d.lookupParent(pathInfo)
 	if err != nil {
 		return nil, errors.Wrapf(err, "could not stat '%s'", relativePath)
 	}
+	if err := injectFault(subdirInode, pathInfo.Entry, inode.FaultStat); err != nil {
+		return nil, errors.Wrapf(err, "could not stat '%s'", relativePath)
+	}
 	// Grab the file or directory inode from subdirInode
-	genericInode, err := subdirInode.InodeEntry(pathInfo.Entry)
+	genericInode, err := d.entryInode(subdirInode, pathInfo, relativePath)
 	if err != nil {
 		return nil, errors.Wrapf(err, "could not stat %s", relativePath)
 	}
+	name := pathInfo.Entry
 	switch inodeTyped := genericInode.(type) {
 	case *inode.FileInode:
 		if pathInfo.MustBeDir {
 			return nil, errors.Wrapf(fserrors.ENotDir, "file found where directory %s expected", relativePath)
 		}
-		return &FileInfo{
-			Type: FileType,
-			Size: inodeTyped.Size(),
-		}, nil
 	case *inode.DirectoryInode:
-		return &FileInfo{
-			Type: DirectoryType,
-			Size: inodeTyped.Size(),
-		}, nil
+		if name == "" || name == filepath.SelfDirectoryEntry || name == filepath.ParentDirectoryEntry {
+			if name, err = d.directoryName(inodeTyped); err != nil {
+				return nil, errors.Wrapf(err, "could not stat %s", relativePath)
+			}
+		}
+	}
+	fileInfo, err := newFileInfo(name, genericInode)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not stat '%s'", relativePath)
+	}
+	return fileInfo, nil
+}
+
+func (d *directory) StatEntries(subdirectory string) ([]*FileInfo, error) {
+	if !d.isRelativePath(subdirectory) {
+		return nil, fmt.Errorf("'%s' is not a relative path", subdirectory)
+	}
+	dirInode, err := d.lookupSubdirectory(subdirectory)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not stat entries in '%s'", subdirectory)
+	}
+	snapshot := dirInode.Snapshot()
+	toReturn := make([]*FileInfo, 0, snapshot.Len())
+	for _, entry := range snapshot.Entries() {
+		fileInfo, err := newFileInfo(entry.Name, entry.Inode)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not stat entries in '%s'", subdirectory)
+		}
+		toReturn = append(toReturn, fileInfo)
+	}
+	return toReturn, nil
+}
+
+// newFileInfo returns a FileInfo that describes genericInode, whose name is name
+func newFileInfo(name string, genericInode inode.Inode) (*FileInfo, error) {
+	var entryType DirectoryEntryType
+	switch genericInode.(type) {
+	case *inode.FileInode:
+		entryType = FileType
+	case *inode.DirectoryInode:
+		entryType = DirectoryType
 	default:
-		return nil, fmt.Errorf("malformed inoded of type '%s' on path '%s'", genericInode.InodeType().String(), relativePath)
+		return nil, fmt.Errorf("malformed inoded of type '%s' for entry '%s'", genericInode.InodeType().String(), name)
+	}
+	uid, gid := genericInode.Owner()
+	return &FileInfo{
+		Perm:       genericInode.Mode(),
+		Name:       name,
+		Type:       entryType,
+		Size:       genericInode.Size(),
+		Ino:        genericInode.Ino(),
+		ModTime:    genericInode.ModTime(),
+		AccessTime: genericInode.AccessTime(),
+		ChangeTime: genericInode.ChangeTime(),
+		Uid:        uid,
+		Gid:        gid,
+	}, nil
+}
+
+// directoryName returns the name of dirInode's entry in its parent, or "/" (in the filesystem's
+// path syntax) if dirInode is d's root
+func (d *directory) directoryName(dirInode *inode.DirectoryInode) (string, error) {
+	path, err := dirInode.PathWithin(d.root)
+	if err != nil {
+		return "", err
+	}
+	if path == filepath.PathSeparator {
+		return d.Parser().FromSlash(path), nil
 	}
+	return path[strings.LastIndex(path, filepath.PathSeparator)+1:], nil
 }
 
 func (d *directory) DeleteFile(relativePath string) error {
-	pathInfo := filepath.ParsePath(relativePath)
+	pathInfo, err := d.parsePath(relativePath)
+	if err != nil {
+		return err
+	}
 	if !pathInfo.IsRelative {
 		return fmt.Errorf("'%s' is not a relative path", relativePath)
 	}
@@ -298,40 +793,92 @@ func (d *directory) DeleteFile(relativePath string) error {
 		return errors.Wrapf(fserrors.EInval, "path specifies a directory")
 	}
 	// Lookup the directory that will be parent to the relativePath
-	subdirInode, err := d.DirectoryInode.LookupSubdirectory(pathInfo.ParentPath)
+	subdirInode, err := d.lookupParent(pathInfo)
 	if err != nil {
 		return errors.Wrapf(err, "could not delete '%s'", relativePath)
 	}
 	// Remove the file
+	defer d.countMutation()()
 	if err := subdirInode.DeleteFile(pathInfo.Entry); err != nil {
 		return errors.Wrapf(err, "could not delete '%s'", relativePath)
 	}
+	publish(subdirInode, pathInfo.Entry, notify.Remove)
 	return nil
 }
 
-// Parse parent
+// moveFunc moves an entry between two directories (see inode.MoveEntry() and
+// inode.ExchangeEntries())
+type moveFunc func(srcParentInode, dstParentInode *inode.DirectoryInode, src, dst *filepath.PathInfo) error
+
 func (d *directory) Rename(srcRelativePath, dstRelativePath string) error {
-	srcPathInfo := filepath.ParsePath(srcRelativePath)
-	dstPathInfo := filepath.ParsePath(dstRelativePath)
+	return d.rename(srcRelativePath, dstRelativePath, inode.MoveEntry, false)
+}
+
+func (d *directory) RenameNoReplace(srcRelativePath, dstRelativePath string) error {
+	return d.rename(srcRelativePath, dstRelativePath, inode.MoveEntryNoReplace, false)
+}
+
+func (d *directory) RenameExchange(relativePath1, relativePath2 string) error {
+	return d.rename(relativePath1, relativePath2, inode.ExchangeEntries, true)
+}
+
+func (d *directory) CanRename(srcRelativePath, dstRelativePath string) error {
+	srcDirInode, dstDirInode, srcPathInfo, dstPathInfo, err := d.resolveRename(srcRelativePath, dstRelativePath)
+	if err != nil {
+		return err
+	}
+	if err := inode.CheckMoveEntry(srcDirInode, dstDirInode, srcPathInfo, dstPathInfo); err != nil {
+		return errors.Wrapf(err, "could not rename '%s' to '%s'", srcRelativePath, dstRelativePath)
+	}
+	return nil
+}
+
+// rename resolves the parent directories of the src and dst paths, then uses move to move the entry.
+// If exchanged is true, then move also moved the entry at the dst path to the src path.
+func (d *directory) rename(srcRelativePath, dstRelativePath string, move moveFunc, exchanged bool) error {
+	srcDirInode, dstDirInode, srcPathInfo, dstPathInfo, err := d.resolveRename(srcRelativePath, dstRelativePath)
+	if err != nil {
+		return err
+	}
+	// Move the entry
+	defer d.countMutation()()
+	if err := move(srcDirInode, dstDirInode, srcPathInfo, dstPathInfo); err != nil {
+		return errors.Wrapf(err, "could not rename '%s' to '%s'", srcRelativePath, dstRelativePath)
+	}
+	publish(srcDirInode, srcPathInfo.Entry, notify.Rename)
+	publish(dstDirInode, dstPathInfo.Entry, notify.Create)
+	if exchanged {
+		publish(dstDirInode, dstPathInfo.Entry, notify.Rename)
+		publish(srcDirInode, srcPathInfo.Entry, notify.Create)
+	}
+	return nil
+}
+
+// resolveRename parses the src and dst paths of a rename and looks up their parent directories
+func (d *directory) resolveRename(srcRelativePath, dstRelativePath string) (*inode.DirectoryInode, *inode.DirectoryInode, *filepath.PathInfo, *filepath.PathInfo, error) {
+	srcPathInfo, err := d.parsePath(srcRelativePath)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+	dstPathInfo, err := d.parsePath(dstRelativePath)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
 	// Validate that both parts are relative
 	if !srcPathInfo.IsRelative {
-		return fmt.Errorf("'%s' is not a relative path", srcRelativePath)
+		return nil, nil, nil, nil, fmt.Errorf("'%s' is not a relative path", srcRelativePath)
 	}
 	if !dstPathInfo.IsRelative {
-		return fmt.Errorf("'%s' is not a relative path", dstRelativePath)
+		return nil, nil, nil, nil, fmt.Errorf("'%s' is not a relative path", dstRelativePath)
 	}
 	// Look up the directories that are parent to src and dst
-	srcDirInode, err := d.DirectoryInode.LookupSubdirectory(srcPathInfo.ParentPath)
+	srcDirInode, err := d.lookupParent(srcPathInfo)
 	if err != nil {
-		return errors.Wrapf(err, "could not rename '%s' to '%s'", srcRelativePath, dstRelativePath)
+		return nil, nil, nil, nil, errors.Wrapf(err, "could not rename '%s' to '%s'", srcRelativePath, dstRelativePath)
 	}
-	dstDirInode, err := d.DirectoryInode.LookupSubdirectory(dstPathInfo.ParentPath)
+	dstDirInode, err := d.lookupParent(dstPathInfo)
 	if err != nil {
-		return errors.Wrapf(err, "could not rename '%s' to '%s'", srcRelativePath, dstRelativePath)
+		return nil, nil, nil, nil, errors.Wrapf(err, "could not rename '%s' to '%s'", srcRelativePath, dstRelativePath)
 	}
-	// Move the entry
-	if err := inode.MoveEntry(srcDirInode, dstDirInode, srcPathInfo, dstPathInfo); err != nil {
-		return errors.Wrapf(err, "could not rename '%s' to '%s'", srcRelativePath, dstRelativePath)
-	}
-	return nil
+	return srcDirInode, dstDirInode, srcPathInfo, dstPathInfo, nil
 }
@@ -184,37 +184,65 @@ func (s *DirectoryRenameTestSuite) TestRenameOverFileSameDirectory() {
 		},
 	}, entries)
 
-	// Add a file to c
-	fileInC, err := s.CSubdir.CreateFile("a_file")
-	assert.Nil(s.T(), err)
-
-	// Do the rename
+	// Renaming a directory over a file fails with ENOTDIR, like rename(2)
 	err = s.RootDir.Rename("a/b/c", "a/b/some_file")
-	assert.Nil(s.T(), err)
+	assert.ErrorIs(s.T(), err, fserrors.ENotDir)
 
-	// Verify the entries after renaming c
+	// Verify that the entries are unchanged
 	entries, err = s.RootDir.ReadDir("a/b")
 	assert.Nil(s.T(), err)
 	assert.ElementsMatch(s.T(), []directory.DirectoryEntry{
+		{
+			Name: "c",
+			Type: directory.DirectoryType,
+		},
 		{
 			Name: "foobar",
 			Type: directory.DirectoryType,
 		},
 		{
 			Name: "some_file",
-			Type: directory.DirectoryType,
+			Type: directory.FileType,
 		},
 	}, entries)
+}
 
-	// Make sure that the file in c is available under some_file
-	fileInCNewName, err := s.RootDir.OpenFile("a/b/some_file/a_file", os.CombineModes(os.O_RDWR))
+func (s *DirectoryRenameTestSuite) TestRenameDirectoryOverFile() {
+	someFile, err := s.RootDir.CreateFile("some_file")
 	assert.Nil(s.T(), err)
-	assert.True(s.T(), fileInCNewName.Equals(fileInC))
+	err = s.RootDir.Rename("a/b/c", "some_file")
+	assert.ErrorIs(s.T(), err, fserrors.ENotDir)
 
-	// Verify that /a/b/some_file was deleted
-	_, err = s.BSubdir.OpenFile("some_file", os.CombineModes(os.O_RDWR))
-	assert.NotNil(s.T(), err)
+	// Both entries are left in place
+	_, err = s.RootDir.LookupSubdirectory("a/b/c")
+	assert.Nil(s.T(), err)
+	stillThere, err := s.RootDir.OpenFile("some_file", os.O_RDONLY)
+	assert.Nil(s.T(), err)
+	assert.True(s.T(), someFile.Equals(stillThere))
+}
+
+func (s *DirectoryRenameTestSuite) TestRenameFileOverDirectory() {
+	_, err := s.BSubdir.CreateFile("some_file")
+	assert.Nil(s.T(), err)
+
+	// Renaming a file over a directory fails with EISDIR, like rename(2), whether or not the
+	// directory is empty and whether or not the two share a parent
+	err = s.BSubdir.Rename("some_file", "foobar")
 	assert.ErrorIs(s.T(), err, fserrors.EIsDir)
+	err = s.RootDir.Rename("a/b/some_file", "fizz")
+	assert.ErrorIs(s.T(), err, fserrors.EIsDir)
+	_, err = s.CSubdir.CreateFile("nonempty")
+	assert.Nil(s.T(), err)
+	err = s.BSubdir.Rename("some_file", "c")
+	assert.ErrorIs(s.T(), err, fserrors.EIsDir)
+
+	// All of the entries are left in place
+	for _, dir := range []string{"a/b/foobar", "fizz", "a/b/c"} {
+		_, err = s.RootDir.LookupSubdirectory(dir)
+		assert.Nil(s.T(), err)
+	}
+	_, err = s.BSubdir.OpenFile("some_file", os.O_RDONLY)
+	assert.Nil(s.T(), err)
 }
 
 func (s *DirectoryRenameTestSuite) TestRenameFile() {
@@ -323,6 +351,45 @@ func (s *DirectoryRenameTestSuite) TestRenameOverSpecialParentDirectory() {
 	assert.ErrorIs(s.T(), err, fserrors.EInval)
 }
 
+func (s *DirectoryRenameTestSuite) TestRenameDirectoryIntoItself() {
+	err := s.RootDir.Rename("a/b", "a/b/b")
+	assert.ErrorIs(s.T(), err, fserrors.EInval)
+	// The tree is unchanged
+	_, err = s.RootDir.LookupSubdirectory("a/b/c")
+	assert.Nil(s.T(), err)
+}
+
+func (s *DirectoryRenameTestSuite) TestRenameDirectoryIntoDirectChild() {
+	err := s.RootDir.Rename("a/b", "a/b/c/b")
+	assert.ErrorIs(s.T(), err, fserrors.EInval)
+	path, err := s.CSubdir.ReversePathLookup()
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), "/a/b/c", path)
+}
+
+func (s *DirectoryRenameTestSuite) TestRenameDirectoryIntoDeeperDescendant() {
+	err := s.RootDir.Rename("a", "a/b/c/foo")
+	assert.ErrorIs(s.T(), err, fserrors.EInval)
+	// Relative paths that climb out of the subtree and back into it are caught too
+	err = s.BSubdir.Rename("../../a", "c/a")
+	assert.ErrorIs(s.T(), err, fserrors.EInval)
+	path, err := s.CSubdir.ReversePathLookup()
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), "/a/b/c", path)
+}
+
+func (s *DirectoryRenameTestSuite) TestRenameDirectoryIntoSibling() {
+	assert.Nil(s.T(), s.RootDir.Rename("a", "fizz/a"))
+	path, err := s.CSubdir.ReversePathLookup()
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), "/fizz/a/b/c", path)
+	// Moving a directory up into an ancestor of its parent is also legal
+	assert.Nil(s.T(), s.RootDir.Rename("fizz/a/b/c", "c"))
+	path, err = s.CSubdir.ReversePathLookup()
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), "/c", path)
+}
+
 func TestDirectoryRenameTestSuite(t *testing.T) {
 	suite.Run(t, new(DirectoryRenameTestSuite))
 }
@@ -1,6 +1,7 @@
 package directory_test
 
 import (
+	"fmt"
 	"testing"
 
 	"github.com/manderson5192/memfs/directory"
@@ -59,6 +60,27 @@ func (s *DirectoryTestSuite) TestEquals() {
 	assert.False(s.T(), s.ASubdir.Equals(s.RootDir), "root dir is not .Equal() to its subdirectory (opposite order)")
 }
 
+func (s *DirectoryTestSuite) TestIsValid() {
+	assert.True(s.T(), s.RootDir.IsValid())
+	assert.True(s.T(), s.CSubdir.IsValid())
+	assert.Nil(s.T(), s.BSubdir.Rmdir("c"))
+	assert.False(s.T(), s.CSubdir.IsValid(), "a handle to an Rmdir'd directory is invalid")
+	assert.True(s.T(), s.BSubdir.IsValid())
+
+	// Creating entries in the invalid directory fails
+	_, err := s.CSubdir.Mkdir("d")
+	assert.ErrorIs(s.T(), err, fserrors.ENoEnt)
+
+	// A directory that is replaced by a rename is also invalid
+	fizz, err := s.RootDir.LookupSubdirectory("fizz")
+	assert.Nil(s.T(), err)
+	assert.Nil(s.T(), s.RootDir.Rename("buzz", "fizz"))
+	assert.False(s.T(), fizz.IsValid())
+	buzz, err := s.RootDir.LookupSubdirectory("fizz")
+	assert.Nil(s.T(), err)
+	assert.True(s.T(), buzz.IsValid())
+}
+
 func (s *DirectoryTestSuite) TestReversePathLookup() {
 	// When reverse lookups are performed on each of the directories
 	rootDirPath, rootDirPathLookupErr := s.RootDir.ReversePathLookup()
@@ -262,3 +284,74 @@ func (s *DirectoryTestSuite) TestDeleteFileOnDirectory() {
 func TestDirectoryTestSuite(t *testing.T) {
 	suite.Run(t, new(DirectoryTestSuite))
 }
+
+func (s *DirectoryTestSuite) TestReadDirSorted() {
+	// Enough entries, created in reverse order, that map iteration order is all but certain to
+	// differ from lexical order
+	names := []string{}
+	for n := 0; n < 50; n++ {
+		names = append(names, fmt.Sprintf("entry_%02d", n))
+	}
+	for idx := len(names) - 1; idx >= 0; idx-- {
+		_, err := s.CSubdir.CreateFile(names[idx])
+		assert.Nil(s.T(), err)
+	}
+	_, err := s.CSubdir.Mkdir("Z_dir")
+	assert.Nil(s.T(), err)
+	expected := append([]string{"Z_dir"}, names...)
+
+	for attempt := 0; attempt < 10; attempt++ {
+		entries, err := s.RootDir.ReadDirSorted("a/b/c")
+		assert.Nil(s.T(), err)
+		actual := make([]string, 0, len(entries))
+		for _, entry := range entries {
+			actual = append(actual, entry.Name)
+		}
+		assert.Equal(s.T(), expected, actual)
+	}
+}
+
+func (s *DirectoryTestSuite) TestReadDirSortedNoExist() {
+	_, err := s.RootDir.ReadDirSorted("a/noexist")
+	assert.ErrorIs(s.T(), err, fserrors.ENoEnt)
+}
+
+func (s *DirectoryTestSuite) TestRmdirWithOpenHandle() {
+	_, err := s.CSubdir.CreateFile("file")
+	assert.Nil(s.T(), err)
+
+	// A non-empty directory can't be removed while it has no open handles
+	assert.ErrorIs(s.T(), s.BSubdir.Rmdir("c"), fserrors.ENotEmpty)
+
+	handle := s.CSubdir.OpenHandle()
+	// Rmdir() still requires the directory to be empty, but RmdirOpen() doesn't
+	assert.ErrorIs(s.T(), s.BSubdir.Rmdir("c"), fserrors.ENotEmpty)
+	assert.Nil(s.T(), s.BSubdir.RmdirOpen("c"))
+	assert.False(s.T(), handle.IsValid())
+	_, err = handle.ReversePathLookup()
+	assert.ErrorIs(s.T(), err, fserrors.ENoEnt)
+	_, err = s.BSubdir.LookupSubdirectory("c")
+	assert.ErrorIs(s.T(), err, fserrors.ENoEnt)
+
+	// The unlinked directory's entries are still reachable through the handle
+	entries, err := handle.ReadDirSorted("")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []directory.DirectoryEntry{{Name: "file", Type: directory.FileType}}, entries)
+	f, err := handle.OpenFile("file", os.O_RDWR)
+	assert.Nil(s.T(), err)
+	assert.False(s.T(), f.IsDeleted())
+
+	// Closing the last handle unlinks the directory's subtree
+	assert.Nil(s.T(), handle.Close())
+	assert.True(s.T(), f.IsDeleted())
+	assert.ErrorIs(s.T(), handle.Close(), fserrors.EInval)
+}
+
+func (s *DirectoryTestSuite) TestOpenHandleDoesNotAllowReplacingNonEmptyDirectory() {
+	handle := s.BSubdir.OpenHandle()
+	defer handle.Close()
+	_, err := s.ASubdir.Mkdir("other")
+	assert.Nil(s.T(), err)
+	assert.ErrorIs(s.T(), s.ASubdir.Rename("other", "b"), fserrors.ENotEmpty)
+	assert.True(s.T(), handle.IsValid())
+}
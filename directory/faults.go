@@ -0,0 +1,29 @@
+package directory
+
+import (
+	"github.com/manderson5192/memfs/filepath"
+	"github.com/manderson5192/memfs/inode"
+)
+
+// injectFault returns an error if the filesystem is configured to fail an operation of kind op on
+// the entry named entry in parent (see inode.Superblock.InjectFault()).  It must not be called
+// while any inode locks are held.
+func injectFault(parent *inode.DirectoryInode, entry string, op inode.FaultOp) error {
+	return parent.Superblock().InjectFault(op, func() string {
+		dirInode := parent
+		switch entry {
+		case SelfDirectoryEntry:
+			entry = ""
+		case ParentDirectoryEntry:
+			dirInode, entry = parent.Parent(), ""
+		}
+		path, err := dirInode.Path()
+		if err != nil {
+			return ""
+		}
+		if entry == "" {
+			return path
+		}
+		return filepath.Join(path, entry)
+	})
+}
@@ -0,0 +1,58 @@
+package directory
+
+import (
+	"io/fs"
+	"time"
+)
+
+// IsDir returns true if the FileInfo describes a directory
+func (f *FileInfo) IsDir() bool {
+	return f.Type == DirectoryType
+}
+
+// Mode returns the FileInfo's file mode bits: its permission bits, along with fs.ModeDir if it
+// describes a directory
+func (f *FileInfo) Mode() fs.FileMode {
+	if f.IsDir() {
+		return fs.ModeDir | f.Perm
+	}
+	return f.Perm
+}
+
+// StdFileInfo returns an fs.FileInfo that describes the same file or directory as the FileInfo, for
+// use with code that expects the standard library's interface.  FileInfo can't implement
+// fs.FileInfo itself, since Go doesn't allow its Size and Name fields to coexist with Size() and
+// Name() methods.  The fs.FileInfo's Size() returns the Size field as an int64, and its Sys()
+// returns the FileInfo.
+func (f *FileInfo) StdFileInfo() fs.FileInfo {
+	return stdFileInfo{info: f}
+}
+
+// stdFileInfo adapts a FileInfo to fs.FileInfo
+type stdFileInfo struct {
+	info *FileInfo
+}
+
+func (s stdFileInfo) Name() string {
+	return s.info.Name
+}
+
+func (s stdFileInfo) Size() int64 {
+	return int64(s.info.Size)
+}
+
+func (s stdFileInfo) Mode() fs.FileMode {
+	return s.info.Mode()
+}
+
+func (s stdFileInfo) ModTime() time.Time {
+	return s.info.ModTime
+}
+
+func (s stdFileInfo) IsDir() bool {
+	return s.info.IsDir()
+}
+
+func (s stdFileInfo) Sys() interface{} {
+	return s.info
+}
@@ -0,0 +1,30 @@
+package directory
+
+import (
+	"sync/atomic"
+
+	"github.com/manderson5192/memfs/fserrors"
+	"github.com/pkg/errors"
+)
+
+func (d *directory) OpenHandle() Directory {
+	d.DirectoryInode.AcquireHandle()
+	return &directory{
+		DirectoryInode: d.DirectoryInode,
+		root:           d.root,
+		umask:          d.umask,
+		opened:         true,
+		mutations:      d.mutations,
+	}
+}
+
+func (d *directory) Close() error {
+	if !d.opened {
+		return nil
+	}
+	if !atomic.CompareAndSwapInt32(&d.closed, 0, 1) {
+		return errors.Wrapf(fserrors.EInval, "directory handle is already closed")
+	}
+	d.DirectoryInode.ReleaseHandle()
+	return nil
+}
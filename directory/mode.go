@@ -0,0 +1,41 @@
+package directory
+
+import (
+	"github.com/manderson5192/memfs/inode"
+	"github.com/manderson5192/memfs/os"
+	"github.com/pkg/errors"
+)
+
+func (d *directory) WithUmask(mask os.FileMode) Directory {
+	return &directory{
+		DirectoryInode: d.DirectoryInode,
+		root:           d.root,
+		umask:          mask.Perm(),
+		mutations:      d.mutations,
+	}
+}
+
+func (d *directory) Umask() os.FileMode {
+	return d.umask
+}
+
+// applyUmask masks the permission bits of newInode, which was just created through d, by d's umask
+func (d *directory) applyUmask(newInode inode.Inode) {
+	d.applyPerm(newInode, newInode.Mode())
+}
+
+// applyPerm sets the permission bits of newInode, which was just created through d, to perm masked
+// by d's umask
+func (d *directory) applyPerm(newInode inode.Inode, perm os.FileMode) {
+	newInode.Chmod(perm.Perm() &^ d.umask)
+}
+
+func (d *directory) Chmod(relativePath string, mode os.FileMode) error {
+	target, err := d.lookupInode(relativePath)
+	if err != nil {
+		return errors.Wrapf(err, "could not change mode of '%s'", relativePath)
+	}
+	defer d.countMutation()()
+	target.Chmod(mode)
+	return nil
+}
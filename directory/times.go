@@ -0,0 +1,27 @@
+package directory
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func (d *directory) Chtimes(relativePath string, accessTime, modTime time.Time) error {
+	target, err := d.lookupInode(relativePath)
+	if err != nil {
+		return errors.Wrapf(err, "could not change times of '%s'", relativePath)
+	}
+	defer d.countMutation()()
+	target.SetTimes(accessTime, modTime)
+	return nil
+}
+
+func (d *directory) Chown(relativePath string, uid, gid int) error {
+	target, err := d.lookupInode(relativePath)
+	if err != nil {
+		return errors.Wrapf(err, "could not change owner of '%s'", relativePath)
+	}
+	defer d.countMutation()()
+	target.Chown(uid, gid)
+	return nil
+}
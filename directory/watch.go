@@ -0,0 +1,67 @@
+package directory
+
+import (
+	"github.com/manderson5192/memfs/filepath"
+	"github.com/manderson5192/memfs/fserrors"
+	"github.com/manderson5192/memfs/inode"
+	"github.com/manderson5192/memfs/notify"
+	"github.com/pkg/errors"
+)
+
+func (d *directory) Watch(relativePath string) (<-chan notify.Event, func(), error) {
+	if !d.isRelativePath(relativePath) {
+		return nil, nil, errors.Wrapf(fserrors.EInval, "'%s' is not a relative path", relativePath)
+	}
+	watchers := d.DirectoryInode.Superblock().Watchers()
+	if watchers == nil {
+		return nil, nil, errors.Wrapf(fserrors.EInval, "directory does not belong to a filesystem that supports watches")
+	}
+	// Resolve the watched path to an absolute path.  Its final entry may be a file or a directory.
+	pathInfo, err := d.parsePath(relativePath)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "could not watch '%s'", relativePath)
+	}
+	parentInode, err := d.lookupParent(pathInfo)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "could not watch '%s'", relativePath)
+	}
+	watchedInode, err := d.entryInode(parentInode, pathInfo, relativePath)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "could not watch '%s'", relativePath)
+	}
+	var path string
+	switch inodeTyped := watchedInode.(type) {
+	case *inode.DirectoryInode:
+		path, err = inodeTyped.Path()
+	case *inode.FileInode:
+		if pathInfo.MustBeDir {
+			return nil, nil, errors.Wrapf(fserrors.ENotDir, "file found where directory %s expected", relativePath)
+		}
+		path, err = inodeTyped.Path()
+	}
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "could not watch '%s'", relativePath)
+	}
+	events, unwatch := watchers.Watch(path)
+	return events, unwatch, nil
+}
+
+// publish notifies the watchers of parent's filesystem that op happened to the entry named entry
+// in parent.  It must not be called while any inode locks are held, since it walks up the tree to
+// compute the entry's path.  The path is only computed if somebody is watching.
+func publish(parent *inode.DirectoryInode, entry string, op notify.Op) {
+	watchers := parent.Superblock().Watchers()
+	if !watchers.HasWatchers() {
+		return
+	}
+	parentPath, err := parent.Path()
+	if err != nil {
+		// The parent has been removed from the tree since the change, so there is no path at which
+		// to report it
+		return
+	}
+	watchers.Publish(notify.Event{
+		Path: filepath.Join(parentPath, entry),
+		Op:   op,
+	})
+}
@@ -0,0 +1,77 @@
+package directory
+
+import (
+	"fmt"
+
+	"github.com/manderson5192/memfs/fserrors"
+	"github.com/manderson5192/memfs/inode"
+	"github.com/pkg/errors"
+)
+
+func (d *directory) SetXattr(relativePath, name string, value []byte) error {
+	target, err := d.lookupInode(relativePath)
+	if err != nil {
+		return errors.Wrapf(err, "could not set extended attribute on '%s'", relativePath)
+	}
+	defer d.countMutation()()
+	if err := target.SetXattr(name, value); err != nil {
+		return errors.Wrapf(err, "could not set extended attribute on '%s'", relativePath)
+	}
+	return nil
+}
+
+func (d *directory) GetXattr(relativePath, name string) ([]byte, error) {
+	target, err := d.lookupInode(relativePath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not get extended attribute of '%s'", relativePath)
+	}
+	value, err := target.GetXattr(name)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not get extended attribute of '%s'", relativePath)
+	}
+	return value, nil
+}
+
+func (d *directory) ListXattr(relativePath string) ([]string, error) {
+	target, err := d.lookupInode(relativePath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not list extended attributes of '%s'", relativePath)
+	}
+	return target.ListXattr(), nil
+}
+
+func (d *directory) RemoveXattr(relativePath, name string) error {
+	target, err := d.lookupInode(relativePath)
+	if err != nil {
+		return errors.Wrapf(err, "could not remove extended attribute from '%s'", relativePath)
+	}
+	defer d.countMutation()()
+	if err := target.RemoveXattr(name); err != nil {
+		return errors.Wrapf(err, "could not remove extended attribute from '%s'", relativePath)
+	}
+	return nil
+}
+
+// lookupInode returns the file or directory inode at relativePath.  If relativePath is empty, then
+// the receiver's own inode is returned.
+func (d *directory) lookupInode(relativePath string) (inode.Inode, error) {
+	pathInfo, err := d.parsePath(relativePath)
+	if err != nil {
+		return nil, err
+	}
+	if !pathInfo.IsRelative {
+		return nil, fmt.Errorf("'%s' is not a relative path", relativePath)
+	}
+	subdirInode, err := d.lookupParent(pathInfo)
+	if err != nil {
+		return nil, err
+	}
+	target, err := d.entryInode(subdirInode, pathInfo, relativePath)
+	if err != nil {
+		return nil, err
+	}
+	if pathInfo.MustBeDir && !inode.IsDirectory(target) {
+		return nil, errors.Wrapf(fserrors.ENotDir, "file found where directory %s expected", relativePath)
+	}
+	return target, nil
+}
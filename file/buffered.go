@@ -0,0 +1,79 @@
+package file
+
+import (
+	"bufio"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// BufferedFile wraps a File with bufio buffering.  Many small writes are batched into fewer writes
+// on the underlying File, which reduces the number of inode lock acquisitions in write-heavy loops.
+// Likewise, many small reads are served from a buffer that is filled by fewer, larger reads.
+//
+// Buffered writes are not visible through the underlying File (or any other handle to the same
+// file) until Flush() is called, so callers must Flush() before the file is read elsewhere.
+// BufferedFile keeps its reads and writes consistent with one another: buffered writes are flushed
+// before a read, and any read-ahead data is discarded (and the underlying File's offset rewound)
+// before a write.
+//
+// Like bufio's types, BufferedFile is not safe for concurrent use by multiple goroutines.
+type BufferedFile struct {
+	file   File
+	reader *bufio.Reader
+	writer *bufio.Writer
+}
+
+// NewBuffered returns a BufferedFile wrapping f whose read and write buffers each hold size bytes.
+// If size is too small, bufio's default minimum is used instead.
+func NewBuffered(f File, size int) *BufferedFile {
+	return &BufferedFile{
+		file:   f,
+		reader: bufio.NewReaderSize(f, size),
+		writer: bufio.NewWriterSize(f, size),
+	}
+}
+
+// Read reads data into p, first flushing any buffered writes.  It returns the number of bytes read
+// into p.  At EOF, the count will be zero and err will be io.EOF.
+func (b *BufferedFile) Read(p []byte) (int, error) {
+	if err := b.writer.Flush(); err != nil {
+		return 0, errors.Wrapf(err, "could not flush buffered writes prior to read")
+	}
+	return b.reader.Read(p)
+}
+
+// Write writes the contents of p into the buffer, first discarding any read-ahead data.  It returns
+// the number of bytes written.  If n < len(p), it also returns an error explaining why the write is
+// short.
+func (b *BufferedFile) Write(p []byte) (int, error) {
+	if err := b.discardReadAhead(); err != nil {
+		return 0, err
+	}
+	return b.writer.Write(p)
+}
+
+// Flush writes any buffered data to the underlying File
+func (b *BufferedFile) Flush() error {
+	return b.writer.Flush()
+}
+
+// Buffered returns the number of bytes that have been written into the buffer but not yet flushed
+// to the underlying File
+func (b *BufferedFile) Buffered() int {
+	return b.writer.Buffered()
+}
+
+// discardReadAhead rewinds the underlying File's offset past any data that the read buffer has
+// consumed from the File but that has not yet been returned by Read(), then empties the read buffer
+func (b *BufferedFile) discardReadAhead() error {
+	readAhead := b.reader.Buffered()
+	if readAhead == 0 {
+		return nil
+	}
+	if _, err := b.file.Seek(-int64(readAhead), io.SeekCurrent); err != nil {
+		return errors.Wrapf(err, "could not rewind past read-ahead data prior to write")
+	}
+	b.reader.Reset(b.file)
+	return nil
+}
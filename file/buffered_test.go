@@ -0,0 +1,90 @@
+package file_test
+
+import (
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/manderson5192/memfs/file"
+	"github.com/manderson5192/memfs/inode"
+	"github.com/manderson5192/memfs/os"
+	"github.com/stretchr/testify/assert"
+)
+
+func (s *FileTestSuite) TestBufferedWritesInvisibleUntilFlush() {
+	buffered := file.NewBuffered(s.File, 64)
+	for _, ch := range "hello" {
+		n, err := buffered.Write([]byte{byte(ch)})
+		assert.Nil(s.T(), err)
+		assert.Equal(s.T(), 1, n)
+	}
+	assert.Equal(s.T(), 5, buffered.Buffered())
+	assert.Equal(s.T(), 0, s.File.Size(), "buffered writes are not visible before Flush()")
+
+	assert.Nil(s.T(), buffered.Flush())
+	assert.Equal(s.T(), 0, buffered.Buffered())
+	data, err := s.File.ReadAll()
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), "hello", string(data))
+}
+
+func (s *FileTestSuite) TestBufferedRead() {
+	assert.Nil(s.T(), s.File.TruncateAndWriteAll([]byte("Lorem ipsum dolor sit amet.")))
+	buffered := file.NewBuffered(s.File, 16)
+	data, err := ioutil.ReadAll(buffered)
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), "Lorem ipsum dolor sit amet.", string(data))
+}
+
+func (s *FileTestSuite) TestBufferedReadThenWrite() {
+	assert.Nil(s.T(), s.File.TruncateAndWriteAll([]byte("hello, world!")))
+	buffered := file.NewBuffered(s.File, 16)
+
+	// Reading 5 bytes fills the read buffer with the entire file, but the write that follows must
+	// land immediately after the 5 bytes that were actually returned
+	buf := make([]byte, 5)
+	n, err := buffered.Read(buf)
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), "hello", string(buf[:n]))
+	_, err = buffered.Write([]byte("; "))
+	assert.Nil(s.T(), err)
+
+	// Reading flushes the write, and continues after it
+	rest, err := ioutil.ReadAll(buffered)
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), "world!", string(rest))
+	data, err := s.File.ReadAll()
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), "hello; world!", string(data))
+}
+
+func (s *FileTestSuite) TestBufferedImplementsInterfaces() {
+	var _ io.Reader = file.NewBuffered(s.File, 16)
+	var _ io.Writer = file.NewBuffered(s.File, 16)
+}
+
+func BenchmarkUnbufferedSmallWrites(b *testing.B) {
+	f := file.NewFile(inode.NewFileInode(), os.O_RDWR)
+	p := []byte("x")
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		if _, err := f.Write(p); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkBufferedSmallWrites(b *testing.B) {
+	f := file.NewFile(inode.NewFileInode(), os.O_RDWR)
+	buffered := file.NewBuffered(f, 4096)
+	p := []byte("x")
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		if _, err := buffered.Write(p); err != nil {
+			b.Fatal(err)
+		}
+	}
+	if err := buffered.Flush(); err != nil {
+		b.Fatal(err)
+	}
+}
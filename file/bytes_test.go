@@ -0,0 +1,129 @@
+package file_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/manderson5192/memfs/file"
+	"github.com/manderson5192/memfs/filesys"
+	"github.com/manderson5192/memfs/fserrors"
+	"github.com/manderson5192/memfs/inode"
+	"github.com/manderson5192/memfs/os"
+	"github.com/stretchr/testify/assert"
+)
+
+func (s *FileTestSuite) TestBytesReadOnlyIsStableSnapshot() {
+	_, err := s.File.WriteString("hello, world")
+	assert.Nil(s.T(), err)
+	view, err := s.File.BytesReadOnly()
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), "hello, world", string(view))
+	again, err := s.File.BytesReadOnly()
+	assert.Nil(s.T(), err)
+	assert.Same(s.T(), &view[0], &again[0], "the data is not copied")
+
+	// Overwriting, appending, and truncating the file don't change the views
+	_, err = s.File.WriteAt([]byte("HELLO"), 0)
+	assert.Nil(s.T(), err)
+	_, err = s.File.WriteString("!")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), "hello, world", string(view))
+	contents, err := s.File.ReadString()
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), "HELLO, world!", contents)
+	newView, err := s.File.BytesReadOnly()
+	assert.Nil(s.T(), err)
+	assert.Nil(s.T(), s.File.TruncateAndWriteAll([]byte("bye")))
+	_, err = s.File.WriteAt([]byte("B"), 0)
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), "hello, world", string(view))
+	assert.Equal(s.T(), "HELLO, world!", string(newView))
+
+	// Appending to a view doesn't write into the file's storage
+	_, err = s.File.WriteAt([]byte("Bye"), 0)
+	assert.Nil(s.T(), err)
+	view, err = s.File.BytesReadOnly()
+	assert.Nil(s.T(), err)
+	_ = append(view, []byte(" now")...)
+	_, err = s.File.Seek(0, 2)
+	assert.Nil(s.T(), err)
+	_, err = s.File.WriteString(" for now")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), "Bye", string(view))
+	contents, err = s.File.ReadString()
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), "Bye for now", contents)
+}
+
+func (s *FileTestSuite) TestBytesReadOnlyHonorsMode() {
+	writeOnly, err := s.RootDir.OpenFile("file", os.O_WRONLY)
+	assert.Nil(s.T(), err)
+	_, err = writeOnly.BytesReadOnly()
+	assert.ErrorIs(s.T(), err, fserrors.EBadF)
+
+	// A buffered file's view includes the data that it hasn't flushed yet
+	buffered, err := s.RootDir.OpenFileBuffered("file", os.O_RDWR, 64)
+	assert.Nil(s.T(), err)
+	_, err = buffered.WriteString("buffered")
+	assert.Nil(s.T(), err)
+	view, err := buffered.BytesReadOnly()
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), "buffered", string(view))
+	assert.Zero(s.T(), s.File.Size())
+}
+
+func TestBytesOfDedupedFiles(t *testing.T) {
+	root := filesys.NewFileSystemWithDedup().RootDirectory()
+	a, err := root.CreateFile("a")
+	assert.Nil(t, err)
+	b, err := root.CreateFile("b")
+	assert.Nil(t, err)
+	assert.Nil(t, a.TruncateAndWriteAll([]byte("same data")))
+	assert.Nil(t, b.TruncateAndWriteAll([]byte("same data")))
+	view, err := a.BytesReadOnly()
+	assert.Nil(t, err)
+
+	// Writing either file leaves the other file and the view alone
+	_, err = b.WriteAt([]byte("SAME"), 0)
+	assert.Nil(t, err)
+	_, err = a.WriteAt([]byte("more"), 9)
+	assert.Nil(t, err)
+	assert.Equal(t, "same data", string(view))
+	contents, err := a.ReadString()
+	assert.Nil(t, err)
+	assert.Equal(t, "same datamore", contents)
+	contents, err = b.ReadString()
+	assert.Nil(t, err)
+	assert.Equal(t, "SAME data", contents)
+}
+
+// newLargeFile returns a file holding 16 MiB of data, for benchmarking reads
+func newLargeFile() file.File {
+	i := inode.NewFileInode()
+	if err := i.TruncateAndWriteAll(bytes.Repeat([]byte("x"), 16<<20)); err != nil {
+		panic(err)
+	}
+	return file.NewFile(i, os.O_RDONLY)
+}
+
+func BenchmarkReadAllLargeFile(b *testing.B) {
+	f := newLargeFile()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		if _, err := f.ReadAll(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkBytesReadOnlyLargeFile(b *testing.B) {
+	f := newLargeFile()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		if _, err := f.BytesReadOnly(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package file_test
+
+import (
+	"sync"
+
+	"github.com/manderson5192/memfs/fserrors"
+	"github.com/manderson5192/memfs/os"
+	"github.com/stretchr/testify/assert"
+)
+
+func (s *FileTestSuite) TestCompareAndSwapAll() {
+	assert.Nil(s.T(), s.File.TruncateAndWriteAll([]byte("v1")))
+	swapped, err := s.File.CompareAndSwapAll([]byte("v0"), []byte("v2"))
+	assert.Nil(s.T(), err)
+	assert.False(s.T(), swapped)
+	swapped, err = s.File.CompareAndSwapAll([]byte("v1"), []byte("version 2"))
+	assert.Nil(s.T(), err)
+	assert.True(s.T(), swapped)
+	data, err := s.File.ReadAll()
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), "version 2", string(data))
+	assert.Equal(s.T(), int64(0), s.File.Tell())
+
+	// A prefix of the contents doesn't match
+	swapped, err = s.File.CompareAndSwapAll([]byte("version"), []byte("v3"))
+	assert.Nil(s.T(), err)
+	assert.False(s.T(), swapped)
+}
+
+func (s *FileTestSuite) TestCompareAndSwapAllRace() {
+	assert.Nil(s.T(), s.File.TruncateAndWriteAll([]byte("initial")))
+	const numWriters = 8
+	replacements := []string{}
+	for idx := 0; idx < numWriters; idx++ {
+		replacements = append(replacements, string(rune('a'+idx)))
+	}
+	winners := make(chan string, numWriters)
+	var wg sync.WaitGroup
+	for _, replacement := range replacements {
+		wg.Add(1)
+		go func(replacement string) {
+			defer wg.Done()
+			f, err := s.RootDir.OpenFile("file", os.O_RDWR)
+			assert.Nil(s.T(), err)
+			swapped, err := f.CompareAndSwapAll([]byte("initial"), []byte(replacement))
+			assert.Nil(s.T(), err)
+			if swapped {
+				winners <- replacement
+			}
+		}(replacement)
+	}
+	wg.Wait()
+	close(winners)
+	won := []string{}
+	for winner := range winners {
+		won = append(won, winner)
+	}
+	assert.Len(s.T(), won, 1)
+	data, err := s.File.ReadAll()
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), won[0], string(data))
+}
+
+func (s *FileTestSuite) TestCompareAndSwapAllHonorsMode() {
+	readOnly, err := s.RootDir.OpenFile("file", os.O_RDONLY)
+	assert.Nil(s.T(), err)
+	_, err = readOnly.CompareAndSwapAll([]byte{}, []byte("data"))
+	assert.ErrorIs(s.T(), err, fserrors.EBadF)
+	appender, err := s.RootDir.OpenFile("file", os.CombineModes(os.O_WRONLY, os.O_APPEND))
+	assert.Nil(s.T(), err)
+	_, err = appender.CompareAndSwapAll([]byte{}, []byte("data"))
+	assert.ErrorIs(s.T(), err, fserrors.EBadF)
+	limited, err := s.RootDir.OpenFileWithLimit("file", os.O_RDWR, 2)
+	assert.Nil(s.T(), err)
+	_, err = limited.CompareAndSwapAll([]byte{}, []byte("data"))
+	assert.ErrorIs(s.T(), err, fserrors.ENoSpace)
+	assert.Zero(s.T(), s.File.Size())
+}
+
+func (s *FileTestSuite) TestCompareAndSwapAllFlushesBufferedWrites() {
+	buffered, err := s.RootDir.OpenFileBuffered("file", os.O_RDWR, 64)
+	assert.Nil(s.T(), err)
+	_, err = buffered.Write([]byte("pending"))
+	assert.Nil(s.T(), err)
+	swapped, err := buffered.CompareAndSwapAll([]byte("pending"), []byte("swapped"))
+	assert.Nil(s.T(), err)
+	assert.True(s.T(), swapped)
+	data, err := s.File.ReadAll()
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), "swapped", string(data))
+}
@@ -0,0 +1,29 @@
+package file
+
+import (
+	"github.com/manderson5192/memfs/fserrors"
+	"github.com/pkg/errors"
+)
+
+// modeViolationError is returned when a File is used in a way that its mode doesn't allow.  It
+// wraps EBADF, and it also satisfies errors.Is(err, EINVAL) for callers that predate EBADF.
+type modeViolationError struct {
+	err error
+}
+
+// modeViolation returns a modeViolationError for a file that is open in the named mode
+func modeViolation(mode string) error {
+	return &modeViolationError{err: errors.Wrapf(fserrors.EBadF, "file is open in %s mode", mode)}
+}
+
+func (e *modeViolationError) Error() string {
+	return e.err.Error()
+}
+
+func (e *modeViolationError) Unwrap() error {
+	return e.err
+}
+
+func (e *modeViolationError) Is(target error) bool {
+	return target == fserrors.EInval
+}
@@ -0,0 +1,63 @@
+package file_test
+
+import (
+	"crypto/sha256"
+	"errors"
+
+	"github.com/manderson5192/memfs/fserrors"
+	"github.com/manderson5192/memfs/os"
+	"github.com/stretchr/testify/assert"
+)
+
+// assertModeViolation asserts that err reports a mode violation
+func (s *FileTestSuite) assertModeViolation(err error) {
+	assert.ErrorIs(s.T(), err, fserrors.EBadF)
+	assert.ErrorIs(s.T(), err, fserrors.EInval)
+}
+
+func (s *FileTestSuite) TestReadOnlyModeViolations() {
+	readOnly, err := s.RootDir.OpenFile("created", os.CombineModes(os.O_RDONLY, os.O_CREATE))
+	assert.Nil(s.T(), err)
+	s.assertModeViolation(readOnly.TruncateAndWriteAll([]byte("data")))
+	_, err = readOnly.WriteAt([]byte("data"), 0)
+	s.assertModeViolation(err)
+	_, err = readOnly.Write([]byte("data"))
+	s.assertModeViolation(err)
+	_, err = readOnly.WriteString("data")
+	s.assertModeViolation(err)
+	assert.Zero(s.T(), readOnly.Size())
+}
+
+func (s *FileTestSuite) TestWriteOnlyModeViolations() {
+	writeOnly, err := s.RootDir.OpenFile("file", os.O_WRONLY)
+	assert.Nil(s.T(), err)
+	_, err = writeOnly.ReadAll()
+	s.assertModeViolation(err)
+	_, err = writeOnly.ReadAt(make([]byte, 1), 0)
+	s.assertModeViolation(err)
+	_, err = writeOnly.Read(make([]byte, 1))
+	s.assertModeViolation(err)
+	_, err = writeOnly.Checksum(sha256.New())
+	s.assertModeViolation(err)
+}
+
+func (s *FileTestSuite) TestAppendModeViolations() {
+	appender, err := s.RootDir.OpenFile("file", os.CombineModes(os.O_RDWR, os.O_APPEND))
+	assert.Nil(s.T(), err)
+	s.assertModeViolation(appender.TruncateAndWriteAll([]byte("data")))
+	_, err = appender.WriteAt([]byte("data"), 0)
+	s.assertModeViolation(err)
+	_, err = appender.Write([]byte("data"))
+	assert.Nil(s.T(), err)
+}
+
+func (s *FileTestSuite) TestOtherErrorsAreNotModeViolations() {
+	limited, err := s.RootDir.OpenFileWithLimit("file", os.O_RDWR, 1)
+	assert.Nil(s.T(), err)
+	err = limited.TruncateAndWriteAll([]byte("data"))
+	assert.ErrorIs(s.T(), err, fserrors.ENoSpace)
+	assert.False(s.T(), errors.Is(err, fserrors.EBadF))
+	_, err = s.File.ReadAt(make([]byte, 1), -1)
+	assert.NotNil(s.T(), err)
+	assert.False(s.T(), errors.Is(err, fserrors.EBadF))
+}
@@ -1,59 +1,246 @@
 package file
 
 import (
-	"fmt"
+	"hash"
 	"io"
 	"sync"
+	"sync/atomic"
 
 	"github.com/manderson5192/memfs/fserrors"
 	"github.com/manderson5192/memfs/inode"
+	"github.com/manderson5192/memfs/notify"
 	"github.com/manderson5192/memfs/os"
 	"github.com/pkg/errors"
 )
 
 // File is a typical file abstraction, representing a file descriptor and an offset.  To hold a
 // file open is to hold a reference to a non-nil File.  To close it is to let the garbage collector
-// do its work by losing any reference to this File.  Access to this File's offset is synchronized
-// on a per-file basis, but operations to the underlying file data are synchronized at the inode
-// layer.
+// do its work by losing any reference to this File (after calling Close() if it buffers writes,
+// holds an advisory lock, or was written in a deduplicating filesystem).  Access to this File's offset is synchronized on a per-file basis, but
+// operations to the underlying file data are synchronized at the inode layer.
+//
+// An operation that the File's mode doesn't allow (e.g. a write to a file that is open in read-only
+// mode, or a read from one that is open in write-only mode) fails with an error that wraps
+// fserrors.EBadF.  For compatibility, errors.Is() also reports that it is fserrors.EInval.
 type File interface {
 	// Equals returns true if the other file is backed by the same FileInode
 	Equals(other File) bool
+	// IsDeleted returns true if the file is no longer linked into any directory, e.g. because it
+	// was deleted or replaced by a rename.  The handle keeps working, but its path no longer refers
+	// to it.
+	IsDeleted() bool
 	// ReadAll returns a copy of all of the data in the file.  It does not affect the file offset.
 	ReadAll() ([]byte, error)
+	// BytesReadOnly returns all of the data in the file, like ReadAll(), but without copying it
+	// when possible (see inode.FileInode.Bytes()).  The returned slice may alias the file's
+	// storage, so callers must not modify it.  It remains a stable snapshot of the file's data:
+	// subsequent writes to the file are never visible through it.  It does not affect the file
+	// offset.
+	BytesReadOnly() ([]byte, error)
+	// ReadString returns all of the data in the file as a string, like ReadAll().  It does not
+	// affect the file offset.
+	ReadString() (string, error)
+	// Checksum writes the file's current contents into h and returns h.Sum(nil).  The contents are
+	// hashed in place as a consistent snapshot, even if there are concurrent writers.  Callers
+	// should pass a new (or freshly Reset()) hash.  It does not affect the file offset.
+	Checksum(h hash.Hash) ([]byte, error)
 	// TruncateAndWriteAll truncates the file and writes in all of the data in buf.  It returns an
 	// error on failure.  It does not affect the file offset
 	TruncateAndWriteAll(buf []byte) error
+	// CompareAndSwapAll replaces the file's contents with replacement, like TruncateAndWriteAll(),
+	// but only if its current contents are equal to expected, and returns whether it replaced them.
+	// The comparison and the replacement are a single atomic step, so when several writers race to
+	// swap out the same contents, exactly one of them succeeds.  Any writes that the File has
+	// buffered are flushed first (see NewWriteBackFile()).  It does not affect the file offset.
+	CompareAndSwapAll(expected, replacement []byte) (bool, error)
 	// ReadAt tries to copy len(p) bytes at offset off from the file into p.  If there are fewer than
 	// len(p) bytes between the offset and the end of the file, then the error will be non-nil and
 	// equal to io.EOF.
 	ReadAt(p []byte, off int64) (int, error)
+	// SectionReader returns an io.SectionReader that reads the n bytes of the file that start at
+	// offset off, using ReadAt(), so that a byte range of the file can be handed to a parser.  The
+	// section is clamped to the file's current size (a negative off or n is treated as 0), so reads
+	// return io.EOF at the end of the section, or at the end of the file if it is smaller.  The
+	// section reader has its own offset, so it doesn't affect the File's.
+	SectionReader(off, n int64) *io.SectionReader
 	// WriteAt attempts copying len(p) bytes from p into the FileInode's data at offset off.  If off is
 	// beyond the end of the file, then the file is extended with zero bytes up to the offset before
 	// copying begins.  It returns the number of bytes that were copied, or 0 and an error.
 	WriteAt(p []byte, off int64) (int, error)
 	// Size returns the size of the file in bytes
 	Size() int
+	// AllocatedSize returns the number of bytes that the file actually stores, which is less than
+	// Size() if the file is sparse and has holes (see filesys.NewFileSystemSparse())
+	AllocatedSize() int
+	// CompressedSize returns the number of bytes that the file's data occupies in memory, which is
+	// less than Size() for compressible data if the file is compressed (see
+	// filesys.NewFileSystemCompressed()).  For other files, it is the same as AllocatedSize().
+	CompressedSize() int
+	// Name returns the file's current absolute path in its filesystem (ignoring any chroot), like
+	// os.File.Name().  Unlike os.File.Name(), the path is resolved from the file's inode each time,
+	// so it reflects any renames of the file or its ancestors since the file was opened.  memfs has
+	// no hard links, so a linked file has exactly one path.  (If a file is reachable through a bind
+	// mount, then the path beneath the mounted directory is returned.)  It returns ENOENT if the
+	// file has been unlinked.
+	Name() (string, error)
+	// Sync commits the file's contents to stable storage, like os.File.Sync().  Since the contents
+	// live in memory, it is a no-op that returns nil unless the filesystem has a sync hook (see
+	// filesys.Options), in which case it returns the hook's result.  It first flushes any writes that
+	// the File has buffered (see NewWriteBackFile()).
+	Sync() error
+	// Close flushes any writes that the File has buffered (see NewWriteBackFile()) and releases the
+	// File's advisory lock, if any.  The lock is shared with the File's duplicates (see Dup()), so
+	// closing any one of them releases it (whereas flock(2) waits for the last duplicate to be
+	// closed).  If the File allows writing and the filesystem deduplicates file contents, then the
+	// file's finished contents are deduplicated (see inode.FileInode.Deduplicate()).  The File
+	// remains usable afterwards.
+	Close() error
+	// Lock takes an exclusive advisory lock on the file, blocking until no other handle holds a
+	// lock on the same file.  Like flock(2), advisory locks belong to the File handle (not to the
+	// calling goroutine), they are shared by every File for the same inode, and they don't affect
+	// reads or writes.  Locking a handle that already holds an exclusive lock is a no-op, and a
+	// handle that holds a shared lock must RUnlock() it before calling Lock(), or EINVAL is returned.
+	// Concurrent calls through the same handle (or its duplicates, see Dup()) take the lock once:
+	// the later calls wait for the first one and then behave as if it had already returned.
+	Lock() error
+	// TryLock is like Lock, except that it returns false instead of blocking if the lock is not
+	// available, including while another goroutine is taking a lock through the same handle
+	TryLock() (bool, error)
+	// Unlock releases the exclusive advisory lock held by this handle.  It returns EINVAL if the
+	// handle does not hold an exclusive lock.
+	Unlock() error
+	// RLock takes a shared advisory lock on the file, blocking while another handle holds an
+	// exclusive lock on the same file.  See Lock() for the semantics of advisory locks.
+	RLock() error
+	// RUnlock releases the shared advisory lock held by this handle.  It returns EINVAL if the
+	// handle does not hold a shared lock.
+	RUnlock() error
+	// Revoke invalidates this File and its duplicates (see Dup()), like BSD's revoke(2): subsequent
+	// reads and writes through them fail with EBADF, and any writes that they have buffered are
+	// discarded.  Their advisory lock, if any, is released, and subsequent calls to lock or unlock
+	// them fail with EBADF.  The file itself, and other handles to it, are unaffected.
+	Revoke()
+	// Dup returns a new File that shares this File's offset, mode, and advisory lock, like dup(2).
+	// A Seek(), Read(), or Write() through either File moves the offset observed by both.  This is
+	// unlike opening the same file twice, which yields handles with independent offsets.
+	Dup() File
+	// Tell returns the file's current offset, like Seek(0, io.SeekCurrent) but without the
+	// possibility of an error
+	Tell() int64
+	// Rewind resets the file's offset to the beginning of the file, like Seek(0, io.SeekStart)
+	Rewind() error
 	io.Reader
 	io.Writer
+	// WriteString is like Write(), but writes the contents of a string, so that io.WriteString()
+	// doesn't need to convert it to a []byte.  It honors the file's mode exactly as Write() does.
+	io.StringWriter
 	io.Seeker
 }
 
 type file struct {
 	*inode.FileInode
+	*openFile
+}
+
+// openFile is the state that is shared by a File and all of its duplicates (see Dup()), like an open
+// file description in POSIX
+type openFile struct {
 	offset int64
 	mutex  sync.Mutex // synchronizes access to this file's offset
 	mode   int
+	// maxSize is the largest size (in bytes) that writes through this file may grow the file to,
+	// or a negative number if there is no such limit
+	maxSize int64
+	// ringSize, if positive, makes this file a ring file that retains only the last ringSize bytes
+	// written to it (see NewRingFile())
+	ringSize int
+	// bufSize, if positive, makes this file buffer up to bufSize bytes of writes before flushing them
+	// to the inode (see NewWriteBackFile())
+	bufSize int
+	// buffer holds data that was written to this file but not yet flushed to the inode.  It belongs
+	// at bufferOffset, or at the end of the file in append mode.  Both are protected by mutex.
+	buffer       []byte
+	bufferOffset int64
+	// lockMutex synchronizes access to lockState, which records the advisory lock held by this file,
+	// and to lockCond (see lockAcquired())
+	lockMutex sync.Mutex
+	lockState lockState
+	lockCond  *sync.Cond
+	// revoked is set by Revoke()
+	revoked atomic.Bool
+	// mutations also counts the writes that are made through this file, or is nil (see
+	// WithMutationCounter())
+	mutations *inode.MutationCounter
 }
 
 func NewFile(inode *inode.FileInode, mode int) File {
+	return NewFileWithLimit(inode, mode, -1)
+}
+
+// NewFileWithLimit creates a File that cannot write data at or beyond offset maxBytes.  Writes that
+// would cross this limit are cut short: they write as many bytes as fit below the limit, then
+// return that count along with ENOSPC.  A negative maxBytes means that there is no limit.  The
+// limit only applies to writes through the returned File, not other handles to the same inode.
+func NewFileWithLimit(inode *inode.FileInode, mode int, maxBytes int64) File {
+	return &file{
+		FileInode: inode,
+		openFile: &openFile{
+			offset:  0,
+			mode:    mode,
+			maxSize: maxBytes,
+		},
+	}
+}
+
+// NewRingFile creates a File that behaves like a ring buffer of maxBytes bytes, for simulating
+// bounded log files.  It is opened in append mode (O_APPEND is added to mode), and each write
+// appends to the file and then evicts the oldest bytes so that the file never exceeds maxBytes
+// bytes (see inode.FileInode.AppendRing()).  Reads return the retained window of the data.  Since
+// eviction shifts the data, a reader's offset may refer to different bytes after a write.  As with
+// NewFileWithLimit(), the bound only applies to writes through the returned File.
+func NewRingFile(inode *inode.FileInode, mode int, maxBytes int) File {
 	return &file{
 		FileInode: inode,
-		offset:    0,
-		mode:      mode,
+		openFile: &openFile{
+			offset:   0,
+			mode:     os.CombineModes(mode, os.O_APPEND),
+			maxSize:  -1,
+			ringSize: maxBytes,
+		},
 	}
 }
 
+func (f *file) Dup() File {
+	return &file{
+		FileInode: f.FileInode,
+		openFile:  f.openFile,
+	}
+}
+
+// WithMutationCounter returns f after making the writes through it (and through its duplicates)
+// count in c as well as in the filesystem's total (see inode.Superblock.CountMutation()).  It must
+// be called before f is used, and it returns files that weren't created by this package unchanged.
+func WithMutationCounter(f File, c *inode.MutationCounter) File {
+	if typed, ok := f.(*file); ok {
+		typed.mutations = c
+	}
+	return f
+}
+
+// countMutation records that a write is about to be made through f, and returns a function that
+// records that it is over (see inode.Superblock.CountMutation())
+func (f *file) countMutation() func() {
+	return f.FileInode.Superblock().CountMutation(f.mutations)
+}
+
+func (f *file) Name() (string, error) {
+	path, err := f.FileInode.Path()
+	if err != nil {
+		return "", errors.Wrapf(err, "could not resolve the file's name")
+	}
+	return f.FileInode.Superblock().Parser().FromSlash(path), nil
+}
+
 func (f *file) Equals(other File) bool {
 	if f == nil || other == nil {
 		return false
@@ -66,25 +253,95 @@ func (f *file) Equals(other File) bool {
 }
 
 func (f *file) TruncateAndWriteAll(buf []byte) error {
-	if os.IsReadOnly(f.mode) {
-		return errors.Wrapf(fserrors.EInval, "file is open in read-only mode")
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if err := f.checkWritable(); err != nil {
+		return err
 	}
+	defer f.countMutation()()
 	if os.IsAppendMode(f.mode) {
-		return errors.Wrapf(fserrors.EInval, "file is open in append-only mode")
+		return modeViolation("append-only")
+	}
+	if f.maxSize >= 0 && int64(len(buf)) > f.maxSize {
+		return errors.Wrapf(fserrors.ENoSpace, "cannot write beyond the file's limit of %d bytes", f.maxSize)
+	}
+	if err := f.FileInode.TruncateAndWriteAll(buf); err != nil {
+		return err
+	}
+	// The new contents replace any buffered writes
+	f.buffer = nil
+	f.publishWrite()
+	return nil
+}
+
+func (f *file) CompareAndSwapAll(expected, replacement []byte) (bool, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if err := f.checkWritable(); err != nil {
+		return false, err
+	}
+	defer f.countMutation()()
+	if os.IsAppendMode(f.mode) {
+		return false, modeViolation("append-only")
+	}
+	if f.maxSize >= 0 && int64(len(replacement)) > f.maxSize {
+		return false, errors.Wrapf(fserrors.ENoSpace, "cannot write beyond the file's limit of %d bytes", f.maxSize)
+	}
+	if err := f.flush(); err != nil {
+		return false, err
+	}
+	swapped, err := f.FileInode.CompareAndSwapAll(expected, replacement)
+	if err != nil || !swapped {
+		return false, err
 	}
-	return f.FileInode.TruncateAndWriteAll(buf)
+	f.publishWrite()
+	return true, nil
 }
 
 func (f *file) ReadAll() ([]byte, error) {
-	if os.IsWriteOnly(f.mode) {
-		return nil, errors.Wrapf(fserrors.EInval, "file is open in write-only mode")
+	if err := f.checkReadable(); err != nil {
+		return nil, err
+	}
+	if f.bufSize > 0 {
+		f.mutex.Lock()
+		defer f.mutex.Unlock()
+		return f.withBuffer(f.FileInode.ReadAll()), nil
 	}
 	return f.FileInode.ReadAll(), nil
 }
 
+func (f *file) BytesReadOnly() ([]byte, error) {
+	if err := f.checkReadable(); err != nil {
+		return nil, err
+	}
+	if f.bufSize > 0 {
+		// The buffered data has to be merged into a copy of the inode's data
+		return f.ReadAll()
+	}
+	return f.FileInode.Bytes(), nil
+}
+
+func (f *file) Checksum(h hash.Hash) ([]byte, error) {
+	if err := f.checkReadable(); err != nil {
+		return nil, err
+	}
+	if f.bufSize > 0 {
+		data, err := f.ReadAll()
+		if err != nil {
+			return nil, err
+		}
+		h.Write(data)
+		return h.Sum(nil), nil
+	}
+	return f.FileInode.Checksum(h), nil
+}
+
 func (f *file) doReadAt(p []byte, off int64) (int, error) {
-	if os.IsWriteOnly(f.mode) {
-		return 0, errors.Wrapf(fserrors.EInval, "file is open in write-only mode")
+	if err := f.checkReadable(); err != nil {
+		return 0, err
+	}
+	if f.bufSize > 0 {
+		return f.readBufferedAt(p, off)
 	}
 	n, err := f.FileInode.ReadAt(p, off)
 	return n, err
@@ -105,18 +362,54 @@ func (f *file) Read(p []byte) (int, error) {
 }
 
 func (f *file) doWriteAt(p []byte, off int64) (int, error) {
-	if os.IsReadOnly(f.mode) {
-		return 0, errors.Wrapf(fserrors.EInval, "file is open in read-only mode")
+	if err := f.checkWritable(); err != nil {
+		return 0, err
+	}
+	defer f.countMutation()()
+	if f.bufSize > 0 {
+		return f.writeBufferedAt(p, off)
+	}
+	// If the write would cross the file's size limit, then only write the bytes that fit
+	if f.maxSize >= 0 && off >= 0 && off+int64(len(p)) > f.maxSize {
+		n := 0
+		if off < f.maxSize {
+			var err error
+			if n, err = f.FileInode.WriteAt(p[:f.maxSize-off], off); err != nil {
+				return n, err
+			}
+			f.publishWrite()
+		}
+		return n, errors.Wrapf(fserrors.ENoSpace, "cannot write beyond the file's limit of %d bytes", f.maxSize)
 	}
 	n, err := f.FileInode.WriteAt(p, off)
+	if n > 0 {
+		f.publishWrite()
+	}
 	return n, err
 }
 
+// publishWrite notifies the filesystem's watchers that the file's contents have changed.  Nothing
+// is published if the file has been unlinked.
+func (f *file) publishWrite() {
+	watchers := f.FileInode.Superblock().Watchers()
+	if !watchers.HasWatchers() {
+		return
+	}
+	path, err := f.FileInode.Path()
+	if err != nil {
+		return
+	}
+	watchers.Publish(notify.Event{
+		Path: path,
+		Op:   notify.Write,
+	})
+}
+
 func (f *file) WriteAt(p []byte, off int64) (int, error) {
 	f.mutex.Lock()
 	defer f.mutex.Unlock()
 	if os.IsAppendMode(f.mode) {
-		return 0, errors.Wrapf(fserrors.EInval, "file is open in append-only mode")
+		return 0, modeViolation("append-only")
 	}
 	return f.doWriteAt(p, off)
 }
@@ -125,15 +418,47 @@ func (f *file) Write(p []byte) (int, error) {
 	f.mutex.Lock()
 	defer f.mutex.Unlock()
 	if os.IsAppendMode(f.mode) {
-		if _, err := f.doSeek(0, io.SeekEnd); err != nil {
-			return 0, fmt.Errorf("failed to seek prior to write for append-only mode")
-		}
+		return f.doAppend(p)
 	}
 	n, err := f.doWriteAt(p, f.offset)
 	f.offset += int64(n)
 	return n, err
 }
 
+// doAppend writes p at the end of the file and leaves the offset just past the written data.  As
+// with O_APPEND in POSIX, finding the end of the file and writing there is a single atomic step, so
+// concurrent appends never overwrite one another.
+func (f *file) doAppend(p []byte) (int, error) {
+	if err := f.checkWritable(); err != nil {
+		return 0, err
+	}
+	defer f.countMutation()()
+	if f.bufSize > 0 {
+		return f.appendBuffered(p)
+	}
+	if f.ringSize > 0 {
+		n, err := f.FileInode.AppendRing(p, f.ringSize)
+		if err != nil {
+			return n, err
+		}
+		f.offset = int64(f.size())
+		f.publishWrite()
+		return n, nil
+	}
+	n, start, err := f.FileInode.AppendAllWithLimit(p, f.maxSize)
+	if err != nil {
+		return n, err
+	}
+	f.offset = start + int64(n)
+	if n > 0 {
+		f.publishWrite()
+	}
+	if n < len(p) {
+		return n, errors.Wrapf(fserrors.ENoSpace, "cannot write beyond the file's limit of %d bytes", f.maxSize)
+	}
+	return n, nil
+}
+
 func (f *file) doSeek(offset int64, whence int) (int64, error) {
 	// interpret whence
 	switch whence {
@@ -141,7 +466,7 @@ func (f *file) doSeek(offset int64, whence int) (int64, error) {
 	case io.SeekCurrent:
 		offset = f.offset + offset
 	case io.SeekEnd:
-		offset = int64(f.Size()) + offset
+		offset = int64(f.size()) + offset
 	default:
 		return f.offset, errors.Wrapf(fserrors.EInval, "invalid whence value %d", whence)
 	}
@@ -158,3 +483,14 @@ func (f *file) Seek(offset int64, whence int) (int64, error) {
 	defer f.mutex.Unlock()
 	return f.doSeek(offset, whence)
 }
+
+func (f *file) Tell() int64 {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.offset
+}
+
+func (f *file) Rewind() error {
+	_, err := f.Seek(0, io.SeekStart)
+	return err
+}
@@ -1,8 +1,12 @@
 package file_test
 
 import (
+	"crypto/sha256"
+	"fmt"
 	"io"
 	"io/ioutil"
+	"regexp"
+	"sync"
 	"testing"
 
 	"github.com/manderson5192/memfs/directory"
@@ -53,6 +57,30 @@ func (s *FileTestSuite) TestEquals() {
 // * ReaderAt
 // * WriterAt
 // * Seeker
+func (s *FileTestSuite) TestIsDeleted() {
+	assert.False(s.T(), s.File.IsDeleted())
+	other, err := s.RootDir.OpenFile("file", os.O_RDONLY)
+	assert.Nil(s.T(), err)
+	assert.Nil(s.T(), s.RootDir.DeleteFile("file"))
+	assert.True(s.T(), s.File.IsDeleted())
+	assert.True(s.T(), other.IsDeleted())
+
+	// The deleted file's handles keep working
+	assert.Nil(s.T(), s.File.TruncateAndWriteAll([]byte("hello!")))
+	data, err := other.ReadAll()
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []byte("hello!"), data)
+
+	// A file that is replaced by a rename is deleted, but a renamed file is not
+	replaced, err := s.RootDir.CreateFile("replaced")
+	assert.Nil(s.T(), err)
+	renamed, err := s.RootDir.CreateFile("renamed")
+	assert.Nil(s.T(), err)
+	assert.Nil(s.T(), s.RootDir.Rename("renamed", "replaced"))
+	assert.True(s.T(), replaced.IsDeleted())
+	assert.False(s.T(), renamed.IsDeleted())
+}
+
 func (s *FileTestSuite) TestImplementsInterfaces() {
 	file := file.NewFile(inode.NewFileInode(), os.CombineModes(os.O_RDWR))
 	var _ io.Reader = file
@@ -115,6 +143,11 @@ func (s *FileTestSuite) TestSeek() {
 	assert.Equal(s.T(), int64(1000), offset)
 	assert.Nil(s.T(), err)
 
+	// Reading past the end of the file produces nothing
+	n, err := s.File.Read(make([]byte, 5))
+	assert.Equal(s.T(), 0, n)
+	assert.Equal(s.T(), io.EOF, err)
+
 	// Seek to offset 500 from the current offset
 	offset, err = s.File.Seek(-500, io.SeekCurrent)
 	assert.Equal(s.T(), int64(500), offset)
@@ -149,3 +182,100 @@ func (s *FileTestSuite) TestIOUtilReadAll() {
 func TestFileTestSuite(t *testing.T) {
 	suite.Run(t, new(FileTestSuite))
 }
+
+func (s *FileTestSuite) TestConcurrentAppends() {
+	const numAppenders = 8
+	const appendsPerAppender = 200
+	var wg sync.WaitGroup
+	for idx := 0; idx < numAppenders; idx++ {
+		// Each goroutine appends through its own handle, as separate processes would
+		f, err := s.RootDir.OpenFile("file", os.O_WRONLY|os.O_APPEND)
+		assert.Nil(s.T(), err)
+		wg.Add(1)
+		go func(idx int, f file.File) {
+			defer wg.Done()
+			for n := 0; n < appendsPerAppender; n++ {
+				marker := []byte(fmt.Sprintf("<%d:%03d>", idx, n))
+				written, err := f.Write(marker)
+				assert.Nil(s.T(), err)
+				assert.Equal(s.T(), len(marker), written)
+			}
+		}(idx, f)
+	}
+	wg.Wait()
+
+	// Every marker is present exactly once and intact, and each appender's markers are in order
+	data, err := s.File.ReadAll()
+	assert.Nil(s.T(), err)
+	markers := regexp.MustCompile(`<(\d):(\d{3})>`).FindAllStringSubmatch(string(data), -1)
+	assert.Len(s.T(), markers, numAppenders*appendsPerAppender)
+	assert.Equal(s.T(), len(markers)*len("<0:000>"), len(data), "markers must not overlap")
+	next := map[string]int{}
+	for _, marker := range markers {
+		assert.Equal(s.T(), fmt.Sprintf("%03d", next[marker[1]]), marker[2])
+		next[marker[1]]++
+	}
+}
+
+func (s *FileTestSuite) TestAppendOffset() {
+	assert.Nil(s.T(), s.File.TruncateAndWriteAll([]byte("hello")))
+	f, err := s.RootDir.OpenFile("file", os.O_RDWR|os.O_APPEND)
+	assert.Nil(s.T(), err)
+	_, err = f.Write([]byte(", world"))
+	assert.Nil(s.T(), err)
+	// The offset is left just past the appended data
+	offset, err := f.Seek(0, io.SeekCurrent)
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), int64(len("hello, world")), offset)
+}
+
+func (s *FileTestSuite) TestDup() {
+	assert.Nil(s.T(), s.File.TruncateAndWriteAll([]byte("hello, world")))
+	dup := s.File.Dup()
+	other := file.NewFile(inode.NewFileInode(), os.CombineModes(os.O_RDWR))
+	assert.True(s.T(), dup.Equals(s.File))
+	assert.True(s.T(), s.File.Equals(dup))
+	assert.False(s.T(), dup.Equals(other))
+
+	// Seeking one handle moves the offset observed by the other
+	_, err := s.File.Seek(7, io.SeekStart)
+	assert.Nil(s.T(), err)
+	buf := make([]byte, 5)
+	n, err := dup.Read(buf)
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), "world", string(buf[:n]))
+	offset, err := s.File.Seek(0, io.SeekCurrent)
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), int64(12), offset)
+}
+
+func (s *FileTestSuite) TestChecksum() {
+	assert.Nil(s.T(), s.File.TruncateAndWriteAll([]byte("hello, world")))
+	sum, err := s.File.Checksum(sha256.New())
+	assert.Nil(s.T(), err)
+	expected := sha256.Sum256([]byte("hello, world"))
+	assert.Equal(s.T(), expected[:], sum)
+
+	writeOnly := file.NewFile(inode.NewFileInode(), os.CombineModes(os.O_WRONLY))
+	_, err = writeOnly.Checksum(sha256.New())
+	assert.ErrorIs(s.T(), err, fserrors.EInval)
+}
+
+func (s *FileTestSuite) TestTellAndRewind() {
+	assert.Equal(s.T(), int64(0), s.File.Tell())
+	_, err := s.File.Write([]byte("hello, world"))
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), int64(12), s.File.Tell())
+
+	_, err = s.File.Seek(-5, io.SeekCurrent)
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), int64(7), s.File.Tell())
+
+	assert.Nil(s.T(), s.File.Rewind())
+	assert.Equal(s.T(), int64(0), s.File.Tell())
+	buf := make([]byte, 5)
+	_, err = s.File.Read(buf)
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), "hello", string(buf))
+	assert.Equal(s.T(), int64(5), s.File.Tell())
+}
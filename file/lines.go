@@ -0,0 +1,40 @@
+package file
+
+import (
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ReadLines returns the lines of f's contents, split on "\n".  The newline characters are not
+// included in the lines, and the empty element that would follow a trailing newline is dropped, so
+// "a\n\nb\n" and "a\n\nb" both yield ["a", "", "b"].  Like ReadAll(), it does not affect f's offset.
+func ReadLines(f File) ([]string, error) {
+	data, err := f.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return []string{}, nil
+	}
+	lines := strings.Split(string(data), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines, nil
+}
+
+// AppendLine writes s followed by "\n" at the end of f and leaves f's offset just past the written
+// data.  If f was opened in append mode, then the write is atomic with respect to other appends
+// (see os.O_APPEND).  Otherwise, seeking to the end and writing are separate steps, so a
+// concurrent writer that extends the file in between may be overwritten.
+func AppendLine(f File, s string) error {
+	if _, err := f.Seek(0, io.SeekEnd); err != nil {
+		return errors.Wrapf(err, "could not seek to the end of the file")
+	}
+	if _, err := f.Write([]byte(s + "\n")); err != nil {
+		return errors.Wrapf(err, "could not append line")
+	}
+	return nil
+}
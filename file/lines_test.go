@@ -0,0 +1,62 @@
+package file_test
+
+import (
+	"github.com/manderson5192/memfs/file"
+	"github.com/manderson5192/memfs/os"
+	"github.com/stretchr/testify/assert"
+)
+
+func (s *FileTestSuite) TestAppendLineAndReadLines() {
+	for _, line := range []string{"first", "", "third"} {
+		assert.Nil(s.T(), file.AppendLine(s.File, line))
+	}
+	data, err := s.File.ReadAll()
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), "first\n\nthird\n", string(data))
+	lines, err := file.ReadLines(s.File)
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []string{"first", "", "third"}, lines)
+	// Reading lines doesn't move the offset, which AppendLine left at the end of the file
+	assert.Equal(s.T(), int64(len(data)), s.File.Tell())
+}
+
+func (s *FileTestSuite) TestReadLinesWithoutTrailingNewline() {
+	assert.Nil(s.T(), s.File.TruncateAndWriteAll([]byte("a\n\nb")))
+	lines, err := file.ReadLines(s.File)
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []string{"a", "", "b"}, lines)
+
+	// AppendLine doesn't add the missing newline, so the line joins the unterminated one
+	assert.Nil(s.T(), file.AppendLine(s.File, "c"))
+	lines, err = file.ReadLines(s.File)
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []string{"a", "", "bc"}, lines)
+}
+
+func (s *FileTestSuite) TestReadLinesOnEmptyFile() {
+	lines, err := file.ReadLines(s.File)
+	assert.Nil(s.T(), err)
+	assert.Empty(s.T(), lines)
+
+	// A file holding a single newline has one empty line
+	assert.Nil(s.T(), file.AppendLine(s.File, ""))
+	lines, err = file.ReadLines(s.File)
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []string{""}, lines)
+}
+
+func (s *FileTestSuite) TestLineHelpersRespectModes() {
+	assert.Nil(s.T(), s.File.TruncateAndWriteAll([]byte("existing\n")))
+	appender, err := s.RootDir.OpenFile("file", os.O_WRONLY|os.O_APPEND)
+	assert.Nil(s.T(), err)
+	assert.Nil(s.T(), file.AppendLine(appender, "appended"))
+	_, err = file.ReadLines(appender)
+	assert.NotNil(s.T(), err, "write-only files can't be read")
+
+	reader, err := s.RootDir.OpenFile("file", os.O_RDONLY)
+	assert.Nil(s.T(), err)
+	assert.NotNil(s.T(), file.AppendLine(reader, "rejected"), "read-only files can't be written")
+	lines, err := file.ReadLines(reader)
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []string{"existing", "appended"}, lines)
+}
@@ -0,0 +1,139 @@
+package file
+
+import (
+	"sync"
+
+	"github.com/manderson5192/memfs/fserrors"
+	"github.com/pkg/errors"
+)
+
+// lockState records which advisory lock, if any, a File handle holds on its FileInode
+type lockState int
+
+const (
+	unlocked lockState = iota
+	sharedLock
+	exclusiveLock
+	// acquiringLock means that a goroutine is acquiring the advisory lock for the handle
+	acquiringLock
+)
+
+// acquire takes the advisory lock in the specified mode, blocking if block is true.  It returns
+// whether the lock was acquired, or EBADF if the handle has been revoked.
+func (f *file) acquire(exclusive, block bool) (bool, error) {
+	want := sharedLock
+	if exclusive {
+		want = exclusiveLock
+	}
+	f.lockMutex.Lock()
+	if f.revoked.Load() {
+		f.lockMutex.Unlock()
+		return false, errors.Wrapf(fserrors.EBadF, "file handle has been revoked")
+	}
+	// Only one goroutine at a time acquires the lock for the handle (or its duplicates), so that
+	// the handle never takes the inode's advisory lock twice
+	for f.lockState == acquiringLock {
+		if !block {
+			f.lockMutex.Unlock()
+			return false, nil
+		}
+		f.lockAcquired().Wait()
+	}
+	state := f.lockState
+	if state == want {
+		f.lockMutex.Unlock()
+		// As with flock(2), the lock belongs to the handle, so taking it again is a no-op
+		return true, nil
+	}
+	if state != unlocked {
+		f.lockMutex.Unlock()
+		return false, errors.Wrapf(fserrors.EInval, "file already holds a lock in a different mode")
+	}
+	f.lockState = acquiringLock
+	// Don't hold lockMutex while blocking, so that the handle remains usable by other goroutines
+	f.lockMutex.Unlock()
+	acquired := true
+	if block {
+		f.FileInode.AdvisoryLock(exclusive)
+	} else {
+		acquired = f.FileInode.TryAdvisoryLock(exclusive)
+	}
+	f.lockMutex.Lock()
+	defer f.lockMutex.Unlock()
+	f.lockState = unlocked
+	f.lockAcquired().Broadcast()
+	if f.revoked.Load() {
+		// The handle was revoked while the lock was being acquired, so Revoke() couldn't release it
+		if acquired {
+			f.FileInode.AdvisoryUnlock(exclusive)
+		}
+		return false, errors.Wrapf(fserrors.EBadF, "file handle has been revoked")
+	}
+	if acquired {
+		f.lockState = want
+	}
+	return acquired, nil
+}
+
+// lockAcquired returns the condition variable that is broadcast whenever a goroutine finishes
+// acquiring the lock for the handle, creating it if necessary.  lockMutex must be held.
+func (f *file) lockAcquired() *sync.Cond {
+	if f.lockCond == nil {
+		f.lockCond = sync.NewCond(&f.lockMutex)
+	}
+	return f.lockCond
+}
+
+// release gives up the advisory lock, which must be held in the specified mode.  It returns EBADF
+// if the handle has been revoked, since Revoke() has already released its lock.
+func (f *file) release(exclusive bool) error {
+	want := sharedLock
+	if exclusive {
+		want = exclusiveLock
+	}
+	f.lockMutex.Lock()
+	defer f.lockMutex.Unlock()
+	if f.revoked.Load() {
+		return errors.Wrapf(fserrors.EBadF, "file handle has been revoked")
+	}
+	if f.lockState != want {
+		return errors.Wrapf(fserrors.EInval, "file does not hold the lock that it is releasing")
+	}
+	f.FileInode.AdvisoryUnlock(exclusive)
+	f.lockState = unlocked
+	return nil
+}
+
+// releaseHeld gives up the advisory lock if the handle holds one, in either mode.  A lock that is
+// still being acquired is left alone.
+func (f *file) releaseHeld() {
+	f.lockMutex.Lock()
+	defer f.lockMutex.Unlock()
+	switch f.lockState {
+	case sharedLock, exclusiveLock:
+		f.FileInode.AdvisoryUnlock(f.lockState == exclusiveLock)
+		f.lockState = unlocked
+	}
+}
+
+func (f *file) Lock() error {
+	_, err := f.acquire(true, true)
+	return err
+}
+
+func (f *file) TryLock() (bool, error) {
+	return f.acquire(true, false)
+}
+
+func (f *file) Unlock() error {
+	return f.release(true)
+}
+
+func (f *file) RLock() error {
+	_, err := f.acquire(false, true)
+	return err
+}
+
+func (f *file) RUnlock() error {
+	return f.release(false)
+}
@@ -0,0 +1,178 @@
+package file_test
+
+import (
+	"sync"
+	"time"
+
+	"github.com/manderson5192/memfs/file"
+	"github.com/manderson5192/memfs/fserrors"
+	"github.com/manderson5192/memfs/os"
+	"github.com/stretchr/testify/assert"
+)
+
+func (s *FileTestSuite) TestExclusiveLockContention() {
+	other, err := s.RootDir.OpenFile("file", os.O_RDWR)
+	assert.Nil(s.T(), err)
+
+	// Two goroutines increment a counter stored in the file, each through its own handle.  The
+	// read-modify-write is only safe because the handles contend for the same advisory lock.
+	const increments = 200
+	increment := func(f file.File) {
+		for n := 0; n < increments; n++ {
+			assert.Nil(s.T(), f.Lock())
+			buf := make([]byte, 2)
+			_, _ = f.ReadAt(buf, 0)
+			value := int(buf[0])<<8 | int(buf[1])
+			value++
+			_, err := f.WriteAt([]byte{byte(value >> 8), byte(value)}, 0)
+			assert.Nil(s.T(), err)
+			assert.Nil(s.T(), f.Unlock())
+		}
+	}
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		increment(s.File)
+	}()
+	go func() {
+		defer wg.Done()
+		increment(other)
+	}()
+	wg.Wait()
+
+	data, err := s.File.ReadAll()
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), 2*increments, int(data[0])<<8|int(data[1]))
+}
+
+func (s *FileTestSuite) TestLockBlocksUntilReleased() {
+	other, err := s.RootDir.OpenFile("file", os.O_RDONLY)
+	assert.Nil(s.T(), err)
+	assert.Nil(s.T(), s.File.Lock())
+
+	acquired := make(chan struct{})
+	go func() {
+		assert.Nil(s.T(), other.Lock())
+		close(acquired)
+	}()
+	select {
+	case <-acquired:
+		s.T().Fatal("Lock() returned while a conflicting lock was held")
+	case <-time.After(50 * time.Millisecond):
+	}
+	assert.Nil(s.T(), s.File.Unlock())
+	select {
+	case <-acquired:
+	case <-time.After(5 * time.Second):
+		s.T().Fatal("Lock() did not return after the conflicting lock was released")
+	}
+	assert.Nil(s.T(), other.Unlock())
+}
+
+func (s *FileTestSuite) TestCloseReleasesLock() {
+	other, err := s.RootDir.OpenFile("file", os.O_RDONLY)
+	assert.Nil(s.T(), err)
+	dup := s.File.Dup()
+	assert.Nil(s.T(), s.File.Lock())
+	acquired, err := other.TryLock()
+	assert.Nil(s.T(), err)
+	assert.False(s.T(), acquired)
+
+	// Closing a duplicate releases the lock that it shares
+	assert.Nil(s.T(), dup.Close())
+	acquired, err = other.TryLock()
+	assert.Nil(s.T(), err)
+	assert.True(s.T(), acquired)
+	assert.ErrorIs(s.T(), s.File.Unlock(), fserrors.EInval)
+	assert.Nil(s.T(), other.Close())
+
+	// Closing a handle that holds no lock is harmless
+	assert.Nil(s.T(), s.File.Close())
+	assert.Nil(s.T(), s.File.RLock())
+	assert.Nil(s.T(), s.File.RUnlock())
+}
+
+func (s *FileTestSuite) TestTryLock() {
+	other, err := s.RootDir.OpenFile("file", os.O_RDONLY)
+	assert.Nil(s.T(), err)
+
+	// Shared locks are compatible with one another, but not with exclusive locks
+	assert.Nil(s.T(), s.File.RLock())
+	assert.Nil(s.T(), other.RLock())
+	assert.Nil(s.T(), other.RUnlock())
+	acquired, err := other.TryLock()
+	assert.Nil(s.T(), err)
+	assert.False(s.T(), acquired)
+
+	assert.Nil(s.T(), s.File.RUnlock())
+	acquired, err = other.TryLock()
+	assert.Nil(s.T(), err)
+	assert.True(s.T(), acquired)
+
+	// Locking a handle that already holds the lock is a no-op
+	acquired, err = other.TryLock()
+	assert.Nil(s.T(), err)
+	assert.True(s.T(), acquired)
+	assert.Nil(s.T(), other.Unlock())
+}
+
+func (s *FileTestSuite) TestConcurrentLocksOnOneHandle() {
+	other, err := s.RootDir.OpenFile("file", os.O_RDWR)
+	assert.Nil(s.T(), err)
+	for n := 0; n < 1000; n++ {
+		// Shared locks taken concurrently through a handle and its duplicate are a single lock,
+		// which one RUnlock() releases
+		dup := s.File.Dup()
+		var wg sync.WaitGroup
+		wg.Add(2)
+		for _, f := range []file.File{s.File, dup} {
+			go func() {
+				defer wg.Done()
+				assert.Nil(s.T(), f.RLock())
+			}()
+		}
+		s.waitForLockers(&wg)
+		assert.Nil(s.T(), dup.RUnlock())
+		assert.ErrorIs(s.T(), s.File.RUnlock(), fserrors.EInval)
+		acquired, err := other.TryLock()
+		assert.Nil(s.T(), err)
+		assert.True(s.T(), acquired, "no shared lock was leaked")
+		assert.Nil(s.T(), other.Unlock())
+
+		// Neither do concurrent exclusive locks through one handle deadlock with each other
+		wg.Add(2)
+		for i := 0; i < 2; i++ {
+			go func() {
+				defer wg.Done()
+				assert.Nil(s.T(), s.File.Lock())
+			}()
+		}
+		s.waitForLockers(&wg)
+		assert.Nil(s.T(), s.File.Unlock())
+	}
+}
+
+// waitForLockers waits for wg, failing the test if that takes so long that the goroutines must have
+// deadlocked
+func (s *FileTestSuite) waitForLockers(wg *sync.WaitGroup) {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		s.T().Fatal("concurrent locks through one handle deadlocked")
+	}
+}
+
+func (s *FileTestSuite) TestUnlockWithoutLock() {
+	assert.ErrorIs(s.T(), s.File.Unlock(), fserrors.EInval)
+	assert.ErrorIs(s.T(), s.File.RUnlock(), fserrors.EInval)
+	assert.Nil(s.T(), s.File.RLock())
+	assert.ErrorIs(s.T(), s.File.Unlock(), fserrors.EInval)
+	assert.ErrorIs(s.T(), s.File.Lock(), fserrors.EInval, "shared locks must be released before locking exclusively")
+	assert.Nil(s.T(), s.File.RUnlock())
+}
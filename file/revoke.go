@@ -0,0 +1,40 @@
+package file
+
+import (
+	"github.com/manderson5192/memfs/fserrors"
+	"github.com/manderson5192/memfs/os"
+	"github.com/pkg/errors"
+)
+
+func (f *file) Revoke() {
+	f.mutex.Lock()
+	f.revoked.Store(true)
+	f.buffer = nil
+	f.mutex.Unlock()
+	// A lock that is being acquired is released by acquire(), which checks revoked once it has it
+	f.releaseHeld()
+}
+
+// checkReadable returns an error wrapping EBADF if f has been revoked or its mode doesn't allow
+// reads
+func (f *file) checkReadable() error {
+	if f.revoked.Load() {
+		return errors.Wrapf(fserrors.EBadF, "file handle has been revoked")
+	}
+	if os.IsWriteOnly(f.mode) {
+		return modeViolation("write-only")
+	}
+	return nil
+}
+
+// checkWritable returns an error wrapping EBADF if f has been revoked or its mode doesn't allow
+// writes
+func (f *file) checkWritable() error {
+	if f.revoked.Load() {
+		return errors.Wrapf(fserrors.EBadF, "file handle has been revoked")
+	}
+	if os.IsReadOnly(f.mode) {
+		return modeViolation("read-only")
+	}
+	return nil
+}
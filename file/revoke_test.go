@@ -0,0 +1,56 @@
+package file_test
+
+import (
+	"errors"
+
+	"github.com/manderson5192/memfs/file"
+	"github.com/manderson5192/memfs/fserrors"
+	"github.com/manderson5192/memfs/inode"
+	"github.com/manderson5192/memfs/os"
+	"github.com/stretchr/testify/assert"
+)
+
+func (s *FileTestSuite) TestRevokeFailsReadsAndWrites() {
+	fileInode := inode.NewFileInode()
+	assert.Nil(s.T(), fileInode.TruncateAndWriteAll([]byte("data")))
+	f := file.NewFile(fileInode, os.O_RDWR)
+	dup := f.Dup()
+	other := file.NewFile(fileInode, os.O_RDWR)
+	f.Revoke()
+
+	for _, revoked := range []file.File{f, dup} {
+		_, err := revoked.ReadAll()
+		assert.ErrorIs(s.T(), err, fserrors.EBadF)
+		_, err = revoked.Read(make([]byte, 1))
+		assert.ErrorIs(s.T(), err, fserrors.EBadF)
+		_, err = revoked.ReadString()
+		assert.ErrorIs(s.T(), err, fserrors.EBadF)
+		_, err = revoked.Write([]byte("x"))
+		assert.ErrorIs(s.T(), err, fserrors.EBadF)
+		_, err = revoked.WriteAt([]byte("x"), 0)
+		assert.ErrorIs(s.T(), err, fserrors.EBadF)
+		assert.ErrorIs(s.T(), revoked.TruncateAndWriteAll([]byte("x")), fserrors.EBadF)
+		_, err = revoked.CompareAndSwapAll([]byte("data"), []byte("x"))
+		assert.ErrorIs(s.T(), err, fserrors.EBadF)
+		// Unlike a mode violation, a revoked handle isn't reported as EINVAL
+		assert.False(s.T(), errors.Is(err, fserrors.EInval))
+	}
+
+	// Other handles to the file are unaffected
+	data, err := other.ReadAll()
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []byte("data"), data)
+	_, err = other.WriteAt([]byte("D"), 0)
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []byte("Data"), fileInode.ReadAll())
+}
+
+func (s *FileTestSuite) TestRevokeDiscardsBufferedWrites() {
+	fileInode := inode.NewFileInode()
+	f := file.NewWriteBackFile(fileInode, os.O_RDWR, 64)
+	_, err := f.Write([]byte("buffered"))
+	assert.Nil(s.T(), err)
+	f.Revoke()
+	assert.Nil(s.T(), f.Close())
+	assert.Equal(s.T(), 0, fileInode.Size())
+}
@@ -0,0 +1,20 @@
+package file
+
+import "io"
+
+func (f *file) SectionReader(off, n int64) *io.SectionReader {
+	size := int64(f.Size())
+	if off < 0 {
+		off = 0
+	}
+	if off > size {
+		off = size
+	}
+	if n < 0 {
+		n = 0
+	}
+	if n > size-off {
+		n = size - off
+	}
+	return io.NewSectionReader(f, off, n)
+}
@@ -0,0 +1,42 @@
+package file_test
+
+import (
+	"io"
+	"io/ioutil"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func (s *FileTestSuite) TestSectionReader() {
+	assert.Nil(s.T(), s.File.TruncateAndWriteAll([]byte("header|payload|trailer")))
+	section := s.File.SectionReader(7, 7)
+	assert.Equal(s.T(), int64(7), section.Size())
+	data, err := ioutil.ReadAll(section)
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), "payload", string(data))
+	assert.Equal(s.T(), int64(0), s.File.Tell(), "the section doesn't move the file's offset")
+
+	// Reads stop at the section's end even though the file has more bytes
+	buf := make([]byte, 4)
+	n, err := section.ReadAt(buf, 5)
+	assert.ErrorIs(s.T(), err, io.EOF)
+	assert.Equal(s.T(), "ad", string(buf[:n]))
+	n, err = section.Read(buf)
+	assert.ErrorIs(s.T(), err, io.EOF)
+	assert.Zero(s.T(), n)
+}
+
+func (s *FileTestSuite) TestSectionReaderClampsToFileSize() {
+	assert.Nil(s.T(), s.File.TruncateAndWriteAll([]byte("0123456789")))
+	section := s.File.SectionReader(6, 100)
+	assert.Equal(s.T(), int64(4), section.Size())
+	data, err := ioutil.ReadAll(section)
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), "6789", string(data))
+
+	assert.Equal(s.T(), int64(0), s.File.SectionReader(20, 5).Size())
+	assert.Equal(s.T(), int64(0), s.File.SectionReader(2, -1).Size())
+	data, err = ioutil.ReadAll(s.File.SectionReader(-3, 2))
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), "01", string(data))
+}
@@ -0,0 +1,13 @@
+package file
+
+func (f *file) WriteString(s string) (int, error) {
+	return f.Write([]byte(s))
+}
+
+func (f *file) ReadString() (string, error) {
+	data, err := f.ReadAll()
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
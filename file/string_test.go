@@ -0,0 +1,43 @@
+package file_test
+
+import (
+	"io"
+
+	"github.com/manderson5192/memfs/fserrors"
+	"github.com/manderson5192/memfs/os"
+	"github.com/stretchr/testify/assert"
+)
+
+func (s *FileTestSuite) TestWriteString() {
+	n, err := io.WriteString(s.File, "hello")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), 5, n)
+	assert.Equal(s.T(), int64(5), s.File.Tell())
+	n, err = s.File.WriteString(", world")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), 7, n)
+	assert.Equal(s.T(), int64(12), s.File.Tell())
+	contents, err := s.File.ReadString()
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), "hello, world", contents)
+	assert.Equal(s.T(), int64(12), s.File.Tell(), "ReadString doesn't move the offset")
+}
+
+func (s *FileTestSuite) TestWriteStringHonorsMode() {
+	readOnly, err := s.RootDir.OpenFile("file", os.O_RDONLY)
+	assert.Nil(s.T(), err)
+	_, err = io.WriteString(readOnly, "hello")
+	assert.ErrorIs(s.T(), err, fserrors.EInval)
+	assert.Zero(s.T(), s.File.Size())
+
+	assert.Nil(s.T(), s.File.TruncateAndWriteAll([]byte("log:")))
+	appender, err := s.RootDir.OpenFile("file", os.CombineModes(os.O_WRONLY, os.O_APPEND))
+	assert.Nil(s.T(), err)
+	_, err = appender.WriteString(" entry")
+	assert.Nil(s.T(), err)
+	_, err = appender.ReadString()
+	assert.ErrorIs(s.T(), err, fserrors.EInval)
+	contents, err := s.File.ReadString()
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), "log: entry", contents)
+}
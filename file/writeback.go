@@ -0,0 +1,207 @@
+package file
+
+import (
+	"io"
+
+	"github.com/manderson5192/memfs/fserrors"
+	"github.com/manderson5192/memfs/inode"
+	"github.com/manderson5192/memfs/os"
+	"github.com/pkg/errors"
+)
+
+// NewWriteBackFile creates a File that buffers its writes, like a write-back cache, to reduce inode
+// lock contention under many small writes.  Written data accumulates in a buffer that is shared by
+// the File and its duplicates (see Dup()), and is only written to the inode once the buffer holds
+// at least bufSize bytes, when a write isn't contiguous with the buffered data, or when Sync() or
+// Close() is called.  Reads through the File see the buffered data, but other handles to the same
+// file don't see it until it is flushed.  An error from writing buffered data to the inode (e.g.
+// ENOSPC) is returned by the call that flushed it, and the data stays buffered.
+//
+// Unlike BufferedFile, which wraps any File with bufio buffering, the returned File is a complete
+// File whose ReadAll(), ReadAt(), and Size() reflect its buffered writes.
+func NewWriteBackFile(inode *inode.FileInode, mode int, bufSize int) File {
+	return &file{
+		FileInode: inode,
+		openFile: &openFile{
+			offset:  0,
+			mode:    mode,
+			maxSize: -1,
+			bufSize: bufSize,
+		},
+	}
+}
+
+func (f *file) Size() int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.size()
+}
+
+// size returns the size of the file as seen through f, including any data that f has buffered.  It
+// must only be called while f.mutex is held.
+func (f *file) size() int {
+	size := f.FileInode.Size()
+	if len(f.buffer) == 0 {
+		return size
+	}
+	if end := int(f.bufferStart(size)) + len(f.buffer); end > size {
+		return end
+	}
+	return size
+}
+
+func (f *file) Sync() error {
+	f.mutex.Lock()
+	err := f.flush()
+	f.mutex.Unlock()
+	if err != nil {
+		return err
+	}
+	return f.FileInode.Sync()
+}
+
+func (f *file) Close() error {
+	f.mutex.Lock()
+	err := f.flush()
+	f.mutex.Unlock()
+	if err == nil && os.IsWriteAllowed(f.mode) {
+		// The file may have been written piece by piece, so share its finished contents
+		f.FileInode.Deduplicate()
+	}
+	f.releaseHeld()
+	return err
+}
+
+// bufferStart returns the offset at which f's buffered data belongs, given the size of the inode's
+// data: at the end of the data if f is in append mode, or wherever it was written otherwise.  It
+// must only be called while f.mutex is held.
+func (f *file) bufferStart(inodeSize int) int64 {
+	if os.IsAppendMode(f.mode) {
+		return int64(inodeSize)
+	}
+	return f.bufferOffset
+}
+
+// flush writes f's buffered data to the inode.  If the data can't be written, then it stays
+// buffered.  It must only be called while f.mutex is held.
+func (f *file) flush() error {
+	if len(f.buffer) == 0 {
+		return nil
+	}
+	// Count the flush as a write of its own, since the writes that it flushes were counted when they
+	// were buffered, which may have been long before (e.g. before a transaction began)
+	defer f.countMutation()()
+	var err error
+	if os.IsAppendMode(f.mode) {
+		_, _, err = f.FileInode.AppendAllWithLimit(f.buffer, -1)
+	} else {
+		_, err = f.FileInode.WriteAt(f.buffer, f.bufferOffset)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "could not flush buffered writes")
+	}
+	f.buffer = nil
+	f.publishWrite()
+	return nil
+}
+
+// withBuffer returns data, the inode's data, updated with the data that f has buffered.  It must
+// only be called while f.mutex is held.
+func (f *file) withBuffer(data []byte) []byte {
+	if len(f.buffer) == 0 {
+		return data
+	}
+	start := int(f.bufferStart(len(data)))
+	if end := start + len(f.buffer); end > len(data) {
+		data = append(data, make([]byte, end-len(data))...)
+	}
+	copy(data[start:], f.buffer)
+	return data
+}
+
+// readBufferedAt is like FileInode.ReadAt(), but the data that f has buffered takes precedence over
+// the inode's data.  It must only be called while f.mutex is held.
+func (f *file) readBufferedAt(p []byte, off int64) (int, error) {
+	if len(f.buffer) == 0 || p == nil || off < 0 {
+		return f.FileInode.ReadAt(p, off)
+	}
+	inodeSize := f.FileInode.Size()
+	size := int64(f.size())
+	if off >= size {
+		return 0, io.EOF
+	}
+	n := len(p)
+	if int64(n) > size-off {
+		n = int(size - off)
+	}
+	read, err := f.FileInode.ReadAt(p[:n], off)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return 0, err
+	}
+	// Whatever lies between the end of the inode's data and the buffered data is a hole
+	for idx := read; idx < n; idx++ {
+		p[idx] = 0
+	}
+	start := f.bufferStart(inodeSize)
+	lo, hi := off, off+int64(n)
+	if start > lo {
+		lo = start
+	}
+	if end := start + int64(len(f.buffer)); end < hi {
+		hi = end
+	}
+	if lo < hi {
+		copy(p[lo-off:hi-off], f.buffer[lo-start:hi-start])
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// writeBufferedAt buffers p to be written at offset off, first flushing the buffered data if p
+// isn't contiguous with it, and then flushing the buffer if it is full.  It must only be called
+// while f.mutex is held.
+func (f *file) writeBufferedAt(p []byte, off int64) (int, error) {
+	if p == nil {
+		return 0, errors.Wrapf(fserrors.EInval, "buffer is nil")
+	}
+	if off < 0 {
+		return 0, errors.Wrapf(fserrors.EInval, "negative offset")
+	}
+	if len(f.buffer) > 0 && (off < f.bufferOffset || off > f.bufferOffset+int64(len(f.buffer))) {
+		if err := f.flush(); err != nil {
+			return 0, err
+		}
+	}
+	if len(f.buffer) == 0 {
+		f.bufferOffset = off
+	}
+	relativeOff := int(off - f.bufferOffset)
+	if grow := relativeOff + len(p) - len(f.buffer); grow > 0 {
+		f.buffer = append(f.buffer, make([]byte, grow)...)
+	}
+	copy(f.buffer[relativeOff:], p)
+	if len(f.buffer) >= f.bufSize {
+		if err := f.flush(); err != nil {
+			return len(p), err
+		}
+	}
+	return len(p), nil
+}
+
+// appendBuffered buffers p to be appended to the file, leaves the offset just past it, and then
+// flushes the buffer if it is full.  It must only be called while f.mutex is held.
+func (f *file) appendBuffered(p []byte) (int, error) {
+	if p == nil {
+		return 0, errors.Wrapf(fserrors.EInval, "buffer is nil")
+	}
+	f.buffer = append(f.buffer, p...)
+	f.offset = int64(f.size())
+	if len(f.buffer) >= f.bufSize {
+		if err := f.flush(); err != nil {
+			return len(p), err
+		}
+	}
+	return len(p), nil
+}
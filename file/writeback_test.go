@@ -0,0 +1,89 @@
+package file_test
+
+import (
+	"crypto/sha256"
+	"io"
+
+	"github.com/manderson5192/memfs/file"
+	"github.com/manderson5192/memfs/inode"
+	"github.com/manderson5192/memfs/os"
+	"github.com/stretchr/testify/assert"
+)
+
+func (s *FileTestSuite) TestWriteBackReadsSeeBufferedData() {
+	fileInode := inode.NewFileInode()
+	assert.Nil(s.T(), fileInode.TruncateAndWriteAll([]byte("0123456789")))
+	f := file.NewWriteBackFile(fileInode, os.O_RDWR, 64)
+	_, err := f.WriteAt([]byte("ab"), 4)
+	assert.Nil(s.T(), err)
+	_, err = f.WriteAt([]byte("cd"), 6)
+	assert.Nil(s.T(), err)
+	_, err = f.Seek(12, io.SeekStart)
+	assert.Nil(s.T(), err)
+
+	// The inode doesn't have the buffered data yet
+	assert.Equal(s.T(), []byte("0123456789"), fileInode.ReadAll())
+	data, err := f.ReadAll()
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []byte("0123abcd89"), data)
+	buf := make([]byte, 4)
+	n, err := f.ReadAt(buf, 2)
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []byte("23ab"), buf[:n])
+
+	// A write past the end of the file leaves a hole, and extends the file as seen by the handle
+	_, err = f.Write([]byte("xy"))
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), 14, f.Size())
+	assert.Equal(s.T(), 10, fileInode.Size(), "the non-contiguous write flushed the earlier writes")
+	assert.Equal(s.T(), []byte("0123abcd89"), fileInode.ReadAll())
+	buf = make([]byte, 8)
+	n, err = f.ReadAt(buf, 8)
+	assert.ErrorIs(s.T(), err, io.EOF)
+	assert.Equal(s.T(), []byte{'8', '9', 0, 0, 'x', 'y'}, buf[:n])
+	checksum, err := f.Checksum(sha256.New())
+	assert.Nil(s.T(), err)
+	expected := sha256.Sum256([]byte{'0', '1', '2', '3', 'a', 'b', 'c', 'd', '8', '9', 0, 0, 'x', 'y'})
+	assert.Equal(s.T(), expected[:], checksum)
+
+	assert.Nil(s.T(), f.Close())
+	assert.Equal(s.T(), []byte{'0', '1', '2', '3', 'a', 'b', 'c', 'd', '8', '9', 0, 0, 'x', 'y'}, fileInode.ReadAll())
+}
+
+func (s *FileTestSuite) TestWriteBackFlushesFullBuffer() {
+	fileInode := inode.NewFileInode()
+	f := file.NewWriteBackFile(fileInode, os.O_RDWR, 4)
+	_, err := f.Write([]byte("abc"))
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), 0, fileInode.Size())
+	_, err = f.Write([]byte("d"))
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []byte("abcd"), fileInode.ReadAll())
+
+	// Truncation replaces buffered writes
+	_, err = f.Write([]byte("e"))
+	assert.Nil(s.T(), err)
+	assert.Nil(s.T(), f.TruncateAndWriteAll([]byte("new")))
+	assert.Nil(s.T(), f.Sync())
+	assert.Equal(s.T(), []byte("new"), fileInode.ReadAll())
+}
+
+func (s *FileTestSuite) TestWriteBackAppend() {
+	fileInode := inode.NewFileInode()
+	assert.Nil(s.T(), fileInode.TruncateAndWriteAll([]byte("start")))
+	f := file.NewWriteBackFile(fileInode, os.CombineModes(os.O_RDWR, os.O_APPEND), 64)
+	_, err := f.Write([]byte("-mine"))
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), int64(10), f.Tell())
+
+	// Buffered appends land at the end of the file when they are flushed, even if another handle
+	// has extended it in the meantime
+	other := file.NewFile(fileInode, os.CombineModes(os.O_RDWR, os.O_APPEND))
+	_, err = other.Write([]byte("-theirs"))
+	assert.Nil(s.T(), err)
+	data, err := f.ReadAll()
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []byte("start-theirs-mine"), data)
+	assert.Nil(s.T(), f.Sync())
+	assert.Equal(s.T(), []byte("start-theirs-mine"), fileInode.ReadAll())
+}
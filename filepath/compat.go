@@ -0,0 +1,44 @@
+package filepath
+
+import "path"
+
+// The functions in this file give this package the same names as the Go standard library's
+// path/filepath package, so that portable code written against path/filepath can use this package
+// by swapping the import.  Since MemFS has no volumes and its default separator is '/', VolumeName,
+// FromSlash, and ToSlash are intentional no-ops (see Parser for filesystems with other separators).
+
+// ErrBadPattern indicates that a pattern passed to Match was malformed.  It is the same error value
+// as path/filepath's ErrBadPattern.
+var ErrBadPattern = path.ErrBadPattern
+
+// IsAbs reports whether path is absolute.  It is identical to IsAbsolutePath.
+func IsAbs(path string) bool {
+	return IsAbsolutePath(path)
+}
+
+// VolumeName always returns "", since MemFS paths have no volume names.  It is a no-op provided for
+// parity with path/filepath.
+func VolumeName(path string) string {
+	return ""
+}
+
+// FromSlash returns path unchanged, since MemFS's default separator is already '/'.  It is a no-op
+// provided for parity with path/filepath.
+func FromSlash(path string) string {
+	return DefaultParser.FromSlash(path)
+}
+
+// ToSlash returns path unchanged, since MemFS's default separator is already '/'.  It is a no-op
+// provided for parity with path/filepath.
+func ToSlash(path string) string {
+	return DefaultParser.ToSlash(path)
+}
+
+// Match reports whether name matches the shell pattern, using the same pattern syntax as
+// path/filepath's Match: '*' matches any sequence of characters other than the separator, '?'
+// matches any single character other than the separator, '[...]' matches a character class, and
+// '\\' escapes the following character.  The only possible error is ErrBadPattern.
+func Match(pattern, name string) (bool, error) {
+	// path.Match implements exactly these semantics for '/'-separated paths
+	return path.Match(pattern, name)
+}
@@ -0,0 +1,51 @@
+package filepath_test
+
+import (
+	"testing"
+
+	"github.com/manderson5192/memfs/filepath"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsAbs(t *testing.T) {
+	for _, path := range []string{"", "/", "/a", "a", "a/b", "./a", "../a", "//a"} {
+		assert.Equal(t, filepath.IsAbsolutePath(path), filepath.IsAbs(path), "IsAbs(%q)", path)
+	}
+}
+
+func TestSlashNoOps(t *testing.T) {
+	for _, path := range []string{"", "/", "a/b", "/a/b/", `a\b`} {
+		assert.Equal(t, path, filepath.FromSlash(path))
+		assert.Equal(t, path, filepath.ToSlash(path))
+		assert.Equal(t, "", filepath.VolumeName(path))
+	}
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		matched bool
+	}{
+		{"abc", "abc", true},
+		{"*", "abc", true},
+		{"*", "a/b", false},
+		{"a/*", "a/b", true},
+		{"a/*/c", "a/b/c", true},
+		{"a?c", "abc", true},
+		{"a?c", "a/c", false},
+		{"[a-c]x", "bx", true},
+		{"[^a-c]x", "bx", false},
+		{`a\*`, "a*", true},
+		{`a\*`, "ab", false},
+		{"*.txt", "notes.txt", true},
+		{"*.txt", "notes.md", false},
+	}
+	for _, test := range tests {
+		matched, err := filepath.Match(test.pattern, test.name)
+		assert.Nil(t, err)
+		assert.Equal(t, test.matched, matched, "Match(%q, %q)", test.pattern, test.name)
+	}
+	_, err := filepath.Match("[a-", "a")
+	assert.Equal(t, filepath.ErrBadPattern, err)
+}
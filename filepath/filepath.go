@@ -1,11 +1,5 @@
 package filepath
 
-import (
-	"strings"
-
-	"github.com/manderson5192/memfs/utils"
-)
-
 type PathType int
 
 const (
@@ -15,12 +9,14 @@ const (
 	ParentDirectoryEntry string = ".."
 )
 
+// IsAbsolutePath returns true if path begins with a path separator
 func IsAbsolutePath(path string) bool {
-	return strings.HasPrefix(path, PathSeparator)
+	return DefaultParser.IsAbsolutePath(path)
 }
 
+// IsRelativePath returns true if path does not begin with a path separator
 func IsRelativePath(path string) bool {
-	return !IsAbsolutePath(path)
+	return DefaultParser.IsRelativePath(path)
 }
 
 // Clean lexically simplifies a path by applying the following operations, in order:
@@ -32,46 +28,22 @@ func IsRelativePath(path string) bool {
 // Cut() method (from the path/filepath module).  Candidly, Go's implementation is much more
 // efficient -- I just figured it was a stretch to use their implementation for this assignment :).
 func Clean(path string) string {
-	// Replace sequential path separators with a single path separator
-	var builder strings.Builder
-	lastRuneWasSeparator := false
-	for _, r := range path {
-		if r == PathSeparatorRune && lastRuneWasSeparator {
-			continue
-		}
-		if r != PathSeparatorRune && lastRuneWasSeparator {
-			lastRuneWasSeparator = false
-		}
-		lastRuneWasSeparator = PathSeparatorRune == r
-		builder.WriteRune(r)
-	}
-	path = builder.String()
-
-	// Remove '.' elements from the path
-	parts := strings.Split(path, PathSeparator)
-	sanitizedParts := make([]string, 0, len(parts))
-	for _, part := range parts {
-		if part != "." {
-			sanitizedParts = append(sanitizedParts, part)
-		}
-	}
-	path = strings.Join(sanitizedParts, PathSeparator)
-
-	// Remove leading '..' entries from absolute paths
-	for IsAbsolutePath(path) && strings.HasPrefix(path, "/../") {
-		path = "/" + strings.TrimPrefix(path, "/../")
-	}
-	if path == "/.." {
-		path = "/"
-	}
+	return DefaultParser.Clean(path)
+}
 
-	return path
+// CleanFull lexically simplifies a path with the same semantics as the Go standard library's
+// path.Clean(): in addition to everything that Clean() does, it resolves each '..' against the
+// preceding element, removes any trailing path separator, and returns "." for a path that would
+// otherwise be empty.  Unlike Clean(), it does not preserve the meaning of a path whose elements
+// might not be directories, which is why lookups use Clean() instead.
+func CleanFull(path string) string {
+	return DefaultParser.CleanFull(path)
 }
 
 // Join joins together all of the supplied path parts with the PathSeparator before Clean()'ing and
 // returning the result
 func Join(parts ...string) string {
-	return Clean(strings.Join(parts, PathSeparator))
+	return DefaultParser.Join(parts...)
 }
 
 // PathInfo represents a path.  Entry and ParentPath are guaranteed to be non-empty strings such
@@ -90,40 +62,5 @@ type PathInfo struct {
 // path indicates that the entry name must be a directory (e.g. if it ends with a path separator),
 // and (4) whether the path is relative (or absolute).  It stores this information in a PathInfo.
 func ParsePath(path string) *PathInfo {
-	// Clean the path for convenience
-	cleanPath := Clean(path)
-	// interpret "" as a reference to the current directory
-	if cleanPath == "" {
-		return &PathInfo{
-			Entry:      SelfDirectoryEntry,
-			ParentPath: SelfDirectoryEntry,
-			MustBeDir:  true,
-			IsRelative: true,
-		}
-	}
-	// special case: "/"
-	if cleanPath == "/" {
-		return &PathInfo{
-			Entry:      SelfDirectoryEntry,
-			ParentPath: cleanPath,
-			MustBeDir:  true,
-			IsRelative: false,
-		}
-	}
-	isRelative := IsRelativePath(cleanPath)
-	mustBeDir := strings.HasSuffix(cleanPath, "/")
-	if mustBeDir {
-		cleanPath = cleanPath[0 : len(cleanPath)-1]
-	}
-	parentPath, entry, found := utils.RightCut(cleanPath, PathSeparator)
-	if !found {
-		// There was no path separator, so this is a relative path and the whole path is entry name
-		parentPath = SelfDirectoryEntry
-	}
-	return &PathInfo{
-		Entry:      entry,
-		ParentPath: parentPath,
-		MustBeDir:  mustBeDir,
-		IsRelative: isRelative,
-	}
+	return DefaultParser.ParsePath(path)
 }
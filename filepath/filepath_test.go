@@ -1,6 +1,7 @@
 package filepath_test
 
 import (
+	"path"
 	"testing"
 
 	"github.com/manderson5192/memfs/filepath"
@@ -72,3 +73,38 @@ func TestParsePath(t *testing.T) {
 		IsRelative: false,
 	}, filepath.ParsePath("/a/b/c/"))
 }
+
+func TestCleanFull(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{"", "."},
+		{".", "."},
+		{"/", "/"},
+		{"//", "/"},
+		{"a", "a"},
+		{"a/", "a"},
+		{"/a/", "/a"},
+		{"a//b", "a/b"},
+		{"a/./b", "a/b"},
+		{"a/b/../c", "a/c"},
+		{"a/../b", "b"},
+		{"a/..", "."},
+		{"a/../..", ".."},
+		{"../a", "../a"},
+		{"../../a/b/..", "../../a"},
+		{"a/../../b", "../b"},
+		{"/..", "/"},
+		{"/../a", "/a"},
+		{"/a/b/../../..", "/"},
+		{"/a/b/./../c/", "/a/c"},
+		{"abc/def/ghi/../../jkl", "abc/jkl"},
+		{"...", "..."},
+		{"a/.../b", "a/.../b"},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.expected, filepath.CleanFull(test.path), "CleanFull(%q)", test.path)
+		assert.Equal(t, path.Clean(test.path), filepath.CleanFull(test.path), "path.Clean(%q)", test.path)
+	}
+}
@@ -0,0 +1,34 @@
+package filepath
+
+import "strings"
+
+// ListSeparator is the default separator between the paths in a path list, like the ':' in a PATH
+// environment variable.  It is distinct from PathSeparator, which separates the parts of one path.
+const ListSeparator string = ":"
+
+// SplitList splits a list of paths joined by ListSeparator, like Go's filepath.SplitList().  An
+// empty list yields an empty slice, and consecutive separators yield empty paths.
+func SplitList(list string) []string {
+	return SplitListWithSeparator(list, ListSeparator)
+}
+
+// SplitListWithSeparator behaves like SplitList, except that the paths are separated by separator
+// instead of ListSeparator
+func SplitListWithSeparator(list, separator string) []string {
+	if list == "" {
+		return []string{}
+	}
+	return strings.Split(list, separator)
+}
+
+// JoinList joins paths into a single list, separated by ListSeparator.  It is the inverse of
+// SplitList(), except that JoinList() of a single empty path yields the empty list.
+func JoinList(paths ...string) string {
+	return JoinListWithSeparator(ListSeparator, paths...)
+}
+
+// JoinListWithSeparator behaves like JoinList, except that the paths are separated by separator
+// instead of ListSeparator
+func JoinListWithSeparator(separator string, paths ...string) string {
+	return strings.Join(paths, separator)
+}
@@ -0,0 +1,49 @@
+package filepath_test
+
+import (
+	"testing"
+
+	"github.com/manderson5192/memfs/filepath"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitList(t *testing.T) {
+	tests := []struct {
+		list     string
+		expected []string
+	}{
+		{"", []string{}},
+		{"/bin", []string{"/bin"}},
+		{"/bin:/usr/bin", []string{"/bin", "/usr/bin"}},
+		{"/bin::/usr/bin", []string{"/bin", "", "/usr/bin"}},
+		{":/bin:", []string{"", "/bin", ""}},
+		{":", []string{"", ""}},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.expected, filepath.SplitList(test.list), "SplitList(%q)", test.list)
+	}
+}
+
+func TestSplitListWithSeparator(t *testing.T) {
+	assert.Equal(t, []string{}, filepath.SplitListWithSeparator("", ";"))
+	assert.Equal(t, []string{"/a:b", "/c"}, filepath.SplitListWithSeparator("/a:b;/c", ";"))
+	assert.Equal(t, []string{"/a", "", "/c"}, filepath.SplitListWithSeparator("/a;;/c", ";"))
+}
+
+func TestJoinList(t *testing.T) {
+	tests := []struct {
+		paths    []string
+		expected string
+	}{
+		{[]string{}, ""},
+		{[]string{"/bin"}, "/bin"},
+		{[]string{"/bin", "/usr/bin"}, "/bin:/usr/bin"},
+		{[]string{"/bin", "", "/usr/bin"}, "/bin::/usr/bin"},
+	}
+	for _, test := range tests {
+		joined := filepath.JoinList(test.paths...)
+		assert.Equal(t, test.expected, joined, "JoinList(%q)", test.paths)
+		assert.Equal(t, test.paths, filepath.SplitList(joined), "SplitList(JoinList(%q))", test.paths)
+	}
+	assert.Equal(t, "/a;/b", filepath.JoinListWithSeparator(";", "/a", "/b"))
+}
@@ -0,0 +1,243 @@
+package filepath
+
+import (
+	"strings"
+
+	"github.com/manderson5192/memfs/fserrors"
+	"github.com/manderson5192/memfs/utils"
+	"github.com/pkg/errors"
+)
+
+// Parser lexically manipulates paths that use a particular syntax: the rune that separates their
+// parts, and the names of the entries that refer to a directory itself and to its parent.  The
+// package-level functions (Clean(), Join(), ParsePath(), etc.) use DefaultParser, and a Parser's
+// methods have the same semantics as those functions, with its own syntax substituted for '/', '.',
+// and '..'.
+//
+// Internally, MemFS always works with paths in the default syntax.  A filesystem that is created
+// with another Parser (see filesys.NewFileSystemWithParser()) checks the paths that it is given with
+// CheckPath() and converts them with ToSlash(), and converts the paths that it reports with
+// FromSlash().
+type Parser struct {
+	// Separator separates the parts of a path
+	Separator rune
+	// SelfEntry is the name of every directory's entry for itself
+	SelfEntry string
+	// ParentEntry is the name of every directory's entry for its parent
+	ParentEntry string
+}
+
+// DefaultParser is the Parser for MemFS's native path syntax, in which '/' separates the parts of a
+// path, '.' is a directory's entry for itself, and '..' is its entry for its parent
+var DefaultParser = NewParser(PathSeparatorRune, SelfDirectoryEntry, ParentDirectoryEntry)
+
+// NewParser returns a Parser for paths whose parts are separated by separator and in which
+// selfEntry and parentEntry are the names of a directory's entries for itself and its parent
+func NewParser(separator rune, selfEntry, parentEntry string) *Parser {
+	return &Parser{
+		Separator:   separator,
+		SelfEntry:   selfEntry,
+		ParentEntry: parentEntry,
+	}
+}
+
+// isDefault returns true if p uses the same syntax as DefaultParser
+func (p *Parser) isDefault() bool {
+	return p.Separator == PathSeparatorRune && p.SelfEntry == SelfDirectoryEntry &&
+		p.ParentEntry == ParentDirectoryEntry
+}
+
+func (p *Parser) separator() string {
+	// Avoid allocating a new string for the common case
+	if p.Separator == PathSeparatorRune {
+		return PathSeparator
+	}
+	return string(p.Separator)
+}
+
+// IsAbsolutePath returns true if path begins with p's separator
+func (p *Parser) IsAbsolutePath(path string) bool {
+	return strings.HasPrefix(path, p.separator())
+}
+
+// IsRelativePath returns true if path does not begin with p's separator
+func (p *Parser) IsRelativePath(path string) bool {
+	return !p.IsAbsolutePath(path)
+}
+
+// ContainsSeparator returns true if name contains p's separator or PathSeparator, either of which
+// would make it impossible to name an entry called name in a path
+func (p *Parser) ContainsSeparator(name string) bool {
+	return strings.ContainsRune(name, p.Separator) || strings.Contains(name, PathSeparator)
+}
+
+// Clean is like the package-level Clean(), but for paths in p's syntax
+func (p *Parser) Clean(path string) string {
+	separator := p.separator()
+
+	// Replace sequential path separators with a single path separator
+	var builder strings.Builder
+	lastRuneWasSeparator := false
+	for _, r := range path {
+		if r == p.Separator && lastRuneWasSeparator {
+			continue
+		}
+		lastRuneWasSeparator = p.Separator == r
+		builder.WriteRune(r)
+	}
+	path = builder.String()
+
+	// Remove self entries from the path
+	parts := strings.Split(path, separator)
+	sanitizedParts := make([]string, 0, len(parts))
+	for _, part := range parts {
+		if part != p.SelfEntry {
+			sanitizedParts = append(sanitizedParts, part)
+		}
+	}
+	path = strings.Join(sanitizedParts, separator)
+
+	// Remove leading parent entries from absolute paths
+	rootParent := separator + p.ParentEntry
+	for strings.HasPrefix(path, rootParent+separator) {
+		path = separator + strings.TrimPrefix(path, rootParent+separator)
+	}
+	if path == rootParent {
+		path = separator
+	}
+
+	return path
+}
+
+// CleanFull is like the package-level CleanFull(), but for paths in p's syntax
+func (p *Parser) CleanFull(path string) string {
+	separator := p.separator()
+	rooted := p.IsAbsolutePath(path)
+	elements := []string{}
+	for _, part := range strings.Split(path, separator) {
+		switch {
+		case part == "" || part == p.SelfEntry:
+			continue
+		case part != p.ParentEntry:
+			elements = append(elements, part)
+		case len(elements) > 0 && elements[len(elements)-1] != p.ParentEntry:
+			elements = elements[:len(elements)-1]
+		case !rooted:
+			// A relative path can't resolve a leading parent entry, so it must be kept
+			elements = append(elements, part)
+		}
+	}
+	cleanPath := strings.Join(elements, separator)
+	if rooted {
+		return separator + cleanPath
+	}
+	if cleanPath == "" {
+		return p.SelfEntry
+	}
+	return cleanPath
+}
+
+// Join is like the package-level Join(), but for paths in p's syntax
+func (p *Parser) Join(parts ...string) string {
+	return p.Clean(strings.Join(parts, p.separator()))
+}
+
+// ParsePath is like the package-level ParsePath(), but for paths in p's syntax.  The fields of the
+// returned PathInfo are in p's syntax, too.
+func (p *Parser) ParsePath(path string) *PathInfo {
+	separator := p.separator()
+	// Clean the path for convenience
+	cleanPath := p.Clean(path)
+	// interpret "" as a reference to the current directory
+	if cleanPath == "" {
+		return &PathInfo{
+			Entry:      p.SelfEntry,
+			ParentPath: p.SelfEntry,
+			MustBeDir:  true,
+			IsRelative: true,
+		}
+	}
+	// special case: the root directory
+	if cleanPath == separator {
+		return &PathInfo{
+			Entry:      p.SelfEntry,
+			ParentPath: cleanPath,
+			MustBeDir:  true,
+			IsRelative: false,
+		}
+	}
+	isRelative := p.IsRelativePath(cleanPath)
+	mustBeDir := strings.HasSuffix(cleanPath, separator)
+	if mustBeDir {
+		cleanPath = cleanPath[0 : len(cleanPath)-len(separator)]
+	}
+	parentPath, entry, found := utils.RightCut(cleanPath, separator)
+	if !found {
+		// There was no path separator, so this is a relative path and the whole path is entry name
+		parentPath = p.SelfEntry
+	}
+	return &PathInfo{
+		Entry:      entry,
+		ParentPath: parentPath,
+		MustBeDir:  mustBeDir,
+		IsRelative: isRelative,
+	}
+}
+
+// CheckPath returns EINVAL if ToSlash() can't convert path to the default syntax without changing
+// its meaning.  Unless p is DefaultParser, no part of path may contain a '/', which would separate
+// parts in the default syntax, and no part may be '.' or '..' unless p uses that name for the same
+// entry, since it would name a directory's self or parent entry in the default syntax.
+func (p *Parser) CheckPath(path string) error {
+	if p.isDefault() {
+		return nil
+	}
+	for _, part := range strings.Split(path, p.separator()) {
+		if p.Separator != PathSeparatorRune && strings.Contains(part, PathSeparator) {
+			return errors.Wrapf(fserrors.EInval, "path '%s' has a part containing '%s'", path, PathSeparator)
+		}
+		if (part == SelfDirectoryEntry && p.SelfEntry != SelfDirectoryEntry) ||
+			(part == ParentDirectoryEntry && p.ParentEntry != ParentDirectoryEntry) {
+			return errors.Wrapf(fserrors.EInval, "path '%s' has a part named '%s'", path, part)
+		}
+	}
+	return nil
+}
+
+// ToSlash converts path from p's syntax to the default syntax, replacing each separator with '/'
+// and each self or parent entry with '.' or '..'.  path should be checked with CheckPath() first,
+// since a path that fails the check doesn't keep its meaning.  ToSlash is idempotent, and it returns
+// path unchanged if p is DefaultParser.
+func (p *Parser) ToSlash(path string) string {
+	if p.isDefault() {
+		return path
+	}
+	parts := strings.Split(path, p.separator())
+	for idx, part := range parts {
+		switch part {
+		case p.SelfEntry:
+			parts[idx] = SelfDirectoryEntry
+		case p.ParentEntry:
+			parts[idx] = ParentDirectoryEntry
+		}
+	}
+	return strings.Join(parts, PathSeparator)
+}
+
+// FromSlash converts path from the default syntax to p's syntax.  It is the inverse of ToSlash()
+// for any path whose entry names don't contain p's separator.
+func (p *Parser) FromSlash(path string) string {
+	if p.isDefault() {
+		return path
+	}
+	parts := strings.Split(path, PathSeparator)
+	for idx, part := range parts {
+		switch part {
+		case SelfDirectoryEntry:
+			parts[idx] = p.SelfEntry
+		case ParentDirectoryEntry:
+			parts[idx] = p.ParentEntry
+		}
+	}
+	return strings.Join(parts, p.separator())
+}
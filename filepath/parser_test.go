@@ -0,0 +1,88 @@
+package filepath_test
+
+import (
+	"testing"
+
+	"github.com/manderson5192/memfs/filepath"
+	"github.com/manderson5192/memfs/fserrors"
+	"github.com/stretchr/testify/assert"
+)
+
+var backslashParser = filepath.NewParser('\\', ".", "..")
+
+func TestDefaultParser(t *testing.T) {
+	for _, path := range []string{"", "/", "a/b/", "/../a/./b", "a/../b", "//a//b"} {
+		assert.Equal(t, filepath.Clean(path), filepath.DefaultParser.Clean(path))
+		assert.Equal(t, filepath.CleanFull(path), filepath.DefaultParser.CleanFull(path))
+		assert.Equal(t, filepath.ParsePath(path), filepath.DefaultParser.ParsePath(path))
+		assert.Equal(t, path, filepath.DefaultParser.ToSlash(path))
+		assert.Equal(t, path, filepath.DefaultParser.FromSlash(path))
+	}
+}
+
+func TestParserJoinAndClean(t *testing.T) {
+	p := backslashParser
+	assert.True(t, p.IsAbsolutePath(`\a`))
+	assert.True(t, p.IsRelativePath(`a\b`))
+	assert.True(t, p.IsRelativePath(`/a`))
+	assert.Equal(t, `\`, p.Join(`\`))
+	assert.Equal(t, `foo\bar`, p.Join("foo", "bar"))
+	assert.Equal(t, `\foo\bar\`, p.Join(`\foo`, `bar\`))
+	assert.Equal(t, `a\b`, p.Join(`a\`, ".", "b"))
+	assert.Equal(t, `\a\b`, p.Join(`\..\..\a\b`))
+	assert.Equal(t, `\`, p.Join(`\`, ".."))
+	assert.Equal(t, `\foo\bar\..\fizz\buzz\`, p.Join(`\\\foo\\\\\`, `\\bar`, `..\fizz\\\.\\\buzz\`))
+	assert.Equal(t, `\a\c`, p.CleanFull(`\a\b\..\.\c\`))
+	assert.Equal(t, `..\c`, p.CleanFull(`a\..\..\c`))
+	assert.Equal(t, ".", p.CleanFull(`a\..`))
+}
+
+func TestParserParsePath(t *testing.T) {
+	p := backslashParser
+	assert.Equal(t, &filepath.PathInfo{
+		Entry:      ".",
+		ParentPath: `\`,
+		MustBeDir:  true,
+		IsRelative: false,
+	}, p.ParsePath(`\\`))
+	assert.Equal(t, &filepath.PathInfo{
+		Entry:      "c",
+		ParentPath: `\a\b`,
+		MustBeDir:  true,
+		IsRelative: false,
+	}, p.ParsePath(`\a\.\b\c\`))
+	assert.Equal(t, &filepath.PathInfo{
+		Entry:      "file.txt",
+		ParentPath: ".",
+		MustBeDir:  false,
+		IsRelative: true,
+	}, p.ParsePath("file.txt"))
+}
+
+func TestParserSlashConversion(t *testing.T) {
+	p := filepath.NewParser('\\', "@", "^")
+	assert.Equal(t, "/a/../b/./c", p.ToSlash(`\a\^\b\@\c`))
+	assert.Equal(t, `\a\^\b\@\c`, p.FromSlash("/a/../b/./c"))
+	// Converting a path twice doesn't change it
+	assert.Equal(t, "/a/../b", p.ToSlash(p.ToSlash(`\a\^\b`)))
+
+	assert.True(t, p.ContainsSeparator(`a\b`))
+	assert.True(t, p.ContainsSeparator("a/b"))
+	assert.False(t, p.ContainsSeparator("a.b"))
+	assert.False(t, filepath.DefaultParser.ContainsSeparator(`a\b`))
+}
+
+func TestParserCheckPath(t *testing.T) {
+	p := filepath.NewParser(':', "@", "^")
+	assert.Nil(t, p.CheckPath(":a:@:^:b"))
+	assert.Nil(t, p.CheckPath("a.b:..c"))
+	// A '/' or a default self or parent entry would change the path's meaning in the default syntax
+	assert.ErrorIs(t, p.CheckPath(":a/b"), fserrors.EInval)
+	assert.ErrorIs(t, p.CheckPath("a:.:b"), fserrors.EInval)
+	assert.ErrorIs(t, p.CheckPath("a:..:b"), fserrors.EInval)
+
+	// A Parser that uses the default self and parent entries only rejects '/'
+	assert.Nil(t, backslashParser.CheckPath(`\a\.\..`))
+	assert.ErrorIs(t, backslashParser.CheckPath(`\a/b`), fserrors.EInval)
+	assert.Nil(t, filepath.DefaultParser.CheckPath("/a/./../b"))
+}
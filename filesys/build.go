@@ -0,0 +1,95 @@
+package filesys
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/manderson5192/memfs/filepath"
+	"github.com/manderson5192/memfs/fserrors"
+	"github.com/pkg/errors"
+)
+
+// Build creates a new FileSystem (like NewFileSystem()) whose tree is described by spec, like an
+// in-memory analog of testing/fstest.MapFS.  Each key of spec is a path, which is resolved against
+// the root directory whether or not it begins with a path separator.  A key that ends with a path
+// separator creates a directory (and its value is ignored), and any other key creates a file whose
+// contents are its value.  The intermediate directories of every path are created automatically.
+//
+// Build returns EINVAL, and no FileSystem, if spec is inconsistent: if a path is used as both a
+// file and a directory (including as the ancestor of another path), or if two keys refer to the
+// same file with different contents.
+func Build(spec map[string]string) (FileSystem, error) {
+	dirs, files, err := parseBuildSpec(spec)
+	if err != nil {
+		return nil, err
+	}
+	fs := NewFileSystem()
+	root := fs.RootDirectory()
+	// Sorting guarantees that every directory is created after its parent
+	sort.Strings(dirs)
+	for _, dir := range dirs {
+		if _, err := root.Mkdir(dir); err != nil {
+			return nil, errors.Wrapf(err, "could not create directory '/%s'", dir)
+		}
+	}
+	for path, contents := range files {
+		f, err := root.CreateFile(path)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not create file '/%s'", path)
+		}
+		if err := f.TruncateAndWriteAll([]byte(contents)); err != nil {
+			return nil, errors.Wrapf(err, "could not write file '/%s'", path)
+		}
+	}
+	return fs, nil
+}
+
+// parseBuildSpec validates spec and returns the relative paths of the directories it describes
+// (including intermediate directories) and a map from the relative path of each file it describes
+// to the file's contents
+func parseBuildSpec(spec map[string]string) ([]string, map[string]string, error) {
+	isDir := map[string]bool{}
+	files := map[string]string{}
+	// record notes that path is a directory or a file, returning an error if that contradicts a
+	// previous record
+	record := func(key, path string, dir bool) error {
+		if previous, exists := isDir[path]; exists && previous != dir {
+			return errors.Wrapf(fserrors.EInval, "spec key '%s': '/%s' is used as both a file and a directory", key, path)
+		}
+		isDir[path] = dir
+		return nil
+	}
+	for key, contents := range spec {
+		dir := strings.HasSuffix(key, filepath.PathSeparator)
+		path := strings.TrimPrefix(filepath.CleanFull(filepath.PathSeparator+key), filepath.PathSeparator)
+		if path == "" {
+			if !dir {
+				return nil, nil, errors.Wrapf(fserrors.EInval, "spec key '%s' refers to the root directory, which cannot be a file", key)
+			}
+			continue
+		}
+		if err := record(key, path, dir); err != nil {
+			return nil, nil, err
+		}
+		parts := strings.Split(path, filepath.PathSeparator)
+		for i := 1; i < len(parts); i++ {
+			if err := record(key, strings.Join(parts[:i], filepath.PathSeparator), true); err != nil {
+				return nil, nil, err
+			}
+		}
+		if dir {
+			continue
+		}
+		if previous, exists := files[path]; exists && previous != contents {
+			return nil, nil, errors.Wrapf(fserrors.EInval, "spec key '%s': file '/%s' is given conflicting contents", key, path)
+		}
+		files[path] = contents
+	}
+	dirs := make([]string, 0, len(isDir))
+	for path, dir := range isDir {
+		if dir {
+			dirs = append(dirs, path)
+		}
+	}
+	return dirs, files, nil
+}
@@ -0,0 +1,93 @@
+package filesys_test
+
+import (
+	"testing"
+
+	"github.com/manderson5192/memfs/filesys"
+	"github.com/manderson5192/memfs/fserrors"
+	"github.com/manderson5192/memfs/os"
+	"github.com/manderson5192/memfs/process"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type BuildTestSuite struct {
+	suite.Suite
+}
+
+func (s *BuildTestSuite) assertContents(p process.ProcessFilesystemContext, path, expected string) {
+	f, err := p.OpenFile(path, os.O_RDONLY)
+	assert.Nil(s.T(), err)
+	if err != nil {
+		return
+	}
+	data, err := f.ReadAll()
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), expected, string(data))
+}
+
+func (s *BuildTestSuite) TestBuild() {
+	fs, err := filesys.Build(map[string]string{
+		"/a/b/":           "ignored",
+		"/a/foobar_file":  "hello!",
+		"a/b/c/deep_file": "deep",
+		"/empty_dir/":     "",
+		"top_file":        "",
+	})
+	assert.Nil(s.T(), err)
+	p := process.NewProcessFilesystemContext(fs)
+	assert.Equal(s.T(), []string{
+		"/",
+		"/a",
+		"/a/b",
+		"/a/b/c",
+		"/a/b/c/deep_file",
+		"/a/foobar_file",
+		"/empty_dir",
+		"/top_file",
+	}, walkPaths(s.T(), p))
+	s.assertContents(p, "/a/foobar_file", "hello!")
+	s.assertContents(p, "/a/b/c/deep_file", "deep")
+	s.assertContents(p, "/top_file", "")
+}
+
+func (s *BuildTestSuite) TestBuildEmpty() {
+	fs, err := filesys.Build(map[string]string{})
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []string{"/"}, walkPaths(s.T(), process.NewProcessFilesystemContext(fs)))
+
+	fs, err = filesys.Build(map[string]string{"/": ""})
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []string{"/"}, walkPaths(s.T(), process.NewProcessFilesystemContext(fs)))
+}
+
+func (s *BuildTestSuite) TestBuildEquivalentKeys() {
+	fs, err := filesys.Build(map[string]string{
+		"/a/file":    "same",
+		"a//./file":  "same",
+		"/a/b/../c/": "",
+	})
+	assert.Nil(s.T(), err)
+	p := process.NewProcessFilesystemContext(fs)
+	assert.Equal(s.T(), []string{"/", "/a", "/a/c", "/a/file"}, walkPaths(s.T(), p))
+	s.assertContents(p, "/a/file", "same")
+}
+
+func (s *BuildTestSuite) TestBuildConflicts() {
+	specs := []map[string]string{
+		{"/a": "file", "/a/": ""},
+		{"/a": "file", "/a/b": "file"},
+		{"/a/b/": "", "/a": "file"},
+		{"/a/file": "one", "a/file": "two"},
+		{"": "the root can't be a file"},
+	}
+	for _, spec := range specs {
+		fs, err := filesys.Build(spec)
+		assert.ErrorIs(s.T(), err, fserrors.EInval, "spec %v", spec)
+		assert.Nil(s.T(), fs)
+	}
+}
+
+func TestBuildTestSuite(t *testing.T) {
+	suite.Run(t, new(BuildTestSuite))
+}
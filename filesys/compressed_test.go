@@ -0,0 +1,127 @@
+package filesys_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io"
+	"testing"
+
+	"github.com/manderson5192/memfs/filesys"
+	"github.com/manderson5192/memfs/os"
+	"github.com/manderson5192/memfs/process"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type CompressedTestSuite struct {
+	suite.Suite
+	fs filesys.FileSystem
+	p  process.ProcessFilesystemContext
+}
+
+func (s *CompressedTestSuite) SetupTest() {
+	s.fs = filesys.NewFileSystemCompressed()
+	s.p = process.NewProcessFilesystemContext(s.fs)
+}
+
+func (s *CompressedTestSuite) TestCompressibleDataIsSmaller() {
+	data := bytes.Repeat([]byte("all work and no play makes jack a dull boy\n"), 1000)
+	assert.Nil(s.T(), s.p.WriteFile("/text", data, 0))
+	f, err := s.p.OpenFile("/text", os.O_RDONLY)
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), len(data), f.Size())
+	assert.Equal(s.T(), len(data), f.AllocatedSize())
+	assert.Less(s.T(), f.CompressedSize(), len(data)/10)
+
+	readBack, err := s.p.ReadFile("/text")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), data, readBack)
+	sum := sha256.Sum256(data)
+	checksum, err := s.p.Checksum("/text")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), sum[:], checksum)
+}
+
+func (s *CompressedTestSuite) TestReadsAndWritesMatchUncompressedFile() {
+	plainP := process.NewProcessFilesystemContext(filesys.NewFileSystem())
+	for _, p := range []process.ProcessFilesystemContext{s.p, plainP} {
+		f, err := p.CreateFile("/file")
+		assert.Nil(s.T(), err)
+		_, err = f.Write([]byte("hello, world"))
+		assert.Nil(s.T(), err)
+		_, err = f.WriteAt([]byte("HELLO"), 0)
+		assert.Nil(s.T(), err)
+		// Writing beyond the end pads with zero bytes
+		_, err = f.WriteAt([]byte("!"), 20)
+		assert.Nil(s.T(), err)
+		appender, err := p.OpenFile("/file", os.O_WRONLY|os.O_APPEND)
+		assert.Nil(s.T(), err)
+		_, err = appender.Write([]byte(" appended"))
+		assert.Nil(s.T(), err)
+	}
+	compressed, err := s.p.OpenFile("/file", os.O_RDONLY)
+	assert.Nil(s.T(), err)
+	plain, err := plainP.OpenFile("/file", os.O_RDONLY)
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), plain.Size(), compressed.Size())
+	for _, off := range []int64{0, 7, 18, 25} {
+		plainBuf := make([]byte, 8)
+		plainN, plainErr := plain.ReadAt(plainBuf, off)
+		compressedBuf := make([]byte, 8)
+		compressedN, compressedErr := compressed.ReadAt(compressedBuf, off)
+		assert.Equal(s.T(), plainN, compressedN)
+		assert.Equal(s.T(), plainErr, compressedErr)
+		assert.Equal(s.T(), plainBuf, compressedBuf)
+	}
+	_, err = compressed.ReadAt(make([]byte, 1), int64(compressed.Size()))
+	assert.Equal(s.T(), io.EOF, err)
+
+	data, err := s.p.ReadFile("/file")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), "HELLO, world\x00\x00\x00\x00\x00\x00\x00\x00! appended", string(data))
+}
+
+func (s *CompressedTestSuite) TestQuotaCountsLogicalBytes() {
+	fs := filesys.NewFileSystemWithOptions(filesys.Options{Compressed: true, HasQuota: true, QuotaBytes: 100})
+	p := process.NewProcessFilesystemContext(fs)
+	assert.Nil(s.T(), p.WriteFile("/zeroes", make([]byte, 100), 0))
+	assert.NotNil(s.T(), p.WriteFile("/more", []byte("x"), 0))
+}
+
+func (s *CompressedTestSuite) TestSnapshotForkPreservesCompression() {
+	data := bytes.Repeat([]byte("z"), 4096)
+	assert.Nil(s.T(), s.p.WriteFile("/file", data, 0))
+	snap, err := filesys.TakeSnapshot(s.fs)
+	assert.Nil(s.T(), err)
+	forkP := process.NewProcessFilesystemContext(snap.Fork())
+	f, err := forkP.OpenFile("/file", os.O_RDONLY)
+	assert.Nil(s.T(), err)
+	assert.Less(s.T(), f.CompressedSize(), len(data))
+	readBack, err := f.ReadAll()
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), data, readBack)
+
+	// New files in the fork are compressed too
+	assert.Nil(s.T(), forkP.WriteFile("/new", data, 0))
+	f, err = forkP.OpenFile("/new", os.O_RDONLY)
+	assert.Nil(s.T(), err)
+	assert.Less(s.T(), f.CompressedSize(), len(data))
+}
+
+func (s *CompressedTestSuite) TestRingFile() {
+	f, err := s.p.OpenRingFile("/log", 8)
+	assert.Nil(s.T(), err)
+	for _, chunk := range []string{"abcdef", "ghijkl", "mnopqr"} {
+		_, err = f.Write([]byte(chunk))
+		assert.Nil(s.T(), err)
+	}
+	assert.Equal(s.T(), 8, f.Size())
+	assert.Equal(s.T(), 8, f.AllocatedSize())
+	data, err := s.p.ReadFile("/log")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), "klmnopqr", string(data))
+}
+
+func TestCompressedTestSuite(t *testing.T) {
+	suite.Run(t, new(CompressedTestSuite))
+}
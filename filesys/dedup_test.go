@@ -0,0 +1,164 @@
+package filesys_test
+
+import (
+	"testing"
+
+	"github.com/manderson5192/memfs/filesys"
+	"github.com/manderson5192/memfs/inode"
+	"github.com/manderson5192/memfs/os"
+	"github.com/manderson5192/memfs/process"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type DedupTestSuite struct {
+	suite.Suite
+	fs filesys.FileSystem
+	p  process.ProcessFilesystemContext
+}
+
+func (s *DedupTestSuite) SetupTest() {
+	s.fs = filesys.NewFileSystemWithDedup()
+	s.p = process.NewProcessFilesystemContext(s.fs)
+}
+
+func (s *DedupTestSuite) dedupStats() inode.DedupStats {
+	stats, err := filesys.Statfs(s.fs)
+	assert.Nil(s.T(), err)
+	assert.True(s.T(), stats.HasDedup)
+	return stats.Dedup
+}
+
+func (s *DedupTestSuite) TestIdenticalFilesShareStorageUntilWritten() {
+	contents := []byte("the same contents")
+	one, err := s.p.CreateFile("/one")
+	assert.Nil(s.T(), err)
+	assert.Nil(s.T(), one.TruncateAndWriteAll(contents))
+	two, err := s.p.CreateFile("/two")
+	assert.Nil(s.T(), err)
+	assert.Nil(s.T(), two.TruncateAndWriteAll([]byte("the same contents")))
+	assert.Equal(s.T(), inode.DedupStats{
+		Hits:          1,
+		SharedBuffers: 1,
+		SavedBytes:    int64(len(contents)),
+	}, s.dedupStats())
+
+	// Mutating the caller's buffer doesn't affect either file
+	contents[0] = 'T'
+	data, err := one.ReadAll()
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), "the same contents", string(data))
+
+	// Writing to one file gives it a private copy and leaves the other file unaffected
+	_, err = two.WriteAt([]byte("THE"), 0)
+	assert.Nil(s.T(), err)
+	data, err = two.ReadAll()
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), "THE same contents", string(data))
+	data, err = one.ReadAll()
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), "the same contents", string(data))
+	assert.Equal(s.T(), inode.DedupStats{Hits: 1}, s.dedupStats())
+}
+
+func (s *DedupTestSuite) TestFilesWrittenPieceByPieceShareStorageOnClose() {
+	for _, path := range []string{"/one", "/two"} {
+		f, err := s.p.CreateFile(path)
+		assert.Nil(s.T(), err)
+		for _, piece := range []string{"the same ", "contents"} {
+			_, err := f.Write([]byte(piece))
+			assert.Nil(s.T(), err)
+		}
+		assert.Equal(s.T(), 0, s.dedupStats().SharedBuffers)
+		assert.Nil(s.T(), f.Close())
+	}
+	assert.Equal(s.T(), inode.DedupStats{
+		Hits:          1,
+		SharedBuffers: 1,
+		SavedBytes:    int64(len("the same contents")),
+	}, s.dedupStats())
+
+	// Closing a file again, or closing a read-only handle, doesn't count another hit
+	f, err := s.p.OpenFile("/one", os.O_RDWR)
+	assert.Nil(s.T(), err)
+	assert.Nil(s.T(), f.Close())
+	f, err = s.p.OpenFile("/two", os.O_RDONLY)
+	assert.Nil(s.T(), err)
+	assert.Nil(s.T(), f.Close())
+	assert.Equal(s.T(), int64(1), s.dedupStats().Hits)
+}
+
+func (s *DedupTestSuite) TestWriteFileAndWriteFileAtomicShareStorage() {
+	assert.Nil(s.T(), s.p.WriteFile("/one", []byte("the same contents"), 0))
+	assert.Nil(s.T(), s.p.WriteFileAtomic("/two", []byte("the same contents")))
+	assert.Nil(s.T(), s.p.WriteFile("/three", []byte("the same contents"), 0))
+	assert.Equal(s.T(), inode.DedupStats{
+		Hits:          2,
+		SharedBuffers: 1,
+		SavedBytes:    2 * int64(len("the same contents")),
+	}, s.dedupStats())
+}
+
+func (s *DedupTestSuite) TestManyIdenticalFiles() {
+	for _, path := range []string{"/a", "/b", "/c", "/d"} {
+		f, err := s.p.CreateFile(path)
+		assert.Nil(s.T(), err)
+		assert.Nil(s.T(), f.TruncateAndWriteAll([]byte("12345")))
+	}
+	assert.Equal(s.T(), inode.DedupStats{Hits: 3, SharedBuffers: 1, SavedBytes: 15}, s.dedupStats())
+
+	// Replacing a file's contents releases its share of the old buffer
+	f, err := s.p.OpenFile("/a", os.O_RDWR)
+	assert.Nil(s.T(), err)
+	assert.Nil(s.T(), f.TruncateAndWriteAll([]byte("different")))
+	assert.Equal(s.T(), inode.DedupStats{Hits: 3, SharedBuffers: 1, SavedBytes: 10}, s.dedupStats())
+
+	// Truncating on open is a write too
+	_, err = s.p.OpenFile("/b", os.O_RDWR|os.O_TRUNC)
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), inode.DedupStats{Hits: 3, SharedBuffers: 1, SavedBytes: 5}, s.dedupStats())
+	for _, path := range []string{"/c", "/d"} {
+		f, err := s.p.OpenFile(path, os.O_RDONLY)
+		assert.Nil(s.T(), err)
+		data, err := f.ReadAll()
+		assert.Nil(s.T(), err)
+		assert.Equal(s.T(), "12345", string(data))
+	}
+}
+
+func (s *DedupTestSuite) TestDeletedFilesReleaseStorage() {
+	contents := make([]byte, 1<<20)
+	paths := []string{"/a", "/b", "/c"}
+	for _, path := range paths {
+		f, err := s.p.CreateFile(path)
+		assert.Nil(s.T(), err)
+		assert.Nil(s.T(), f.TruncateAndWriteAll(contents))
+	}
+	assert.Equal(s.T(), inode.DedupStats{Hits: 2, SharedBuffers: 1, SavedBytes: 2 << 20}, s.dedupStats())
+
+	// A deleted file that is still open stops sharing, but remains readable and writable
+	f, err := s.p.OpenFile("/a", os.O_RDWR)
+	assert.Nil(s.T(), err)
+	assert.Nil(s.T(), s.p.DeleteFile("/a"))
+	assert.Equal(s.T(), inode.DedupStats{Hits: 2, SharedBuffers: 1, SavedBytes: 1 << 20}, s.dedupStats())
+	_, err = f.WriteAt([]byte("x"), 0)
+	assert.Nil(s.T(), err)
+	data, err := s.p.ReadFile("/b")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), contents, data)
+
+	for _, path := range paths[1:] {
+		assert.Nil(s.T(), s.p.DeleteFile(path))
+	}
+	assert.Equal(s.T(), inode.DedupStats{Hits: 2}, s.dedupStats())
+}
+
+func (s *DedupTestSuite) TestWithoutDedup() {
+	stats, err := filesys.Statfs(filesys.NewFileSystem())
+	assert.Nil(s.T(), err)
+	assert.False(s.T(), stats.HasDedup)
+}
+
+func TestDedupTestSuite(t *testing.T) {
+	suite.Run(t, new(DedupTestSuite))
+}
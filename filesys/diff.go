@@ -0,0 +1,168 @@
+package filesys
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/manderson5192/memfs/directory"
+	"github.com/manderson5192/memfs/filepath"
+	"github.com/manderson5192/memfs/os"
+	"github.com/pkg/errors"
+)
+
+// DiffKind is an enum that indicates how two FileSystems differ at a path
+type DiffKind int
+
+const (
+	// MissingLeft indicates that the path exists in the right FileSystem but not the left one
+	MissingLeft DiffKind = iota + 1
+	// MissingRight indicates that the path exists in the left FileSystem but not the right one
+	MissingRight
+	// TypeMismatch indicates that the path is a file in one FileSystem and a directory in the other
+	TypeMismatch
+	// ContentMismatch indicates that the path is a file in both FileSystems, but with different
+	// contents
+	ContentMismatch
+)
+
+func (k DiffKind) String() string {
+	switch k {
+	case MissingLeft:
+		return "MissingLeft"
+	case MissingRight:
+		return "MissingRight"
+	case TypeMismatch:
+		return "TypeMismatch"
+	case ContentMismatch:
+		return "ContentMismatch"
+	default:
+		return "DiffKindInvalid"
+	}
+}
+
+// DiffEntry describes a single difference between two FileSystems
+type DiffEntry struct {
+	// Path is the absolute path at which the FileSystems differ, in the default syntax (see
+	// filepath.Parser) regardless of the syntax of either FileSystem
+	Path string
+	Kind DiffKind
+}
+
+// Diff walks the directory trees of left and right and returns their differences, sorted by path.
+// When a directory exists in only one of the FileSystems, or is a directory in one and a file in the
+// other, only the directory's own path is reported, not the paths of its descendants.  Files are
+// compared by contents alone, so two FileSystems with no differences yield an empty slice.
+//
+// Diff does not lock either FileSystem, so the result is only meaningful if neither is modified
+// while it runs.
+func Diff(left, right FileSystem) ([]DiffEntry, error) {
+	differ := &differ{
+		left:  left.RootDirectory(),
+		right: right.RootDirectory(),
+		diffs: []DiffEntry{},
+	}
+	if err := differ.diffDirectory(""); err != nil {
+		return nil, err
+	}
+	// Entries are visited in sorted order, but a depth-first traversal doesn't quite yield paths in
+	// sorted order (e.g. "/a/b" is visited before "/a-b")
+	sort.Slice(differ.diffs, func(i, j int) bool {
+		return differ.diffs[i].Path < differ.diffs[j].Path
+	})
+	return differ.diffs, nil
+}
+
+// differ accumulates the differences between the directory trees beneath left and right
+type differ struct {
+	left  directory.Directory
+	right directory.Directory
+	diffs []DiffEntry
+}
+
+// diffDirectory compares the directory at relativePath, which exists in both trees, by merging
+// their sorted lists of entries
+func (d *differ) diffDirectory(relativePath string) error {
+	leftEntries, err := d.left.ReadDirSorted(nativePath(d.left, relativePath))
+	if err != nil {
+		return errors.Wrapf(err, "could not list left directory '/%s'", relativePath)
+	}
+	rightEntries, err := d.right.ReadDirSorted(nativePath(d.right, relativePath))
+	if err != nil {
+		return errors.Wrapf(err, "could not list right directory '/%s'", relativePath)
+	}
+	leftIdx, rightIdx := 0, 0
+	for leftIdx < len(leftEntries) || rightIdx < len(rightEntries) {
+		switch {
+		case rightIdx == len(rightEntries) ||
+			(leftIdx < len(leftEntries) && leftEntries[leftIdx].Name < rightEntries[rightIdx].Name):
+			d.record(childPath(relativePath, leftEntries[leftIdx].Name), MissingRight)
+			leftIdx++
+		case leftIdx == len(leftEntries) || rightEntries[rightIdx].Name < leftEntries[leftIdx].Name:
+			d.record(childPath(relativePath, rightEntries[rightIdx].Name), MissingLeft)
+			rightIdx++
+		default:
+			if err := d.diffEntry(relativePath, leftEntries[leftIdx], rightEntries[rightIdx]); err != nil {
+				return err
+			}
+			leftIdx++
+			rightIdx++
+		}
+	}
+	return nil
+}
+
+// diffEntry compares left and right, which are entries with the same name in the directory at
+// parentPath
+func (d *differ) diffEntry(parentPath string, left, right directory.DirectoryEntry) error {
+	relativePath := childPath(parentPath, left.Name)
+	switch {
+	case left.Type != right.Type:
+		d.record(relativePath, TypeMismatch)
+		return nil
+	case left.Type == directory.DirectoryType:
+		return d.diffDirectory(relativePath)
+	}
+	leftData, err := readAll(d.left, relativePath)
+	if err != nil {
+		return errors.Wrapf(err, "could not read left file '/%s'", relativePath)
+	}
+	rightData, err := readAll(d.right, relativePath)
+	if err != nil {
+		return errors.Wrapf(err, "could not read right file '/%s'", relativePath)
+	}
+	if !bytes.Equal(leftData, rightData) {
+		d.record(relativePath, ContentMismatch)
+	}
+	return nil
+}
+
+func (d *differ) record(relativePath string, kind DiffKind) {
+	d.diffs = append(d.diffs, DiffEntry{
+		Path: filepath.PathSeparator + relativePath,
+		Kind: kind,
+	})
+}
+
+// childPath returns the relative path, in the default syntax, of the entry name in the directory at
+// relativePath.  (Unlike filepath.Join(), it doesn't turn a child of "" into an absolute path.)
+func childPath(relativePath, name string) string {
+	if relativePath == "" {
+		return name
+	}
+	return relativePath + filepath.PathSeparator + name
+}
+
+// nativePath converts relativePath from the default syntax to dir's own (see
+// directory.Directory.Parser()), so that it can be passed to dir's methods
+func nativePath(dir directory.Directory, relativePath string) string {
+	return dir.Parser().FromSlash(relativePath)
+}
+
+// readAll returns the contents of the file at relativePath, in the default syntax, beneath dir
+func readAll(dir directory.Directory, relativePath string) ([]byte, error) {
+	f, err := dir.OpenFile(nativePath(dir, relativePath), os.O_RDONLY)
+	if err != nil {
+		return nil, err
+	}
+	return f.ReadAll()
+}
@@ -0,0 +1,86 @@
+package filesys_test
+
+import (
+	"testing"
+
+	"github.com/manderson5192/memfs/filepath"
+	"github.com/manderson5192/memfs/filesys"
+	"github.com/manderson5192/memfs/process"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type DiffTestSuite struct {
+	suite.Suite
+	left   filesys.FileSystem
+	right  filesys.FileSystem
+	leftP  process.ProcessFilesystemContext
+	rightP process.ProcessFilesystemContext
+}
+
+func (s *DiffTestSuite) SetupTest() {
+	s.left = filesys.NewFileSystem()
+	s.right = filesys.NewFileSystem()
+	s.leftP = process.NewProcessFilesystemContext(s.left)
+	s.rightP = process.NewProcessFilesystemContext(s.right)
+	for _, p := range []process.ProcessFilesystemContext{s.leftP, s.rightP} {
+		assert.Nil(s.T(), p.MakeDirectoryWithAncestors("/a/b"))
+		assert.Nil(s.T(), p.MakeDirectory("/a-b"))
+		assert.Nil(s.T(), p.WriteFile("/a/file", []byte("same"), 0))
+		assert.Nil(s.T(), p.WriteFile("/a/b/file", []byte("same"), 0))
+	}
+}
+
+func (s *DiffTestSuite) TestIdenticalTrees() {
+	diffs, err := filesys.Diff(s.left, s.right)
+	assert.Nil(s.T(), err)
+	assert.Empty(s.T(), diffs)
+}
+
+func (s *DiffTestSuite) TestDifferences() {
+	// Added on the right
+	assert.Nil(s.T(), s.rightP.WriteFile("/a/b/added", []byte("added"), 0))
+	// Removed from the right
+	assert.Nil(s.T(), s.rightP.DeleteFile("/a/file"))
+	// Changed contents
+	assert.Nil(s.T(), s.rightP.WriteFile("/a/b/file", []byte("different"), 0))
+	// Changed type
+	assert.Nil(s.T(), s.leftP.WriteFile("/a-b/entry", []byte("file"), 0))
+	assert.Nil(s.T(), s.rightP.MakeDirectory("/a-b/entry"))
+	// A subtree that exists only on the left is reported once
+	assert.Nil(s.T(), s.leftP.MakeDirectoryWithAncestors("/left_only/x/y"))
+
+	diffs, err := filesys.Diff(s.left, s.right)
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []filesys.DiffEntry{
+		{Path: "/a-b/entry", Kind: filesys.TypeMismatch},
+		{Path: "/a/b/added", Kind: filesys.MissingLeft},
+		{Path: "/a/b/file", Kind: filesys.ContentMismatch},
+		{Path: "/a/file", Kind: filesys.MissingRight},
+		{Path: "/left_only", Kind: filesys.MissingRight},
+	}, diffs)
+}
+
+func (s *DiffTestSuite) TestNonDefaultParser() {
+	left := filesys.NewFileSystemWithParser(filepath.NewParser('\\', "@", "^"))
+	leftP := process.NewProcessFilesystemContext(left)
+	assert.Nil(s.T(), leftP.MakeDirectoryWithAncestors(`\a\b`))
+	assert.Nil(s.T(), leftP.MakeDirectory(`\a-b`))
+	assert.Nil(s.T(), leftP.WriteFile(`\a\file`, []byte("same"), 0))
+	assert.Nil(s.T(), leftP.WriteFile(`\a\b\file`, []byte("different"), 0))
+
+	diffs, err := filesys.Diff(left, s.right)
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []filesys.DiffEntry{
+		{Path: "/a/b/file", Kind: filesys.ContentMismatch},
+	}, diffs)
+}
+
+func (s *DiffTestSuite) TestDiffKindString() {
+	assert.Equal(s.T(), "MissingLeft", filesys.MissingLeft.String())
+	assert.Equal(s.T(), "ContentMismatch", filesys.ContentMismatch.String())
+}
+
+func TestDiffTestSuite(t *testing.T) {
+	suite.Run(t, new(DiffTestSuite))
+}
@@ -0,0 +1,30 @@
+package filesys
+
+import "github.com/manderson5192/memfs/inode"
+
+// These aliases let clients configure fault injection without importing the inode package
+type (
+	FaultConfig = inode.FaultConfig
+	Fault       = inode.Fault
+	FaultOp     = inode.FaultOp
+)
+
+const (
+	FaultRead  = inode.FaultRead
+	FaultWrite = inode.FaultWrite
+	FaultStat  = inode.FaultStat
+	FaultOpen  = inode.FaultOpen
+	FaultMkdir = inode.FaultMkdir
+)
+
+// NewFileSystemWithFaults creates a new FileSystem whose operations fail on demand, as described by
+// config, so that tests can deterministically exercise how callers handle errors.  Each Fault makes
+// one kind of operation fail, optionally only on a specific path or beneath a path prefix, and
+// optionally only after a number of matching operations have succeeded.  Faults are checked when
+// file data is read (File.Read(), File.ReadAt()) or written (File.Write(), File.WriteAt(),
+// File.TruncateAndWriteAll()), and when files are stat'd, opened, or created, and when directories
+// are created.  If several Faults match an operation, the first one in config.Faults that is ready
+// to fail decides the error.
+func NewFileSystemWithFaults(config FaultConfig) FileSystem {
+	return NewFileSystemWithOptions(Options{Faults: config})
+}
@@ -0,0 +1,114 @@
+package filesys_test
+
+import (
+	"io"
+	"testing"
+
+	"github.com/manderson5192/memfs/filesys"
+	"github.com/manderson5192/memfs/fserrors"
+	"github.com/manderson5192/memfs/os"
+	"github.com/manderson5192/memfs/process"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type FaultsTestSuite struct {
+	suite.Suite
+}
+
+// newProcess creates a filesystem with the specified faults and the directories /a and /b
+func (s *FaultsTestSuite) newProcess(faults ...filesys.Fault) process.ProcessFilesystemContext {
+	p := process.NewProcessFilesystemContext(filesys.NewFileSystemWithFaults(filesys.FaultConfig{
+		Faults: faults,
+	}))
+	assert.Nil(s.T(), p.MakeDirectory("/a"))
+	assert.Nil(s.T(), p.MakeDirectory("/b"))
+	return p
+}
+
+func (s *FaultsTestSuite) TestWriteFaultOnMatchingPathsOnly() {
+	p := s.newProcess(filesys.Fault{
+		Op:         filesys.FaultWrite,
+		Err:        fserrors.ENoSpace,
+		PathPrefix: "/a",
+	})
+	inA, err := p.CreateFile("/a/file")
+	assert.Nil(s.T(), err)
+	inB, err := p.CreateFile("/b/file")
+	assert.Nil(s.T(), err)
+	// A sibling whose name merely starts with the prefix doesn't match
+	assert.Nil(s.T(), p.MakeDirectory("/ab"))
+	inAB, err := p.CreateFile("/ab/file")
+	assert.Nil(s.T(), err)
+
+	_, err = inA.Write([]byte("data"))
+	assert.ErrorIs(s.T(), err, fserrors.ENoSpace)
+	_, err = inA.WriteAt([]byte("data"), 10)
+	assert.ErrorIs(s.T(), err, fserrors.ENoSpace)
+	assert.ErrorIs(s.T(), inA.TruncateAndWriteAll([]byte("data")), fserrors.ENoSpace)
+	assert.Equal(s.T(), 0, inA.Size())
+
+	// Writes elsewhere, and reads of the faulty path, are unaffected
+	_, err = inB.Write([]byte("data"))
+	assert.Nil(s.T(), err)
+	_, err = inAB.Write([]byte("data"))
+	assert.Nil(s.T(), err)
+	_, err = inA.ReadAt(make([]byte, 1), 0)
+	assert.ErrorIs(s.T(), err, io.EOF)
+
+	// The fault follows the path, not the file
+	assert.Nil(s.T(), p.Rename("/a/file", "/b/moved"))
+	_, err = inA.Write([]byte("data"))
+	assert.Nil(s.T(), err)
+}
+
+func (s *FaultsTestSuite) TestFaultAfterNCalls() {
+	p := s.newProcess(filesys.Fault{
+		Op:     filesys.FaultRead,
+		Err:    fserrors.EAccess,
+		Path:   "/a/file",
+		AfterN: 2,
+	})
+	f, err := p.CreateFile("/a/file")
+	assert.Nil(s.T(), err)
+	assert.Nil(s.T(), f.TruncateAndWriteAll([]byte("data")))
+	buf := make([]byte, 4)
+	for n := 0; n < 2; n++ {
+		_, err = f.ReadAt(buf, 0)
+		assert.Nil(s.T(), err)
+	}
+	for n := 0; n < 2; n++ {
+		_, err = f.ReadAt(buf, 0)
+		assert.ErrorIs(s.T(), err, fserrors.EAccess)
+	}
+}
+
+func (s *FaultsTestSuite) TestDirectoryFaults() {
+	p := s.newProcess(
+		filesys.Fault{Op: filesys.FaultMkdir, Path: "/a/dir"},
+		filesys.Fault{Op: filesys.FaultOpen, Path: "/b/file", Err: fserrors.EAccess},
+		filesys.Fault{Op: filesys.FaultStat, Path: "/b"},
+	)
+	assert.ErrorIs(s.T(), p.MakeDirectory("/a/dir"), fserrors.EIO, "the default error is EIO")
+	assert.Nil(s.T(), p.MakeDirectory("/b/dir"))
+
+	_, err := p.CreateFile("/b/file")
+	assert.ErrorIs(s.T(), err, fserrors.EAccess)
+	_, err = p.OpenFile("/b/file", os.O_RDWR|os.O_CREATE)
+	assert.ErrorIs(s.T(), err, fserrors.EAccess)
+	_, _, err = p.CreateExclusive("/b/file")
+	assert.ErrorIs(s.T(), err, fserrors.EAccess)
+	_, err = p.CreateFile("/b/other")
+	assert.Nil(s.T(), err)
+
+	_, err = p.Stat("/b")
+	assert.ErrorIs(s.T(), err, fserrors.EIO)
+	_, err = p.Stat("/b/dir/..")
+	assert.ErrorIs(s.T(), err, fserrors.EIO)
+	_, err = p.Stat("/b/other")
+	assert.Nil(s.T(), err)
+}
+
+func TestFaultsTestSuite(t *testing.T) {
+	suite.Run(t, new(FaultsTestSuite))
+}
@@ -2,6 +2,7 @@ package filesys
 
 import (
 	"github.com/manderson5192/memfs/directory"
+	"github.com/manderson5192/memfs/filepath"
 	"github.com/manderson5192/memfs/inode"
 )
 
@@ -13,12 +14,70 @@ type FileSystem interface {
 
 type fileSystem struct {
 	rootDirectory *inode.DirectoryInode
+	superblock    *inode.Superblock
 }
 
 // NewFileSystem creates a new FileSystem instance based on an inode tree
 func NewFileSystem() FileSystem {
+	return newFileSystemWithSuperblock(inode.NewSuperblock())
+}
+
+// NewFileSystemWithQuota creates a new FileSystem that can store at most maxBytes bytes of file
+// data, summed across all of the files in the filesystem.  Writes that would exceed this quota fail
+// with fserrors.ENoSpace.  Shrinking or deleting files frees up space for subsequent writes.
+func NewFileSystemWithQuota(maxBytes int64) FileSystem {
+	return NewFileSystemWithOptions(Options{HasQuota: true, QuotaBytes: maxBytes})
+}
+
+// NewFileSystemWithDedup creates a new FileSystem that deduplicates identical file contents.
+// Whenever a file's entire contents are replaced (e.g. by File.TruncateAndWriteAll()), or a File
+// that allows writing is closed (see File.Close()), the file shares its storage with any other file
+// that has the same SHA-256 hash and contents.  Shared
+// storage is copy-on-write: a subsequent write to any one of the files gives that file a private
+// copy, leaving the others unaffected.  Statfs() reports how much storage is being saved.
+func NewFileSystemWithDedup() FileSystem {
+	return NewFileSystemWithOptions(Options{Dedup: true})
+}
+
+// NewFileSystemSparse creates a new FileSystem whose files are sparse: each file stores only the
+// extents that have been written to it, so writing at a huge offset doesn't allocate the hole that
+// precedes it.  Holes read back as zero bytes, and File.Size() reports a file's logical length while
+// File.AllocatedSize() reports the number of bytes that it actually stores.  Quotas count allocated
+// bytes.
+func NewFileSystemSparse() FileSystem {
+	return NewFileSystemWithOptions(Options{Sparse: true})
+}
+
+// NewFileSystemCompressed creates a new FileSystem whose files store their data gzip-compressed.
+// Reads and writes behave exactly as they do for uncompressed files, but each one decompresses the
+// file's data, and each write compresses it again, so compression trades speed for memory.
+// File.Size() reports a file's logical length while File.CompressedSize() reports the number of
+// bytes that it actually occupies.  Quotas count logical bytes.
+func NewFileSystemCompressed() FileSystem {
+	return NewFileSystemWithOptions(Options{Compressed: true})
+}
+
+// NewIndexedFileSystem creates a new FileSystem that maintains an index from each entry name to the
+// directories that contain an entry by that name, updating it whenever entries are created,
+// removed, or renamed.  ProcessFilesystemContext.FindAll() uses the index to find entries with a
+// lookup instead of walking the tree, trading some memory and write cost for faster searches.
+// FindAll() still walks the tree while the filesystem has bind mounts or configured faults.
+func NewIndexedFileSystem() FileSystem {
+	return NewFileSystemWithOptions(Options{Indexed: true})
+}
+
+// NewFileSystemWithParser creates a new FileSystem whose paths use p's syntax instead of the
+// default syntax, e.g. '\\' as the path separator to model Windows-style paths.  Every Directory
+// of the filesystem (and every ProcessFilesystemContext for it) accepts and reports paths in p's
+// syntax, and entry names may contain neither p's separator nor '/'.
+func NewFileSystemWithParser(p *filepath.Parser) FileSystem {
+	return NewFileSystemWithOptions(Options{Parser: p})
+}
+
+func newFileSystemWithSuperblock(sb *inode.Superblock) *fileSystem {
 	return &fileSystem{
-		rootDirectory: inode.NewRootDirectoryInode(),
+		rootDirectory: inode.NewRootDirectoryInodeWithSuperblock(sb),
+		superblock:    sb,
 	}
 }
 
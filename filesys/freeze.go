@@ -0,0 +1,33 @@
+package filesys
+
+import (
+	"github.com/manderson5192/memfs/fserrors"
+	"github.com/pkg/errors"
+)
+
+// Freeze makes fs temporarily immutable, so that a long read-only operation (such as serializing
+// the whole tree) observes a consistent view of it without copying it.  Until Unfreeze(fs) is
+// called, every operation that would mutate fs blocks rather than failing, while reads proceed
+// normally.  Freeze waits for mutations that are already in progress to finish before returning.
+//
+// fs must not be frozen again before it is unfrozen, and the goroutine that froze fs must not
+// mutate it until it is unfrozen, or it will block forever.
+func Freeze(fs FileSystem) error {
+	f, ok := fs.(*fileSystem)
+	if !ok {
+		return errors.Wrapf(fserrors.EInval, "cannot freeze a filesystem of type %T", fs)
+	}
+	f.superblock.Freeze()
+	return nil
+}
+
+// Unfreeze allows the mutations of fs that were blocked by Freeze(fs) to proceed.  It panics if fs
+// is not frozen.
+func Unfreeze(fs FileSystem) error {
+	f, ok := fs.(*fileSystem)
+	if !ok {
+		return errors.Wrapf(fserrors.EInval, "cannot unfreeze a filesystem of type %T", fs)
+	}
+	f.superblock.Unfreeze()
+	return nil
+}
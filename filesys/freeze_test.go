@@ -0,0 +1,154 @@
+package filesys_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/manderson5192/memfs/filesys"
+	"github.com/manderson5192/memfs/fserrors"
+	"github.com/manderson5192/memfs/inode"
+	"github.com/manderson5192/memfs/process"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type FreezeTestSuite struct {
+	suite.Suite
+	fs filesys.FileSystem
+	p  process.ProcessFilesystemContext
+}
+
+func (s *FreezeTestSuite) SetupTest() {
+	s.fs = filesys.NewFileSystem()
+	s.p = process.NewProcessFilesystemContext(s.fs)
+	assert.Nil(s.T(), s.p.MakeDirectory("/a"))
+	assert.Nil(s.T(), s.p.WriteFile("/a/file", []byte("hello"), 0))
+}
+
+// assertBlocked asserts that done is not closed within a short time
+func (s *FreezeTestSuite) assertBlocked(done <-chan struct{}, msg string) {
+	select {
+	case <-done:
+		s.T().Fatalf("operation completed while the filesystem was frozen: %s", msg)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+// assertCompletes asserts that done is closed soon
+func (s *FreezeTestSuite) assertCompletes(done <-chan struct{}, msg string) {
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		s.T().Fatalf("operation did not complete after the filesystem was unfrozen: %s", msg)
+	}
+}
+
+func (s *FreezeTestSuite) TestMutationsBlockUntilUnfrozen() {
+	assert.Nil(s.T(), filesys.Freeze(s.fs))
+
+	writeDone := make(chan struct{})
+	go func() {
+		defer close(writeDone)
+		assert.Nil(s.T(), s.p.WriteFile("/a/file", []byte("goodbye"), 0))
+	}()
+	mkdirDone := make(chan struct{})
+	go func() {
+		defer close(mkdirDone)
+		assert.Nil(s.T(), s.p.MakeDirectory("/b"))
+	}()
+	s.assertBlocked(writeDone, "write")
+	s.assertBlocked(mkdirDone, "mkdir")
+
+	// Reads proceed normally and don't observe the blocked mutations
+	data, err := s.p.ReadFile("/a/file")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), "hello", string(data))
+	assert.Equal(s.T(), []string{"/", "/a", "/a/file"}, walkPaths(s.T(), s.p))
+
+	assert.Nil(s.T(), filesys.Unfreeze(s.fs))
+	s.assertCompletes(writeDone, "write")
+	s.assertCompletes(mkdirDone, "mkdir")
+	data, err = s.p.ReadFile("/a/file")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), "goodbye", string(data))
+	assert.Equal(s.T(), []string{"/", "/a", "/a/file", "/b"}, walkPaths(s.T(), s.p))
+}
+
+func (s *FreezeTestSuite) TestFreezeAgainAfterUnfreeze() {
+	assert.Nil(s.T(), filesys.Freeze(s.fs))
+	assert.Nil(s.T(), filesys.Unfreeze(s.fs))
+	assert.Nil(s.T(), filesys.Freeze(s.fs))
+	renameDone := make(chan struct{})
+	go func() {
+		defer close(renameDone)
+		assert.Nil(s.T(), s.p.Rename("/a/file", "/a/renamed"))
+	}()
+	s.assertBlocked(renameDone, "rename")
+	assert.Nil(s.T(), filesys.Unfreeze(s.fs))
+	s.assertCompletes(renameDone, "rename")
+	assert.Equal(s.T(), []string{"/", "/a", "/a/renamed"}, walkPaths(s.T(), s.p))
+}
+
+func (s *FreezeTestSuite) TestUnsupportedFileSystem() {
+	overlay := filesys.NewOverlay(filesys.NewFileSystem(), filesys.NewFileSystem())
+	assert.ErrorIs(s.T(), filesys.Freeze(overlay), fserrors.EInval)
+	assert.ErrorIs(s.T(), filesys.Unfreeze(overlay), fserrors.EInval)
+}
+
+func (s *FreezeTestSuite) TestMutationCounts() {
+	before, err := filesys.Mutations(s.fs)
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), before.Started, before.Finished)
+	// Reads aren't counted
+	_, err = s.p.ReadFile("/a/file")
+	assert.Nil(s.T(), err)
+	after, err := filesys.Mutations(s.fs)
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), before, after)
+
+	// Mutations through a Directory with a MutationCounter count in both it and the total
+	var counter inode.MutationCounter
+	dir := s.fs.RootDirectory().WithMutationCounter(&counter)
+	_, err = dir.Mkdir("b")
+	assert.Nil(s.T(), err)
+	f, err := dir.CreateFile("b/file")
+	assert.Nil(s.T(), err)
+	_, err = f.Write([]byte("data"))
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), int64(3), counter.Count())
+	after, err = filesys.Mutations(s.fs)
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), before.Started+3, after.Started)
+	assert.Equal(s.T(), before.Finished+3, after.Finished)
+
+	// A mutation that is waiting for the filesystem to be unfrozen has started but not finished
+	snapshot, err := filesys.TakeSnapshot(s.fs)
+	assert.Nil(s.T(), err)
+	assert.Nil(s.T(), filesys.Freeze(s.fs))
+	frozen, err := filesys.Mutations(s.fs)
+	assert.Nil(s.T(), err)
+	writeDone := make(chan struct{})
+	go func() {
+		defer close(writeDone)
+		assert.Nil(s.T(), s.p.WriteFile("/a/file", []byte("goodbye"), 0))
+	}()
+	s.assertBlocked(writeDone, "write")
+	during, err := filesys.Mutations(s.fs)
+	assert.Nil(s.T(), err)
+	assert.Greater(s.T(), during.Started, frozen.Started)
+	assert.Equal(s.T(), frozen.Finished, during.Finished)
+	assert.Nil(s.T(), filesys.Unfreeze(s.fs))
+	s.assertCompletes(writeDone, "write")
+
+	// The goroutine that froze the filesystem can still restore a snapshot of it
+	assert.Nil(s.T(), filesys.Freeze(s.fs))
+	assert.Nil(s.T(), snapshot.RestoreFrozen(s.fs))
+	assert.Nil(s.T(), filesys.Unfreeze(s.fs))
+	data, err := s.p.ReadFile("/a/file")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []byte("hello"), data)
+}
+
+func TestFreezeTestSuite(t *testing.T) {
+	suite.Run(t, new(FreezeTestSuite))
+}
@@ -0,0 +1,16 @@
+package filesys
+
+import "github.com/manderson5192/memfs/inode"
+
+// Limits lets clients configure a filesystem's limits on names and paths without importing the
+// inode package
+type Limits = inode.Limits
+
+// NewFileSystemWithLimits creates a new FileSystem that enforces limits, modeling a real
+// filesystem's NAME_MAX and PATH_MAX so that tests can exercise those edge cases.  Creating or
+// renaming an entry with a name longer than limits.MaxNameLength bytes, and creating, renaming, or
+// looking up an entry that is more than limits.MaxPathDepth entries below the root directory, fail
+// with fserrors.ENameTooLong.  A zero limit is unlimited.
+func NewFileSystemWithLimits(limits Limits) FileSystem {
+	return NewFileSystemWithOptions(Options{Limits: limits})
+}
@@ -0,0 +1,96 @@
+package filesys_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/manderson5192/memfs/filesys"
+	"github.com/manderson5192/memfs/fserrors"
+	"github.com/manderson5192/memfs/process"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaxNameLength(t *testing.T) {
+	p := process.NewProcessFilesystemContext(filesys.NewFileSystemWithLimits(filesys.Limits{
+		MaxNameLength: 8,
+	}))
+	assert.Nil(t, p.MakeDirectory("/12345678"))
+	assert.Nil(t, p.WriteFile("/file", []byte("data"), 0))
+
+	longName := "/" + strings.Repeat("x", 9)
+	assert.ErrorIs(t, p.MakeDirectory(longName), fserrors.ENameTooLong)
+	_, err := p.CreateFile(longName)
+	assert.ErrorIs(t, err, fserrors.ENameTooLong)
+	assert.ErrorIs(t, p.Rename("/file", longName), fserrors.ENameTooLong)
+	assert.False(t, p.Exists(longName))
+	assert.True(t, p.Exists("/file"))
+}
+
+func TestMaxPathDepth(t *testing.T) {
+	p := process.NewProcessFilesystemContext(filesys.NewFileSystemWithLimits(filesys.Limits{
+		MaxPathDepth: 3,
+	}))
+	assert.Nil(t, p.MakeDirectoryWithAncestors("/a/b/c"))
+	assert.Nil(t, p.WriteFile("/a/b/file", []byte("data"), 0))
+
+	// Nothing can be created more than 3 entries below the root
+	assert.ErrorIs(t, p.MakeDirectory("/a/b/c/d"), fserrors.ENameTooLong)
+	assert.ErrorIs(t, p.MakeDirectoryWithAncestors("/a/b/c/d/e"), fserrors.ENameTooLong)
+	_, err := p.CreateFile("/a/b/c/file")
+	assert.ErrorIs(t, err, fserrors.ENameTooLong)
+	assert.ErrorIs(t, p.Rename("/a/b/file", "/a/b/c/file"), fserrors.ENameTooLong)
+
+	// Lookups count the depth of the directories they pass through, including the working
+	// directory's
+	assert.Nil(t, p.ChangeDirectory("/a/b"))
+	_, err = p.Stat("c/../c/../../b/c")
+	assert.Nil(t, err)
+	_, err = p.Stat("c/x/y")
+	assert.ErrorIs(t, err, fserrors.ENameTooLong)
+}
+
+func TestMaxPathDepthFinalEntry(t *testing.T) {
+	p := process.NewProcessFilesystemContext(filesys.NewFileSystemWithLimits(filesys.Limits{
+		MaxPathDepth: 2,
+	}))
+	assert.Nil(t, p.MakeDirectoryWithAncestors("/a/b"))
+
+	// Looking up the final entry of a path counts its depth, too
+	_, err := p.Stat("/a/b/x")
+	assert.ErrorIs(t, err, fserrors.ENameTooLong)
+	_, err = p.OpenFile("/a/b/x", 0)
+	assert.ErrorIs(t, err, fserrors.ENameTooLong)
+	_, err = p.Stat("/a/b/.")
+	assert.Nil(t, err)
+}
+
+func TestMaxPathDepthRenameSubtree(t *testing.T) {
+	p := process.NewProcessFilesystemContext(filesys.NewFileSystemWithLimits(filesys.Limits{
+		MaxPathDepth: 3,
+	}))
+	assert.Nil(t, p.MakeDirectory("/d1"))
+	assert.Nil(t, p.MakeDirectoryWithAncestors("/e1/e2"))
+	assert.Nil(t, p.WriteFile("/e1/e2/f", []byte("data"), 0))
+
+	// Moving /e1 below /d1 would put /e1/e2/f 4 entries below the root
+	assert.ErrorIs(t, p.Rename("/e1", "/d1/e1"), fserrors.ENameTooLong)
+	assert.ErrorIs(t, p.CanRename("/e1", "/d1/e1"), fserrors.ENameTooLong)
+	data, err := p.ReadFile("/e1/e2/f")
+	assert.Nil(t, err)
+	assert.Equal(t, "data", string(data))
+	assert.False(t, p.Exists("/d1/e1"))
+
+	assert.Nil(t, p.MakeDirectory("/d1/x"))
+	assert.ErrorIs(t, p.RenameExchange("/e1", "/d1/x"), fserrors.ENameTooLong)
+	assert.True(t, p.Exists("/e1/e2/f"))
+	assert.Nil(t, p.RemoveDirectory("/d1/x"))
+
+	// A subtree that fits can be moved
+	assert.Nil(t, p.DeleteFile("/e1/e2/f"))
+	assert.Nil(t, p.Rename("/e1", "/d1/e1"))
+	assert.True(t, p.Exists("/d1/e1/e2"))
+
+	// Renaming within a directory doesn't change any depths
+	assert.Nil(t, p.Rename("/d1/e1", "/d1/e3"))
+	assert.True(t, p.Exists("/d1/e3/e2"))
+}
@@ -0,0 +1,105 @@
+package filesys
+
+import (
+	"io/fs"
+	"testing/fstest"
+
+	"github.com/manderson5192/memfs/directory"
+	"github.com/manderson5192/memfs/fserrors"
+	"github.com/pkg/errors"
+)
+
+// FromMapFS creates a new FileSystem (like NewFileSystem()) with the same tree as m, a standard
+// library fstest.MapFS.  Entries whose Mode has fs.ModeDir set become directories, and all others
+// become files containing their Data.  As in fstest.MapFS, the parent directories of every entry
+// are created implicitly, with the default permissions.  Each entry's permission bits become its
+// permission bits, and its ModTime, if it is not the zero time, becomes both its modification and
+// access time.
+//
+// FromMapFS returns EINVAL if a key of m is not a valid fs.FS path, if an entry has a file type
+// (such as fs.ModeSymlink) that MemFS can't represent, or if a path is used as both a file and a
+// directory.
+func FromMapFS(m fstest.MapFS) (FileSystem, error) {
+	spec := make(map[string]string, len(m))
+	for path, mapFile := range m {
+		if !fs.ValidPath(path) {
+			return nil, errors.Wrapf(fserrors.EInval, "'%s' is not a valid fs.FS path", path)
+		}
+		if mapFile == nil {
+			return nil, errors.Wrapf(fserrors.EInval, "'%s' has a nil MapFile", path)
+		}
+		switch fileType := mapFile.Mode.Type(); {
+		case fileType == fs.ModeDir:
+			spec[path+"/"] = ""
+		case fileType == 0:
+			spec[path] = string(mapFile.Data)
+		default:
+			return nil, errors.Wrapf(fserrors.EInval, "'%s' has file type %v, which is not supported", path, fileType)
+		}
+	}
+	fsys, err := Build(spec)
+	if err != nil {
+		return nil, err
+	}
+	root := fsys.RootDirectory()
+	for path, mapFile := range m {
+		if path == "." {
+			path = ""
+		}
+		if err := root.Chmod(path, mapFile.Mode.Perm()); err != nil {
+			return nil, errors.Wrapf(err, "could not set the mode of '%s'", path)
+		}
+		if mapFile.ModTime.IsZero() {
+			continue
+		}
+		if err := root.Chtimes(path, mapFile.ModTime, mapFile.ModTime); err != nil {
+			return nil, errors.Wrapf(err, "could not set the times of '%s'", path)
+		}
+	}
+	return fsys, nil
+}
+
+// ToMapFS returns an fstest.MapFS with the same tree as fsys.  Every file and directory except the
+// root directory gets an entry, so that empty directories are preserved.  Each entry's Mode and
+// ModTime are those reported by directory.FileInfo.StdFileInfo().
+//
+// ToMapFS does not lock fsys, so the result is only meaningful if fsys is not modified while it
+// runs.
+func ToMapFS(fsys FileSystem) (fstest.MapFS, error) {
+	m := fstest.MapFS{}
+	if err := addToMapFS(m, fsys.RootDirectory(), ""); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// addToMapFS adds an entry to m for every file and directory beneath the directory at relativePath
+func addToMapFS(m fstest.MapFS, root directory.Directory, relativePath string) error {
+	entries, err := root.ReadDirSorted(nativePath(root, relativePath))
+	if err != nil {
+		return errors.Wrapf(err, "could not list directory '/%s'", relativePath)
+	}
+	for _, entry := range entries {
+		entryPath := childPath(relativePath, entry.Name)
+		info, err := root.Stat(nativePath(root, entryPath))
+		if err != nil {
+			return errors.Wrapf(err, "could not stat '/%s'", entryPath)
+		}
+		stdInfo := info.StdFileInfo()
+		mapFile := &fstest.MapFile{
+			Mode:    stdInfo.Mode(),
+			ModTime: stdInfo.ModTime(),
+		}
+		m[entryPath] = mapFile
+		if info.IsDir() {
+			if err := addToMapFS(m, root, entryPath); err != nil {
+				return err
+			}
+			continue
+		}
+		if mapFile.Data, err = readAll(root, entryPath); err != nil {
+			return errors.Wrapf(err, "could not read file '/%s'", entryPath)
+		}
+	}
+	return nil
+}
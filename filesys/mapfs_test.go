@@ -0,0 +1,112 @@
+package filesys_test
+
+import (
+	"io/fs"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/manderson5192/memfs/filepath"
+	"github.com/manderson5192/memfs/filesys"
+	"github.com/manderson5192/memfs/fserrors"
+	"github.com/manderson5192/memfs/process"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type MapFSTestSuite struct {
+	suite.Suite
+}
+
+func (s *MapFSTestSuite) TestRoundTrip() {
+	m := fstest.MapFS{
+		"a":                {Mode: fs.ModeDir | 0755},
+		"a/b":              {Mode: fs.ModeDir | 0700},
+		"a/b/c":            {Mode: fs.ModeDir},
+		"a/b/c/deep_file":  {Data: []byte("deep")},
+		"a/foobar_file":    {Data: []byte("hello!"), ModTime: time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)},
+		"empty_dir":        {Mode: fs.ModeDir, ModTime: time.Date(2001, time.January, 1, 0, 0, 0, 0, time.UTC)},
+		"top_file":         {Data: []byte{}, Mode: 0600},
+		"zzz_binary_file":  {Data: []byte{0, 1, 2, 0xff}},
+		"zzz_dir":          {Mode: fs.ModeDir},
+		"zzz_dir/zzz_file": {Data: []byte("z")},
+	}
+	fsys, err := filesys.FromMapFS(m)
+	assert.Nil(s.T(), err)
+	roundTripped, err := filesys.ToMapFS(fsys)
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), len(m), len(roundTripped))
+	for path, expected := range m {
+		actual, exists := roundTripped[path]
+		if !assert.True(s.T(), exists, "'%s' should exist", path) {
+			continue
+		}
+		assert.Equal(s.T(), expected.Mode, actual.Mode, "mode of '%s'", path)
+		if !expected.ModTime.IsZero() {
+			assert.True(s.T(), expected.ModTime.Equal(actual.ModTime), "mod time of '%s'", path)
+		}
+		if expected.Mode.IsDir() {
+			assert.Empty(s.T(), actual.Data, "data of '%s'", path)
+		} else {
+			assert.Equal(s.T(), expected.Data, actual.Data, "data of '%s'", path)
+		}
+	}
+	// The result is a valid fs.FS
+	assert.Nil(s.T(), fstest.TestFS(roundTripped, "a/b/c/deep_file", "a/foobar_file", "empty_dir"))
+}
+
+func (s *MapFSTestSuite) TestFromMapFSCreatesParents() {
+	fsys, err := filesys.FromMapFS(fstest.MapFS{
+		"a/b/file": {Data: []byte("hello!")},
+	})
+	assert.Nil(s.T(), err)
+	p := process.NewProcessFilesystemContext(fsys)
+	assert.Equal(s.T(), []string{"/", "/a", "/a/b", "/a/b/file"}, walkPaths(s.T(), p))
+
+	m, err := filesys.ToMapFS(fsys)
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), fs.ModeDir|0755, m["a"].Mode)
+	assert.Equal(s.T(), fs.ModeDir|0755, m["a/b"].Mode)
+	assert.Equal(s.T(), fs.FileMode(0), m["a/b/file"].Mode)
+	assert.Equal(s.T(), []byte("hello!"), m["a/b/file"].Data)
+}
+
+func (s *MapFSTestSuite) TestFromMapFSErrors() {
+	maps := []fstest.MapFS{
+		{"/a": {Data: []byte("absolute paths are invalid")}},
+		{"a/": {Mode: fs.ModeDir}},
+		{"a/../b": {}},
+		{"link": {Mode: fs.ModeSymlink, Data: []byte("target")}},
+		{"a": {Data: []byte("file")}, "a/b": {Data: []byte("file")}},
+		{"nil": nil},
+	}
+	for _, m := range maps {
+		fsys, err := filesys.FromMapFS(m)
+		assert.ErrorIs(s.T(), err, fserrors.EInval, "MapFS %v", m)
+		assert.Nil(s.T(), fsys)
+	}
+}
+
+func (s *MapFSTestSuite) TestToMapFSWithNonDefaultParser() {
+	fsys := filesys.NewFileSystemWithParser(filepath.NewParser('\\', "@", "^"))
+	p := process.NewProcessFilesystemContext(fsys)
+	assert.Nil(s.T(), p.MakeDirectoryWithAncestors(`\a\b`))
+	assert.Nil(s.T(), p.WriteFile(`\a\b\file`, []byte("hello!"), 0))
+
+	m, err := filesys.ToMapFS(fsys)
+	assert.Nil(s.T(), err)
+	assert.Len(s.T(), m, 3)
+	assert.Equal(s.T(), fs.ModeDir|0755, m["a"].Mode)
+	assert.Equal(s.T(), fs.ModeDir|0755, m["a/b"].Mode)
+	assert.Equal(s.T(), []byte("hello!"), m["a/b/file"].Data)
+}
+
+func (s *MapFSTestSuite) TestToMapFSOfEmptyFileSystem() {
+	m, err := filesys.ToMapFS(filesys.NewFileSystem())
+	assert.Nil(s.T(), err)
+	assert.Empty(s.T(), m)
+}
+
+func TestMapFSTestSuite(t *testing.T) {
+	suite.Run(t, new(MapFSTestSuite))
+}
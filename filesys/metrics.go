@@ -0,0 +1,33 @@
+package filesys
+
+import (
+	"github.com/manderson5192/memfs/inode"
+)
+
+// FilesystemMetrics counts the operations performed on an InstrumentedFileSystem
+type FilesystemMetrics = inode.Metrics
+
+// InstrumentedFileSystem is a FileSystem that counts the operations performed on it
+type InstrumentedFileSystem interface {
+	FileSystem
+	// Metrics returns the number of each kind of operation that has succeeded on the filesystem
+	// since it was created.  The counters are maintained atomically, so Metrics() may be called
+	// concurrently with any other operation.
+	Metrics() FilesystemMetrics
+}
+
+// NewInstrumentedFileSystem creates a new FileSystem that counts the files opened and created, the
+// directories created, the entries deleted and renamed, and the reads and writes of file data (and
+// their sizes) performed on it, whether through a Directory, a File, or a ProcessFilesystemContext.
+// The operations are counted where they take effect, so an operation that fails is not counted.
+// Counting costs a few atomic adds per operation.  Filesystems that aren't instrumented only pay
+// for an atomic load of a nil pointer.
+func NewInstrumentedFileSystem() InstrumentedFileSystem {
+	return NewFileSystemWithOptions(Options{Instrumented: true}).(*fileSystem)
+}
+
+// Metrics returns the filesystem's operation counts.  They are all zero if the filesystem is not
+// instrumented.
+func (f *fileSystem) Metrics() FilesystemMetrics {
+	return f.superblock.Metrics()
+}
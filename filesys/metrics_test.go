@@ -0,0 +1,104 @@
+package filesys_test
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/manderson5192/memfs/filesys"
+	"github.com/manderson5192/memfs/process"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetricsCountOperations(t *testing.T) {
+	fs := filesys.NewInstrumentedFileSystem()
+	p := process.NewProcessFilesystemContext(fs)
+	assert.Equal(t, filesys.FilesystemMetrics{}, fs.Metrics())
+
+	assert.Nil(t, p.MakeDirectory("/dir"))
+	f, err := p.CreateFile("/dir/a")
+	assert.Nil(t, err)
+	n, err := f.Write([]byte("hello"))
+	assert.Nil(t, err)
+	assert.Equal(t, 5, n)
+	// Opening with O_TRUNC counts as a write of zero bytes
+	assert.Nil(t, p.WriteFile("/dir/b", []byte("abc"), 0))
+	data, err := p.ReadFile("/dir/a")
+	assert.Nil(t, err)
+	assert.Equal(t, "hello", string(data))
+	assert.Nil(t, p.Rename("/dir/a", "/dir/c"))
+	// Replacing /dir/b counts as a rename, not a delete
+	assert.Nil(t, p.Rename("/dir/c", "/dir/b"))
+	assert.Nil(t, p.DeleteFile("/dir/b"))
+	assert.Nil(t, p.RemoveDirectory("/dir"))
+
+	// Operations that fail are not counted
+	assert.NotNil(t, p.DeleteFile("/dir/b"))
+	_, err = p.ReadFile("/missing")
+	assert.NotNil(t, err)
+	assert.NotNil(t, p.Rename("/missing", "/other"))
+
+	assert.Equal(t, filesys.FilesystemMetrics{
+		Opens:        3,
+		Creates:      2,
+		Mkdirs:       1,
+		Deletes:      2,
+		Renames:      2,
+		Reads:        1,
+		BytesRead:    5,
+		Writes:       3,
+		BytesWritten: 8,
+	}, fs.Metrics())
+}
+
+func TestMetricsCountReplacingRenamesOnce(t *testing.T) {
+	fs := filesys.NewInstrumentedFileSystem()
+	p := process.NewProcessFilesystemContext(fs)
+	assert.Nil(t, p.WriteFile("/src_file", []byte("src"), 0))
+	assert.Nil(t, p.WriteFile("/dst_file", []byte("dst"), 0))
+	assert.Nil(t, p.MakeDirectory("/src_dir"))
+	assert.Nil(t, p.MakeDirectory("/dst_dir"))
+	before := fs.Metrics()
+
+	assert.Nil(t, p.Rename("/src_file", "/dst_file"))
+	assert.Nil(t, p.Rename("/src_dir", "/dst_dir"))
+	after := fs.Metrics()
+	assert.Equal(t, before.Renames+2, after.Renames)
+	assert.Equal(t, before.Deletes, after.Deletes)
+
+	// Deleting the survivors still counts
+	assert.Nil(t, p.DeleteFile("/dst_file"))
+	assert.Nil(t, p.RemoveDirectory("/dst_dir"))
+	assert.Equal(t, before.Deletes+2, fs.Metrics().Deletes)
+}
+
+func TestMetricsCountConcurrentOperations(t *testing.T) {
+	fs := filesys.NewInstrumentedFileSystem()
+	p := process.NewProcessFilesystemContext(fs)
+	f, err := p.CreateFile("/file")
+	assert.Nil(t, err)
+	const goroutines, writes = 8, 100
+	wg := sync.WaitGroup{}
+	for range goroutines {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range writes {
+				_, err := f.WriteAt([]byte("ab"), 0)
+				assert.Nil(t, err)
+			}
+		}()
+	}
+	wg.Wait()
+	metrics := fs.Metrics()
+	assert.Equal(t, int64(goroutines*writes), metrics.Writes)
+	assert.Equal(t, int64(2*goroutines*writes), metrics.BytesWritten)
+}
+
+func TestMetricsDisabledByDefault(t *testing.T) {
+	fs := filesys.NewFileSystem()
+	p := process.NewProcessFilesystemContext(fs)
+	assert.Nil(t, p.WriteFile("/file", []byte("data"), 0))
+	instrumented, ok := fs.(filesys.InstrumentedFileSystem)
+	assert.True(t, ok)
+	assert.Equal(t, filesys.FilesystemMetrics{}, instrumented.Metrics())
+}
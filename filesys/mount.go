@@ -0,0 +1,87 @@
+package filesys
+
+import (
+	"github.com/manderson5192/memfs/filepath"
+	"github.com/manderson5192/memfs/fserrors"
+	"github.com/manderson5192/memfs/inode"
+	"github.com/pkg/errors"
+)
+
+// BindMount makes the existing directory target in fs resolve to the directory source, like a bind
+// mount, so that the same subtree appears at both paths and changes made through either path are
+// visible through both.  Both paths are resolved against fs's root directory, whether or not they
+// begin with a path separator.  target's own entries are hidden for as long as it is a mount point.
+// Until the bind mount is removed (see Unmount()), neither target nor source can be removed or
+// replaced (EBUSY), and no directory can be renamed beneath a bind mount of itself (EINVAL).
+//
+// A ".." that follows target in a path leads back to target's parent, so "target/sub/../.." is
+// target's parent.  However, Directories are identified by their inodes, so a Directory (or working
+// directory) obtained through target is the same as one obtained through source: a ".." at the
+// start of a path resolved from it, and its ReversePathLookup(), refer to source's location.
+//
+// BindMount returns EINVAL if fs doesn't support bind mounts, if target is the root directory, or if
+// target is source or beneath it (which would make the tree cyclic), and EBUSY if target is already
+// a mount point.  Bind mounts are not captured by snapshots, and restoring a snapshot removes the
+// bind mounts whose target or source it replaces.  Walking fs visits a bind-mounted subtree once
+// for each path at which it appears (although Statfs() counts it once).
+func BindMount(fs FileSystem, source, target string) error {
+	f, ok := fs.(*fileSystem)
+	if !ok {
+		return errors.Wrapf(fserrors.EInval, "cannot bind mount in a filesystem of type %T", fs)
+	}
+	parser := f.superblock.Parser()
+	for _, path := range []string{source, target} {
+		if err := parser.CheckPath(path); err != nil {
+			return errors.Wrapf(err, "could not bind mount '%s' at '%s'", source, target)
+		}
+	}
+	sourceInode, err := f.rootDirectory.LookupSubdirectory(rootRelativePath(parser.ToSlash(source)))
+	if err != nil {
+		return errors.Wrapf(err, "could not bind mount '%s' at '%s'", source, target)
+	}
+	targetInfo := filepath.ParsePath(rootRelativePath(parser.ToSlash(target)))
+	targetParent, err := f.rootDirectory.LookupSubdirectory(rootRelativePath(targetInfo.ParentPath))
+	if err != nil {
+		return errors.Wrapf(err, "could not bind mount '%s' at '%s'", source, target)
+	}
+	defer f.superblock.CountMutation(nil)()
+	if err := inode.BindMount(sourceInode, targetParent, targetInfo.Entry); err != nil {
+		return errors.Wrapf(err, "could not bind mount '%s' at '%s'", source, target)
+	}
+	return nil
+}
+
+// Unmount removes the bind mount on the directory target in fs (see BindMount()), so that target's
+// own entries are visible again.  target is resolved against fs's root directory, whether or not it
+// begins with a path separator.  Unmount returns EINVAL if fs doesn't support bind mounts or if
+// target is not a mount point.
+func Unmount(fs FileSystem, target string) error {
+	f, ok := fs.(*fileSystem)
+	if !ok {
+		return errors.Wrapf(fserrors.EInval, "cannot unmount in a filesystem of type %T", fs)
+	}
+	parser := f.superblock.Parser()
+	if err := parser.CheckPath(target); err != nil {
+		return errors.Wrapf(err, "could not unmount '%s'", target)
+	}
+	targetInfo := filepath.ParsePath(rootRelativePath(parser.ToSlash(target)))
+	targetParent, err := f.rootDirectory.LookupSubdirectory(rootRelativePath(targetInfo.ParentPath))
+	if err != nil {
+		return errors.Wrapf(err, "could not unmount '%s'", target)
+	}
+	defer f.superblock.CountMutation(nil)()
+	if err := inode.Unmount(targetParent, targetInfo.Entry); err != nil {
+		return errors.Wrapf(err, "could not unmount '%s'", target)
+	}
+	return nil
+}
+
+// rootRelativePath returns path as a path relative to the root directory, whether or not it begins
+// with a path separator
+func rootRelativePath(path string) string {
+	relativePath := filepath.Clean(path)
+	if filepath.IsAbsolutePath(relativePath) {
+		relativePath = relativePath[1:]
+	}
+	return relativePath
+}
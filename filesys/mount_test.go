@@ -0,0 +1,179 @@
+package filesys_test
+
+import (
+	"testing"
+
+	"github.com/manderson5192/memfs/filesys"
+	"github.com/manderson5192/memfs/fserrors"
+	"github.com/manderson5192/memfs/process"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type BindMountTestSuite struct {
+	suite.Suite
+	fs filesys.FileSystem
+	p  process.ProcessFilesystemContext
+}
+
+func (s *BindMountTestSuite) SetupTest() {
+	s.fs = filesys.NewFileSystem()
+	s.p = process.NewProcessFilesystemContext(s.fs)
+	assert.Nil(s.T(), s.p.MakeDirectoryWithAncestors("/src/sub"))
+	assert.Nil(s.T(), s.p.MakeDirectoryWithAncestors("/mnt/target"))
+	assert.Nil(s.T(), s.p.WriteFile("/mnt/target/hidden", []byte("hidden"), 0))
+	assert.Nil(s.T(), filesys.BindMount(s.fs, "/src", "/mnt/target"))
+}
+
+func (s *BindMountTestSuite) TestChangesAreVisibleAtBothPaths() {
+	// A file created via the source is visible via the target, and vice versa
+	assert.Nil(s.T(), s.p.WriteFile("/src/sub/file", []byte("hello"), 0))
+	data, err := s.p.ReadFile("/mnt/target/sub/file")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), "hello", string(data))
+	assert.Nil(s.T(), s.p.MakeDirectory("/mnt/target/new"))
+	isDir, err := s.p.IsDir("/src/new")
+	assert.Nil(s.T(), err)
+	assert.True(s.T(), isDir)
+
+	// The mount point's own entries are hidden
+	assert.False(s.T(), s.p.Exists("/mnt/target/hidden"))
+	names := []string{}
+	entries, err := s.p.ListDirectorySorted("/mnt/target")
+	assert.Nil(s.T(), err)
+	for _, entry := range entries {
+		names = append(names, entry.Name)
+	}
+	assert.Equal(s.T(), []string{"new", "sub"}, names)
+	assert.Nil(s.T(), s.p.DeleteFile("/mnt/target/sub/file"))
+	assert.False(s.T(), s.p.Exists("/src/sub/file"))
+}
+
+func (s *BindMountTestSuite) TestParentEntrySemantics() {
+	assert.Nil(s.T(), s.p.WriteFile("/mnt/sibling", []byte("sibling"), 0))
+	assert.Nil(s.T(), s.p.WriteFile("/src/file", []byte("source"), 0))
+
+	// Within a single path, ".." after the mount point leads back to the mount point's parent
+	data, err := s.p.ReadFile("/mnt/target/sub/../../sibling")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), "sibling", string(data))
+	info, err := s.p.Stat("/mnt/target/..")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), "mnt", info.Name)
+	data, err = s.p.ReadFile("/mnt/target/../target/file")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), "source", string(data))
+
+	// A working directory inside the bind mount is the source directory, so ".." at the start of a
+	// path leads to the source's parent
+	assert.Nil(s.T(), s.p.ChangeDirectory("/mnt/target"))
+	wd, err := s.p.WorkingDirectory()
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), "/src", wd)
+	info, err = s.p.Stat("..")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), "/", info.Name)
+}
+
+func (s *BindMountTestSuite) TestMountPointIsBusy() {
+	assert.ErrorIs(s.T(), s.p.RemoveDirectory("/mnt/target"), fserrors.EBusy)
+	assert.Nil(s.T(), s.p.MakeDirectory("/mnt/empty"))
+	assert.ErrorIs(s.T(), s.p.Rename("/mnt/empty", "/mnt/target"), fserrors.EBusy)
+	assert.ErrorIs(s.T(), filesys.BindMount(s.fs, "/mnt/empty", "/mnt/target"), fserrors.EBusy)
+}
+
+func (s *BindMountTestSuite) TestSourceIsBusy() {
+	assert.Nil(s.T(), s.p.RemoveDirectory("/src/sub"))
+	assert.ErrorIs(s.T(), s.p.RemoveDirectory("/src"), fserrors.EBusy)
+	assert.Nil(s.T(), s.p.MakeDirectory("/empty"))
+	assert.ErrorIs(s.T(), s.p.Rename("/empty", "/src"), fserrors.EBusy)
+
+	// The bind mount still works, and the source can still be renamed
+	assert.Nil(s.T(), s.p.MakeDirectory("/mnt/target/x"))
+	assert.Nil(s.T(), s.p.Rename("/src", "/moved"))
+	assert.True(s.T(), s.p.Exists("/moved/x"))
+	entries, err := s.p.ListDirectory("/mnt/target")
+	assert.Nil(s.T(), err)
+	assert.Len(s.T(), entries, 1)
+}
+
+func (s *BindMountTestSuite) TestRenameIntoSource() {
+	// Moving the mount point (or an ancestor of it) into the source would make the tree cyclic
+	assert.ErrorIs(s.T(), s.p.Rename("/mnt/target", "/src/x"), fserrors.EInval)
+	assert.ErrorIs(s.T(), s.p.Rename("/mnt", "/src/sub/x"), fserrors.EInval)
+	assert.ErrorIs(s.T(), s.p.RenameExchange("/mnt", "/src/sub"), fserrors.EInval)
+	assert.False(s.T(), s.p.Exists("/src/x"))
+	assert.False(s.T(), s.p.Exists("/src/sub/x"))
+
+	// The mount point can still be moved elsewhere
+	assert.Nil(s.T(), s.p.Rename("/mnt/target", "/moved"))
+	assert.True(s.T(), s.p.Exists("/moved/sub"))
+	assert.ErrorIs(s.T(), s.p.Rename("/moved", "/src/sub/x"), fserrors.EInval)
+}
+
+func (s *BindMountTestSuite) TestUnmount() {
+	assert.Nil(s.T(), s.p.WriteFile("/src/file", []byte("source"), 0))
+	assert.Nil(s.T(), filesys.Unmount(s.fs, "/mnt/target"))
+
+	// The mount point's own entries are visible again, and both directories can be removed
+	assert.True(s.T(), s.p.Exists("/mnt/target/hidden"))
+	assert.False(s.T(), s.p.Exists("/mnt/target/file"))
+	assert.Nil(s.T(), s.p.DeleteFile("/src/file"))
+	assert.Nil(s.T(), s.p.RemoveDirectory("/src/sub"))
+	assert.Nil(s.T(), s.p.RemoveDirectory("/src"))
+	assert.Nil(s.T(), s.p.DeleteFile("/mnt/target/hidden"))
+	assert.Nil(s.T(), s.p.RemoveDirectory("/mnt/target"))
+
+	assert.ErrorIs(s.T(), filesys.Unmount(s.fs, "/mnt"), fserrors.EInval)
+	assert.ErrorIs(s.T(), filesys.Unmount(s.fs, "/mnt/target"), fserrors.ENoEnt)
+	overlay := filesys.NewOverlay(filesys.NewFileSystem(), filesys.NewFileSystem())
+	assert.ErrorIs(s.T(), filesys.Unmount(overlay, "/"), fserrors.EInval)
+}
+
+func (s *BindMountTestSuite) TestRestoreRemovesMounts() {
+	snapshot, err := filesys.TakeSnapshot(s.fs)
+	assert.Nil(s.T(), err)
+	assert.Nil(s.T(), snapshot.Restore(s.fs))
+
+	// The restored mount point isn't mounted on, and neither directory is busy
+	assert.True(s.T(), s.p.Exists("/mnt/target/hidden"))
+	assert.ErrorIs(s.T(), filesys.Unmount(s.fs, "/mnt/target"), fserrors.EInval)
+	assert.Nil(s.T(), s.p.RemoveDirectory("/src/sub"))
+	assert.Nil(s.T(), s.p.RemoveDirectory("/src"))
+
+	// An indexed filesystem uses its index again once its bind mounts are gone
+	indexed := filesys.NewIndexedFileSystem()
+	_, err = indexed.RootDirectory().Mkdir("src")
+	assert.Nil(s.T(), err)
+	_, err = indexed.RootDirectory().Mkdir("target")
+	assert.Nil(s.T(), err)
+	assert.Nil(s.T(), filesys.BindMount(indexed, "/src", "/target"))
+	_, indexUsed := indexed.RootDirectory().FindEntries("src")
+	assert.False(s.T(), indexUsed)
+	snapshot, err = filesys.TakeSnapshot(indexed)
+	assert.Nil(s.T(), err)
+	assert.Nil(s.T(), snapshot.Restore(indexed))
+	_, indexUsed = indexed.RootDirectory().FindEntries("src")
+	assert.True(s.T(), indexUsed)
+}
+
+func (s *BindMountTestSuite) TestInvalidMounts() {
+	// A directory can't be mounted beneath itself, even through another bind mount
+	assert.ErrorIs(s.T(), filesys.BindMount(s.fs, "/src", "/src/sub"), fserrors.EInval)
+	assert.ErrorIs(s.T(), filesys.BindMount(s.fs, "/src", "/src"), fserrors.EInval)
+	assert.ErrorIs(s.T(), filesys.BindMount(s.fs, "/mnt", "/src/sub"), fserrors.EInval)
+	assert.ErrorIs(s.T(), filesys.BindMount(s.fs, "/src", "/"), fserrors.EInval)
+
+	assert.Nil(s.T(), s.p.WriteFile("/file", []byte{}, 0))
+	assert.ErrorIs(s.T(), filesys.BindMount(s.fs, "/src", "/file"), fserrors.ENotDir)
+	assert.ErrorIs(s.T(), filesys.BindMount(s.fs, "/file", "/mnt"), fserrors.ENotDir)
+	assert.ErrorIs(s.T(), filesys.BindMount(s.fs, "/src", "/noexist"), fserrors.ENoEnt)
+	assert.ErrorIs(s.T(), filesys.BindMount(s.fs, "/noexist", "/mnt"), fserrors.ENoEnt)
+
+	overlay := filesys.NewOverlay(filesys.NewFileSystem(), filesys.NewFileSystem())
+	assert.ErrorIs(s.T(), filesys.BindMount(overlay, "/", "/"), fserrors.EInval)
+}
+
+func TestBindMountTestSuite(t *testing.T) {
+	suite.Run(t, new(BindMountTestSuite))
+}
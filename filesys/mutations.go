@@ -0,0 +1,25 @@
+package filesys
+
+import (
+	"github.com/manderson5192/memfs/fserrors"
+	"github.com/manderson5192/memfs/inode"
+	"github.com/pkg/errors"
+)
+
+// MutationCounts are a FileSystem's counts of the mutations that have been attempted through its
+// Directories and Files, and by this package's functions (see Mutations())
+type MutationCounts = inode.MutationCounts
+
+// Mutations returns fs's counts of the mutations that have been attempted through its Directories
+// and Files, and by this package's functions (such as Snapshot.Restore() and BindMount()), whether
+// or not they succeeded.  Reading them while fs is frozen (see Freeze()), and again while it is
+// frozen later, tells whether fs may have been mutated in between: any mutation that takes effect
+// after the first reading is counted in the later reading's Started but not the first reading's
+// Finished.  It returns EINVAL if fs doesn't count its mutations.
+func Mutations(fs FileSystem) (MutationCounts, error) {
+	f, ok := fs.(*fileSystem)
+	if !ok {
+		return MutationCounts{}, errors.Wrapf(fserrors.EInval, "cannot count the mutations of a filesystem of type %T", fs)
+	}
+	return f.superblock.MutationCounts(), nil
+}
@@ -0,0 +1,79 @@
+package filesys
+
+import (
+	"github.com/manderson5192/memfs/filepath"
+	"github.com/manderson5192/memfs/inode"
+)
+
+// Options configures a FileSystem created by NewFileSystemWithOptions().  The zero value describes
+// a FileSystem like the one created by NewFileSystem().
+type Options struct {
+	// HasQuota enables a quota of QuotaBytes bytes of file data (see NewFileSystemWithQuota())
+	HasQuota   bool
+	QuotaBytes int64
+	// Dedup enables deduplication of identical file contents (see NewFileSystemWithDedup())
+	Dedup bool
+	// Indexed makes the filesystem maintain an index of its entries by name (see
+	// NewIndexedFileSystem())
+	Indexed bool
+	// Instrumented makes the filesystem count the operations performed on it (see
+	// NewInstrumentedFileSystem())
+	Instrumented bool
+	// Sparse makes files store only the extents that are written to them (see NewFileSystemSparse())
+	Sparse bool
+	// Compressed makes files store their data gzip-compressed (see NewFileSystemCompressed()).  It
+	// has no effect if Sparse is also set.
+	Compressed bool
+	// SyncHook, if non-nil, is called by File.Sync() with the absolute path of the file being
+	// synced (or the empty string if the file has been deleted), and its result is returned by
+	// File.Sync().  Tests can use it to simulate I/O failures or to observe syncs.  If it is nil,
+	// then File.Sync() is a no-op that returns nil.
+	SyncHook func(path string) error
+	// Faults configures operations to fail on demand (see NewFileSystemWithFaults())
+	Faults FaultConfig
+	// Parser, if non-nil, determines the syntax of the filesystem's paths (see
+	// NewFileSystemWithParser())
+	Parser *filepath.Parser
+	// RejectControlChars makes the filesystem refuse to create entries whose names contain control
+	// characters, such as newlines or tabs.  Names containing a NUL byte are always refused.
+	RejectControlChars bool
+	// Limits caps the lengths of entry names and the depth of paths (see NewFileSystemWithLimits())
+	Limits Limits
+}
+
+// NewFileSystemWithOptions creates a new FileSystem that is configured by opts
+func NewFileSystemWithOptions(opts Options) FileSystem {
+	sb := inode.NewSuperblock()
+	if opts.HasQuota {
+		sb.SetQuota(opts.QuotaBytes)
+	}
+	if opts.Dedup {
+		sb.EnableDedup()
+	}
+	if opts.Indexed {
+		sb.EnableIndex()
+	}
+	if opts.Instrumented {
+		sb.EnableMetrics()
+	}
+	if opts.Sparse {
+		sb.EnableSparse()
+	}
+	if opts.Compressed {
+		sb.EnableCompression()
+	}
+	if opts.SyncHook != nil {
+		sb.SetSyncHook(opts.SyncHook)
+	}
+	if len(opts.Faults.Faults) > 0 {
+		sb.SetFaults(opts.Faults)
+	}
+	if opts.Parser != nil {
+		sb.SetParser(opts.Parser)
+	}
+	if opts.RejectControlChars {
+		sb.RejectControlCharacters()
+	}
+	sb.SetLimits(opts.Limits)
+	return newFileSystemWithSuperblock(sb)
+}
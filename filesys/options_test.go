@@ -0,0 +1,75 @@
+package filesys_test
+
+import (
+	"testing"
+
+	"github.com/manderson5192/memfs/filesys"
+	"github.com/manderson5192/memfs/fserrors"
+	"github.com/manderson5192/memfs/os"
+	"github.com/manderson5192/memfs/process"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSyncWithoutHook(t *testing.T) {
+	p := process.NewProcessFilesystemContext(filesys.NewFileSystem())
+	f, err := p.CreateFile("/file")
+	assert.Nil(t, err)
+	assert.Nil(t, f.Sync())
+}
+
+func TestSyncHookRecordsCalls(t *testing.T) {
+	synced := []string{}
+	fs := filesys.NewFileSystemWithOptions(filesys.Options{
+		SyncHook: func(path string) error {
+			synced = append(synced, path)
+			return nil
+		},
+	})
+	p := process.NewProcessFilesystemContext(fs)
+	assert.Nil(t, p.MakeDirectory("/dir"))
+	f, err := p.CreateFile("/dir/file")
+	assert.Nil(t, err)
+	assert.Nil(t, f.Sync())
+	assert.Nil(t, p.Rename("/dir/file", "/moved"))
+	assert.Nil(t, f.Sync())
+	assert.Nil(t, p.DeleteFile("/moved"))
+	assert.Nil(t, f.Sync())
+	assert.Equal(t, []string{"/dir/file", "/moved", ""}, synced)
+}
+
+func TestSyncHookInjectsError(t *testing.T) {
+	fs := filesys.NewFileSystemWithOptions(filesys.Options{
+		SyncHook: func(path string) error {
+			if path == "/bad" {
+				return fserrors.ENoSpace
+			}
+			return nil
+		},
+	})
+	p := process.NewProcessFilesystemContext(fs)
+	bad, err := p.CreateFile("/bad")
+	assert.Nil(t, err)
+	good, err := p.CreateFile("/good")
+	assert.Nil(t, err)
+	assert.ErrorIs(t, bad.Sync(), fserrors.ENoSpace)
+	assert.Nil(t, good.Sync())
+
+	// Forks of the filesystem keep the hook
+	snap, err := filesys.TakeSnapshot(fs)
+	assert.Nil(t, err)
+	forked, err := process.NewProcessFilesystemContext(snap.Fork()).OpenFile("/bad", os.O_RDWR)
+	assert.Nil(t, err)
+	assert.ErrorIs(t, forked.Sync(), fserrors.ENoSpace)
+}
+
+func TestOptionsCombineFeatures(t *testing.T) {
+	fs := filesys.NewFileSystemWithOptions(filesys.Options{HasQuota: true, QuotaBytes: 4, Dedup: true})
+	p := process.NewProcessFilesystemContext(fs)
+	f, err := p.CreateFile("/file")
+	assert.Nil(t, err)
+	assert.ErrorIs(t, f.TruncateAndWriteAll([]byte("12345")), fserrors.ENoSpace)
+	stats, err := filesys.Statfs(fs)
+	assert.Nil(t, err)
+	assert.True(t, stats.HasQuota)
+	assert.True(t, stats.HasDedup)
+}
@@ -0,0 +1,1265 @@
+package filesys
+
+import (
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/manderson5192/memfs/directory"
+	"github.com/manderson5192/memfs/file"
+	"github.com/manderson5192/memfs/filepath"
+	"github.com/manderson5192/memfs/fserrors"
+	"github.com/manderson5192/memfs/inode"
+	"github.com/manderson5192/memfs/notify"
+	"github.com/manderson5192/memfs/os"
+	"github.com/pkg/errors"
+)
+
+const (
+	// whiteoutPrefix begins the name of a whiteout: a file in the upper layer that hides the lower
+	// layer's entry with the rest of the name
+	whiteoutPrefix = ".wh."
+	// opaqueXattr is set on a directory in the upper layer to hide the contents of the lower layer's
+	// directory with the same path
+	opaqueXattr = "trusted.overlay.opaque"
+)
+
+type overlayFileSystem struct {
+	lower FileSystem
+	upper FileSystem
+}
+
+// NewOverlay returns a FileSystem that merges lower, which is treated as read-only, with upper, in
+// the manner of Linux's overlayfs.  A path refers to upper's entry if there is one, and otherwise to
+// lower's.  Directories that exist in both layers are merged, with upper's entries shadowing
+// lower's.
+//
+// All changes are made to upper.  Opening a lower file for writing (or changing its extended
+// attributes) first copies it up to upper, along with any of its ancestor directories that exist
+// only in lower.  Removing a lower entry creates a whiteout in upper: a file whose name is the
+// entry's name prefixed with ".wh.", which hides the entry from the merged view.  A directory that
+// is created where a lower entry was removed is marked opaque (with the "trusted.overlay.opaque"
+// extended attribute), so the removed directory's lower contents stay hidden.  Whiteouts and the
+// opaque attribute never appear in the merged view, and entry names may not begin with ".wh.".
+//
+// As with overlayfs, a directory that exists in lower cannot be renamed (EXDEV), since doing so
+// would require copying up its entire subtree.  Stat() reports the inode number of the entry in the
+// layer that provides it, so an entry's inode number changes when it is copied up.  Watches are not
+// supported.
+func NewOverlay(lower, upper FileSystem) FileSystem {
+	return &overlayFileSystem{
+		lower: lower,
+		upper: upper,
+	}
+}
+
+func (f *overlayFileSystem) RootDirectory() directory.Directory {
+	return &overlayDirectory{
+		lowerRoot: f.lower.RootDirectory(),
+		upperRoot: f.upper.RootDirectory(),
+		umask:     os.DefaultUmask,
+	}
+}
+
+// overlayDirectory is a directory.Directory for a directory in an overlay's merged view.  Unlike
+// directory.Directory, it identifies its directory by path (relative to the overlay's root), so it
+// resolves the path in both layers for every operation.
+type overlayDirectory struct {
+	lowerRoot directory.Directory
+	upperRoot directory.Directory
+	// components are the names of the directory's ancestors, and then the directory itself,
+	// starting beneath the overlay's root
+	components []string
+	// rootDepth is the number of components that make up the path of the directory that this
+	// overlayDirectory treats as its root (see Chroot())
+	rootDepth int
+	// umask masks the permission bits of entries that are created through this overlayDirectory
+	// (see WithUmask())
+	umask os.FileMode
+}
+
+// overlayLayers are a directory's counterparts in each layer of an overlay
+type overlayLayers struct {
+	// upper is the directory in the upper layer, or nil if it only exists in the lower layer
+	upper directory.Directory
+	// lower is the directory in the lower layer, or nil if it doesn't exist there or is hidden
+	lower directory.Directory
+}
+
+// overlayEntry describes what a name in a merged directory refers to in each layer
+type overlayEntry struct {
+	name string
+	// exists is true if the entry is visible in the merged view, in which case entryType is its type
+	exists    bool
+	entryType directory.DirectoryEntryType
+	// inUpper is true if the visible entry is in the upper layer
+	inUpper bool
+	// lowerHasName is true if the lower layer has a visible entry with the same name, whether or
+	// not it is shadowed by the upper layer.  Removing the entry requires a whiteout in this case.
+	lowerHasName bool
+	// layers are the directory's counterparts in each layer, if the entry is a directory
+	layers overlayLayers
+}
+
+// withComponents returns an overlayDirectory for the directory with the specified components that
+// has the same root as o
+func (o *overlayDirectory) withComponents(components []string) *overlayDirectory {
+	return &overlayDirectory{
+		lowerRoot:  o.lowerRoot,
+		upperRoot:  o.upperRoot,
+		components: components,
+		rootDepth:  o.rootDepth,
+		umask:      o.umask,
+	}
+}
+
+// resolvePath lexically resolves relativePath against o's path, returning the components of the
+// resulting path.  ".." in o's root refers to the root itself.  It also returns whether the path
+// must refer to a directory.
+func (o *overlayDirectory) resolvePath(relativePath string) ([]string, bool, error) {
+	if !filepath.IsRelativePath(relativePath) {
+		return nil, false, errors.Wrapf(fserrors.EInval, "'%s' is not a relative path", relativePath)
+	}
+	components := append([]string{}, o.components...)
+	for _, part := range strings.Split(relativePath, filepath.PathSeparator) {
+		switch part {
+		case "", filepath.SelfDirectoryEntry:
+		case filepath.ParentDirectoryEntry:
+			if len(components) > o.rootDepth {
+				components = components[:len(components)-1]
+			}
+		default:
+			if strings.HasPrefix(part, whiteoutPrefix) {
+				return nil, false, errors.Wrapf(fserrors.EInval, "entry names may not begin with '%s'", whiteoutPrefix)
+			}
+			components = append(components, part)
+		}
+	}
+	return components, filepath.ParsePath(relativePath).MustBeDir, nil
+}
+
+// rootLayers returns the overlay's root directory in each layer
+func (o *overlayDirectory) rootLayers() overlayLayers {
+	layers := overlayLayers{
+		upper: o.upperRoot,
+		lower: o.lowerRoot,
+	}
+	if isOpaque(o.upperRoot) {
+		layers.lower = nil
+	}
+	return layers
+}
+
+// lookupEntry determines what name refers to in the merged directory described by layers
+func lookupEntry(layers overlayLayers, name string) (overlayEntry, error) {
+	entry := overlayEntry{name: name}
+	if layers.upper != nil {
+		info, err := layers.upper.Stat(name)
+		if err == nil {
+			entry.exists = true
+			entry.inUpper = true
+			entry.entryType = info.Type
+			if info.Type == directory.DirectoryType {
+				if entry.layers.upper, err = layers.upper.LookupSubdirectory(name); err != nil {
+					return entry, err
+				}
+			}
+		} else if !errors.Is(err, fserrors.ENoEnt) {
+			return entry, err
+		} else if _, err := layers.upper.Stat(whiteoutPrefix + name); err == nil {
+			// The entry has been removed from the merged view
+			return entry, nil
+		}
+	}
+	if layers.lower == nil {
+		return entry, nil
+	}
+	info, err := layers.lower.Stat(name)
+	if errors.Is(err, fserrors.ENoEnt) {
+		return entry, nil
+	} else if err != nil {
+		return entry, err
+	}
+	entry.lowerHasName = true
+	if !entry.exists {
+		entry.exists = true
+		entry.entryType = info.Type
+	}
+	// A lower directory is merged into the entry if it is visible and not hidden by an opaque upper
+	// directory
+	if entry.entryType == directory.DirectoryType && info.Type == directory.DirectoryType &&
+		(entry.layers.upper == nil || !isOpaque(entry.layers.upper)) {
+		if entry.layers.lower, err = layers.lower.LookupSubdirectory(name); err != nil {
+			return entry, err
+		}
+	}
+	return entry, nil
+}
+
+// resolveDirectory returns the layers of the merged directory with the specified components, or
+// an error if there is no such directory
+func (o *overlayDirectory) resolveDirectory(components []string) (overlayLayers, error) {
+	layers := o.rootLayers()
+	for idx, name := range components {
+		entry, err := lookupEntry(layers, name)
+		if err != nil {
+			return overlayLayers{}, err
+		}
+		if !entry.exists {
+			return overlayLayers{}, errors.Wrapf(fserrors.ENoEnt, "entry '%s' does not exist", filepath.Join(components[:idx+1]...))
+		}
+		if entry.entryType != directory.DirectoryType {
+			return overlayLayers{}, errors.Wrapf(fserrors.ENotDir, "entry '%s' is not a directory", filepath.Join(components[:idx+1]...))
+		}
+		layers = entry.layers
+	}
+	return layers, nil
+}
+
+// resolveEntry returns the layers of the parent of the entry with the specified components, which
+// must not be empty, along with the entry itself
+func (o *overlayDirectory) resolveEntry(components []string) (overlayLayers, overlayEntry, error) {
+	parent, err := o.resolveDirectory(components[:len(components)-1])
+	if err != nil {
+		return overlayLayers{}, overlayEntry{}, err
+	}
+	entry, err := lookupEntry(parent, components[len(components)-1])
+	return parent, entry, err
+}
+
+// copyUpDirectory returns the upper layer's directory with the specified components, creating it
+// and any of its ancestors that exist only in the lower layer.  The directory must exist in the
+// merged view.
+func (o *overlayDirectory) copyUpDirectory(components []string) (directory.Directory, error) {
+	upper := o.upperRoot
+	lower := o.lowerRoot
+	for _, name := range components {
+		next, err := upper.LookupSubdirectory(name)
+		if errors.Is(err, fserrors.ENoEnt) {
+			if next, err = upper.Mkdir(name); err != nil {
+				return nil, err
+			}
+			if lower != nil {
+				if err := copyXattrs(lower, next, name, ""); err != nil {
+					return nil, err
+				}
+				if err := copyOwner(lower, next, name, ""); err != nil {
+					return nil, err
+				}
+				if err := copyMode(lower, next, name, ""); err != nil {
+					return nil, err
+				}
+				if err := copyTimes(lower, next, name, ""); err != nil {
+					return nil, err
+				}
+			}
+		} else if err != nil {
+			return nil, err
+		}
+		upper = next
+		if lower != nil {
+			if lower, err = lower.LookupSubdirectory(name); err != nil {
+				lower = nil
+			}
+		}
+	}
+	return upper, nil
+}
+
+// copyUpFile copies the lower layer's file named name into the upper layer's directory upperParent,
+// along with its timestamps, unless truncate is true, in which case only its extended attributes,
+// ownership, and permissions are copied
+func copyUpFile(lowerParent, upperParent directory.Directory, name string, truncate bool) error {
+	f, err := upperParent.OpenFile(name, os.OpenFileModeEqualToCreateFile)
+	if err != nil {
+		return err
+	}
+	if err := copyOwner(lowerParent, upperParent, name, name); err != nil {
+		return err
+	}
+	if err := copyMode(lowerParent, upperParent, name, name); err != nil {
+		return err
+	}
+	if !truncate {
+		lowerFile, err := lowerParent.OpenFile(name, os.O_RDONLY)
+		if err != nil {
+			return err
+		}
+		data, err := lowerFile.ReadAll()
+		if err != nil {
+			return err
+		}
+		if err := f.TruncateAndWriteAll(data); err != nil {
+			return err
+		}
+		if err := copyTimes(lowerParent, upperParent, name, name); err != nil {
+			return err
+		}
+	}
+	return copyXattrs(lowerParent, upperParent, name, name)
+}
+
+// copyXattrs copies the extended attributes of src's entry srcPath to dst's entry dstPath
+func copyXattrs(src, dst directory.Directory, srcPath, dstPath string) error {
+	names, err := src.ListXattr(srcPath)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		value, err := src.GetXattr(srcPath, name)
+		if err != nil {
+			return err
+		}
+		if err := dst.SetXattr(dstPath, name, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyOwner copies the ownership of src's entry srcPath to dst's entry dstPath
+func copyOwner(src, dst directory.Directory, srcPath, dstPath string) error {
+	info, err := src.Stat(srcPath)
+	if err != nil {
+		return err
+	}
+	return dst.Chown(dstPath, info.Uid, info.Gid)
+}
+
+// copyMode copies the permission bits of src's entry srcPath to dst's entry dstPath
+func copyMode(src, dst directory.Directory, srcPath, dstPath string) error {
+	info, err := src.Stat(srcPath)
+	if err != nil {
+		return err
+	}
+	return dst.Chmod(dstPath, info.Perm)
+}
+
+// copyTimes copies the timestamps of src's entry srcPath to dst's entry dstPath
+func copyTimes(src, dst directory.Directory, srcPath, dstPath string) error {
+	info, err := src.Stat(srcPath)
+	if err != nil {
+		return err
+	}
+	return dst.Chtimes(dstPath, info.AccessTime, info.ModTime)
+}
+
+// isOpaque returns true if the upper layer's directory dir hides the lower layer's directory
+func isOpaque(dir directory.Directory) bool {
+	_, err := dir.GetXattr("", opaqueXattr)
+	return err == nil
+}
+
+// hasWhiteout returns whether the upper layer's directory upperParent has a whiteout for name
+func hasWhiteout(upperParent directory.Directory, name string) (bool, error) {
+	_, err := upperParent.Stat(whiteoutPrefix + name)
+	if errors.Is(err, fserrors.ENoEnt) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// removeWhiteout removes the whiteout for name from the upper layer's directory upperParent, if
+// there is one, and returns whether there was
+func removeWhiteout(upperParent directory.Directory, name string) (bool, error) {
+	err := upperParent.DeleteFile(whiteoutPrefix + name)
+	if errors.Is(err, fserrors.ENoEnt) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// createWhiteout hides the lower layer's entry named name in the merged directory with the
+// specified components
+func (o *overlayDirectory) createWhiteout(parentComponents []string, name string) error {
+	upperParent, err := o.copyUpDirectory(parentComponents)
+	if err != nil {
+		return err
+	}
+	_, err = upperParent.CreateFile(whiteoutPrefix + name)
+	return err
+}
+
+// readDir returns the entries of the merged directory described by layers
+func readDir(layers overlayLayers) ([]directory.DirectoryEntry, error) {
+	entries := []directory.DirectoryEntry{}
+	hidden := map[string]bool{}
+	if layers.upper != nil {
+		upperEntries, err := layers.upper.ReadDir("")
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range upperEntries {
+			if strings.HasPrefix(entry.Name, whiteoutPrefix) {
+				hidden[strings.TrimPrefix(entry.Name, whiteoutPrefix)] = true
+				continue
+			}
+			hidden[entry.Name] = true
+			entries = append(entries, entry)
+		}
+	}
+	if layers.lower != nil {
+		lowerEntries, err := layers.lower.ReadDir("")
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range lowerEntries {
+			if !hidden[entry.Name] {
+				entries = append(entries, entry)
+			}
+		}
+	}
+	return entries, nil
+}
+
+// clearWhiteouts removes every whiteout from the upper layer's directory dir, so that it can be
+// removed or replaced, and returns the names of the whiteouts that it removed.  If it fails, then
+// it restores the whiteouts that it had already removed.
+func clearWhiteouts(dir directory.Directory) ([]string, error) {
+	entries, err := dir.ReadDir("")
+	if err != nil {
+		return nil, err
+	}
+	cleared := []string{}
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name, whiteoutPrefix) {
+			if err := dir.DeleteFile(entry.Name); err != nil {
+				restoreWhiteouts(dir, cleared)
+				return nil, err
+			}
+			cleared = append(cleared, entry.Name)
+		}
+	}
+	return cleared, nil
+}
+
+// restoreWhiteouts recreates the whiteouts named names (as returned by clearWhiteouts()) in the
+// upper layer's directory dir after the operation that cleared them has failed.  It is best-effort,
+// since it only runs when dir's layer is already failing.
+func restoreWhiteouts(dir directory.Directory, names []string) {
+	for _, name := range names {
+		_, _ = dir.CreateFile(name)
+	}
+}
+
+func (o *overlayDirectory) Equals(other directory.Directory) bool {
+	otherDir, ok := other.(*overlayDirectory)
+	if o == nil || !ok || otherDir == nil {
+		return false
+	}
+	return o.lowerRoot.Equals(otherDir.lowerRoot) && o.upperRoot.Equals(otherDir.upperRoot) &&
+		filepath.Join(o.components...) == filepath.Join(otherDir.components...)
+}
+
+// IsValid returns true if the overlay directory's path still resolves to a directory, since
+// overlay Directory handles are path-based
+func (o *overlayDirectory) IsValid() bool {
+	_, err := o.resolveDirectory(o.components)
+	return err == nil
+}
+
+// OpenHandle returns o itself: an overlayDirectory identifies its directory by path, so it can't
+// keep an unlinked directory reachable, and its handles are not counted
+func (o *overlayDirectory) OpenHandle() directory.Directory {
+	return o
+}
+
+func (o *overlayDirectory) Close() error {
+	return nil
+}
+
+func (o *overlayDirectory) ReversePathLookup() (string, error) {
+	if _, err := o.resolveDirectory(o.components); err != nil {
+		return "", errors.Wrapf(err, "could not complete reverse path lookup")
+	}
+	return filepath.PathSeparator + filepath.Join(o.components[o.rootDepth:]...), nil
+}
+
+func (o *overlayDirectory) Chroot() directory.Directory {
+	chrooted := o.withComponents(o.components)
+	chrooted.rootDepth = len(o.components)
+	return chrooted
+}
+
+func (o *overlayDirectory) WithUmask(mask os.FileMode) directory.Directory {
+	withUmask := o.withComponents(o.components)
+	withUmask.umask = mask.Perm()
+	return withUmask
+}
+
+func (o *overlayDirectory) Umask() os.FileMode {
+	return o.umask
+}
+
+// WithMutationCounter returns o itself: an overlay can't be snapshotted (see TakeSnapshot()), so
+// nothing needs to tell its mutations apart, and they are only counted by the upper layer's own
+// Directories
+func (o *overlayDirectory) WithMutationCounter(c *inode.MutationCounter) directory.Directory {
+	return o
+}
+
+// Parser always returns filepath.DefaultParser, since an overlay's paths use the default syntax
+// regardless of the syntax of its layers
+func (o *overlayDirectory) Parser() *filepath.Parser {
+	return filepath.DefaultParser
+}
+
+// FindEntries always returns false, since an overlay's entries aren't indexed
+func (o *overlayDirectory) FindEntries(name string) ([]string, bool) {
+	return nil, false
+}
+
+func (o *overlayDirectory) LookupSubdirectory(subdirectory string) (directory.Directory, error) {
+	components, _, err := o.resolvePath(subdirectory)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := o.resolveDirectory(components); err != nil {
+		return nil, errors.Wrapf(err, "cannot find subdirectory '%s'", subdirectory)
+	}
+	return o.withComponents(components), nil
+}
+
+func (o *overlayDirectory) Mkdir(subdirectory string) (directory.Directory, error) {
+	return o.MkdirMode(subdirectory, os.ModePerm)
+}
+
+func (o *overlayDirectory) MkdirMode(subdirectory string, perm os.FileMode) (directory.Directory, error) {
+	components, _, err := o.resolvePath(subdirectory)
+	if err != nil {
+		return nil, err
+	}
+	if len(components) == 0 {
+		return nil, errors.Wrapf(fserrors.EExist, "could not create %s", subdirectory)
+	}
+	_, entry, err := o.resolveEntry(components)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not create %s", subdirectory)
+	}
+	if entry.exists {
+		return nil, errors.Wrapf(fserrors.EExist, "could not create %s", subdirectory)
+	}
+	parentComponents := components[:len(components)-1]
+	upperParent, err := o.copyUpDirectory(parentComponents)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not create %s", subdirectory)
+	}
+	hadWhiteout, err := hasWhiteout(upperParent, entry.name)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not create %s", subdirectory)
+	}
+	newDir, err := upperParent.WithUmask(o.umask).MkdirMode(entry.name, perm)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not create %s", subdirectory)
+	}
+	if hadWhiteout {
+		// Don't let a removed lower directory's contents reappear in the new directory.  The whiteout
+		// is only removed once the new directory hides the lower entry, and the new directory is
+		// removed again if that fails, so that the lower entry stays hidden.
+		err := newDir.SetXattr("", opaqueXattr, []byte("y"))
+		if err == nil {
+			_, err = removeWhiteout(upperParent, entry.name)
+		}
+		if err != nil {
+			_ = upperParent.Rmdir(entry.name)
+			return nil, errors.Wrapf(err, "could not create %s", subdirectory)
+		}
+	}
+	return o.withComponents(components), nil
+}
+
+func (o *overlayDirectory) ReadDir(subdirectory string) ([]directory.DirectoryEntry, error) {
+	components, _, err := o.resolvePath(subdirectory)
+	if err != nil {
+		return nil, err
+	}
+	layers, err := o.resolveDirectory(components)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not list entries in '%s'", subdirectory)
+	}
+	return readDir(layers)
+}
+
+func (o *overlayDirectory) ReadDirSorted(subdirectory string) ([]directory.DirectoryEntry, error) {
+	entries, err := o.ReadDir(subdirectory)
+	if err != nil {
+		return nil, err
+	}
+	directory.SortEntries(entries)
+	return entries, nil
+}
+
+func (o *overlayDirectory) OpenDir(subdirectory string) (directory.DirReader, error) {
+	entries, err := o.ReadDirSorted(subdirectory)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not open '%s'", subdirectory)
+	}
+	return &overlayDirReader{entries: entries}, nil
+}
+
+func (o *overlayDirectory) Rmdir(subdirectory string) error {
+	components, _, err := o.resolvePath(subdirectory)
+	if err != nil {
+		return err
+	}
+	if len(components) <= o.rootDepth {
+		return errors.Wrapf(fserrors.EInval, "refusing to remove the root directory")
+	}
+	parent, entry, err := o.resolveEntry(components)
+	if err != nil {
+		return errors.Wrapf(err, "could not delete '%s'", subdirectory)
+	}
+	if !entry.exists {
+		return errors.Wrapf(fserrors.ENoEnt, "could not delete '%s'", subdirectory)
+	}
+	if entry.entryType != directory.DirectoryType {
+		return errors.Wrapf(fserrors.ENotDir, "could not delete '%s'", subdirectory)
+	}
+	entries, err := readDir(entry.layers)
+	if err != nil {
+		return errors.Wrapf(err, "could not delete '%s'", subdirectory)
+	}
+	if len(entries) > 0 {
+		return errors.Wrapf(fserrors.ENotEmpty, "could not delete '%s'", subdirectory)
+	}
+	if entry.inUpper {
+		cleared, err := clearWhiteouts(entry.layers.upper)
+		if err != nil {
+			return errors.Wrapf(err, "could not delete '%s'", subdirectory)
+		}
+		if err := parent.upper.Rmdir(entry.name); err != nil {
+			restoreWhiteouts(entry.layers.upper, cleared)
+			return errors.Wrapf(err, "could not delete '%s'", subdirectory)
+		}
+	}
+	if entry.lowerHasName {
+		if err := o.createWhiteout(components[:len(components)-1], entry.name); err != nil {
+			return errors.Wrapf(err, "could not delete '%s'", subdirectory)
+		}
+	}
+	return nil
+}
+
+// RmdirOpen behaves exactly like Rmdir(), since an overlayDirectory's handles are not counted (see
+// OpenHandle())
+func (o *overlayDirectory) RmdirOpen(subdirectory string) error {
+	return o.Rmdir(subdirectory)
+}
+
+func (o *overlayDirectory) CreateFile(relativePath string) (file.File, error) {
+	f, err := o.OpenFile(relativePath, os.OpenFileModeEqualToCreateFile)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not create '%s'", relativePath)
+	}
+	return f, nil
+}
+
+// CreateExclusive leaves the decision of whether the file is created to the upper layer's
+// CreateExclusive(), so the existence check and the creation happen atomically there.  A lower
+// file is copied up first, and so is reported as already existing.
+func (o *overlayDirectory) CreateExclusive(relativePath string) (file.File, bool, error) {
+	created := false
+	mode := os.CombineModes(os.O_RDWR, os.O_CREATE)
+	f, err := o.openFile(relativePath, mode, func(upperParent directory.Directory, name string) (file.File, error) {
+		f, upperCreated, err := upperParent.CreateExclusive(name)
+		created = upperCreated
+		return f, err
+	})
+	if err != nil {
+		return nil, false, errors.Wrapf(err, "could not create '%s'", relativePath)
+	}
+	return f, created, nil
+}
+
+func (o *overlayDirectory) OpenFile(relativePath string, mode int) (file.File, error) {
+	return o.OpenFileWithLimit(relativePath, mode, -1)
+}
+
+func (o *overlayDirectory) OpenFileWithLimit(relativePath string, mode int, maxBytes int64) (file.File, error) {
+	return o.openFile(relativePath, mode, func(upperParent directory.Directory, name string) (file.File, error) {
+		return upperParent.OpenFileWithLimit(name, mode, maxBytes)
+	})
+}
+
+func (o *overlayDirectory) OpenRingFile(relativePath string, maxBytes int) (file.File, error) {
+	mode := os.CombineModes(os.O_RDWR, os.O_CREATE, os.O_APPEND)
+	return o.openFile(relativePath, mode, func(upperParent directory.Directory, name string) (file.File, error) {
+		return upperParent.OpenRingFile(name, maxBytes)
+	})
+}
+
+func (o *overlayDirectory) OpenFileBuffered(relativePath string, mode int, bufSize int) (file.File, error) {
+	return o.openFile(relativePath, mode, func(upperParent directory.Directory, name string) (file.File, error) {
+		return upperParent.OpenFileBuffered(name, mode, bufSize)
+	})
+}
+
+// openFile opens the file at relativePath with mode, copying it up to the upper layer if mode
+// allows writing.  It opens the upper layer's file with open.
+func (o *overlayDirectory) openFile(relativePath string, mode int, open func(upperParent directory.Directory, name string) (file.File, error)) (file.File, error) {
+	components, mustBeDir, err := o.resolvePath(relativePath)
+	if err != nil {
+		return nil, err
+	}
+	if mustBeDir {
+		return nil, errors.Wrapf(fserrors.EInval, "path specifies a directory")
+	}
+	if len(components) == 0 {
+		return nil, errors.Wrapf(fserrors.EIsDir, "could not open '%s'", relativePath)
+	}
+	parent, entry, err := o.resolveEntry(components)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not open '%s'", relativePath)
+	}
+	switch {
+	case entry.exists && entry.entryType == directory.DirectoryType:
+		return nil, errors.Wrapf(fserrors.EIsDir, "could not open '%s'", relativePath)
+	case entry.exists && os.IsExclusiveMode(mode):
+		return nil, errors.Wrapf(fserrors.EExist, "could not open '%s'", relativePath)
+	case !entry.exists && !os.IsCreateMode(mode):
+		return nil, errors.Wrapf(fserrors.ENoEnt, "could not open '%s'", relativePath)
+	case entry.exists && !entry.inUpper && !os.IsWriteAllowed(mode):
+		// Reading a lower file doesn't require copying it up
+		f, err := parent.lower.OpenFile(entry.name, mode)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not open '%s'", relativePath)
+		}
+		return f, nil
+	}
+	upperParent, err := o.copyUpDirectory(components[:len(components)-1])
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not open '%s'", relativePath)
+	}
+	// A new file's permissions are masked by the umask, while a copied up file's are copied
+	upperParent = upperParent.WithUmask(o.umask)
+	if entry.exists && !entry.inUpper {
+		if err := copyUpFile(parent.lower, upperParent, entry.name, os.IsTruncateMode(mode)); err != nil {
+			return nil, errors.Wrapf(err, "could not copy up '%s'", relativePath)
+		}
+	}
+	f, err := open(upperParent, entry.name)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not open '%s'", relativePath)
+	}
+	if !entry.exists {
+		// The new file shadows any whiteout for its name, so the whiteout is removed last, and the
+		// file is removed again if that fails, so that a removed lower entry stays hidden
+		if _, err := removeWhiteout(upperParent, entry.name); err != nil {
+			_ = f.Close()
+			_ = upperParent.DeleteFile(entry.name)
+			return nil, errors.Wrapf(err, "could not open '%s'", relativePath)
+		}
+	}
+	return f, nil
+}
+
+func (o *overlayDirectory) DeleteFile(relativePath string) error {
+	components, mustBeDir, err := o.resolvePath(relativePath)
+	if err != nil {
+		return err
+	}
+	if mustBeDir {
+		return errors.Wrapf(fserrors.EInval, "path specifies a directory")
+	}
+	if len(components) == 0 {
+		return errors.Wrapf(fserrors.EIsDir, "could not delete '%s'", relativePath)
+	}
+	parent, entry, err := o.resolveEntry(components)
+	if err != nil {
+		return errors.Wrapf(err, "could not delete '%s'", relativePath)
+	}
+	if !entry.exists {
+		return errors.Wrapf(fserrors.ENoEnt, "could not delete '%s'", relativePath)
+	}
+	if entry.entryType == directory.DirectoryType {
+		return errors.Wrapf(fserrors.EIsDir, "could not delete '%s'", relativePath)
+	}
+	if entry.inUpper {
+		if err := parent.upper.DeleteFile(entry.name); err != nil {
+			return errors.Wrapf(err, "could not delete '%s'", relativePath)
+		}
+	}
+	if entry.lowerHasName {
+		if err := o.createWhiteout(components[:len(components)-1], entry.name); err != nil {
+			return errors.Wrapf(err, "could not delete '%s'", relativePath)
+		}
+	}
+	return nil
+}
+
+func (o *overlayDirectory) Rename(srcRelativePath, dstRelativePath string) error {
+	return o.renamePaths(srcRelativePath, dstRelativePath, false)
+}
+
+func (o *overlayDirectory) RenameNoReplace(srcRelativePath, dstRelativePath string) error {
+	return o.renamePaths(srcRelativePath, dstRelativePath, true)
+}
+
+func (o *overlayDirectory) CanRename(srcRelativePath, dstRelativePath string) error {
+	srcComponents, _, err := o.resolvePath(srcRelativePath)
+	if err != nil {
+		return err
+	}
+	dstComponents, _, err := o.resolvePath(dstRelativePath)
+	if err != nil {
+		return err
+	}
+	if _, _, _, err := o.checkRename(srcComponents, dstComponents, false); err != nil {
+		return errors.Wrapf(err, "could not rename '%s' to '%s'", srcRelativePath, dstRelativePath)
+	}
+	return nil
+}
+
+// renamePaths resolves srcRelativePath and dstRelativePath and renames the entry (see rename())
+func (o *overlayDirectory) renamePaths(srcRelativePath, dstRelativePath string, noReplace bool) error {
+	srcComponents, _, err := o.resolvePath(srcRelativePath)
+	if err != nil {
+		return err
+	}
+	dstComponents, _, err := o.resolvePath(dstRelativePath)
+	if err != nil {
+		return err
+	}
+	if err := o.rename(srcComponents, dstComponents, noReplace); err != nil {
+		return errors.Wrapf(err, "could not rename '%s' to '%s'", srcRelativePath, dstRelativePath)
+	}
+	return nil
+}
+
+// checkRename returns the error that renaming the entry with srcComponents to dstComponents would
+// hit before it modified either layer, along with the layers of the source's parent and the source
+// and destination entries.  If noReplace is true, then it returns EEXIST if there is already an
+// entry at dstComponents in the merged view.
+func (o *overlayDirectory) checkRename(srcComponents, dstComponents []string, noReplace bool) (overlayLayers, overlayEntry, overlayEntry, error) {
+	if len(srcComponents) <= o.rootDepth || len(dstComponents) <= o.rootDepth {
+		return overlayLayers{}, overlayEntry{}, overlayEntry{}, errors.Wrapf(fserrors.EInval, "cannot rename the root directory")
+	}
+	srcParent, src, err := o.resolveEntry(srcComponents)
+	if err != nil {
+		return overlayLayers{}, overlayEntry{}, overlayEntry{}, err
+	}
+	if !src.exists {
+		return overlayLayers{}, overlayEntry{}, overlayEntry{}, errors.Wrapf(fserrors.ENoEnt, "entry '%s' does not exist", src.name)
+	}
+	_, dst, err := o.resolveEntry(dstComponents)
+	if err != nil {
+		return overlayLayers{}, overlayEntry{}, overlayEntry{}, err
+	}
+	if dst.exists && noReplace {
+		return overlayLayers{}, overlayEntry{}, overlayEntry{}, errors.Wrapf(fserrors.EExist, "entry '%s' already exists", dst.name)
+	}
+	srcPath := filepath.Join(srcComponents...)
+	dstPath := filepath.Join(dstComponents...)
+	if srcPath == dstPath {
+		return srcParent, src, dst, nil
+	}
+	if src.entryType == directory.DirectoryType {
+		if strings.HasPrefix(dstPath+filepath.PathSeparator, srcPath+filepath.PathSeparator) {
+			return overlayLayers{}, overlayEntry{}, overlayEntry{}, errors.Wrapf(fserrors.EInval, "cannot move a directory into its own subtree")
+		}
+		if src.layers.lower != nil {
+			return overlayLayers{}, overlayEntry{}, overlayEntry{}, errors.Wrapf(fserrors.EXDev, "cannot rename a directory that exists in the lower layer")
+		}
+	}
+	if dst.exists {
+		switch {
+		case src.entryType == directory.DirectoryType && dst.entryType != directory.DirectoryType:
+			return overlayLayers{}, overlayEntry{}, overlayEntry{}, errors.Wrapf(fserrors.ENotDir, "cannot replace a file with a directory")
+		case src.entryType != directory.DirectoryType && dst.entryType == directory.DirectoryType:
+			return overlayLayers{}, overlayEntry{}, overlayEntry{}, errors.Wrapf(fserrors.EIsDir, "cannot replace a directory with a file")
+		case dst.entryType == directory.DirectoryType:
+			if dst.layers.lower != nil {
+				return overlayLayers{}, overlayEntry{}, overlayEntry{}, errors.Wrapf(fserrors.EXDev, "cannot replace a directory that exists in the lower layer")
+			}
+			entries, err := readDir(dst.layers)
+			if err != nil {
+				return overlayLayers{}, overlayEntry{}, overlayEntry{}, err
+			}
+			if len(entries) > 0 {
+				return overlayLayers{}, overlayEntry{}, overlayEntry{}, errors.Wrapf(fserrors.ENotEmpty, "cannot replace a non-empty directory")
+			}
+		}
+	}
+	return srcParent, src, dst, nil
+}
+
+// rename moves the entry with srcComponents to dstComponents.  If noReplace is true, then it returns
+// EEXIST if there is already an entry at dstComponents in the merged view.
+func (o *overlayDirectory) rename(srcComponents, dstComponents []string, noReplace bool) error {
+	srcParent, src, dst, err := o.checkRename(srcComponents, dstComponents, noReplace)
+	if err != nil {
+		return err
+	}
+	srcPath := filepath.Join(srcComponents...)
+	dstPath := filepath.Join(dstComponents...)
+	if srcPath == dstPath {
+		return nil
+	}
+	upperSrcParent, err := o.copyUpDirectory(srcComponents[:len(srcComponents)-1])
+	if err != nil {
+		return err
+	}
+	if !src.inUpper {
+		if err := copyUpFile(srcParent.lower, upperSrcParent, src.name, false); err != nil {
+			return err
+		}
+	}
+	upperDstParent, err := o.copyUpDirectory(dstComponents[:len(dstComponents)-1])
+	if err != nil {
+		return err
+	}
+	// The whiteouts are removed just before the move, and restored if it fails, so that the lower
+	// entries that they hide can't reappear
+	hadWhiteout, err := removeWhiteout(upperDstParent, dst.name)
+	if err != nil {
+		return err
+	}
+	restore := func() {
+		if hadWhiteout {
+			restoreWhiteouts(upperDstParent, []string{whiteoutPrefix + dst.name})
+		}
+	}
+	if dst.exists && dst.entryType == directory.DirectoryType {
+		// The replaced directory is empty in the merged view, but it may still hold whiteouts
+		cleared, err := clearWhiteouts(dst.layers.upper)
+		if err != nil {
+			restore()
+			return err
+		}
+		restoreDst := restore
+		restore = func() {
+			restoreWhiteouts(dst.layers.upper, cleared)
+			restoreDst()
+		}
+	}
+	move := o.upperRoot.Rename
+	if noReplace {
+		move = o.upperRoot.RenameNoReplace
+	}
+	if err := move(srcPath, dstPath); err != nil {
+		restore()
+		return err
+	}
+	if (hadWhiteout || dst.lowerHasName) && src.entryType == directory.DirectoryType {
+		// Don't let a removed or shadowed lower directory's contents appear in the moved directory
+		if err := o.upperRoot.SetXattr(dstPath, opaqueXattr, []byte("y")); err != nil {
+			return err
+		}
+	}
+	if src.lowerHasName {
+		return o.createWhiteout(srcComponents[:len(srcComponents)-1], src.name)
+	}
+	return nil
+}
+
+func (o *overlayDirectory) RenameExchange(relativePath1, relativePath2 string) error {
+	components1, _, err := o.resolvePath(relativePath1)
+	if err != nil {
+		return err
+	}
+	components2, _, err := o.resolvePath(relativePath2)
+	if err != nil {
+		return err
+	}
+	if err := o.exchange(components1, components2); err != nil {
+		return errors.Wrapf(err, "could not exchange '%s' and '%s'", relativePath1, relativePath2)
+	}
+	return nil
+}
+
+// exchange swaps the entries with components1 and components2 in the upper layer, copying them up
+// first if necessary
+func (o *overlayDirectory) exchange(components1, components2 []string) error {
+	if len(components1) <= o.rootDepth || len(components2) <= o.rootDepth {
+		return errors.Wrapf(fserrors.EInval, "cannot exchange the root directory")
+	}
+	parent1, entry1, err := o.resolveEntry(components1)
+	if err != nil {
+		return err
+	}
+	parent2, entry2, err := o.resolveEntry(components2)
+	if err != nil {
+		return err
+	}
+	path1 := filepath.Join(components1...)
+	path2 := filepath.Join(components2...)
+	for _, entry := range []overlayEntry{entry1, entry2} {
+		if !entry.exists {
+			return errors.Wrapf(fserrors.ENoEnt, "entry '%s' does not exist", entry.name)
+		}
+		if entry.entryType == directory.DirectoryType && entry.layers.lower != nil {
+			return errors.Wrapf(fserrors.EXDev, "cannot move a directory that exists in the lower layer")
+		}
+	}
+	if path1 == path2 {
+		return nil
+	}
+	if _, err := o.copyUpEntry(components1, parent1, entry1); err != nil {
+		return err
+	}
+	if _, err := o.copyUpEntry(components2, parent2, entry2); err != nil {
+		return err
+	}
+	if err := o.upperRoot.RenameExchange(path1, path2); err != nil {
+		return err
+	}
+	// Don't let a shadowed lower directory's contents appear in a moved directory
+	if entry1.entryType == directory.DirectoryType && entry2.lowerHasName {
+		if err := o.upperRoot.SetXattr(path2, opaqueXattr, []byte("y")); err != nil {
+			return err
+		}
+	}
+	if entry2.entryType == directory.DirectoryType && entry1.lowerHasName {
+		if err := o.upperRoot.SetXattr(path1, opaqueXattr, []byte("y")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (o *overlayDirectory) StatEntries(subdirectory string) ([]*directory.FileInfo, error) {
+	// The layers can't be snapshotted together, so this is only as consistent as ReadDir() followed
+	// by Stat()
+	entries, err := o.ReadDirSorted(subdirectory)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not stat entries in '%s'", subdirectory)
+	}
+	toReturn := make([]*directory.FileInfo, 0, len(entries))
+	for _, entry := range entries {
+		info, err := o.Stat(childPath(subdirectory, entry.Name))
+		if errors.Is(err, fserrors.ENoEnt) {
+			// The entry was removed after it was listed
+			continue
+		} else if err != nil {
+			return nil, errors.Wrapf(err, "could not stat entries in '%s'", subdirectory)
+		}
+		toReturn = append(toReturn, info)
+	}
+	return toReturn, nil
+}
+
+func (o *overlayDirectory) Stat(relativePath string) (*directory.FileInfo, error) {
+	components, mustBeDir, err := o.resolvePath(relativePath)
+	if err != nil {
+		return nil, err
+	}
+	var layers overlayLayers
+	if len(components) == 0 {
+		layers = o.rootLayers()
+	} else {
+		parent, entry, err := o.resolveEntry(components)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not stat '%s'", relativePath)
+		}
+		if !entry.exists {
+			return nil, errors.Wrapf(fserrors.ENoEnt, "could not stat '%s'", relativePath)
+		}
+		if entry.entryType != directory.DirectoryType {
+			if mustBeDir {
+				return nil, errors.Wrapf(fserrors.ENotDir, "file found where directory %s expected", relativePath)
+			}
+			if entry.inUpper {
+				return parent.upper.Stat(entry.name)
+			}
+			return parent.lower.Stat(entry.name)
+		}
+		layers = entry.layers
+	}
+	entries, err := readDir(layers)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not stat '%s'", relativePath)
+	}
+	// A merged directory takes the inode number of its upper layer's directory, if there is one
+	effective := layers.upper
+	if effective == nil {
+		effective = layers.lower
+	}
+	info, err := effective.Stat("")
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not stat '%s'", relativePath)
+	}
+	name := filepath.PathSeparator
+	if len(components) > o.rootDepth {
+		name = components[len(components)-1]
+	}
+	return &directory.FileInfo{
+		Name:       name,
+		Type:       directory.DirectoryType,
+		Size:       len(entries),
+		Ino:        info.Ino,
+		ModTime:    info.ModTime,
+		AccessTime: info.AccessTime,
+		ChangeTime: info.ChangeTime,
+		Uid:        info.Uid,
+		Gid:        info.Gid,
+		Perm:       info.Perm,
+	}, nil
+}
+
+// entryLayer returns the layer's directory that holds the visible entry at relativePath, and the
+// entry's path relative to that directory
+func (o *overlayDirectory) entryLayer(relativePath string) (directory.Directory, string, error) {
+	components, _, err := o.resolvePath(relativePath)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(components) == 0 {
+		return o.upperRoot, "", nil
+	}
+	parent, entry, err := o.resolveEntry(components)
+	if err != nil {
+		return nil, "", err
+	}
+	if !entry.exists {
+		return nil, "", errors.Wrapf(fserrors.ENoEnt, "entry '%s' does not exist", relativePath)
+	}
+	if entry.inUpper {
+		return parent.upper, entry.name, nil
+	}
+	return parent.lower, entry.name, nil
+}
+
+// copyUp copies the entry at relativePath up to the upper layer, if it isn't already there, and
+// returns its parent directory in the upper layer
+func (o *overlayDirectory) copyUp(relativePath string) (directory.Directory, string, error) {
+	components, _, err := o.resolvePath(relativePath)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(components) == 0 {
+		return o.upperRoot, "", nil
+	}
+	parent, entry, err := o.resolveEntry(components)
+	if err != nil {
+		return nil, "", err
+	}
+	if !entry.exists {
+		return nil, "", errors.Wrapf(fserrors.ENoEnt, "entry '%s' does not exist", relativePath)
+	}
+	upperParent, err := o.copyUpEntry(components, parent, entry)
+	if err != nil {
+		return nil, "", err
+	}
+	return upperParent, entry.name, nil
+}
+
+// copyUpEntry copies entry, which has the specified components and whose parent has the specified
+// layers, up to the upper layer, if it isn't already there.  It returns the entry's parent directory
+// in the upper layer.
+func (o *overlayDirectory) copyUpEntry(components []string, parent overlayLayers, entry overlayEntry) (directory.Directory, error) {
+	if entry.entryType == directory.DirectoryType {
+		if _, err := o.copyUpDirectory(components); err != nil {
+			return nil, err
+		}
+	}
+	upperParent, err := o.copyUpDirectory(components[:len(components)-1])
+	if err != nil {
+		return nil, err
+	}
+	if !entry.inUpper && entry.entryType != directory.DirectoryType {
+		if err := copyUpFile(parent.lower, upperParent, entry.name, false); err != nil {
+			return nil, err
+		}
+	}
+	return upperParent, nil
+}
+
+func (o *overlayDirectory) Chtimes(relativePath string, accessTime, modTime time.Time) error {
+	upperParent, entryName, err := o.copyUp(relativePath)
+	if err != nil {
+		return errors.Wrapf(err, "could not change times of '%s'", relativePath)
+	}
+	return upperParent.Chtimes(entryName, accessTime, modTime)
+}
+
+func (o *overlayDirectory) Chown(relativePath string, uid, gid int) error {
+	upperParent, entryName, err := o.copyUp(relativePath)
+	if err != nil {
+		return errors.Wrapf(err, "could not change owner of '%s'", relativePath)
+	}
+	return upperParent.Chown(entryName, uid, gid)
+}
+
+func (o *overlayDirectory) Chmod(relativePath string, mode os.FileMode) error {
+	upperParent, entryName, err := o.copyUp(relativePath)
+	if err != nil {
+		return errors.Wrapf(err, "could not change mode of '%s'", relativePath)
+	}
+	return upperParent.Chmod(entryName, mode)
+}
+
+func (o *overlayDirectory) SetXattr(relativePath, name string, value []byte) error {
+	if name == opaqueXattr {
+		return errors.Wrapf(fserrors.EInval, "extended attribute '%s' is reserved", name)
+	}
+	upperParent, entryName, err := o.copyUp(relativePath)
+	if err != nil {
+		return errors.Wrapf(err, "could not set extended attribute on '%s'", relativePath)
+	}
+	return upperParent.SetXattr(entryName, name, value)
+}
+
+func (o *overlayDirectory) GetXattr(relativePath, name string) ([]byte, error) {
+	if name == opaqueXattr {
+		return nil, errors.Wrapf(fserrors.ENoData, "no extended attribute named '%s'", name)
+	}
+	dir, entryName, err := o.entryLayer(relativePath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not get extended attribute of '%s'", relativePath)
+	}
+	return dir.GetXattr(entryName, name)
+}
+
+func (o *overlayDirectory) ListXattr(relativePath string) ([]string, error) {
+	dir, entryName, err := o.entryLayer(relativePath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not list extended attributes of '%s'", relativePath)
+	}
+	names, err := dir.ListXattr(entryName)
+	if err != nil {
+		return nil, err
+	}
+	toReturn := make([]string, 0, len(names))
+	for _, name := range names {
+		if name != opaqueXattr {
+			toReturn = append(toReturn, name)
+		}
+	}
+	return toReturn, nil
+}
+
+func (o *overlayDirectory) RemoveXattr(relativePath, name string) error {
+	if name == opaqueXattr {
+		return errors.Wrapf(fserrors.ENoData, "no extended attribute named '%s'", name)
+	}
+	upperParent, entryName, err := o.copyUp(relativePath)
+	if err != nil {
+		return errors.Wrapf(err, "could not remove extended attribute from '%s'", relativePath)
+	}
+	return upperParent.RemoveXattr(entryName, name)
+}
+
+func (o *overlayDirectory) Watch(relativePath string) (<-chan notify.Event, func(), error) {
+	return nil, nil, errors.Wrapf(fserrors.EInval, "overlay filesystems do not support watches")
+}
+
+// overlayDirReader is a directory.DirReader over a snapshot of a merged directory's entries
+type overlayDirReader struct {
+	mutex   sync.Mutex // synchronizes access to entries
+	entries []directory.DirectoryEntry
+}
+
+func (r *overlayDirReader) ReadDir(n int) ([]directory.DirectoryEntry, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if n > 0 && len(r.entries) == 0 {
+		return []directory.DirectoryEntry{}, io.EOF
+	}
+	if n <= 0 || n > len(r.entries) {
+		n = len(r.entries)
+	}
+	toReturn := r.entries[:n]
+	r.entries = r.entries[n:]
+	return toReturn, nil
+}
@@ -0,0 +1,333 @@
+package filesys_test
+
+import (
+	"testing"
+
+	"github.com/manderson5192/memfs/filesys"
+	"github.com/manderson5192/memfs/fserrors"
+	"github.com/manderson5192/memfs/os"
+	"github.com/manderson5192/memfs/process"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type OverlayTestSuite struct {
+	suite.Suite
+	lowerP   process.ProcessFilesystemContext
+	upperP   process.ProcessFilesystemContext
+	overlayP process.ProcessFilesystemContext
+}
+
+func (s *OverlayTestSuite) SetupTest() {
+	lower := filesys.NewFileSystem()
+	upper := filesys.NewFileSystem()
+	s.lowerP = process.NewProcessFilesystemContext(lower)
+	s.upperP = process.NewProcessFilesystemContext(upper)
+	assert.Nil(s.T(), s.lowerP.MakeDirectoryWithAncestors("/a/b"))
+	assert.Nil(s.T(), s.lowerP.WriteFile("/a/lower_file", []byte("lower"), 0))
+	assert.Nil(s.T(), s.lowerP.WriteFile("/a/b/deep_file", []byte("deep"), 0))
+	assert.Nil(s.T(), s.lowerP.WriteFile("/top_file", []byte("top"), 0))
+	s.overlayP = process.NewProcessFilesystemContext(filesys.NewOverlay(lower, upper))
+}
+
+func (s *OverlayTestSuite) readDirNames(p process.ProcessFilesystemContext, path string) []string {
+	entries, err := p.ListDirectorySorted(path)
+	assert.Nil(s.T(), err)
+	names := []string{}
+	for _, entry := range entries {
+		names = append(names, entry.Name)
+	}
+	return names
+}
+
+func (s *OverlayTestSuite) assertIsDir(path string) {
+	s.assertIsDirIn(s.overlayP, path)
+}
+
+func (s *OverlayTestSuite) assertIsDirIn(p process.ProcessFilesystemContext, path string) {
+	isDir, err := p.IsDir(path)
+	assert.Nil(s.T(), err)
+	assert.True(s.T(), isDir)
+}
+
+func (s *OverlayTestSuite) TestReadThrough() {
+	data, err := s.overlayP.ReadFile("/a/b/deep_file")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []byte("deep"), data)
+	s.assertIsDir("/a/b")
+	assert.False(s.T(), s.upperP.Exists("/a"))
+}
+
+func (s *OverlayTestSuite) TestCopyUpOnWrite() {
+	f, err := s.overlayP.OpenFile("/a/b/deep_file", os.O_RDWR)
+	assert.Nil(s.T(), err)
+	_, err = f.WriteAt([]byte("DE"), 0)
+	assert.Nil(s.T(), err)
+
+	data, err := s.overlayP.ReadFile("/a/b/deep_file")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []byte("DEep"), data)
+	data, err = s.upperP.ReadFile("/a/b/deep_file")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []byte("DEep"), data)
+	data, err = s.lowerP.ReadFile("/a/b/deep_file")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []byte("deep"), data)
+}
+
+func (s *OverlayTestSuite) TestCopyUpWithTruncate() {
+	assert.Nil(s.T(), s.overlayP.WriteFile("/top_file", []byte("new"), 0))
+	data, err := s.overlayP.ReadFile("/top_file")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []byte("new"), data)
+	data, err = s.lowerP.ReadFile("/top_file")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []byte("top"), data)
+}
+
+func (s *OverlayTestSuite) TestCopyUpPreservesOwner() {
+	assert.Nil(s.T(), s.lowerP.Chown("/a/b", 5, 6))
+	assert.Nil(s.T(), s.lowerP.Chown("/a/b/deep_file", 7, 8))
+	assert.Nil(s.T(), s.overlayP.WriteFile("/a/b/deep_file", []byte("new"), 0))
+	info, err := s.upperP.Stat("/a/b")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []int{5, 6}, []int{info.Uid, info.Gid})
+	info, err = s.upperP.Stat("/a/b/deep_file")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []int{7, 8}, []int{info.Uid, info.Gid})
+
+	// Chown copies up the entry without changing the lower layer
+	assert.Nil(s.T(), s.overlayP.Chown("/top_file", 1, 2))
+	info, err = s.overlayP.Stat("/top_file")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []int{1, 2}, []int{info.Uid, info.Gid})
+	info, err = s.lowerP.Stat("/top_file")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []int{0, 0}, []int{info.Uid, info.Gid})
+}
+
+func (s *OverlayTestSuite) TestCopyUpPreservesMode() {
+	assert.Nil(s.T(), s.lowerP.Chmod("/a/b", 0700))
+	assert.Nil(s.T(), s.lowerP.Chmod("/a/b/deep_file", 0600))
+	s.overlayP.SetUmask(077)
+	f, err := s.overlayP.OpenFile("/a/b/deep_file", os.O_RDWR)
+	assert.Nil(s.T(), err)
+	_, err = f.WriteAt([]byte("DE"), 0)
+	assert.Nil(s.T(), err)
+	info, err := s.upperP.Stat("/a/b")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), os.FileMode(0700), info.Perm)
+	info, err = s.upperP.Stat("/a/b/deep_file")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), os.FileMode(0600), info.Perm)
+
+	// New entries are masked by the overlay context's umask
+	assert.Nil(s.T(), s.overlayP.WriteFile("/a/new_file", []byte{}, 0))
+	info, err = s.overlayP.Stat("/a/new_file")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), os.FileMode(0600), info.Perm)
+	assert.Nil(s.T(), s.overlayP.MakeDirectory("/a/new_dir"))
+	info, err = s.overlayP.Stat("/a/new_dir")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), os.FileMode(0700), info.Perm)
+	assert.Nil(s.T(), s.overlayP.MakeDirectoryMode("/a/new_dir/sub", 0750))
+	info, err = s.upperP.Stat("/a/new_dir/sub")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), os.FileMode(0700), info.Perm)
+}
+
+func (s *OverlayTestSuite) TestDeleteCreatesWhiteout() {
+	assert.Nil(s.T(), s.overlayP.DeleteFile("/a/lower_file"))
+	assert.False(s.T(), s.overlayP.Exists("/a/lower_file"))
+	_, err := s.overlayP.ReadFile("/a/lower_file")
+	assert.ErrorIs(s.T(), err, fserrors.ENoEnt)
+	assert.Equal(s.T(), []string{"b"}, s.readDirNames(s.overlayP, "/a"))
+	assert.True(s.T(), s.lowerP.Exists("/a/lower_file"))
+
+	// Recreating the file removes the whiteout
+	assert.Nil(s.T(), s.overlayP.WriteFile("/a/lower_file", []byte("again"), 0))
+	data, err := s.overlayP.ReadFile("/a/lower_file")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []byte("again"), data)
+	assert.Equal(s.T(), []string{"b", "lower_file"}, s.readDirNames(s.overlayP, "/a"))
+}
+
+func (s *OverlayTestSuite) TestMergedReadDir() {
+	assert.Nil(s.T(), s.overlayP.WriteFile("/a/upper_file", []byte("upper"), 0))
+	assert.Nil(s.T(), s.overlayP.WriteFile("/a/lower_file", []byte("shadowed"), 0))
+	assert.Equal(s.T(), []string{"b", "lower_file", "upper_file"}, s.readDirNames(s.overlayP, "/a"))
+
+	info, err := s.overlayP.Stat("/a")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), "a", info.Name)
+	assert.Equal(s.T(), 3, info.Size)
+}
+
+func (s *OverlayTestSuite) TestRmdirAndMkdirIsOpaque() {
+	assert.ErrorIs(s.T(), s.overlayP.RemoveDirectory("/a/b"), fserrors.ENotEmpty)
+	assert.Nil(s.T(), s.overlayP.DeleteFile("/a/b/deep_file"))
+	assert.Nil(s.T(), s.overlayP.RemoveDirectory("/a/b"))
+	assert.False(s.T(), s.overlayP.Exists("/a/b"))
+
+	assert.Nil(s.T(), s.overlayP.MakeDirectory("/a/b"))
+	assert.Equal(s.T(), []string{}, s.readDirNames(s.overlayP, "/a/b"))
+	names, err := s.overlayP.ListXattr("/a/b")
+	assert.Nil(s.T(), err)
+	assert.Empty(s.T(), names)
+	assert.True(s.T(), s.lowerP.Exists("/a/b/deep_file"))
+}
+
+// newOverlayP returns a context for an overlay of a copy of the suite's lower layer beneath upper
+func (s *OverlayTestSuite) newOverlayP(upper filesys.FileSystem) process.ProcessFilesystemContext {
+	lower := filesys.NewFileSystem()
+	lowerP := process.NewProcessFilesystemContext(lower)
+	assert.Nil(s.T(), lowerP.MakeDirectoryWithAncestors("/a/b"))
+	assert.Nil(s.T(), lowerP.WriteFile("/a/lower_file", []byte("lower"), 0))
+	assert.Nil(s.T(), lowerP.WriteFile("/a/b/deep_file", []byte("deep"), 0))
+	assert.Nil(s.T(), lowerP.WriteFile("/top_file", []byte("top"), 0))
+	return process.NewProcessFilesystemContext(filesys.NewOverlay(lower, upper))
+}
+
+func (s *OverlayTestSuite) TestFailedCreateKeepsWhiteout() {
+	// Copying up /a/b succeeds, but recreating it after it is removed fails
+	overlayP := s.newOverlayP(filesys.NewFileSystemWithFaults(filesys.FaultConfig{
+		Faults: []filesys.Fault{
+			{Op: filesys.FaultMkdir, Path: "/a/b", AfterN: 1},
+			{Op: filesys.FaultOpen, Path: "/top_file"},
+		},
+	}))
+	assert.Nil(s.T(), overlayP.DeleteFile("/a/b/deep_file"))
+	assert.Nil(s.T(), overlayP.RemoveDirectory("/a/b"))
+	assert.ErrorIs(s.T(), overlayP.MakeDirectory("/a/b"), fserrors.EIO)
+	assert.False(s.T(), overlayP.Exists("/a/b"))
+
+	assert.Nil(s.T(), overlayP.DeleteFile("/top_file"))
+	assert.ErrorIs(s.T(), overlayP.WriteFile("/top_file", []byte("new"), 0), fserrors.EIO)
+	assert.False(s.T(), overlayP.Exists("/top_file"))
+}
+
+func (s *OverlayTestSuite) TestFailedRenameKeepsWhiteouts() {
+	// Moving /src beneath /a would put /src/sub too deep in the upper layer
+	overlayP := s.newOverlayP(filesys.NewFileSystemWithLimits(filesys.Limits{MaxPathDepth: 2}))
+	assert.Nil(s.T(), overlayP.DeleteFile("/a/lower_file"))
+	assert.Nil(s.T(), overlayP.MakeDirectoryWithAncestors("/src/sub"))
+	assert.ErrorIs(s.T(), overlayP.Rename("/src", "/a/lower_file"), fserrors.ENameTooLong)
+	assert.False(s.T(), overlayP.Exists("/a/lower_file"))
+	s.assertIsDirIn(overlayP, "/src/sub")
+}
+
+func (s *OverlayTestSuite) TestCreateExclusive() {
+	f, created, err := s.overlayP.CreateExclusive("/a/new_file")
+	assert.Nil(s.T(), err)
+	assert.NotNil(s.T(), f)
+	assert.True(s.T(), created)
+	_, created, err = s.overlayP.CreateExclusive("/a/new_file")
+	assert.Nil(s.T(), err)
+	assert.False(s.T(), created)
+
+	// A lower file is copied up, not created
+	f, created, err = s.overlayP.CreateExclusive("/a/lower_file")
+	assert.Nil(s.T(), err)
+	assert.False(s.T(), created)
+	data, err := f.ReadAll()
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []byte("lower"), data)
+	assert.True(s.T(), s.upperP.Exists("/a/lower_file"))
+
+	// A removed lower file is created again
+	assert.Nil(s.T(), s.overlayP.DeleteFile("/top_file"))
+	_, created, err = s.overlayP.CreateExclusive("/top_file")
+	assert.Nil(s.T(), err)
+	assert.True(s.T(), created)
+	assert.Equal(s.T(), []string{"a", "top_file"}, s.readDirNames(s.upperP, "/"))
+}
+
+func (s *OverlayTestSuite) TestWhiteoutNamesAreRejected() {
+	_, err := s.overlayP.CreateFile("/.wh.top_file")
+	assert.ErrorIs(s.T(), err, fserrors.EInval)
+}
+
+func (s *OverlayTestSuite) TestRename() {
+	assert.Nil(s.T(), s.overlayP.Rename("/a/lower_file", "/moved"))
+	assert.False(s.T(), s.overlayP.Exists("/a/lower_file"))
+	data, err := s.overlayP.ReadFile("/moved")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []byte("lower"), data)
+	assert.True(s.T(), s.lowerP.Exists("/a/lower_file"))
+
+	assert.ErrorIs(s.T(), s.overlayP.Rename("/a/b", "/c"), fserrors.EXDev)
+
+	assert.Nil(s.T(), s.overlayP.MakeDirectory("/new_dir"))
+	assert.Nil(s.T(), s.overlayP.Rename("/new_dir", "/renamed_dir"))
+	s.assertIsDir("/renamed_dir")
+}
+
+func (s *OverlayTestSuite) TestCanRename() {
+	assert.Nil(s.T(), s.overlayP.CanRename("/a/lower_file", "/moved"))
+	assert.ErrorIs(s.T(), s.overlayP.CanRename("/a/b", "/c"), fserrors.EXDev)
+	assert.ErrorIs(s.T(), s.overlayP.CanRename("/a/missing", "/c"), fserrors.ENoEnt)
+	assert.ErrorIs(s.T(), s.overlayP.CanRename("/top_file", "/a/b"), fserrors.EIsDir)
+	// Checking copies nothing up
+	assert.False(s.T(), s.upperP.Exists("/a"))
+	assert.False(s.T(), s.overlayP.Exists("/moved"))
+
+	// A check reflects the destination directory's contents at the time it is made
+	assert.Nil(s.T(), s.overlayP.MakeDirectory("/new_dir"))
+	assert.Nil(s.T(), s.overlayP.MakeDirectory("/empty_dir"))
+	assert.Nil(s.T(), s.overlayP.Rename("/a/lower_file", "/empty_dir/file"))
+	assert.ErrorIs(s.T(), s.overlayP.CanRename("/new_dir", "/empty_dir"), fserrors.ENotEmpty)
+	assert.Nil(s.T(), s.overlayP.DeleteFile("/empty_dir/file"))
+	assert.Nil(s.T(), s.overlayP.CanRename("/new_dir", "/empty_dir"))
+	assert.Nil(s.T(), s.overlayP.Rename("/new_dir", "/empty_dir"))
+	s.assertIsDir("/empty_dir")
+}
+
+func (s *OverlayTestSuite) TestRenameExchange() {
+	assert.Nil(s.T(), s.overlayP.WriteFile("/upper_file", []byte("upper"), 0))
+	assert.Nil(s.T(), s.overlayP.RenameExchange("/upper_file", "/a/lower_file"))
+	data, err := s.overlayP.ReadFile("/upper_file")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []byte("lower"), data)
+	data, err = s.overlayP.ReadFile("/a/lower_file")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []byte("upper"), data)
+	data, err = s.lowerP.ReadFile("/a/lower_file")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []byte("lower"), data)
+
+	assert.ErrorIs(s.T(), s.overlayP.RenameExchange("/a/b", "/top_file"), fserrors.EXDev)
+}
+
+func (s *OverlayTestSuite) TestChangeDirectoryToFileParent() {
+	f, err := s.overlayP.OpenFile("/a/b/deep_file", os.O_RDONLY)
+	assert.Nil(s.T(), err)
+	assert.Nil(s.T(), s.overlayP.ChangeDirectoryToFileParent(f))
+	workdir, err := s.overlayP.WorkingDirectory()
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), "/a/b", workdir)
+}
+
+func (s *OverlayTestSuite) TestIsValid() {
+	lower := filesys.NewFileSystem()
+	_, err := lower.RootDirectory().Mkdir("lower_dir")
+	assert.Nil(s.T(), err)
+	overlay := filesys.NewOverlay(lower, filesys.NewFileSystem())
+	root := overlay.RootDirectory()
+	lowerDir, err := root.LookupSubdirectory("lower_dir")
+	assert.Nil(s.T(), err)
+	upperDir, err := root.Mkdir("upper_dir")
+	assert.Nil(s.T(), err)
+	assert.True(s.T(), root.IsValid())
+	assert.True(s.T(), lowerDir.IsValid())
+	assert.True(s.T(), upperDir.IsValid())
+
+	assert.Nil(s.T(), root.Rmdir("lower_dir"))
+	assert.Nil(s.T(), root.Rmdir("upper_dir"))
+	assert.False(s.T(), lowerDir.IsValid())
+	assert.False(s.T(), upperDir.IsValid())
+	assert.True(s.T(), root.IsValid())
+}
+
+func TestOverlayTestSuite(t *testing.T) {
+	suite.Run(t, new(OverlayTestSuite))
+}
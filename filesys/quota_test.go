@@ -0,0 +1,145 @@
+package filesys_test
+
+import (
+	"testing"
+
+	"github.com/manderson5192/memfs/filesys"
+	"github.com/manderson5192/memfs/fserrors"
+	"github.com/manderson5192/memfs/os"
+	"github.com/manderson5192/memfs/process"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type QuotaTestSuite struct {
+	suite.Suite
+	fs filesys.FileSystem
+	p  process.ProcessFilesystemContext
+}
+
+func (s *QuotaTestSuite) SetupTest() {
+	s.fs = filesys.NewFileSystemWithQuota(10)
+	s.p = process.NewProcessFilesystemContext(s.fs)
+	assert.Nil(s.T(), s.p.MakeDirectory("/a"))
+}
+
+func (s *QuotaTestSuite) TestFillDeleteAndRefill() {
+	// Fill the filesystem to its limit across two files
+	f1, err := s.p.CreateFile("/a/one")
+	assert.Nil(s.T(), err)
+	n, err := f1.Write([]byte("123456"))
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), 6, n)
+	f2, err := s.p.CreateFile("/a/two")
+	assert.Nil(s.T(), err)
+	assert.Nil(s.T(), f2.TruncateAndWriteAll([]byte("7890")))
+
+	// Any growth is now rejected, and leaves the files untouched
+	n, err = f2.WriteAt([]byte("x"), 4)
+	assert.ErrorIs(s.T(), err, fserrors.ENoSpace)
+	assert.Equal(s.T(), 0, n)
+	_, err = f1.WriteAt([]byte("x"), 100)
+	assert.ErrorIs(s.T(), err, fserrors.ENoSpace)
+	assert.ErrorIs(s.T(), f2.TruncateAndWriteAll([]byte("12345")), fserrors.ENoSpace)
+	assert.Equal(s.T(), 6, f1.Size())
+	assert.Equal(s.T(), 4, f2.Size())
+
+	// Overwriting existing bytes doesn't require any additional space
+	_, err = f1.WriteAt([]byte("abc"), 0)
+	assert.Nil(s.T(), err)
+
+	// Deleting a file frees its space
+	assert.Nil(s.T(), s.p.DeleteFile("/a/one"))
+	f3, err := s.p.CreateFile("/a/three")
+	assert.Nil(s.T(), err)
+	assert.Nil(s.T(), f3.TruncateAndWriteAll([]byte("abcdef")))
+}
+
+func (s *QuotaTestSuite) TestShrinkingFreesSpace() {
+	f, err := s.p.CreateFile("/a/file")
+	assert.Nil(s.T(), err)
+	assert.Nil(s.T(), f.TruncateAndWriteAll([]byte("0123456789")))
+	assert.ErrorIs(s.T(), f.TruncateAndWriteAll([]byte("0123456789a")), fserrors.ENoSpace)
+
+	// Truncating on open frees the file's space
+	_, err = s.p.OpenFile("/a/file", os.O_RDWR|os.O_TRUNC)
+	assert.Nil(s.T(), err)
+	other, err := s.p.CreateFile("/a/other")
+	assert.Nil(s.T(), err)
+	assert.Nil(s.T(), other.TruncateAndWriteAll([]byte("0123456789")))
+}
+
+func (s *QuotaTestSuite) TestOverwritingRenameFreesSpace() {
+	f, err := s.p.CreateFile("/a/big")
+	assert.Nil(s.T(), err)
+	assert.Nil(s.T(), f.TruncateAndWriteAll([]byte("01234567")))
+	small, err := s.p.CreateFile("/a/small")
+	assert.Nil(s.T(), err)
+	assert.Nil(s.T(), small.TruncateAndWriteAll([]byte("01")))
+
+	// Renaming small over big releases big's 8 bytes
+	assert.Nil(s.T(), s.p.Rename("/a/small", "/a/big"))
+	assert.Nil(s.T(), small.TruncateAndWriteAll([]byte("0123456789")))
+}
+
+func (s *QuotaTestSuite) TestDeletedFileStaysWritable() {
+	f, err := s.p.CreateFile("/a/file")
+	assert.Nil(s.T(), err)
+	assert.Nil(s.T(), f.TruncateAndWriteAll([]byte("0123456789")))
+	assert.Nil(s.T(), s.p.DeleteFile("/a/file"))
+
+	// The open handle keeps working, and its data no longer counts against the quota
+	assert.Nil(s.T(), f.TruncateAndWriteAll([]byte("0123456789abcdef")))
+	other, err := s.p.CreateFile("/a/other")
+	assert.Nil(s.T(), err)
+	assert.Nil(s.T(), other.TruncateAndWriteAll([]byte("0123456789")))
+}
+
+func (s *QuotaTestSuite) TestSnapshotRestoreAccounting() {
+	f, err := s.p.CreateFile("/a/file")
+	assert.Nil(s.T(), err)
+	assert.Nil(s.T(), f.TruncateAndWriteAll([]byte("01234")))
+	snap, err := filesys.TakeSnapshot(s.fs)
+	assert.Nil(s.T(), err)
+
+	// Restoring the snapshot swaps the live file's usage for the restored file's usage
+	assert.Nil(s.T(), f.TruncateAndWriteAll([]byte("0123456789")))
+	assert.Nil(s.T(), snap.Restore(s.fs))
+	restored, err := s.p.OpenFile("/a/file", os.O_RDWR)
+	assert.Nil(s.T(), err)
+	assert.Nil(s.T(), restored.TruncateAndWriteAll([]byte("0123456789")))
+	_, err = restored.WriteAt([]byte("x"), 10)
+	assert.ErrorIs(s.T(), err, fserrors.ENoSpace)
+
+	// A fork has the same quota as the original
+	forkedProcess := process.NewProcessFilesystemContext(snap.Fork())
+	forked, err := forkedProcess.OpenFile("/a/file", os.O_RDWR)
+	assert.Nil(s.T(), err)
+	assert.ErrorIs(s.T(), forked.TruncateAndWriteAll([]byte("0123456789a")), fserrors.ENoSpace)
+}
+
+func (s *QuotaTestSuite) TestUnlinkedDirectoryIsChargedUntilClosed() {
+	assert.Nil(s.T(), s.p.MakeDirectory("/a/b"))
+	assert.Nil(s.T(), s.p.WriteFile("/a/b/file", []byte("1234567890"), 0))
+	handle, err := s.p.OpenDirectory("/a/b")
+	assert.Nil(s.T(), err)
+	assert.ErrorIs(s.T(), s.p.RemoveDirectory("/a/b"), fserrors.ENotEmpty)
+	assert.Nil(s.T(), s.p.RemoveOpenDirectory("/a/b"))
+
+	// The unlinked directory's file is still reachable, so it still counts against the quota
+	data, err := s.p.ReadFile("/a/b/file")
+	assert.ErrorIs(s.T(), err, fserrors.ENoEnt)
+	assert.Nil(s.T(), data)
+	f, err := handle.OpenFile("file", os.O_RDONLY)
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), 10, f.Size())
+	assert.ErrorIs(s.T(), s.p.WriteFile("/a/other", []byte("x"), 0), fserrors.ENoSpace)
+
+	// Closing the last handle unlinks the file and frees its space
+	assert.Nil(s.T(), handle.Close())
+	assert.Nil(s.T(), s.p.WriteFile("/a/other", []byte("x"), 0))
+}
+
+func TestQuotaTestSuite(t *testing.T) {
+	suite.Run(t, new(QuotaTestSuite))
+}
@@ -0,0 +1,79 @@
+package filesys
+
+import (
+	"github.com/manderson5192/memfs/fserrors"
+	"github.com/manderson5192/memfs/inode"
+	"github.com/pkg/errors"
+)
+
+// Snapshot is a point-in-time copy of a FileSystem's directory tree.  It is implemented as a deep
+// copy of the inode graph, so mutations made to the live FileSystem after the Snapshot is taken do
+// not alter it.  Nothing is shared copy-on-write: taking, restoring, and forking a Snapshot each
+// copy every directory and all of the file data in the tree, so they cost time and memory in
+// proportion to the tree's size.
+//
+// File and Directory handles opened before a Snapshot is taken continue to reference the live
+// FileSystem's inodes, never the Snapshot's.  After a Restore(), those handles behave like handles
+// to deleted files and directories: they keep working, but their inodes are no longer reachable by
+// path.
+type Snapshot interface {
+	// Restore reverts fs's directory tree to the state captured by this Snapshot.  The Snapshot
+	// itself is unaffected, so it may be restored any number of times.
+	Restore(fs FileSystem) error
+	// RestoreFrozen behaves like Restore, but for a filesystem that the calling goroutine has frozen
+	// (see Freeze()), which Restore would wait for forever.  It lets the caller inspect fs and then
+	// restore it without any other mutation slipping in between.
+	RestoreFrozen(fs FileSystem) error
+	// Fork returns a new FileSystem, independent of both the Snapshot and the FileSystem from
+	// which it was taken, whose directory tree is a copy of the Snapshot
+	Fork() FileSystem
+}
+
+type snapshot struct {
+	rootDirectory *inode.DirectoryInode
+	// superblock is the Superblock of the FileSystem that the snapshot was taken from.  Fork() uses
+	// it to configure the new FileSystem like the original.
+	superblock *inode.Superblock
+}
+
+// TakeSnapshot captures the current state of fs's directory tree and returns it as a Snapshot
+func TakeSnapshot(fs FileSystem) (Snapshot, error) {
+	f, ok := fs.(*fileSystem)
+	if !ok {
+		return nil, errors.Wrapf(fserrors.EInval, "cannot snapshot a filesystem of type %T", fs)
+	}
+	return &snapshot{
+		rootDirectory: f.rootDirectory.CloneTree(),
+		superblock:    f.superblock,
+	}, nil
+}
+
+func (s *snapshot) Restore(fs FileSystem) error {
+	f, ok := fs.(*fileSystem)
+	if !ok {
+		return errors.Wrapf(fserrors.EInval, "cannot restore a snapshot to a filesystem of type %T", fs)
+	}
+	defer f.superblock.CountMutation(nil)()
+	f.rootDirectory.RestoreFrom(s.rootDirectory)
+	return nil
+}
+
+func (s *snapshot) RestoreFrozen(fs FileSystem) error {
+	f, ok := fs.(*fileSystem)
+	if !ok {
+		return errors.Wrapf(fserrors.EInval, "cannot restore a snapshot to a filesystem of type %T", fs)
+	}
+	defer f.superblock.CountMutation(nil)()
+	f.rootDirectory.RestoreFromFrozen(s.rootDirectory)
+	return nil
+}
+
+func (s *snapshot) Fork() FileSystem {
+	sb := inode.NewSuperblockFrom(s.superblock)
+	rootDirectory := s.rootDirectory.CloneTree()
+	rootDirectory.AttachTree(sb)
+	return &fileSystem{
+		rootDirectory: rootDirectory,
+		superblock:    sb,
+	}
+}
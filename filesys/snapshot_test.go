@@ -0,0 +1,121 @@
+package filesys_test
+
+import (
+	"testing"
+
+	"github.com/manderson5192/memfs/directory"
+	"github.com/manderson5192/memfs/filesys"
+	"github.com/manderson5192/memfs/fserrors"
+	"github.com/manderson5192/memfs/os"
+	"github.com/manderson5192/memfs/process"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type SnapshotTestSuite struct {
+	suite.Suite
+	fs filesys.FileSystem
+	p  process.ProcessFilesystemContext
+}
+
+func (s *SnapshotTestSuite) SetupTest() {
+	// Setup a process context with a basic file tree
+	s.fs = filesys.NewFileSystem()
+	s.p = process.NewProcessFilesystemContext(s.fs)
+	assert.Nil(s.T(), s.p.MakeDirectory("/a"))
+	assert.Nil(s.T(), s.p.MakeDirectory("/a/b"))
+	foobarFile, err := s.p.CreateFile("/a/foobar_file")
+	assert.Nil(s.T(), err)
+	assert.Nil(s.T(), foobarFile.TruncateAndWriteAll([]byte("hello!")))
+}
+
+// walkPaths returns every path in p's tree, in Walk() order
+func walkPaths(t *testing.T, p process.ProcessFilesystemContext) []string {
+	paths := make([]string, 0)
+	err := p.Walk("/", func(path string, fileInfo *directory.FileInfo, err error) error {
+		assert.Nil(t, err, "WalkFunc shouldn't receive any errors")
+		paths = append(paths, path)
+		return nil
+	})
+	assert.Nil(t, err)
+	return paths
+}
+
+func (s *SnapshotTestSuite) TestRestore() {
+	snap, err := filesys.TakeSnapshot(s.fs)
+	assert.Nil(s.T(), err)
+
+	// Mutate the live filesystem
+	assert.Nil(s.T(), s.p.MakeDirectory("/a/b/c"))
+	assert.Nil(s.T(), s.p.Rename("/a/foobar_file", "/moved_file"))
+	f, err := s.p.OpenFile("/moved_file", os.O_RDWR)
+	assert.Nil(s.T(), err)
+	assert.Nil(s.T(), f.TruncateAndWriteAll([]byte("goodbye!")))
+	assert.Equal(s.T(), []string{"/", "/a", "/a/b", "/a/b/c", "/moved_file"}, walkPaths(s.T(), s.p))
+
+	// Restore the snapshot and verify that the tree has reverted
+	assert.Nil(s.T(), snap.Restore(s.fs))
+	assert.Equal(s.T(), []string{"/", "/a", "/a/b", "/a/foobar_file"}, walkPaths(s.T(), s.p))
+	restored, err := s.p.OpenFile("/a/foobar_file", os.O_RDONLY)
+	assert.Nil(s.T(), err)
+	data, err := restored.ReadAll()
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), "hello!", string(data))
+
+	// The handle opened before the restore still refers to the (now unlinked) live inode
+	data, err = f.ReadAll()
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), "goodbye!", string(data))
+	assert.False(s.T(), f.Equals(restored))
+}
+
+func (s *SnapshotTestSuite) TestRestoreTwice() {
+	snap, err := filesys.TakeSnapshot(s.fs)
+	assert.Nil(s.T(), err)
+
+	assert.Nil(s.T(), s.p.RemoveDirectory("/a/b"))
+	assert.Nil(s.T(), snap.Restore(s.fs))
+	assert.Nil(s.T(), s.p.RemoveDirectory("/a/b"))
+	assert.Nil(s.T(), snap.Restore(s.fs))
+	assert.Equal(s.T(), []string{"/", "/a", "/a/b", "/a/foobar_file"}, walkPaths(s.T(), s.p))
+}
+
+func (s *SnapshotTestSuite) TestHandleOpenedBeforeSnapshot() {
+	f, err := s.p.OpenFile("/a/foobar_file", os.O_RDWR)
+	assert.Nil(s.T(), err)
+	snap, err := filesys.TakeSnapshot(s.fs)
+	assert.Nil(s.T(), err)
+
+	// Writes through the pre-existing handle affect the live filesystem, not the snapshot
+	assert.Nil(s.T(), f.TruncateAndWriteAll([]byte("changed")))
+	forkedProcess := process.NewProcessFilesystemContext(snap.Fork())
+	forked, err := forkedProcess.OpenFile("/a/foobar_file", os.O_RDONLY)
+	assert.Nil(s.T(), err)
+	data, err := forked.ReadAll()
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), "hello!", string(data))
+}
+
+func (s *SnapshotTestSuite) TestForkIsIndependent() {
+	snap, err := filesys.TakeSnapshot(s.fs)
+	assert.Nil(s.T(), err)
+	forkedProcess := process.NewProcessFilesystemContext(snap.Fork())
+
+	// Mutations to the fork are not visible in the live filesystem, and vice versa
+	assert.Nil(s.T(), forkedProcess.MakeDirectory("/forked"))
+	assert.Nil(s.T(), s.p.MakeDirectory("/live"))
+	_, err = s.p.Stat("/forked")
+	assert.ErrorIs(s.T(), err, fserrors.ENoEnt)
+	_, err = forkedProcess.Stat("/live")
+	assert.ErrorIs(s.T(), err, fserrors.ENoEnt)
+
+	// The fork's reverse path lookups work as they would on the original
+	assert.Nil(s.T(), forkedProcess.ChangeDirectory("/a/b"))
+	workdir, err := forkedProcess.WorkingDirectory()
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), "/a/b", workdir)
+}
+
+func TestSnapshotTestSuite(t *testing.T) {
+	suite.Run(t, new(SnapshotTestSuite))
+}
@@ -0,0 +1,142 @@
+package filesys_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io"
+	"testing"
+
+	"github.com/manderson5192/memfs/filesys"
+	"github.com/manderson5192/memfs/fserrors"
+	"github.com/manderson5192/memfs/os"
+	"github.com/manderson5192/memfs/process"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type SparseTestSuite struct {
+	suite.Suite
+	fs filesys.FileSystem
+	p  process.ProcessFilesystemContext
+}
+
+func (s *SparseTestSuite) SetupTest() {
+	s.fs = filesys.NewFileSystemSparse()
+	s.p = process.NewProcessFilesystemContext(s.fs)
+}
+
+func (s *SparseTestSuite) TestWriteAtHugeOffset() {
+	const offset = 1 << 30
+	f, err := s.p.CreateFile("/sparse")
+	assert.Nil(s.T(), err)
+	n, err := f.WriteAt([]byte("hello"), offset)
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), 5, n)
+	assert.Equal(s.T(), offset+5, f.Size())
+	assert.Equal(s.T(), 5, f.AllocatedSize())
+
+	// Reads from the hole return zeroes, and reads that span the hole and the data return both
+	buf := make([]byte, 8)
+	n, err = f.ReadAt(buf, offset-3)
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []byte("\x00\x00\x00hello"), buf[:n])
+	n, err = f.ReadAt(buf, offset)
+	assert.Equal(s.T(), io.EOF, err)
+	assert.Equal(s.T(), []byte("hello"), buf[:n])
+	n, err = f.ReadAt(buf, 12345)
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), make([]byte, 8), buf[:n])
+}
+
+func (s *SparseTestSuite) TestReadAllMatchesDenseFile() {
+	dense := filesys.NewFileSystem()
+	denseP := process.NewProcessFilesystemContext(dense)
+	writes := []struct {
+		data string
+		off  int64
+	}{
+		{"abc", 10},
+		{"xyz", 20},
+		{"0123456789", 12}, // bridges the two extents
+		{"tail", 40},
+		{"AB", 8}, // touches the start of the first extent
+		{"!", 0},
+	}
+	for _, p := range []process.ProcessFilesystemContext{s.p, denseP} {
+		f, err := p.CreateFile("/file")
+		assert.Nil(s.T(), err)
+		for _, w := range writes {
+			_, err := f.WriteAt([]byte(w.data), w.off)
+			assert.Nil(s.T(), err)
+		}
+	}
+	sparseData, err := s.p.ReadFile("/file")
+	assert.Nil(s.T(), err)
+	denseData, err := denseP.ReadFile("/file")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), denseData, sparseData)
+
+	f, err := s.p.OpenFile("/file", os.O_RDONLY)
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), len(denseData), f.Size())
+	// Allocated extents: "!" at [0, 1), everything from "AB" through "xyz" at [8, 23), and "tail"
+	// at [40, 44)
+	assert.Equal(s.T(), 1+15+4, f.AllocatedSize())
+
+	sparseSum, err := s.p.Checksum("/file")
+	assert.Nil(s.T(), err)
+	denseSum := sha256.Sum256(denseData)
+	assert.Equal(s.T(), denseSum[:], sparseSum)
+}
+
+func (s *SparseTestSuite) TestAppendAndTruncate() {
+	f, err := s.p.OpenFile("/file", os.CombineModes(os.O_RDWR, os.O_CREATE, os.O_APPEND))
+	assert.Nil(s.T(), err)
+	_, err = f.Write([]byte("hello, "))
+	assert.Nil(s.T(), err)
+	_, err = f.Write([]byte("world"))
+	assert.Nil(s.T(), err)
+	data, err := f.ReadAll()
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), "hello, world", string(data))
+
+	other, err := s.p.OpenFile("/file", os.O_RDWR)
+	assert.Nil(s.T(), err)
+	assert.Nil(s.T(), other.TruncateAndWriteAll([]byte("bye")))
+	data, err = other.ReadAll()
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), "bye", string(data))
+	assert.Equal(s.T(), 3, other.AllocatedSize())
+}
+
+func (s *SparseTestSuite) TestQuotaCountsAllocatedBytes() {
+	fs := filesys.NewFileSystemWithOptions(filesys.Options{Sparse: true, HasQuota: true, QuotaBytes: 10})
+	p := process.NewProcessFilesystemContext(fs)
+	f, err := p.CreateFile("/file")
+	assert.Nil(s.T(), err)
+	_, err = f.WriteAt(bytes.Repeat([]byte("a"), 8), 1<<20)
+	assert.Nil(s.T(), err)
+	// Overwriting allocated bytes doesn't need more space
+	_, err = f.WriteAt(bytes.Repeat([]byte("b"), 8), 1<<20)
+	assert.Nil(s.T(), err)
+	_, err = f.WriteAt([]byte("ccc"), 0)
+	assert.ErrorIs(s.T(), err, fserrors.ENoSpace)
+}
+
+func (s *SparseTestSuite) TestRingFile() {
+	f, err := s.p.OpenRingFile("/log", 8)
+	assert.Nil(s.T(), err)
+	for _, chunk := range []string{"abcdef", "ghijkl", "mnopqr"} {
+		_, err = f.Write([]byte(chunk))
+		assert.Nil(s.T(), err)
+	}
+	assert.Equal(s.T(), 8, f.Size())
+	assert.Equal(s.T(), 8, f.AllocatedSize())
+	data, err := s.p.ReadFile("/log")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), "klmnopqr", string(data))
+}
+
+func TestSparseTestSuite(t *testing.T) {
+	suite.Run(t, new(SparseTestSuite))
+}
@@ -0,0 +1,84 @@
+package filesys
+
+import (
+	"github.com/manderson5192/memfs/directory"
+	"github.com/manderson5192/memfs/inode"
+	"github.com/pkg/errors"
+)
+
+// FilesystemStats summarizes the contents of a FileSystem
+type FilesystemStats struct {
+	// TotalFileBytes is the sum of the sizes of all files in the filesystem
+	TotalFileBytes int64
+	// Files is the number of files in the filesystem
+	Files int
+	// Directories is the number of directories in the filesystem, not counting the root directory
+	Directories int
+	// HasQuota is true if the filesystem was created with a quota (see NewFileSystemWithQuota())
+	HasQuota bool
+	// FreeBytes is the number of bytes of file data that can still be written before the quota is
+	// exhausted.  It is zero if HasQuota is false.
+	FreeBytes int64
+	// HasDedup is true if the filesystem was created with deduplication (see
+	// NewFileSystemWithDedup())
+	HasDedup bool
+	// Dedup reports how much storage deduplication is saving.  It is zero if HasDedup is false.
+	Dedup inode.DedupStats
+}
+
+// Statfs walks fs's entire directory tree once and returns a summary of its contents.  A file or
+// directory that appears at more than one path (see BindMount()) is counted once.
+func Statfs(fs FileSystem) (*FilesystemStats, error) {
+	stats := &FilesystemStats{}
+	if err := statfsDirectory(fs.RootDirectory(), stats, map[uint64]bool{}); err != nil {
+		return nil, errors.Wrapf(err, "could not stat filesystem")
+	}
+	if f, ok := fs.(*fileSystem); ok {
+		if maxBytes, hasQuota := f.superblock.Quota(); hasQuota {
+			stats.HasQuota = true
+			stats.FreeBytes = maxBytes - f.superblock.UsedBytes()
+			if stats.FreeBytes < 0 {
+				stats.FreeBytes = 0
+			}
+		}
+		stats.Dedup, stats.HasDedup = f.superblock.DedupStats()
+	}
+	return stats, nil
+}
+
+// statfsDirectory adds the contents of the subtree rooted at dir to stats, skipping the files and
+// directories whose inode numbers are in seen, and adding the rest to seen
+func statfsDirectory(dir directory.Directory, stats *FilesystemStats, seen map[uint64]bool) error {
+	entries, err := dir.ReadDir("")
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.Type != directory.DirectoryType && entry.Type != directory.FileType {
+			continue
+		}
+		info, err := dir.Stat(entry.Name)
+		if err != nil {
+			return err
+		}
+		if seen[info.Ino] {
+			continue
+		}
+		seen[info.Ino] = true
+		switch entry.Type {
+		case directory.DirectoryType:
+			stats.Directories++
+			subdir, err := dir.LookupSubdirectory(entry.Name)
+			if err != nil {
+				return err
+			}
+			if err := statfsDirectory(subdir, stats, seen); err != nil {
+				return err
+			}
+		case directory.FileType:
+			stats.Files++
+			stats.TotalFileBytes += int64(info.Size)
+		}
+	}
+	return nil
+}
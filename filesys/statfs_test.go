@@ -0,0 +1,59 @@
+package filesys_test
+
+import (
+	"testing"
+
+	"github.com/manderson5192/memfs/filesys"
+	"github.com/manderson5192/memfs/process"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatfsEmptyFilesystem(t *testing.T) {
+	stats, err := filesys.Statfs(filesys.NewFileSystem())
+	assert.Nil(t, err)
+	assert.Equal(t, filesys.FilesystemStats{}, *stats)
+}
+
+func TestStatfs(t *testing.T) {
+	fs := filesys.NewFileSystemWithQuota(100)
+	p := process.NewProcessFilesystemContext(fs)
+	assert.Nil(t, p.MakeDirectoryWithAncestors("/a/b/c"))
+	assert.Nil(t, p.MakeDirectory("/d"))
+	f, err := p.CreateFile("/a/hello")
+	assert.Nil(t, err)
+	assert.Nil(t, f.TruncateAndWriteAll([]byte("hello!")))
+	f, err = p.CreateFile("/a/b/c/world")
+	assert.Nil(t, err)
+	assert.Nil(t, f.TruncateAndWriteAll([]byte("world")))
+	_, err = p.CreateFile("/empty")
+	assert.Nil(t, err)
+
+	stats, err := filesys.Statfs(fs)
+	assert.Nil(t, err)
+	assert.Equal(t, filesys.FilesystemStats{
+		TotalFileBytes: 11,
+		Files:          3,
+		Directories:    4,
+		HasQuota:       true,
+		FreeBytes:      89,
+	}, *stats)
+}
+
+func TestStatfsCountsBindMountsOnce(t *testing.T) {
+	fs := filesys.NewFileSystemWithQuota(100)
+	p := process.NewProcessFilesystemContext(fs)
+	assert.Nil(t, p.MakeDirectoryWithAncestors("/s/sub"))
+	assert.Nil(t, p.MakeDirectory("/t"))
+	assert.Nil(t, p.WriteFile("/s/sub/file", []byte("0123456789"), 0))
+	before, err := filesys.Statfs(fs)
+	assert.Nil(t, err)
+
+	assert.Nil(t, filesys.BindMount(fs, "/s", "/t"))
+	after, err := filesys.Statfs(fs)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(10), after.TotalFileBytes)
+	assert.Equal(t, 1, after.Files)
+	assert.Equal(t, before.FreeBytes, after.FreeBytes)
+	// The mount point itself is hidden by the directory that is mounted on it
+	assert.Equal(t, before.Directories-1, after.Directories)
+}
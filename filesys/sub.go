@@ -0,0 +1,37 @@
+package filesys
+
+import (
+	"github.com/manderson5192/memfs/directory"
+	"github.com/pkg/errors"
+)
+
+// subFileSystem is a FileSystem whose root is a subdirectory of another FileSystem
+type subFileSystem struct {
+	rootDirectory directory.Directory
+}
+
+// Sub returns a FileSystem whose root directory is the directory dir of fs, like io/fs.Sub().  dir
+// is in fs's path syntax (see directory.Directory.Parser()), and it is resolved against fs's root
+// directory, whether or not it begins with a path separator.  The two FileSystems share the same
+// files and directories, so changes made through either are visible through the other.  Within the
+// returned FileSystem, dir is treated as the root (see directory.Directory.Chroot()): ".." in it
+// refers to itself, and ReversePathLookup() reports paths relative to it.  Snapshots and quota
+// statistics are only available for the original FileSystem.
+func Sub(fs FileSystem, dir string) (FileSystem, error) {
+	root := fs.RootDirectory()
+	parser := root.Parser()
+	if err := parser.CheckPath(dir); err != nil {
+		return nil, errors.Wrapf(err, "could not create a filesystem rooted at '%s'", dir)
+	}
+	subdir, err := root.LookupSubdirectory(parser.FromSlash(rootRelativePath(parser.ToSlash(dir))))
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not create a filesystem rooted at '%s'", dir)
+	}
+	return &subFileSystem{
+		rootDirectory: subdir.Chroot(),
+	}, nil
+}
+
+func (f *subFileSystem) RootDirectory() directory.Directory {
+	return f.rootDirectory
+}
@@ -0,0 +1,111 @@
+package filesys_test
+
+import (
+	"testing"
+
+	"github.com/manderson5192/memfs/directory"
+	"github.com/manderson5192/memfs/filepath"
+	"github.com/manderson5192/memfs/filesys"
+	"github.com/manderson5192/memfs/fserrors"
+	"github.com/manderson5192/memfs/process"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type SubTestSuite struct {
+	suite.Suite
+	fs   filesys.FileSystem
+	p    process.ProcessFilesystemContext
+	sub  filesys.FileSystem
+	subP process.ProcessFilesystemContext
+}
+
+func (s *SubTestSuite) SetupTest() {
+	s.fs = filesys.NewFileSystem()
+	s.p = process.NewProcessFilesystemContext(s.fs)
+	assert.Nil(s.T(), s.p.MakeDirectoryWithAncestors("/a/b/c"))
+	assert.Nil(s.T(), s.p.WriteFile("/a/outside", []byte("outside"), 0))
+	sub, err := filesys.Sub(s.fs, "/a/b")
+	assert.Nil(s.T(), err)
+	s.sub = sub
+	s.subP = process.NewProcessFilesystemContext(sub)
+}
+
+func (s *SubTestSuite) TestChangesAreShared() {
+	assert.Nil(s.T(), s.subP.WriteFile("/c/from_sub", []byte("sub"), 0))
+	data, err := s.p.ReadFile("/a/b/c/from_sub")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []byte("sub"), data)
+
+	assert.Nil(s.T(), s.p.WriteFile("/a/b/from_parent", []byte("parent"), 0))
+	data, err = s.subP.ReadFile("/from_parent")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []byte("parent"), data)
+}
+
+func (s *SubTestSuite) TestPathsAreRelativeToSubRoot() {
+	path, err := s.sub.RootDirectory().ReversePathLookup()
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), "/", path)
+
+	c, err := s.sub.RootDirectory().LookupSubdirectory("c")
+	assert.Nil(s.T(), err)
+	path, err = c.ReversePathLookup()
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), "/c", path)
+
+	assert.Nil(s.T(), s.subP.ChangeDirectory("/c/../.."))
+	workdir, err := s.subP.WorkingDirectory()
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), "/", workdir)
+
+	matches, err := s.subP.FindAll("/", "c")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []string{"/c"}, matches)
+}
+
+func (s *SubTestSuite) TestCannotEscapeSubRoot() {
+	_, err := s.subP.Stat("/../outside")
+	assert.ErrorIs(s.T(), err, fserrors.ENoEnt)
+	entries, err := s.sub.RootDirectory().ReadDir("..")
+	assert.Nil(s.T(), err)
+	assert.ElementsMatch(s.T(), []directory.DirectoryEntry{
+		{Name: "c", Type: directory.DirectoryType},
+	}, entries)
+}
+
+func (s *SubTestSuite) TestSubOfSub() {
+	subSub, err := filesys.Sub(s.sub, "c")
+	assert.Nil(s.T(), err)
+	path, err := subSub.RootDirectory().ReversePathLookup()
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), "/", path)
+}
+
+func (s *SubTestSuite) TestSubErrors() {
+	_, err := filesys.Sub(s.fs, "/noexist")
+	assert.ErrorIs(s.T(), err, fserrors.ENoEnt)
+	_, err = filesys.Sub(s.fs, "/a/outside")
+	assert.ErrorIs(s.T(), err, fserrors.ENotDir)
+}
+
+func (s *SubTestSuite) TestSubWithNonDefaultParser() {
+	fs := filesys.NewFileSystemWithParser(filepath.NewParser('\\', ".", ".."))
+	p := process.NewProcessFilesystemContext(fs)
+	assert.Nil(s.T(), p.MakeDirectoryWithAncestors(`\a\b`))
+	assert.Nil(s.T(), p.WriteFile(`\a\b\file`, []byte("hello"), 0))
+
+	sub, err := filesys.Sub(fs, `\a\b`)
+	assert.Nil(s.T(), err)
+	data, err := process.NewProcessFilesystemContext(sub).ReadFile(`\file`)
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), "hello", string(data))
+
+	// Paths in the default syntax aren't in the filesystem's syntax
+	_, err = filesys.Sub(fs, "/a/b")
+	assert.ErrorIs(s.T(), err, fserrors.EInval)
+}
+
+func TestSubTestSuite(t *testing.T) {
+	suite.Run(t, new(SubTestSuite))
+}
@@ -13,4 +13,14 @@ var (
 	EInval    = fmt.Errorf("invalid argument")
 	ENoSpace  = fmt.Errorf("no space")
 	ENotEmpty = fmt.Errorf("not empty")
+	EAccess   = fmt.Errorf("permission denied")
+	EIO       = fmt.Errorf("input/output error")
+	ENoData   = fmt.Errorf("no data available")
+	EXDev     = fmt.Errorf("cross-device link")
+	EBusy     = fmt.Errorf("device or resource busy")
+	// EBadF indicates that a file was used in a way that its mode doesn't allow, e.g. writing to a
+	// file that is open in read-only mode
+	EBadF = fmt.Errorf("bad file descriptor")
+	// ENameTooLong indicates that an entry name or a path exceeds one of the filesystem's limits
+	ENameTooLong = fmt.Errorf("file name too long")
 )
@@ -1,6 +1,6 @@
 module github.com/manderson5192/memfs
 
-go 1.17
+go 1.23
 
 require (
 	github.com/pkg/errors v0.9.1
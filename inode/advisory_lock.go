@@ -0,0 +1,93 @@
+package inode
+
+import "sync"
+
+// advisoryLock is a readers-writer lock that can be acquired without blocking.  It backs the
+// flock-style advisory locks on a FileInode.  Unlike basicInode's rwMutex, it never guards any
+// inode state: it only coordinates clients that choose to use it.
+type advisoryLock struct {
+	mutex sync.Mutex // synchronizes access to the fields below
+	// released is signalled whenever the lock is released; it is lazily created so that the zero
+	// value of advisoryLock is ready to use
+	released *sync.Cond
+	readers  int
+	writer   bool
+}
+
+// available returns true if the lock can be acquired in the specified mode.  It must be called
+// with l.mutex held.
+func (l *advisoryLock) available(exclusive bool) bool {
+	if exclusive {
+		return !l.writer && l.readers == 0
+	}
+	return !l.writer
+}
+
+// take acquires the lock in the specified mode.  It must be called with l.mutex held, and only
+// once available() has returned true.
+func (l *advisoryLock) take(exclusive bool) {
+	if exclusive {
+		l.writer = true
+	} else {
+		l.readers++
+	}
+}
+
+func (l *advisoryLock) lock(exclusive bool) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.released == nil {
+		l.released = sync.NewCond(&l.mutex)
+	}
+	for !l.available(exclusive) {
+		l.released.Wait()
+	}
+	l.take(exclusive)
+}
+
+func (l *advisoryLock) tryLock(exclusive bool) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if !l.available(exclusive) {
+		return false
+	}
+	l.take(exclusive)
+	return true
+}
+
+func (l *advisoryLock) unlock(exclusive bool) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if exclusive {
+		if !l.writer {
+			panic("advisory lock is not held exclusively")
+		}
+		l.writer = false
+	} else {
+		if l.readers == 0 {
+			panic("advisory lock is not held in shared mode")
+		}
+		l.readers--
+	}
+	if l.released != nil {
+		l.released.Broadcast()
+	}
+}
+
+// AdvisoryLock acquires the FileInode's advisory lock, blocking until it is available.  If
+// exclusive is true then the lock is acquired exclusively, otherwise it is shared with any other
+// shared holders.  The advisory lock does not affect reads or writes of the FileInode.
+func (i *FileInode) AdvisoryLock(exclusive bool) {
+	i.advisoryLock.lock(exclusive)
+}
+
+// TryAdvisoryLock is like AdvisoryLock, except that it returns false instead of blocking if the
+// lock is not available
+func (i *FileInode) TryAdvisoryLock(exclusive bool) bool {
+	return i.advisoryLock.tryLock(exclusive)
+}
+
+// AdvisoryUnlock releases the FileInode's advisory lock, which must be held in the specified mode
+func (i *FileInode) AdvisoryUnlock(exclusive bool) {
+	i.advisoryLock.unlock(exclusive)
+}
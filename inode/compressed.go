@@ -0,0 +1,68 @@
+package inode
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+)
+
+// gzipBuffer is the compressed representation of a FileInode's data.  It stores the data as a
+// single gzip stream, so every read decompresses the stream and every write decompresses it,
+// modifies the result, and compresses it again.  This trades CPU time for memory, which suits large
+// files that are compressible and rarely modified.
+type gzipBuffer struct {
+	compressed []byte
+	// size is the logical (uncompressed) length of the data
+	size int
+}
+
+// newGzipBuffer returns a gzipBuffer that holds no data
+func newGzipBuffer() *gzipBuffer {
+	b := &gzipBuffer{}
+	b.store([]byte{})
+	return b
+}
+
+// load returns a copy of the gzipBuffer's uncompressed data
+func (b *gzipBuffer) load() []byte {
+	data := bytes.NewBuffer(make([]byte, 0, b.size))
+	b.writeTo(data)
+	return data.Bytes()
+}
+
+// writeTo writes the gzipBuffer's uncompressed data to w without materializing it in a single
+// buffer
+func (b *gzipBuffer) writeTo(w io.Writer) {
+	reader, err := gzip.NewReader(bytes.NewReader(b.compressed))
+	if err != nil {
+		// The stream was produced by store(), so this shouldn't happen
+		panic("compressed file data is corrupt: " + err.Error())
+	}
+	if _, err := io.Copy(w, reader); err != nil {
+		panic("compressed file data is corrupt: " + err.Error())
+	}
+}
+
+// store replaces the gzipBuffer's contents with the compressed form of d
+func (b *gzipBuffer) store(d []byte) {
+	var compressed bytes.Buffer
+	writer := gzip.NewWriter(&compressed)
+	// Writes to a bytes.Buffer never fail
+	writer.Write(d)
+	writer.Close()
+	b.compressed = compressed.Bytes()
+	b.size = len(d)
+}
+
+// compressedSize returns the number of bytes that the gzipBuffer stores
+func (b *gzipBuffer) compressedSize() int {
+	return len(b.compressed)
+}
+
+// clone returns a copy of the gzipBuffer
+func (b *gzipBuffer) clone() *gzipBuffer {
+	return &gzipBuffer{
+		compressed: append([]byte{}, b.compressed...),
+		size:       b.size,
+	}
+}
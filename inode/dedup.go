@@ -0,0 +1,193 @@
+package inode
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"sync"
+)
+
+// sharedBuffer is an immutable buffer of file data that is shared, copy-on-write, by every
+// FileInode whose contents are identical to it
+type sharedBuffer struct {
+	data []byte
+	hash [sha256.Size]byte
+	// refs is the number of FileInodes that share the buffer
+	refs int
+}
+
+// dedupTable indexes a filesystem's sharedBuffers by the SHA-256 hash of their contents
+type dedupTable struct {
+	mutex   sync.Mutex // synchronizes access to the fields below
+	buffers map[[sha256.Size]byte]*sharedBuffer
+	// hits counts the number of times that a file's contents were found to match a sharedBuffer
+	hits int64
+}
+
+func newDedupTable() *dedupTable {
+	return &dedupTable{
+		buffers: map[[sha256.Size]byte]*sharedBuffer{},
+	}
+}
+
+// DedupStats reports how much storage deduplication is saving in a filesystem
+type DedupStats struct {
+	// Hits is the number of times that a file's new contents matched another file's contents and
+	// so shared its storage instead of allocating more
+	Hits int64
+	// SharedBuffers is the number of buffers that are currently shared by two or more files
+	SharedBuffers int
+	// SavedBytes is the number of bytes that are currently not allocated thanks to sharing, i.e.
+	// the bytes of every file that shares a buffer, less the size of the buffers themselves.
+	// Deleted files don't count, even if they are still open.
+	SavedBytes int64
+}
+
+// share returns a sharedBuffer holding a copy of d, reusing an existing sharedBuffer if one with
+// identical contents exists.  The caller holds a reference to the returned sharedBuffer and must
+// eventually release() it.
+func (t *dedupTable) share(d []byte) *sharedBuffer {
+	hash := sha256.Sum256(d)
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if existing, exists := t.buffers[hash]; exists {
+		if bytes.Equal(existing.data, d) {
+			existing.refs++
+			t.hits++
+			return existing
+		}
+		// A hash collision is astronomically unlikely, but must not corrupt data: fall back to an
+		// unindexed buffer
+		return &sharedBuffer{data: append([]byte{}, d...), hash: hash, refs: 1}
+	}
+	buffer := &sharedBuffer{
+		data: append([]byte{}, d...),
+		hash: hash,
+		refs: 1,
+	}
+	t.buffers[hash] = buffer
+	return buffer
+}
+
+// release drops a reference to b, forgetting b once no FileInode references it
+func (t *dedupTable) release(b *sharedBuffer) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	b.refs--
+	if b.refs == 0 && t.buffers[b.hash] == b {
+		delete(t.buffers, b.hash)
+	}
+}
+
+func (t *dedupTable) stats() DedupStats {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	stats := DedupStats{Hits: t.hits}
+	for _, buffer := range t.buffers {
+		if buffer.refs > 1 {
+			stats.SharedBuffers++
+			stats.SavedBytes += int64(buffer.refs-1) * int64(len(buffer.data))
+		}
+	}
+	return stats
+}
+
+// EnableDedup turns on content-addressable deduplication for the filesystem: whenever a file's
+// entire contents are replaced (e.g. by TruncateAndWriteAll()), or a file that was written piece by
+// piece is finished (see Deduplicate()), the file shares its storage with any other file whose
+// contents are identical.  Shared storage is copy-on-write, so a subsequent
+// write to one of the files gives it a private copy.  Deduplication does not affect quota
+// accounting, which always counts each file's full size.
+func (sb *Superblock) EnableDedup() {
+	sb.mutex.Lock()
+	defer sb.mutex.Unlock()
+	if sb.dedup == nil {
+		sb.dedup = newDedupTable()
+	}
+}
+
+// DedupStats returns statistics about deduplication, and whether deduplication is enabled at all
+func (sb *Superblock) DedupStats() (DedupStats, bool) {
+	table := sb.dedupTable()
+	if table == nil {
+		return DedupStats{}, false
+	}
+	return table.stats(), true
+}
+
+// dedupTable returns the filesystem's dedupTable, or nil if deduplication is disabled
+func (sb *Superblock) dedupTable() *dedupTable {
+	if sb == nil {
+		return nil
+	}
+	sb.mutex.Lock()
+	defer sb.mutex.Unlock()
+	return sb.dedup
+}
+
+// storeData makes d the FileInode's data, sharing storage with identical files if the
+// filesystem has deduplication enabled.  Any buffer that the FileInode previously shared is
+// released.
+//
+// This function is **not thread safe**.  It should only be invoked when a Write-level lock is held
+// on the FileInode.
+func (i *FileInode) storeData(d []byte) {
+	if i.shared != nil {
+		i.superblock.dedupTable().release(i.shared)
+		i.shared = nil
+	}
+	// d replaces the data, so any buffer returned by Bytes() is no longer the FileInode's storage
+	i.exposed.Store(false)
+	if i.extents != nil {
+		// Sparse files are never deduplicated
+		i.extents.set(d)
+		return
+	}
+	if i.gzip != nil {
+		// Neither are compressed files
+		i.gzip.store(d)
+		return
+	}
+	table := i.superblock.dedupTable()
+	if table == nil || i.parent == nil {
+		i.data = d
+		return
+	}
+	i.shared = table.share(d)
+	i.data = i.shared.data
+}
+
+// Deduplicate shares the FileInode's current data with any other file whose contents are identical,
+// as if they had just been written by TruncateAndWriteAll(), so that a file that was filled by
+// smaller writes is deduplicated once it is finished (see file.File.Close()).  It does nothing if
+// the filesystem doesn't deduplicate, if the FileInode is empty, sparse, compressed, or already
+// shares its data, or if it has been deleted.  It doesn't change the FileInode's contents, so it
+// isn't counted as a mutation.
+func (i *FileInode) Deduplicate() {
+	if i.superblock.dedupTable() == nil {
+		return
+	}
+	i.rwMutex.Lock()
+	defer i.rwMutex.Unlock()
+	if i.shared != nil || i.extents != nil || i.gzip != nil || i.parent == nil || len(i.data) == 0 {
+		return
+	}
+	i.storeData(i.data)
+}
+
+// unshare is the write barrier for storage that the FileInode doesn't own exclusively: if the
+// FileInode's data is shared with other files (by deduplication) or with callers of Bytes(), then
+// it replaces the data with a private copy so that it can be mutated without affecting them.
+//
+// This function is **not thread safe**.  It should only be invoked when a Write-level lock is held
+// on the FileInode.
+func (i *FileInode) unshare() {
+	if i.shared == nil && !i.exposed.Load() {
+		return
+	}
+	i.data = append([]byte{}, i.data...)
+	i.exposed.Store(false)
+	if i.shared != nil {
+		i.superblock.dedupTable().release(i.shared)
+		i.shared = nil
+	}
+}
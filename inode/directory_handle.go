@@ -0,0 +1,33 @@
+package inode
+
+// AcquireHandle records that a handle to the DirectoryInode has been opened.  While a DirectoryInode
+// has open handles, it may be removed from the filesystem even if it is not empty (see
+// DeleteOpenDirectory()).  Its entries then stay in place, so they remain reachable through the open
+// handles, just as a deleted file's data remains readable through its open files.  Every call to
+// AcquireHandle must be balanced by a call to ReleaseHandle.
+func (i *DirectoryInode) AcquireHandle() {
+	i.rwMutex.Lock()
+	defer i.rwMutex.Unlock()
+	i.openHandles++
+}
+
+// ReleaseHandle records that a handle acquired by AcquireHandle has been closed.  If it was the last
+// open handle to a DirectoryInode that has been removed from the filesystem, then the DirectoryInode's
+// subtree is unlinked: its files stop counting against the filesystem's quota, and its
+// subdirectories are marked as deleted.
+func (i *DirectoryInode) ReleaseHandle() {
+	defer i.superblock.beginMutation()()
+	i.rwMutex.Lock()
+	if i.openHandles <= 0 {
+		i.rwMutex.Unlock()
+		panic("released a directory handle that was never acquired")
+	}
+	i.openHandles--
+	unlink := i.openHandles == 0 && i.deleted
+	i.rwMutex.Unlock()
+	// unlinkTree takes the lock itself, and leaves the entries alone if another handle was acquired
+	// in the meantime
+	if unlink {
+		unlinkTree(i)
+	}
+}
@@ -3,6 +3,7 @@ package inode
 import (
 	"fmt"
 	"strings"
+	"sync"
 
 	"github.com/manderson5192/memfs/filepath"
 	"github.com/manderson5192/memfs/fserrors"
@@ -14,11 +15,24 @@ type DirectoryInode struct {
 	basicInode
 	deleted  bool
 	contents map[string]Inode
+	// openHandles is the number of open handles on the DirectoryInode (see AcquireHandle())
+	openHandles int
+	// superblock is the Superblock of the filesystem that this DirectoryInode belongs to.  It is
+	// inherited by every inode created in this directory.
+	superblock *Superblock
 }
 
 func NewRootDirectoryInode() *DirectoryInode {
+	return NewRootDirectoryInodeWithSuperblock(nil)
+}
+
+// NewRootDirectoryInodeWithSuperblock creates the root DirectoryInode of a new filesystem that is
+// described by sb
+func NewRootDirectoryInodeWithSuperblock(sb *Superblock) *DirectoryInode {
 	rootDirInode := &DirectoryInode{
-		contents: map[string]Inode{},
+		basicInode: newBasicInode(defaultDirectoryPerm),
+		contents:   map[string]Inode{},
+		superblock: sb,
 	}
 	rootDirInode.contents[filepath.SelfDirectoryEntry] = rootDirInode
 	rootDirInode.contents[filepath.ParentDirectoryEntry] = rootDirInode
@@ -27,7 +41,9 @@ func NewRootDirectoryInode() *DirectoryInode {
 
 func NewDirectoryInode(parent *DirectoryInode) *DirectoryInode {
 	newDirInode := &DirectoryInode{
-		contents: map[string]Inode{},
+		basicInode: newBasicInode(defaultDirectoryPerm),
+		contents:   map[string]Inode{},
+		superblock: parent.superblock,
 	}
 	newDirInode.contents[filepath.SelfDirectoryEntry] = newDirInode
 	newDirInode.contents[filepath.ParentDirectoryEntry] = parent
@@ -38,17 +54,18 @@ func (i *DirectoryInode) InodeType() InodeType {
 	return InodeDirectory
 }
 
+// Superblock returns the Superblock of the filesystem that this DirectoryInode belongs to
+func (i *DirectoryInode) Superblock() *Superblock {
+	return i.superblock
+}
+
+// Size returns the number of entries in the DirectoryInode, excluding the self and parent directory
+// entries.  It takes constant time, since every DirectoryInode always has exactly those two special
+// entries in addition to its others.
 func (i *DirectoryInode) Size() int {
 	i.rwMutex.RLock()
 	defer i.rwMutex.RUnlock()
-	numEntries := 0
-	for name := range i.contents {
-		if name == filepath.SelfDirectoryEntry || name == filepath.ParentDirectoryEntry {
-			continue
-		}
-		numEntries++
-	}
-	return numEntries
+	return len(i.contents) - 2
 }
 
 // Parent obtains the DirectoryInode that is parent to this DirectoryInode
@@ -68,9 +85,9 @@ func (i *DirectoryInode) Parent() *DirectoryInode {
 	return parentDirectoryInode
 }
 
-// ReverseLookupEntry returns the entry name for the specified child DirectoryInode, or an error
-// if it is unable to do so
-func (i *DirectoryInode) ReverseLookupEntry(child *DirectoryInode) (string, error) {
+// ReverseLookupEntry returns the entry name for the specified child inode, or an error if it is
+// unable to do so
+func (i *DirectoryInode) ReverseLookupEntry(child Inode) (string, error) {
 	i.rwMutex.RLock()
 	defer i.rwMutex.RUnlock()
 	for entry, inode := range i.contents {
@@ -78,33 +95,91 @@ func (i *DirectoryInode) ReverseLookupEntry(child *DirectoryInode) (string, erro
 		if entry == filepath.SelfDirectoryEntry || entry == filepath.ParentDirectoryEntry {
 			continue
 		}
-		// Ignore non-directory inodes
-		if !IsDirectory(inode) {
-			continue
-		}
 		if child == inode {
 			return entry, nil
 		}
 	}
-	return "", errors.Wrapf(fserrors.ENoEnt, "entry for directory inode was not found")
+	return "", errors.Wrapf(fserrors.ENoEnt, "entry for %s inode was not found", child.InodeType().String())
+}
+
+// Path determines the absolute path of the DirectoryInode by iteratively fetching the parent
+// directory inode (the special ".." entry) and doing a reverse lookup for the child directory inode
+func (i *DirectoryInode) Path() (string, error) {
+	return i.PathWithin(nil)
+}
+
+// PathWithin behaves like Path(), except that the returned path is relative to root, as though root
+// were the filesystem's root directory.  It returns ENOENT if i is not root or one of its
+// descendants.  If root is nil, then the filesystem's actual root directory is used.
+func (i *DirectoryInode) PathWithin(root *DirectoryInode) (string, error) {
+	pathParts := []string{}
+	currentDirInode := i
+	for currentDirInode != root {
+		if currentDirInode.IsRootDirectoryInode() {
+			if root == nil {
+				break
+			}
+			return "", errors.Wrapf(fserrors.ENoEnt, "directory is not beneath the root directory")
+		}
+		parentDirInode := currentDirInode.Parent()
+		pathPart, err := parentDirInode.ReverseLookupEntry(currentDirInode)
+		if err != nil {
+			return "", errors.Wrapf(err, "could not complete reverse path lookup")
+		}
+		pathParts = append([]string{pathPart}, pathParts...)
+		currentDirInode = parentDirInode
+	}
+	path := filepath.Join(pathParts...)
+	return "/" + path, nil
 }
 
 // IsRootDirectoryInode returns whether this DirectoryInode corresponds to the filesystem's root
 func (i *DirectoryInode) IsRootDirectoryInode() bool {
-	i.rwMutex.RLock()
-	defer i.rwMutex.RUnlock()
+	// Note: Parent() takes a Read-level lock, so we must not hold one here.  Recursively acquiring
+	// a Read-level lock can deadlock if a writer begins waiting between the two acquisitions.
 	parent := i.Parent()
 	return i == parent
 }
 
+// selfAndAncestors returns i and all of its ancestors, ending with the root directory.  The answer
+// is only stable while the tree's shape cannot change, i.e. while the filesystem's renameLock() is
+// held.
+func (i *DirectoryInode) selfAndAncestors() []*DirectoryInode {
+	toReturn := []*DirectoryInode{i}
+	current := i
+	for !current.IsRootDirectoryInode() {
+		current = current.Parent()
+		toReturn = append(toReturn, current)
+	}
+	return toReturn
+}
+
+// isAncestorOf returns true if i is a proper ancestor of other.  The answer is only stable while
+// the tree's shape cannot change, i.e. while the filesystem's renameLock() is held.
+func (i *DirectoryInode) isAncestorOf(other *DirectoryInode) bool {
+	current := other
+	for !current.IsRootDirectoryInode() {
+		current = current.Parent()
+		if current == i {
+			return true
+		}
+	}
+	return false
+}
+
 // AddDirectory adds (and returns) a DirectoryInode for a direct child directory named 'name'.  It
-// cannot create an entry containing a path separator and it cannot create a subdirectory that
-// already exists
+// cannot create an entry containing a path separator or a NUL byte (see
+// Superblock.RejectControlCharacters() for other control characters) and it cannot create a
+// subdirectory that already exists
 func (i *DirectoryInode) AddDirectory(name string) (*DirectoryInode, error) {
 	// Check that this directory entry doesn't contain the path separator
-	if strings.Contains(name, filepath.PathSeparator) {
-		return nil, errors.Wrapf(fserrors.EInval, "cannot add subdirectory inode for a name containing path separator %s: %s", filepath.PathSeparator, name)
+	if i.superblock.Parser().ContainsSeparator(name) {
+		return nil, errors.Wrapf(fserrors.EInval, "cannot add subdirectory inode for a name containing a path separator: %s", name)
+	}
+	if err := i.checkNewEntry(name); err != nil {
+		return nil, errors.Wrapf(err, "cannot add subdirectory inode")
 	}
+	defer i.superblock.beginMutation()()
 	i.rwMutex.Lock()
 	defer i.rwMutex.Unlock()
 	// Disallow adding subdirectories on directories that have already been marked as deleted
@@ -117,6 +192,9 @@ func (i *DirectoryInode) AddDirectory(name string) (*DirectoryInode, error) {
 	}
 	subdirInode := NewDirectoryInode(i)
 	i.contents[name] = subdirInode
+	i.indexEntry(name)
+	i.markModified()
+	i.superblock.metricCounters().countMkdir()
 	return subdirInode, nil
 }
 
@@ -125,14 +203,15 @@ type onNoExistFunc func(parent *DirectoryInode, name string) (Inode, error)
 
 // getInodeEntry is a convenience method that provides common functionality for getting entry's
 // inode from the receiver DirectoryInode `i`.  It also supports running arbitrary logic when entry
-// is or is not found in i's entry table.
+// is or is not found in i's entry table.  If entry is a mount point, then the directory that is
+// mounted on it is used instead (see BindMount()).
 //
 // This function is **not thread safe**.  It should be invoked by a caller holding a Read-level lock
 // on i's rwMutex, or a Write-level lock if onExist or onNoExistFunc will mutate i's state.
 func (i *DirectoryInode) getInodeEntry(entry string, onExist onExistFunc, onNoExist onNoExistFunc) (Inode, error) {
 	// Check that this directory entry doesn't contain the path separator
-	if strings.Contains(entry, filepath.PathSeparator) {
-		return nil, errors.Wrapf(fserrors.EInval, "entry %s contains illegal character %s", entry, filepath.PathSeparator)
+	if i.superblock.Parser().ContainsSeparator(entry) {
+		return nil, errors.Wrapf(fserrors.EInval, "entry %s contains a path separator", entry)
 	}
 	inode, exists := i.contents[entry]
 	if !exists {
@@ -142,6 +221,7 @@ func (i *DirectoryInode) getInodeEntry(entry string, onExist onExistFunc, onNoEx
 			return onNoExist(i, entry)
 		}
 	} else {
+		inode = i.superblock.resolveMount(inode)
 		if onExist == nil {
 			return inode, nil
 		} else {
@@ -171,8 +251,10 @@ func (i *DirectoryInode) DirectoryInodeEntry(entry string) (*DirectoryInode, err
 		return nil, errors.Wrapf(fserrors.ENotDir, "entry '%s' is not a directory", entry)
 	}
 	// Deny access to DirectoryInodes after they have been marked as deleted.  This case should be
-	// rare, but is technically possible
-	if dirInode.isDeleted() {
+	// rare, but is technically possible.  The self and parent entries are exempt, so that a deleted
+	// directory's subtree remains navigable through its open handles (see AcquireHandle()).  (The
+	// self entry must be exempt anyway, since checking it would recursively lock i.)
+	if entry != filepath.SelfDirectoryEntry && entry != filepath.ParentDirectoryEntry && dirInode.IsDeleted() {
 		return nil, errors.Wrapf(fserrors.ENoEnt, "entry '%s' does not exist", entry)
 	}
 	return dirInode, nil
@@ -197,13 +279,31 @@ func (i *DirectoryInode) FileInodeEntry(entry string) (*FileInode, error) {
 // casting an existing inode, or by creating a new one altogether.  However, if errOnExist is true,
 // then CreateFileInodeEntry will return EEXIST is i.contents[entry] already exists.
 func (i *DirectoryInode) CreateFileInodeEntry(entry string, errOnExist bool) (*FileInode, error) {
+	fileInode, _, err := i.createFileInodeEntry(entry, errOnExist)
+	return fileInode, err
+}
+
+// GetOrCreateFileInodeEntry behaves like CreateFileInodeEntry(entry, false), but also reports
+// whether the FileInode was newly created (true) or already existed (false).  The existence check
+// and the creation happen atomically under a single Write-level lock.
+func (i *DirectoryInode) GetOrCreateFileInodeEntry(entry string) (*FileInode, bool, error) {
+	return i.createFileInodeEntry(entry, false)
+}
+
+// createFileInodeEntry implements CreateFileInodeEntry and GetOrCreateFileInodeEntry
+func (i *DirectoryInode) createFileInodeEntry(entry string, errOnExist bool) (*FileInode, bool, error) {
 	// Check that entry doesn't contain the path separator
-	if strings.Contains(entry, filepath.PathSeparator) {
-		return nil, errors.Wrapf(fserrors.EInval, "name '%s' contains a path separator", entry)
+	if i.superblock.Parser().ContainsSeparator(entry) {
+		return nil, false, errors.Wrapf(fserrors.EInval, "name '%s' contains a path separator", entry)
+	}
+	if err := i.checkNewEntry(entry); err != nil {
+		return nil, false, err
 	}
 	// Take an exclusive lock in case we end up creating a file
+	defer i.superblock.beginMutation()()
 	i.rwMutex.Lock()
 	defer i.rwMutex.Unlock()
+	created := false
 	onExist := func(inode Inode, name string) (Inode, error) {
 		if errOnExist {
 			return nil, errors.Wrapf(fserrors.EExist, "file '%s' already exists", name)
@@ -215,19 +315,23 @@ func (i *DirectoryInode) CreateFileInodeEntry(entry string, errOnExist bool) (*F
 		if dirInode.deleted {
 			return nil, errors.Wrapf(fserrors.ENoEnt, "cannot add entries to a directory marked for deletion")
 		}
-		newFileInode := NewFileInode()
+		newFileInode := newFileInodeWithParent(dirInode)
 		dirInode.contents[name] = newFileInode
+		dirInode.indexEntry(name)
+		dirInode.markModified()
+		dirInode.superblock.metricCounters().countCreate()
+		created = true
 		return newFileInode, nil
 	}
 	inode, err := i.getInodeEntry(entry, onExist, onNoExist)
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
 	fileInode, ok := inode.(*FileInode)
 	if !ok {
-		return nil, errors.Wrapf(fserrors.EIsDir, "entry '%s' is not a file", entry)
+		return nil, false, errors.Wrapf(fserrors.EIsDir, "entry '%s' is not a file", entry)
 	}
-	return fileInode, nil
+	return fileInode, created, nil
 }
 
 // InodeEntry represents basic information about an entry in a DirectoryInode's entry table
@@ -236,54 +340,112 @@ type InodeEntry struct {
 	Type InodeType
 }
 
+// InodeEntries returns the name and type of each of the DirectoryInode's entries, excluding the
+// self and parent directory entries, from a single Snapshot()
 func (i *DirectoryInode) InodeEntries() []InodeEntry {
-	i.rwMutex.RLock()
-	defer i.rwMutex.RUnlock()
-	toReturn := make([]InodeEntry, 0, len(i.contents))
-	for entryName, inode := range i.contents {
-		if entryName == filepath.SelfDirectoryEntry || entryName == filepath.ParentDirectoryEntry {
-			continue
-		}
+	snapshot := i.Snapshot()
+	toReturn := make([]InodeEntry, 0, snapshot.Len())
+	for _, entry := range snapshot.entries {
 		toReturn = append(toReturn, InodeEntry{
-			Name: entryName,
-			Type: inode.InodeType(),
+			Name: entry.Name,
+			Type: entry.Inode.InodeType(),
 		})
 	}
 	return toReturn
 }
 
+// EntryNames returns the names of the DirectoryInode's entries, excluding the self and parent
+// directory entries, from a single Snapshot()
+func (i *DirectoryInode) EntryNames() []string {
+	snapshot := i.Snapshot()
+	toReturn := make([]string, 0, snapshot.Len())
+	for _, entry := range snapshot.entries {
+		toReturn = append(toReturn, entry.Name)
+	}
+	return toReturn
+}
+
 // LookupSubdirectory will return a DirectoryInode for the specified subdirectory relative to this
 // DirectoryInode.  It assumes that subdirectory is a relative path, even if it begins with a path
 // separator character.  If the specified subdirectory can't be found, or if any named directory
 // entry along its path is not a directory (e.g. if it is a file), then it will return an error.  If
-// subdirectory is the empty string, then the receiver DirectoryInode will be returned.
+// subdirectory is the empty string, then the receiver DirectoryInode will be returned.  subdirectory
+// may be in either the default path syntax or the filesystem's own (see Superblock.Parser()).  The
+// lookup fails with ENAMETOOLONG if it descends deeper than the filesystem's MaxPathDepth (see
+// Limits).
 func (i *DirectoryInode) LookupSubdirectory(subdirectory string) (*DirectoryInode, error) {
+	return i.LookupSubdirectoryWithin(subdirectory, nil)
+}
+
+// LookupSubdirectoryWithin behaves like LookupSubdirectory(), except that root is treated as the
+// filesystem's root directory: a ".." entry in root refers to root itself, so the lookup can never
+// escape from root's subtree (assuming that i is in it).  If root is nil, then it behaves exactly
+// like LookupSubdirectory().
+//
+// A ".." entry that follows another entry in subdirectory returns to the directory in which that
+// entry was looked up, so "m/.." is always the directory in which the lookup started, even if m is
+// a bind mount point (see BindMount()).
+func (i *DirectoryInode) LookupSubdirectoryWithin(subdirectory string, root *DirectoryInode) (*DirectoryInode, error) {
 	if subdirectory == "" {
 		return i, nil
 	}
-	if !filepath.IsRelativePath(subdirectory) {
+	currentSubdirectory := i.superblock.Parser().ToSlash(subdirectory)
+	if !filepath.IsRelativePath(currentSubdirectory) {
 		return nil, errors.Wrapf(fserrors.EInval, "'%s' is not a relative path", subdirectory)
 	}
 	currentDirInode := i
-	currentSubdirectory := subdirectory
+	// depth is the depth of currentDirInode, which is only tracked if the filesystem limits it
+	maxDepth := i.superblock.Limits().MaxPathDepth
+	depth := 0
+	if maxDepth > 0 {
+		depth = i.depth()
+	}
+	// visited holds the directories in which this lookup has looked up entries, so that ".." can
+	// return to them
+	visited := []*DirectoryInode{}
 	for len(currentSubdirectory) > 0 {
 		// Parse a directory entry from the beginning of currentSubdirectory
 		currentSubdirectory = strings.TrimLeft(currentSubdirectory, filepath.PathSeparator)
 		entryName, remainder, _ := utils.Cut(currentSubdirectory, filepath.PathSeparator)
+		if entryName == filepath.ParentDirectoryEntry && currentDirInode == root {
+			currentSubdirectory = remainder
+			continue
+		}
+		if entryName == filepath.ParentDirectoryEntry && len(visited) > 0 {
+			currentDirInode = visited[len(visited)-1]
+			visited = visited[:len(visited)-1]
+			currentSubdirectory = remainder
+			depth--
+			continue
+		}
+		isChildEntry := entryName != filepath.SelfDirectoryEntry && entryName != filepath.ParentDirectoryEntry
+		if isChildEntry && maxDepth > 0 {
+			if err := i.superblock.checkDepth(depth + 1); err != nil {
+				return nil, errors.Wrapf(err, "cannot look up subdirectory '%s'", subdirectory)
+			}
+		}
 		// Get the directory inode for this entry
 		dirInode, getEntryErr := currentDirInode.DirectoryInodeEntry(entryName)
 		if getEntryErr != nil {
 			return nil, errors.Wrapf(getEntryErr, "cannot find subdirectory '%s'", subdirectory)
 		}
 		// iterate
+		if isChildEntry {
+			visited = append(visited, currentDirInode)
+			depth++
+		} else if entryName == filepath.ParentDirectoryEntry && depth > 0 {
+			depth--
+		}
 		currentDirInode = dirInode
 		currentSubdirectory = remainder
 	}
 	return currentDirInode, nil
 }
 
-// delete marks this DirectoryInode as deleted.  It will only succeed if this directory is empty.
-func (i *DirectoryInode) delete() error {
+// delete marks this DirectoryInode as deleted.  It will only succeed if this directory is empty,
+// unless allowOpen is true and the directory has open handles (see AcquireHandle()), in which case
+// its entries are kept so that they remain reachable through those handles.
+func (i *DirectoryInode) delete(allowOpen bool) error {
 	i.rwMutex.Lock()
 	defer i.rwMutex.Unlock()
 	// Check: is the directory already deleted?
@@ -295,6 +457,9 @@ func (i *DirectoryInode) delete() error {
 		if entry == filepath.SelfDirectoryEntry || entry == filepath.ParentDirectoryEntry {
 			continue
 		}
+		if allowOpen && i.openHandles > 0 {
+			break
+		}
 		return errors.Wrapf(fserrors.ENotEmpty, "directory is not empty")
 	}
 	// mark as deleted
@@ -302,18 +467,22 @@ func (i *DirectoryInode) delete() error {
 	return nil
 }
 
-func (i *DirectoryInode) isDeleted() bool {
+// IsDeleted returns true if the DirectoryInode has been removed from the filesystem.  A deleted
+// DirectoryInode can never be linked into the filesystem again, and no entries can be created in it.
+func (i *DirectoryInode) IsDeleted() bool {
 	i.rwMutex.RLock()
 	defer i.rwMutex.RUnlock()
 	return i.deleted
 }
 
 // doDeleteDirectory is a convenience method that provides common functionality for deleting a child
-// DirectoryInode from `i` that is currently under the entry name `entry`.
+// DirectoryInode from `i` that is currently under the entry name `entry`.  If allowOpen is true,
+// then a non-empty child with open handles may be deleted (see delete()).  It doesn't count a delete
+// in the filesystem's metrics, since it also removes the directories that renames replace.
 //
 // This function is **not thread safe**.  It should only be invoked when a Write-level lock is held
 // on the DirectoryInode.
-func (i *DirectoryInode) doDeleteDirectory(entry string) error {
+func (i *DirectoryInode) doDeleteDirectory(entry string, allowOpen bool) error {
 	// Check: disallow removing the special "." and ".." directories
 	if entry == "." || entry == ".." {
 		return errors.Wrapf(fserrors.EInval, "refusing to remove '.' or '..' directory: skipping '%s", entry)
@@ -327,23 +496,49 @@ func (i *DirectoryInode) doDeleteDirectory(entry string) error {
 	if !ok {
 		return errors.Wrapf(fserrors.ENotDir, "entry '%s' is not a directory", entry)
 	}
+	if err := dirInode.checkNotMounted(entry); err != nil {
+		return err
+	}
 	// Make sure we can successfully delete entry's directory
-	if err := dirInode.delete(); err != nil {
+	if err := dirInode.delete(allowOpen); err != nil {
 		return errors.Wrapf(err, "failed to delete directory entry '%s'", entry)
 	}
 	// Finally, remove the entry
 	delete(i.contents, entry)
+	i.unindexEntry(entry)
+	i.markModified()
 	return nil
 }
 
+// DeleteDirectory removes the directory at entry, which must be empty
 func (i *DirectoryInode) DeleteDirectory(entry string) error {
+	defer i.superblock.beginMutation()()
+	i.rwMutex.Lock()
+	defer i.rwMutex.Unlock()
+	if err := i.doDeleteDirectory(entry, false); err != nil {
+		return err
+	}
+	i.superblock.metricCounters().countDelete()
+	return nil
+}
+
+// DeleteOpenDirectory behaves like DeleteDirectory, except that the directory at entry may also be
+// removed if it is not empty but has open handles (see AcquireHandle()).  It then keeps its entries,
+// which remain reachable through those handles, until the last handle is released.
+func (i *DirectoryInode) DeleteOpenDirectory(entry string) error {
+	defer i.superblock.beginMutation()()
 	i.rwMutex.Lock()
 	defer i.rwMutex.Unlock()
-	return i.doDeleteDirectory(entry)
+	if err := i.doDeleteDirectory(entry, true); err != nil {
+		return err
+	}
+	i.superblock.metricCounters().countDelete()
+	return nil
 }
 
 // doDeleteFile is a convenience method that provides common functionality for deleting a child
-// FileInode from `i` that is currently under the entry name `entry`
+// FileInode from `i` that is currently under the entry name `entry`.  It doesn't count a delete in
+// the filesystem's metrics, since it also removes the files that renames replace.
 //
 // This function is **not thread safe**.  It should only be invoked when a Write-level lock is held
 // on the DirectoryInode
@@ -353,19 +548,27 @@ func (i *DirectoryInode) doDeleteFile(entry string) error {
 	if !exists {
 		return errors.Wrapf(fserrors.ENoEnt, "entry '%s' does not exist", entry)
 	}
-	_, ok := inode.(*FileInode)
+	fileInode, ok := inode.(*FileInode)
 	if !ok {
 		return errors.Wrapf(fserrors.EIsDir, "entry '%s' is not a file", entry)
 	}
 	// Remove the entry
 	delete(i.contents, entry)
+	i.unindexEntry(entry)
+	i.markModified()
+	fileInode.unlink()
 	return nil
 }
 
 func (i *DirectoryInode) DeleteFile(entry string) error {
+	defer i.superblock.beginMutation()()
 	i.rwMutex.Lock()
 	defer i.rwMutex.Unlock()
-	return i.doDeleteFile(entry)
+	if err := i.doDeleteFile(entry); err != nil {
+		return err
+	}
+	i.superblock.metricCounters().countDelete()
+	return nil
 }
 
 func (i *DirectoryInode) SetParent(parent *DirectoryInode) {
@@ -374,9 +577,130 @@ func (i *DirectoryInode) SetParent(parent *DirectoryInode) {
 	i.contents[filepath.ParentDirectoryEntry] = parent
 }
 
+// orphanRenameMutex plays the role of a Superblock's renameMutex for inodes that don't belong to any
+// filesystem
+var orphanRenameMutex sync.Mutex
+
+// renameLock returns the mutex that serializes changes to the shape of the filesystem's directory
+// tree (see Superblock.renameMutex).  Holding it guarantees that no other goroutine can change which
+// directories are ancestors of which, which MoveEntry relies on to order its lock acquisitions.
+func (sb *Superblock) renameLock() *sync.Mutex {
+	if sb == nil {
+		return &orphanRenameMutex
+	}
+	return &sb.renameMutex
+}
+
 // MoveEntry will relocate the inode specified by src that is currently a child of srcParentInode
-// to the entry specified by dst that will be a child of dstParentInode
+// to the entry specified by dst that will be a child of dstParentInode.  Like rename(2), it returns
+// EINVAL if src is a directory and dstParentInode is that directory or one of its descendants, or
+// is reachable from it through a bind mount (see BindMount()).
+//
+// MoveEntry is atomic with respect to every other operation on srcParentInode and dstParentInode:
+// it holds Write-level locks on both directories for its duration, so a concurrent reader of
+// either directory observes the entry at exactly one of its old or new locations, never both and
+// never neither.  If dst already exists and is replaced, then the replacement is atomic in the same
+// way.
+//
+// Locks are acquired in a consistent order to make concurrent MoveEntry calls deadlock-free:
+// renames between two different directories of a filesystem are serialized by a per-filesystem
+// mutex (as in Linux's per-superblock s_vfs_rename_mutex), and the two directory locks are acquired
+// in a total order (see lockOrder()).
 func MoveEntry(srcParentInode, dstParentInode *DirectoryInode, src, dst *filepath.PathInfo) error {
+	return moveEntry(srcParentInode, dstParentInode, src, dst, false)
+}
+
+// MoveEntryNoReplace behaves like MoveEntry, except that it returns EEXIST instead of replacing dst
+// if dst already exists, like rename(2)'s RENAME_NOREPLACE flag.  The check for dst happens under
+// the same locks as the move, so an entry that is concurrently created at dst is never replaced.
+func MoveEntryNoReplace(srcParentInode, dstParentInode *DirectoryInode, src, dst *filepath.PathInfo) error {
+	return moveEntry(srcParentInode, dstParentInode, src, dst, true)
+}
+
+// CheckMoveEntry returns the error that MoveEntry would return for the same arguments, or nil if
+// MoveEntry would succeed, without moving anything.  It takes the same locks as MoveEntry (at Read
+// level), so its answer is consistent with the state of both directories at some point during the
+// call, but a concurrent mutation may change the answer before a subsequent MoveEntry.
+func CheckMoveEntry(srcParentInode, dstParentInode *DirectoryInode, src, dst *filepath.PathInfo) error {
+	if err := checkMoveNames(dstParentInode, src, dst); err != nil {
+		return err
+	}
+	if srcParentInode == dstParentInode {
+		if src.Entry == dst.Entry {
+			return nil
+		}
+		srcParentInode.rwMutex.RLock()
+		defer srcParentInode.rwMutex.RUnlock()
+		_, err := checkMove(srcParentInode, dstParentInode, src, dst, false, nil, nil, nil)
+		return err
+	}
+	renameLock := srcParentInode.superblock.renameLock()
+	renameLock.Lock()
+	defer renameLock.Unlock()
+	firstToLock, secondToLock := lockOrder(srcParentInode, dstParentInode)
+	srcAncestors := srcParentInode.selfAndAncestors()
+	dstAncestors := dstParentInode.selfAndAncestors()
+	mounts := srcParentInode.superblock.mountGraph()
+	firstToLock.rwMutex.RLock()
+	defer firstToLock.rwMutex.RUnlock()
+	secondToLock.rwMutex.RLock()
+	defer secondToLock.rwMutex.RUnlock()
+	_, err := checkMove(srcParentInode, dstParentInode, src, dst, false, srcAncestors, dstAncestors, mounts)
+	return err
+}
+
+// moveEntry implements MoveEntry and MoveEntryNoReplace
+func moveEntry(srcParentInode, dstParentInode *DirectoryInode, src, dst *filepath.PathInfo, noReplace bool) error {
+	if err := checkMoveNames(dstParentInode, src, dst); err != nil {
+		return err
+	}
+	defer srcParentInode.superblock.beginMutation()()
+	// Edge case: srcParentInode and dstParentInode are the same.  That requires a different locking
+	// discipline, so we special-case it
+	if srcParentInode == dstParentInode {
+		return srcParentInode.renameEntry(src, dst, noReplace)
+	}
+	renameLock := srcParentInode.superblock.renameLock()
+	renameLock.Lock()
+	defer renameLock.Unlock()
+	firstToLock, secondToLock := lockOrder(srcParentInode, dstParentInode)
+	// Collect both parent directories and their ancestors (and those of the bind mount points) now,
+	// since they can't be looked up once the parent directories are locked.  They remain accurate
+	// because the filesystem's rename lock is held.
+	srcAncestors := srcParentInode.selfAndAncestors()
+	dstAncestors := dstParentInode.selfAndAncestors()
+	mounts := srcParentInode.superblock.mountGraph()
+	firstToLock.rwMutex.Lock()
+	defer firstToLock.rwMutex.Unlock()
+	secondToLock.rwMutex.Lock()
+	defer secondToLock.rwMutex.Unlock()
+	srcInode, err := checkMove(srcParentInode, dstParentInode, src, dst, noReplace, srcAncestors, dstAncestors, mounts)
+	if err != nil {
+		return err
+	}
+	// Insert the inode into its new location
+	switch srcInodeTyped := srcInode.(type) {
+	case *FileInode:
+		if err := dstParentInode.doInsertFileInode(dst.Entry, srcInodeTyped); err != nil {
+			return err
+		}
+	case *DirectoryInode:
+		if err := dstParentInode.doInsertDirectoryInode(dst.Entry, srcInodeTyped); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("source entry '%s' has malformed inode of type '%s'", src.Entry, srcInode.InodeType().String())
+	}
+	// Remove the inode from its old location
+	delete(srcParentInode.contents, src.Entry)
+	srcParentInode.unindexEntry(src.Entry)
+	srcParentInode.markModified()
+	srcParentInode.superblock.metricCounters().countRename()
+	return nil
+}
+
+// checkMoveNames performs the checks of a move that depend only on the src and dst entry names
+func checkMoveNames(dstParentInode *DirectoryInode, src, dst *filepath.PathInfo) error {
 	// Check that srcEntry is not the special self or parent directory entries
 	if src.Entry == filepath.SelfDirectoryEntry || src.Entry == filepath.ParentDirectoryEntry {
 		return errors.Wrapf(fserrors.EInval, "cannot move '.' or '..' entries")
@@ -386,78 +710,134 @@ func MoveEntry(srcParentInode, dstParentInode *DirectoryInode, src, dst *filepat
 		return errors.Wrapf(fserrors.EInval, "cannot overwrite '.' or '..' entries")
 	}
 	// Check that the dst entry name doesn't contain the path separator
-	if strings.Contains(dst.Entry, filepath.PathSeparator) {
+	if dstParentInode.superblock.Parser().ContainsSeparator(dst.Entry) {
 		return errors.Wrapf(fserrors.EInval, "entry name '%s' contains the path separator", dst.Entry)
 	}
-	// Edge case: srcParentInode and dstParentInode are the same.  That requires a different locking
-	// discipline, so we special-case it
-	if srcParentInode == dstParentInode {
-		return srcParentInode.renameEntry(src, dst)
-	}
-	srcParentInode.rwMutex.Lock()
-	defer srcParentInode.rwMutex.Unlock()
-	dstParentInode.rwMutex.Lock()
-	defer dstParentInode.rwMutex.Unlock()
+	return dstParentInode.checkNewEntry(dst.Entry)
+}
+
+// checkMove performs the checks of a move that depend on the contents of srcParentInode and
+// dstParentInode, and returns the inode to be moved.  srcAncestors and dstAncestors are the results
+// of selfAndAncestors() for the two parent directories, and mounts is the filesystem's mountGraph();
+// they may all be nil if the parent directories are the same, since an entry can't then be moved
+// into its own subtree.
+//
+// This function is **not thread safe**.  It should only be invoked when locks are held on both
+// parent directories.
+func checkMove(srcParentInode, dstParentInode *DirectoryInode, src, dst *filepath.PathInfo, noReplace bool, srcAncestors, dstAncestors []*DirectoryInode, mounts *mountGraph) (Inode, error) {
 	// Disallow adding files to directories that have already been marked as deleted
 	if dstParentInode.deleted {
-		return errors.Wrapf(fserrors.ENoEnt, "cannot add entries to a directory marked for deletion")
+		return nil, errors.Wrapf(fserrors.ENoEnt, "cannot add entries to a directory marked for deletion")
 	}
 	// Get the inode for the srcEntry
 	srcInode, exists := srcParentInode.contents[src.Entry]
 	if !exists {
-		return errors.Wrapf(fserrors.ENoEnt, "source entry '%s' does not exist", src.Entry)
+		return nil, errors.Wrapf(fserrors.ENoEnt, "source entry '%s' does not exist", src.Entry)
+	}
+	if _, exists := dstParentInode.contents[dst.Entry]; exists && noReplace {
+		return nil, errors.Wrapf(fserrors.EExist, "destination entry '%s' already exists", dst.Entry)
 	}
 	if srcInode.InodeType() == InodeFile && src.MustBeDir {
 		// src ended with a separator, so it ought to be a directory, but we found a file.
-		return errors.Wrapf(fserrors.ENotDir, "src entry is a file but name references a directory")
+		return nil, errors.Wrapf(fserrors.ENotDir, "src entry is a file but name references a directory")
 	}
 	if srcInode.InodeType() == InodeFile && dst.MustBeDir {
 		// dst ended with a separator, so it ought to be a directory, but src is a file
-		return errors.Wrapf(fserrors.ENotDir, "dst's name references a directory but src is a file")
+		return nil, errors.Wrapf(fserrors.ENotDir, "dst's name references a directory but src is a file")
 	}
-	// Insert the inode into its new location
-	switch srcInodeTyped := srcInode.(type) {
+	// Moving a directory into its own subtree would detach it from the tree in a cycle
+	for _, ancestor := range dstAncestors {
+		if srcInode == ancestor {
+			return nil, errors.Wrapf(fserrors.EInval, "cannot move directory '%s' into its own subtree", src.Entry)
+		}
+	}
+	if err := mounts.checkMountCycle(srcInode, src.Entry, dstAncestors); err != nil {
+		return nil, err
+	}
+	if err := dstParentInode.checkReplace(dst.Entry, srcInode, srcAncestors); err != nil {
+		return nil, err
+	}
+	// checkMoveNames() checked the depth of dst itself, but a directory's descendants move with it.
+	// (A rename within one directory doesn't change any depths.)
+	if dstAncestors != nil {
+		if err := dstParentInode.superblock.checkMovedDepth(srcInode, dstAncestors); err != nil {
+			return nil, errors.Wrapf(err, "cannot move '%s'", src.Entry)
+		}
+	}
+	return srcInode, nil
+}
+
+// checkReplace returns the error that replacing i's entry called entry (if it exists) with newEntry
+// would hit.  As with rename(2), a file can't replace a directory, a directory can't replace a
+// file, and a directory can only replace an empty directory that isn't involved in a bind mount.
+// srcAncestors are the source's parent directory and its ancestors: they are never empty, since
+// they contain the source, and they may already be locked by the caller.
+//
+// This function is **not thread safe**.  It should only be invoked when a lock is held on the
+// DirectoryInode
+func (i *DirectoryInode) checkReplace(entry string, newEntry Inode, srcAncestors []*DirectoryInode) error {
+	oldEntry, exists := i.contents[entry]
+	if !exists {
+		return nil
+	}
+	switch oldEntryTyped := oldEntry.(type) {
 	case *FileInode:
-		if err := dstParentInode.doInsertFileInode(dst.Entry, srcInodeTyped); err != nil {
-			return err
+		if newEntry.InodeType() == InodeDirectory {
+			return errors.Wrapf(fserrors.ENotDir, "cannot replace file '%s' with a directory", entry)
 		}
 	case *DirectoryInode:
-		if err := dstParentInode.doInsertDirectoryInode(dst.Entry, srcInodeTyped); err != nil {
+		if newEntry.InodeType() == InodeFile {
+			return errors.Wrapf(fserrors.EIsDir, "cannot replace directory '%s' with a file", entry)
+		}
+		if err := oldEntryTyped.checkNotMounted(entry); err != nil {
 			return err
 		}
+		for _, ancestor := range srcAncestors {
+			if oldEntryTyped == ancestor {
+				return errors.Wrapf(fserrors.ENotEmpty, "cannot replace directory '%s', which contains the source", entry)
+			}
+		}
+		if oldEntryTyped.Size() > 0 {
+			return errors.Wrapf(fserrors.ENotEmpty, "cannot replace non-empty directory '%s'", entry)
+		}
 	default:
-		return fmt.Errorf("source entry '%s' has malformed inode of type '%s'", src.Entry, srcInode.InodeType().String())
+		return fmt.Errorf("existing entry '%s' has malformed inode of type '%s'", entry, oldEntry.InodeType().String())
 	}
-	// Remove the inode from its old location
-	delete(srcParentInode.contents, src.Entry)
 	return nil
 }
 
-// renameEntry is a special case implementation of MoveEntry where src and dst are both children
+// lockOrder returns the distinct DirectoryInodes a and b in the order in which they must be locked.
+// An ancestor is always locked before its descendant, which matches the top-down order in which
+// the rest of this package nests directory locks.  Unrelated directories are locked in order of
+// their ids, so any two directories are always locked in the same order no matter which is the
+// source and which is the destination of a rename.  The answer is only stable while the filesystem's
+// renameLock() is held.
+func lockOrder(a, b *DirectoryInode) (*DirectoryInode, *DirectoryInode) {
+	switch {
+	case a.isAncestorOf(b):
+		return a, b
+	case b.isAncestorOf(a):
+		return b, a
+	case b.id < a.id:
+		return b, a
+	default:
+		return a, b
+	}
+}
+
+// renameEntry is a special case implementation of moveEntry where src and dst are both children
 // of a single DirectoryInode `i`
-func (i *DirectoryInode) renameEntry(src, dst *filepath.PathInfo) error {
-	// Special case: do nothing
-	if src.Entry == dst.Entry {
+func (i *DirectoryInode) renameEntry(src, dst *filepath.PathInfo, noReplace bool) error {
+	// Special case: do nothing.  (With noReplace, src is its own existing dst, so EEXIST is returned
+	// below.)
+	if src.Entry == dst.Entry && !noReplace {
 		return nil
 	}
 	i.rwMutex.Lock()
 	defer i.rwMutex.Unlock()
-	// Disallow moving files in directories that have already been marked as deleted
-	if i.deleted {
-		return errors.Wrapf(fserrors.ENoEnt, "cannot move entries in a directory marked for deletion")
-	}
-	// Get the inode to be moved
-	inode, exists := i.contents[src.Entry]
-	if !exists {
-		return fmt.Errorf("source entry '%s' does not exist", src.Entry)
-	}
-	if inode.InodeType() == InodeFile && src.MustBeDir {
-		// src ended with a separator, so it ought to be a directory, but we found a file.
-		return errors.Wrapf(fserrors.ENotDir, "src entry is a file but name references a directory")
-	}
-	if inode.InodeType() == InodeFile && dst.MustBeDir {
-		// dst ended with a separator, so it ought to be a directory, but src is a file
-		return errors.Wrapf(fserrors.ENotDir, "dst's name references a directory but src is a file")
+	inode, err := checkMove(i, i, src, dst, noReplace, nil, nil, nil)
+	if err != nil {
+		return err
 	}
 	switch inodeTyped := inode.(type) {
 	case *FileInode:
@@ -472,12 +852,16 @@ func (i *DirectoryInode) renameEntry(src, dst *filepath.PathInfo) error {
 		return fmt.Errorf("source entry '%s' has malformed inode of type '%s'", src.Entry, inodeTyped.InodeType().String())
 	}
 	delete(i.contents, src.Entry)
+	i.unindexEntry(src.Entry)
+	i.markModified()
+	i.superblock.metricCounters().countRename()
 	return nil
 }
 
 // doInsertFileInode is a convenience method that provides common functionality for inserting
-// FileInode `newEntry` into i's entry table under the entry name `entry`.  If an entry by this name
-// already exists, then this method will delete that inode.
+// FileInode `newEntry` into i's entry table under the entry name `entry`.  If a file by this name
+// already exists, then this method will delete that inode.  As with rename(2), it returns EISDIR if
+// a directory by this name already exists.
 //
 // This function is **not thread safe**.  It should only be invoked when a Write-level lock is held
 // on the DirectoryInode
@@ -490,20 +874,23 @@ func (i *DirectoryInode) doInsertFileInode(entry string, newEntry *FileInode) er
 				return errors.Wrapf(err, "failed to delete existing file")
 			}
 		case *DirectoryInode:
-			if err := i.doDeleteDirectory(entry); err != nil {
-				return errors.Wrapf(err, "failed to delete existing directory")
-			}
+			return errors.Wrapf(fserrors.EIsDir, "cannot replace directory '%s' with a file", entry)
 		default:
 			return fmt.Errorf("existing entry '%s' has malformed inode of type '%s'", entry, oldEntry.InodeType().String())
 		}
 	}
 	i.contents[entry] = newEntry
+	i.indexEntry(entry)
+	i.markModified()
+	// update the newEntry inode's parent pointer to point to this inode
+	newEntry.setParent(i)
 	return nil
 }
 
 // doInsertDirectoryInode is a convenience method that provides common functionality for inserting
-// DirectoryInode `newEntry` into i's entry table under the entry name `entry`.  If an entry by this
-// name already exists, then this method will delete that inode.
+// DirectoryInode `newEntry` into i's entry table under the entry name `entry`.  If a directory by
+// this name already exists, then this method will delete that inode (which fails if it is not
+// empty).  As with rename(2), it returns ENOTDIR if a file by this name already exists.
 //
 // This function is **not thread safe**.  It should only be invoked when a Write-level lock is held
 // on the DirectoryInode
@@ -512,15 +899,9 @@ func (i *DirectoryInode) doInsertDirectoryInode(entry string, newEntry *Director
 	if oldEntry, exists := i.contents[entry]; exists {
 		switch oldEntry.(type) {
 		case *FileInode:
-			// Interestingly, the POSIX spec says that rename(2) should return an error (EISDIR)
-			// if the source ("old") path specifies a directory but the destination ("new") path
-			// coincides with a file.  We could do that here, but it doesn't seem strictly
-			// necessary, so we will allow it.
-			if err := i.doDeleteFile(entry); err != nil {
-				return errors.Wrapf(err, "failed to delete existing file")
-			}
+			return errors.Wrapf(fserrors.ENotDir, "cannot replace file '%s' with a directory", entry)
 		case *DirectoryInode:
-			if err := i.doDeleteDirectory(entry); err != nil {
+			if err := i.doDeleteDirectory(entry, false); err != nil {
 				return errors.Wrapf(err, "failed to delete existing directory")
 			}
 		default:
@@ -529,7 +910,143 @@ func (i *DirectoryInode) doInsertDirectoryInode(entry string, newEntry *Director
 	}
 	// insert the entry into this directory
 	i.contents[entry] = newEntry
+	i.indexEntry(entry)
+	i.markModified()
 	// update the newEntry inode's parent pointer to point to this inode
 	newEntry.SetParent(i)
 	return nil
 }
+
+// CloneTree returns a deep copy of the subtree rooted at i.  The copy is a root DirectoryInode (its
+// parent entry refers to itself) that shares no inodes with i, so subsequent mutations to either
+// tree are not visible in the other.
+//
+// Each directory is copied while a Read-level lock is held on it, so the copy of any one directory
+// is consistent, but mutations made concurrently elsewhere in the tree may or may not be captured.
+func (i *DirectoryInode) CloneTree() *DirectoryInode {
+	clone := NewRootDirectoryInode()
+	clone.xattrs = i.copyXattrs()
+	clone.copyTimes(&i.basicInode)
+	clone.copyOwner(&i.basicInode)
+	clone.copyMode(&i.basicInode)
+	i.cloneContentsInto(clone)
+	return clone
+}
+
+// cloneContentsInto recursively copies i's entries into dst, which must be a new DirectoryInode
+// that is not yet reachable by any other goroutine
+func (i *DirectoryInode) cloneContentsInto(dst *DirectoryInode) {
+	i.rwMutex.RLock()
+	defer i.rwMutex.RUnlock()
+	for entry, inode := range i.contents {
+		if entry == filepath.SelfDirectoryEntry || entry == filepath.ParentDirectoryEntry {
+			continue
+		}
+		switch inodeTyped := inode.(type) {
+		case *FileInode:
+			dst.contents[entry] = inodeTyped.Clone()
+		case *DirectoryInode:
+			subdirClone := NewDirectoryInode(dst)
+			subdirClone.xattrs = inodeTyped.copyXattrs()
+			subdirClone.copyTimes(&inodeTyped.basicInode)
+			subdirClone.copyOwner(&inodeTyped.basicInode)
+			subdirClone.copyMode(&inodeTyped.basicInode)
+			inodeTyped.cloneContentsInto(subdirClone)
+			dst.contents[entry] = subdirClone
+		}
+	}
+}
+
+// RestoreFrom replaces all of i's entries with a deep copy of src's entries, and i's timestamps,
+// ownership, and permissions with src's.  The receiver keeps its identity (and its parent entry),
+// so references to i remain valid.  Inodes that were previously entries of i are unlinked from the
+// tree, just as if they had been deleted: open handles to them continue to work, but they can no
+// longer be reached by path.
+func (i *DirectoryInode) RestoreFrom(src *DirectoryInode) {
+	i.restoreFrom(src, true)
+}
+
+// RestoreFromFrozen behaves like RestoreFrom(), but for a filesystem that the calling goroutine has
+// frozen (see Freeze()), which RestoreFrom() would wait for forever
+func (i *DirectoryInode) RestoreFromFrozen(src *DirectoryInode) {
+	i.restoreFrom(src, false)
+}
+
+// restoreFrom implements RestoreFrom() and RestoreFromFrozen().  If waitForThaw is true, then it
+// waits for the filesystem to be unfrozen before mutating i (see beginMutation()).
+func (i *DirectoryInode) restoreFrom(src *DirectoryInode, waitForThaw bool) {
+	// Build the copy before taking any locks on i so that src and i may overlap
+	staging := src.CloneTree()
+	staging.AttachTree(i.superblock)
+	if waitForThaw {
+		defer i.superblock.beginMutation()()
+	}
+	i.rwMutex.Lock()
+	defer i.rwMutex.Unlock()
+	for entry, inode := range i.contents {
+		if entry == filepath.SelfDirectoryEntry || entry == filepath.ParentDirectoryEntry {
+			continue
+		}
+		delete(i.contents, entry)
+		i.unindexEntry(entry)
+		unlinkTree(inode)
+	}
+	// staging is unreachable by other goroutines, so its contents can be read without locking
+	for entry, inode := range staging.contents {
+		if entry == filepath.SelfDirectoryEntry || entry == filepath.ParentDirectoryEntry {
+			continue
+		}
+		if dirInode, ok := inode.(*DirectoryInode); ok {
+			dirInode.SetParent(i)
+		}
+		i.contents[entry] = inode
+		staging.unindexEntry(entry)
+		i.indexEntry(entry)
+	}
+	i.accessTime, i.modTime, i.changeTime = staging.accessTime, staging.modTime, staging.changeTime
+	i.uid, i.gid, i.mode = staging.uid, staging.gid, staging.mode
+}
+
+// AttachTree makes the subtree rooted at i, which must not yet be reachable by any other goroutine,
+// part of the filesystem described by sb.  The subtree's file data is charged against sb's quota
+// even if doing so exceeds it.
+func (i *DirectoryInode) AttachTree(sb *Superblock) {
+	i.superblock = sb
+	for entry, inode := range i.contents {
+		if entry == filepath.SelfDirectoryEntry || entry == filepath.ParentDirectoryEntry {
+			continue
+		}
+		i.indexEntry(entry)
+		switch inodeTyped := inode.(type) {
+		case *FileInode:
+			inodeTyped.attach(i)
+		case *DirectoryInode:
+			inodeTyped.AttachTree(sb)
+		}
+	}
+}
+
+// unlinkTree unlinks every FileInode and marks every DirectoryInode as deleted in the subtree rooted
+// at inode, which has just been removed from the filesystem's tree.  The entries of a DirectoryInode
+// with open handles are left alone until its last handle is released (see ReleaseHandle()).
+func unlinkTree(inode Inode) {
+	switch inodeTyped := inode.(type) {
+	case *FileInode:
+		inodeTyped.unlink()
+	case *DirectoryInode:
+		inodeTyped.rwMutex.Lock()
+		defer inodeTyped.rwMutex.Unlock()
+		inodeTyped.deleted = true
+		inodeTyped.superblock.dropMounts(inodeTyped)
+		if inodeTyped.openHandles > 0 {
+			return
+		}
+		for entry, child := range inodeTyped.contents {
+			if entry == filepath.SelfDirectoryEntry || entry == filepath.ParentDirectoryEntry {
+				continue
+			}
+			inodeTyped.unindexEntry(entry)
+			unlinkTree(child)
+		}
+	}
+}
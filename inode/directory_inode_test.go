@@ -1,8 +1,13 @@
 package inode_test
 
 import (
+	"fmt"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/manderson5192/memfs/filepath"
 	"github.com/manderson5192/memfs/fserrors"
 	"github.com/manderson5192/memfs/inode"
 	"github.com/stretchr/testify/assert"
@@ -38,6 +43,72 @@ func (s *DirectoryInodeSuite) TestSize() {
 	assert.Equal(s.T(), 0, s.C.Size())
 }
 
+// assertSizesMatchEntries asserts that the Size() of each of dirs matches a fresh count of its
+// entries
+func (s *DirectoryInodeSuite) assertSizesMatchEntries(dirs ...*inode.DirectoryInode) {
+	for _, dir := range dirs {
+		assert.Equal(s.T(), len(dir.EntryNames()), dir.Size())
+	}
+}
+
+func (s *DirectoryInodeSuite) TestSizeTracksMutations() {
+	dirs := []*inode.DirectoryInode{s.Root, s.A, s.B, s.C}
+	_, err := s.C.CreateFileInodeEntry("f1", true)
+	assert.Nil(s.T(), err)
+	_, err = s.C.CreateFileInodeEntry("f1", false)
+	assert.Nil(s.T(), err)
+	_, _, err = s.C.GetOrCreateFileInodeEntry("f2")
+	assert.Nil(s.T(), err)
+	_, err = s.C.AddDirectory("d")
+	assert.Nil(s.T(), err)
+	_, err = s.C.AddDirectory("d")
+	assert.ErrorIs(s.T(), err, fserrors.EExist)
+	assert.Equal(s.T(), 3, s.C.Size())
+	s.assertSizesMatchEntries(dirs...)
+
+	// Renames within a directory, across directories, and over existing entries
+	assert.Nil(s.T(), inode.MoveEntry(s.C, s.C, filepath.ParsePath("f1"), filepath.ParsePath("g1")))
+	s.assertSizesMatchEntries(dirs...)
+	assert.Nil(s.T(), inode.MoveEntry(s.C, s.C, filepath.ParsePath("g1"), filepath.ParsePath("f2")))
+	s.assertSizesMatchEntries(dirs...)
+	assert.Nil(s.T(), inode.MoveEntry(s.C, s.A, filepath.ParsePath("f2"), filepath.ParsePath("f")))
+	s.assertSizesMatchEntries(dirs...)
+	assert.Nil(s.T(), inode.MoveEntry(s.C, s.A, filepath.ParsePath("d"), filepath.ParsePath("d")))
+	s.assertSizesMatchEntries(dirs...)
+	_, err = s.C.AddDirectory("d")
+	assert.Nil(s.T(), err)
+	assert.Nil(s.T(), inode.MoveEntry(s.C, s.A, filepath.ParsePath("d"), filepath.ParsePath("d")))
+	s.assertSizesMatchEntries(dirs...)
+	_, err = s.C.CreateFileInodeEntry("f", true)
+	assert.Nil(s.T(), err)
+	assert.ErrorIs(s.T(), inode.MoveEntryNoReplace(s.C, s.A, filepath.ParsePath("f"), filepath.ParsePath("f")), fserrors.EExist)
+	s.assertSizesMatchEntries(dirs...)
+	// Exchanging the same entries twice restores them
+	assert.Nil(s.T(), inode.ExchangeEntries(s.C, s.A, filepath.ParsePath("f"), filepath.ParsePath("d")))
+	s.assertSizesMatchEntries(dirs...)
+	assert.Nil(s.T(), inode.ExchangeEntries(s.C, s.A, filepath.ParsePath("f"), filepath.ParsePath("d")))
+
+	// Deletions
+	assert.Nil(s.T(), s.C.DeleteFile("f"))
+	s.assertSizesMatchEntries(dirs...)
+	assert.Nil(s.T(), s.A.DeleteDirectory("d"))
+	s.assertSizesMatchEntries(dirs...)
+	assert.ErrorIs(s.T(), s.A.DeleteDirectory("b"), fserrors.ENotEmpty)
+	s.assertSizesMatchEntries(dirs...)
+	assert.Equal(s.T(), 0, s.C.Size())
+	assert.Equal(s.T(), 2, s.A.Size())
+
+	// Copies of the tree
+	clone := s.Root.CloneTree()
+	s.assertSizesMatchEntries(clone)
+	assert.Equal(s.T(), s.Root.Size(), clone.Size())
+	_, err = s.Root.AddDirectory("extra")
+	assert.Nil(s.T(), err)
+	s.A.RestoreFrom(s.Root)
+	s.assertSizesMatchEntries(append(dirs, clone)...)
+	assert.Equal(s.T(), 2, s.A.Size())
+}
+
 func (s *DirectoryInodeSuite) TestParent() {
 	assert.True(s.T(), s.Root == s.Root.Parent())
 	assert.True(s.T(), s.A == s.B.Parent())
@@ -88,6 +159,199 @@ func (s *DirectoryInodeSuite) TestLookupSubdirectory() {
 	assert.True(s.T(), lookedUp == s.C)
 }
 
+func (s *DirectoryInodeSuite) TestCloneTree() {
+	clone := s.Root.CloneTree()
+	assert.True(s.T(), clone.IsRootDirectoryInode())
+	clonedC, err := clone.LookupSubdirectory("a/b/c")
+	assert.Nil(s.T(), err)
+	assert.False(s.T(), clonedC == s.C, "clone must not share inodes with the original")
+
+	// Mutating the original does not affect the clone
+	_, err = s.C.AddDirectory("d")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), 0, clonedC.Size())
+}
+
+func (s *DirectoryInodeSuite) TestFileInodeParent() {
+	f, err := s.B.CreateFileInodeEntry("file", true)
+	assert.Nil(s.T(), err)
+	parent, err := f.Parent()
+	assert.Nil(s.T(), err)
+	assert.True(s.T(), parent == s.B)
+
+	assert.Nil(s.T(), inode.MoveEntry(s.B, s.C, &filepath.PathInfo{Entry: "file"}, &filepath.PathInfo{Entry: "moved"}))
+	parent, err = f.Parent()
+	assert.Nil(s.T(), err)
+	assert.True(s.T(), parent == s.C)
+
+	assert.Nil(s.T(), s.C.DeleteFile("moved"))
+	_, err = f.Parent()
+	assert.ErrorIs(s.T(), err, fserrors.ENoEnt)
+}
+
+func (s *DirectoryInodeSuite) TestCrissCrossMoveEntry() {
+	// x and y are unrelated (cousins), so neither is locked first by virtue of being an ancestor
+	x, err := s.C.AddDirectory("x")
+	assert.Nil(s.T(), err)
+	y, err := s.Root.AddDirectory("y")
+	assert.Nil(s.T(), err)
+	const numMovers = 16
+	const movesPerMover = 500
+	for idx := 0; idx < numMovers; idx++ {
+		from := x
+		if idx%2 == 1 {
+			from = y
+		}
+		_, err := from.CreateFileInodeEntry(fmt.Sprintf("file_%d", idx), true)
+		assert.Nil(s.T(), err)
+	}
+
+	var wg sync.WaitGroup
+	for idx := 0; idx < numMovers; idx++ {
+		// Half of the goroutines start in x and half in y, so moves happen in both directions at once
+		from, to := x, y
+		if idx%2 == 1 {
+			from, to = y, x
+		}
+		entry := &filepath.PathInfo{Entry: fmt.Sprintf("file_%d", idx)}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := 0; n < movesPerMover; n++ {
+				assert.Nil(s.T(), inode.MoveEntry(from, to, entry, entry))
+				assert.Nil(s.T(), inode.MoveEntry(to, from, entry, entry))
+			}
+		}()
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		s.T().Fatal("MoveEntry calls did not finish: likely deadlock")
+	}
+
+	// Every file ends up back where it started, with its parent pointer intact
+	assert.Equal(s.T(), numMovers/2, x.Size())
+	assert.Equal(s.T(), numMovers/2, y.Size())
+	for idx := 0; idx < numMovers; idx++ {
+		expectedParent := x
+		if idx%2 == 1 {
+			expectedParent = y
+		}
+		f, err := expectedParent.FileInodeEntry(fmt.Sprintf("file_%d", idx))
+		assert.Nil(s.T(), err)
+		parent, err := f.Parent()
+		assert.Nil(s.T(), err)
+		assert.True(s.T(), parent == expectedParent)
+	}
+}
+
+func (s *DirectoryInodeSuite) TestSnapshot() {
+	file, err := s.A.CreateFileInodeEntry("file", true)
+	assert.Nil(s.T(), err)
+	snapshot := s.A.Snapshot()
+	assert.Equal(s.T(), 2, snapshot.Len())
+	assert.Equal(s.T(), []inode.SnapshotEntry{
+		{Name: "b", Inode: s.B},
+		{Name: "file", Inode: file},
+	}, snapshot.Entries())
+	found, exists := snapshot.Lookup("b")
+	assert.True(s.T(), exists)
+	assert.True(s.T(), s.B == found)
+	_, exists = snapshot.Lookup(filepath.SelfDirectoryEntry)
+	assert.False(s.T(), exists)
+
+	// Later changes to the directory are not reflected in the snapshot
+	assert.Nil(s.T(), s.A.DeleteFile("file"))
+	_, err = s.A.AddDirectory("new")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), 2, snapshot.Len())
+	found, exists = snapshot.Lookup("file")
+	assert.True(s.T(), exists)
+	assert.True(s.T(), file == found)
+	_, exists = snapshot.Lookup("new")
+	assert.False(s.T(), exists)
+
+	// Modifying the returned entries doesn't modify the snapshot
+	entries := snapshot.Entries()
+	entries[0].Name = "modified"
+	assert.Equal(s.T(), "b", snapshot.Entries()[0].Name)
+
+	assert.Equal(s.T(), 0, s.C.Snapshot().Len())
+}
+
+func (s *DirectoryInodeSuite) TestSnapshotIsConsistentUnderConcurrentMutation() {
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		// Repeatedly create and delete the entry "x", alternating between a file and a directory
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			if i%2 == 0 {
+				_, _ = s.C.CreateFileInodeEntry("x", true)
+				_ = s.C.DeleteFile("x")
+			} else {
+				_, _ = s.C.AddDirectory("x")
+				_ = s.C.DeleteDirectory("x")
+			}
+		}
+	}()
+	for i := 0; i < 1000; i++ {
+		snapshot := s.C.Snapshot()
+		entries := snapshot.Entries()
+		assert.LessOrEqual(s.T(), len(entries), 1)
+		for _, entry := range entries {
+			assert.Equal(s.T(), "x", entry.Name)
+			assert.NotNil(s.T(), entry.Inode)
+		}
+	}
+	close(stop)
+	wg.Wait()
+}
+
 func TestDirectoryInodeSuite(t *testing.T) {
 	suite.Run(t, new(DirectoryInodeSuite))
 }
+
+// BenchmarkConcurrentInserts measures goroutines concurrently creating files with distinct names
+// in a single directory, compared with each goroutine creating files in a directory of its own,
+// which no other goroutine contends for
+func BenchmarkConcurrentInserts(b *testing.B) {
+	var lastGoroutineID uint64
+	b.Run("SharedDirectory", func(b *testing.B) {
+		root := inode.NewRootDirectoryInodeWithSuperblock(inode.NewSuperblock())
+		b.RunParallel(func(pb *testing.PB) {
+			goroutineID := atomic.AddUint64(&lastGoroutineID, 1)
+			for n := 0; pb.Next(); n++ {
+				if _, err := root.CreateFileInodeEntry(fmt.Sprintf("%d-%d", goroutineID, n), true); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	})
+	b.Run("PrivateDirectories", func(b *testing.B) {
+		root := inode.NewRootDirectoryInodeWithSuperblock(inode.NewSuperblock())
+		b.RunParallel(func(pb *testing.PB) {
+			goroutineID := atomic.AddUint64(&lastGoroutineID, 1)
+			dir, err := root.AddDirectory(fmt.Sprint(goroutineID))
+			if err != nil {
+				b.Fatal(err)
+			}
+			for n := 0; pb.Next(); n++ {
+				if _, err := dir.CreateFileInodeEntry(fmt.Sprint(n), true); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	})
+}
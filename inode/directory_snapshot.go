@@ -0,0 +1,68 @@
+package inode
+
+import (
+	"sort"
+
+	"github.com/manderson5192/memfs/filepath"
+)
+
+// SnapshotEntry is an entry in a DirectorySnapshot: the name of an entry in a DirectoryInode's entry
+// table, along with the inode that it referred to when the snapshot was taken (or, for a mount
+// point, the directory that was mounted on it)
+type SnapshotEntry struct {
+	Name  string
+	Inode Inode
+}
+
+// DirectorySnapshot is an immutable view of a DirectoryInode's entry table at a single point in
+// time.  It reflects no changes that are made to the directory after it was taken, but the inodes
+// it references are live, so their own contents (e.g. a file's data) may have changed since.
+type DirectorySnapshot struct {
+	// entries are sorted by name and exclude the self and parent directory entries
+	entries []SnapshotEntry
+}
+
+// Snapshot returns a DirectorySnapshot of the DirectoryInode's entries, taken under a single
+// acquisition of its lock, so that it is consistent even while the directory is being modified
+// concurrently
+func (i *DirectoryInode) Snapshot() *DirectorySnapshot {
+	i.rwMutex.RLock()
+	entries := make([]SnapshotEntry, 0, len(i.contents))
+	for name, inode := range i.contents {
+		if name == filepath.SelfDirectoryEntry || name == filepath.ParentDirectoryEntry {
+			continue
+		}
+		entries = append(entries, SnapshotEntry{
+			Name:  name,
+			Inode: i.superblock.resolveMount(inode),
+		})
+	}
+	i.rwMutex.RUnlock()
+	// Sort outside of the lock to keep the critical section short
+	sort.Slice(entries, func(a, b int) bool {
+		return entries[a].Name < entries[b].Name
+	})
+	return &DirectorySnapshot{entries: entries}
+}
+
+// Len returns the number of entries in the DirectorySnapshot
+func (s *DirectorySnapshot) Len() int {
+	return len(s.entries)
+}
+
+// Entries returns a copy of the DirectorySnapshot's entries, sorted by name
+func (s *DirectorySnapshot) Entries() []SnapshotEntry {
+	return append([]SnapshotEntry{}, s.entries...)
+}
+
+// Lookup returns the inode that the entry name referred to when the DirectorySnapshot was taken,
+// and whether the entry existed
+func (s *DirectorySnapshot) Lookup(name string) (Inode, bool) {
+	idx := sort.Search(len(s.entries), func(idx int) bool {
+		return s.entries[idx].Name >= name
+	})
+	if idx < len(s.entries) && s.entries[idx].Name == name {
+		return s.entries[idx].Inode, true
+	}
+	return nil, false
+}
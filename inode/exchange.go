@@ -0,0 +1,138 @@
+package inode
+
+import (
+	"github.com/manderson5192/memfs/filepath"
+	"github.com/manderson5192/memfs/fserrors"
+	"github.com/pkg/errors"
+)
+
+// ExchangeEntries atomically swaps the inode at entry1 of parent1 with the inode at entry2 of
+// parent2, like rename(2)'s RENAME_EXCHANGE flag.  Both entries must exist, but they may be files or
+// directories in any combination.  Afterwards, each entry refers to the inode that the other entry
+// referred to, and each inode's parent is the directory that now contains it.  Like MoveEntry, it
+// returns EINVAL if either entry is a directory that would be moved into its own subtree, including
+// through a bind mount.
+//
+// ExchangeEntries is atomic in the same way as MoveEntry, and it acquires its locks in the same
+// order, so it is deadlock-free with respect to concurrent calls to either function.
+func ExchangeEntries(parent1, parent2 *DirectoryInode, entry1, entry2 *filepath.PathInfo) error {
+	parser := parent1.superblock.Parser()
+	for _, entry := range []*filepath.PathInfo{entry1, entry2} {
+		if entry.Entry == filepath.SelfDirectoryEntry || entry.Entry == filepath.ParentDirectoryEntry {
+			return errors.Wrapf(fserrors.EInval, "cannot exchange '.' or '..' entries")
+		}
+		if parser.ContainsSeparator(entry.Entry) {
+			return errors.Wrapf(fserrors.EInval, "entry name '%s' contains the path separator", entry.Entry)
+		}
+	}
+	defer parent1.superblock.beginMutation()()
+	if parent1 == parent2 {
+		return parent1.exchangeEntries(entry1, entry2)
+	}
+	renameLock := parent1.superblock.renameLock()
+	renameLock.Lock()
+	defer renameLock.Unlock()
+	firstToLock, secondToLock := lockOrder(parent1, parent2)
+	// Collect both parents' ancestors (and those of the bind mount points) now, since they can't be
+	// looked up once the parents are locked.  They remain accurate because the filesystem's rename
+	// lock is held.
+	ancestors1 := parent1.selfAndAncestors()
+	ancestors2 := parent2.selfAndAncestors()
+	mounts := parent1.superblock.mountGraph()
+	firstToLock.rwMutex.Lock()
+	defer firstToLock.rwMutex.Unlock()
+	secondToLock.rwMutex.Lock()
+	defer secondToLock.rwMutex.Unlock()
+	if parent1.deleted || parent2.deleted {
+		return errors.Wrapf(fserrors.ENoEnt, "cannot exchange entries in a directory marked for deletion")
+	}
+	inode1, err := exchangeableEntry(parent1, entry1)
+	if err != nil {
+		return err
+	}
+	inode2, err := exchangeableEntry(parent2, entry2)
+	if err != nil {
+		return err
+	}
+	// Moving a directory into its own subtree would detach it from the tree in a cycle
+	for _, ancestor := range ancestors2 {
+		if inode1 == ancestor {
+			return errors.Wrapf(fserrors.EInval, "cannot move directory '%s' into its own subtree", entry1.Entry)
+		}
+	}
+	for _, ancestor := range ancestors1 {
+		if inode2 == ancestor {
+			return errors.Wrapf(fserrors.EInval, "cannot move directory '%s' into its own subtree", entry2.Entry)
+		}
+	}
+	if err := mounts.checkMountCycle(inode1, entry1.Entry, ancestors2); err != nil {
+		return err
+	}
+	if err := mounts.checkMountCycle(inode2, entry2.Entry, ancestors1); err != nil {
+		return err
+	}
+	if err := parent1.superblock.checkMovedDepth(inode1, ancestors2); err != nil {
+		return errors.Wrapf(err, "cannot exchange '%s'", entry1.Entry)
+	}
+	if err := parent1.superblock.checkMovedDepth(inode2, ancestors1); err != nil {
+		return errors.Wrapf(err, "cannot exchange '%s'", entry2.Entry)
+	}
+	parent1.contents[entry1.Entry] = inode2
+	parent2.contents[entry2.Entry] = inode1
+	setEntryParent(inode1, parent2)
+	setEntryParent(inode2, parent1)
+	parent1.markModified()
+	parent2.markModified()
+	parent1.superblock.metricCounters().countRename()
+	return nil
+}
+
+// exchangeEntries is a special case implementation of ExchangeEntries where both entries are
+// children of a single DirectoryInode `i`
+func (i *DirectoryInode) exchangeEntries(entry1, entry2 *filepath.PathInfo) error {
+	i.rwMutex.Lock()
+	defer i.rwMutex.Unlock()
+	if i.deleted {
+		return errors.Wrapf(fserrors.ENoEnt, "cannot exchange entries in a directory marked for deletion")
+	}
+	inode1, err := exchangeableEntry(i, entry1)
+	if err != nil {
+		return err
+	}
+	inode2, err := exchangeableEntry(i, entry2)
+	if err != nil {
+		return err
+	}
+	// The inodes keep the same parent, so only the entry table changes
+	i.contents[entry1.Entry] = inode2
+	i.contents[entry2.Entry] = inode1
+	i.markModified()
+	i.superblock.metricCounters().countRename()
+	return nil
+}
+
+// exchangeableEntry returns parent's inode for entry, or an error if it does not exist or does not
+// match entry's trailing separator.
+//
+// This function is **not thread safe**.  It should only be invoked when a Write-level lock is held
+// on parent.
+func exchangeableEntry(parent *DirectoryInode, entry *filepath.PathInfo) (Inode, error) {
+	inode, exists := parent.contents[entry.Entry]
+	if !exists {
+		return nil, errors.Wrapf(fserrors.ENoEnt, "entry '%s' does not exist", entry.Entry)
+	}
+	if inode.InodeType() == InodeFile && entry.MustBeDir {
+		return nil, errors.Wrapf(fserrors.ENotDir, "entry '%s' is a file but name references a directory", entry.Entry)
+	}
+	return inode, nil
+}
+
+// setEntryParent updates the parent pointer of inode, which has been moved into parent
+func setEntryParent(inode Inode, parent *DirectoryInode) {
+	switch inodeTyped := inode.(type) {
+	case *FileInode:
+		inodeTyped.setParent(parent)
+	case *DirectoryInode:
+		inodeTyped.SetParent(parent)
+	}
+}
@@ -0,0 +1,157 @@
+package inode
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/manderson5192/memfs/fserrors"
+	"github.com/pkg/errors"
+)
+
+// FaultOp identifies a kind of operation that a Fault can make fail
+type FaultOp int
+
+const (
+	// FaultRead applies to reads of file data
+	FaultRead FaultOp = iota + 1
+	// FaultWrite applies to writes of file data
+	FaultWrite
+	// FaultStat applies to Stat() calls
+	FaultStat
+	// FaultOpen applies to opening (and creating) files
+	FaultOpen
+	// FaultMkdir applies to creating directories
+	FaultMkdir
+)
+
+func (o FaultOp) String() string {
+	switch o {
+	case FaultRead:
+		return "read"
+	case FaultWrite:
+		return "write"
+	case FaultStat:
+		return "stat"
+	case FaultOpen:
+		return "open"
+	case FaultMkdir:
+		return "mkdir"
+	default:
+		return "invalid"
+	}
+}
+
+// Fault describes an error to inject into the operations of a filesystem
+type Fault struct {
+	// Op is the kind of operation that fails
+	Op FaultOp
+	// Err is the error that the operation returns.  If it is nil, then fserrors.EIO is used.
+	Err error
+	// Path, if non-empty, restricts the fault to operations on the file or directory at this
+	// absolute path
+	Path string
+	// PathPrefix, if non-empty, restricts the fault to operations on the file or directory at this
+	// absolute path or anywhere beneath it
+	PathPrefix string
+	// AfterN is the number of matching operations that succeed before the fault starts failing
+	// them.  Once triggered, the fault fails every subsequent matching operation.
+	AfterN int
+}
+
+// FaultConfig is the set of faults to inject into a filesystem
+type FaultConfig struct {
+	Faults []Fault
+}
+
+// matches returns true if the fault applies to an operation of kind op on path
+func (f *Fault) matches(op FaultOp, path string) bool {
+	if f.Op != op {
+		return false
+	}
+	if f.Path != "" && f.Path != path {
+		return false
+	}
+	if f.PathPrefix != "" && f.PathPrefix != "/" && path != f.PathPrefix &&
+		!strings.HasPrefix(path, f.PathPrefix+"/") {
+		return false
+	}
+	return true
+}
+
+// faultInjector decides which operations fail according to a FaultConfig
+type faultInjector struct {
+	mutex  sync.Mutex // synchronizes access to calls
+	faults []Fault
+	// calls counts the matching operations seen so far by each fault
+	calls []int
+}
+
+func newFaultInjector(config FaultConfig) *faultInjector {
+	faults := append([]Fault{}, config.Faults...)
+	return &faultInjector{
+		faults: faults,
+		calls:  make([]int, len(faults)),
+	}
+}
+
+func (fi *faultInjector) inject(op FaultOp, path string) error {
+	fi.mutex.Lock()
+	defer fi.mutex.Unlock()
+	var toReturn error
+	for idx := range fi.faults {
+		fault := &fi.faults[idx]
+		if !fault.matches(op, path) {
+			continue
+		}
+		// Every matching fault counts the operation, even if an earlier fault has already decided
+		// to fail it
+		fi.calls[idx]++
+		if fi.calls[idx] <= fault.AfterN || toReturn != nil {
+			continue
+		}
+		err := fault.Err
+		if err == nil {
+			err = fserrors.EIO
+		}
+		toReturn = errors.Wrapf(err, "injected %s fault on '%s'", op.String(), path)
+	}
+	return toReturn
+}
+
+// SetFaults configures the filesystem to fail operations as described by config, replacing any
+// previous configuration
+func (sb *Superblock) SetFaults(config FaultConfig) {
+	sb.faults.Store(newFaultInjector(config))
+}
+
+// getFaults returns the filesystem's faultInjector, or nil if no faults are configured.  It takes no
+// lock, so it is cheap enough to call on every operation.
+func (sb *Superblock) getFaults() *faultInjector {
+	if sb == nil {
+		return nil
+	}
+	return sb.faults.Load()
+}
+
+// InjectFault returns an error if the filesystem is configured to fail an operation of kind op on
+// the file or directory at the absolute path returned by path.  path is only called if faults are
+// configured, since computing a path can be costly.
+func (sb *Superblock) InjectFault(op FaultOp, path func() string) error {
+	faults := sb.getFaults()
+	if faults == nil {
+		return nil
+	}
+	return faults.inject(op, path())
+}
+
+// injectFault returns an error if the filesystem is configured to fail an operation of kind op on
+// this FileInode.  It must be called without holding any lock on the FileInode.
+func (i *FileInode) injectFault(op FaultOp) error {
+	return i.Superblock().InjectFault(op, func() string {
+		path, err := i.Path()
+		if err != nil {
+			return ""
+		}
+		return path
+	})
+}
@@ -1,9 +1,13 @@
 package inode
 
 import (
+	"bytes"
+	"hash"
 	"io"
 	"math"
+	"sync/atomic"
 
+	"github.com/manderson5192/memfs/filepath"
 	"github.com/manderson5192/memfs/fserrors"
 	"github.com/manderson5192/memfs/utils"
 	"github.com/pkg/errors"
@@ -12,11 +16,49 @@ import (
 type FileInode struct {
 	basicInode
 	data []byte
+	// superblock is the Superblock of the filesystem that this FileInode belongs to.  The file's
+	// data is counted against the superblock's quota for as long as the file is linked into a
+	// directory.
+	superblock *Superblock
+	// parent is the directory that currently contains this FileInode, or nil if the FileInode has
+	// been unlinked (or was never linked into a directory in the first place)
+	parent *DirectoryInode
+	// shared is the deduplicated buffer that holds the FileInode's data, or nil if the data is
+	// private to this FileInode.  When shared is non-nil, data must not be mutated in place (see
+	// unshare()).
+	shared *sharedBuffer
+	// exposed is true if data has been returned by Bytes(), in which case data must not be mutated
+	// in place (see unshare()).  It is only set while a Read-level lock is held, so it is atomic.
+	exposed atomic.Bool
+	// extents holds the FileInode's data if the FileInode is sparse, in which case data is unused.
+	// It is nil if the FileInode's data is stored contiguously in data.
+	extents *extentMap
+	// gzip holds the FileInode's data if the FileInode is compressed, in which case data is unused.
+	// It is nil if the FileInode's data is stored uncompressed.
+	gzip *gzipBuffer
+	// advisoryLock backs the flock-style locks that File handles can take on the FileInode
+	advisoryLock advisoryLock
 }
 
 func NewFileInode() *FileInode {
 	inode := &FileInode{
-		data: []byte{},
+		basicInode: newBasicInode(defaultFilePerm),
+		data:       []byte{},
+	}
+	return inode
+}
+
+func newFileInodeWithParent(parent *DirectoryInode) *FileInode {
+	inode := &FileInode{
+		basicInode: newBasicInode(defaultFilePerm),
+		data:       []byte{},
+		superblock: parent.superblock,
+		parent:     parent,
+	}
+	if parent.superblock.IsSparse() {
+		inode.extents = &extentMap{}
+	} else if parent.superblock.IsCompressed() {
+		inode.gzip = newGzipBuffer()
 	}
 	return inode
 }
@@ -25,9 +67,110 @@ func (i *FileInode) InodeType() InodeType {
 	return InodeFile
 }
 
+// Superblock returns the Superblock of the filesystem that this FileInode belongs to
+func (i *FileInode) Superblock() *Superblock {
+	i.rwMutex.RLock()
+	defer i.rwMutex.RUnlock()
+	return i.superblock
+}
+
+// Parent returns the DirectoryInode that currently contains this FileInode.  It returns ENOENT if
+// the FileInode has been unlinked from the filesystem (e.g. because the file was deleted).
+func (i *FileInode) Parent() (*DirectoryInode, error) {
+	i.rwMutex.RLock()
+	defer i.rwMutex.RUnlock()
+	if i.parent == nil {
+		return nil, errors.Wrapf(fserrors.ENoEnt, "file is not linked into any directory")
+	}
+	return i.parent, nil
+}
+
+// Path returns the absolute path of the FileInode in its filesystem.  It returns ENOENT if the
+// FileInode has been unlinked.
+func (i *FileInode) Path() (string, error) {
+	parent, err := i.Parent()
+	if err != nil {
+		return "", err
+	}
+	entry, err := parent.ReverseLookupEntry(i)
+	if err != nil {
+		return "", errors.Wrapf(err, "could not complete reverse path lookup")
+	}
+	parentPath, err := parent.Path()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(parentPath, entry), nil
+}
+
+// Sync commits the FileInode's data to stable storage.  Since the data only ever lives in memory,
+// this does nothing except call the filesystem's SyncHook, if there is one, and return its result.
+func (i *FileInode) Sync() error {
+	hook := i.Superblock().getSyncHook()
+	if hook == nil {
+		return nil
+	}
+	path, err := i.Path()
+	if err != nil {
+		path = ""
+	}
+	return hook(path)
+}
+
 func (i *FileInode) Size() int {
 	i.rwMutex.RLock()
 	defer i.rwMutex.RUnlock()
+	return i.length()
+}
+
+// AllocatedSize returns the number of bytes of data that the FileInode actually stores.  This is
+// the same as Size() unless the FileInode is sparse, in which case holes that have never been
+// written are not counted.
+func (i *FileInode) AllocatedSize() int {
+	i.rwMutex.RLock()
+	defer i.rwMutex.RUnlock()
+	return i.allocated()
+}
+
+// CompressedSize returns the number of bytes that the FileInode's data occupies in memory.  This is
+// the size of the gzip stream if the FileInode is compressed (see Superblock.EnableCompression()),
+// and the same as AllocatedSize() otherwise.
+func (i *FileInode) CompressedSize() int {
+	i.rwMutex.RLock()
+	defer i.rwMutex.RUnlock()
+	if i.gzip != nil {
+		return i.gzip.compressedSize()
+	}
+	return i.allocated()
+}
+
+// length returns the logical length of the FileInode's data.
+//
+// This function is **not thread safe**.  It should only be invoked when a Read- or Write-level lock
+// is held on the FileInode.
+func (i *FileInode) length() int {
+	if i.extents != nil {
+		return i.extents.size
+	}
+	if i.gzip != nil {
+		return i.gzip.size
+	}
+	return len(i.data)
+}
+
+// allocated returns the number of bytes that the FileInode stores, which is what counts against
+// the filesystem's quota.  Compressed FileInodes count their logical length, so that the quota
+// doesn't depend on how well their data compresses.
+//
+// This function is **not thread safe**.  It should only be invoked when a Read- or Write-level lock
+// is held on the FileInode.
+func (i *FileInode) allocated() int {
+	if i.extents != nil {
+		return i.extents.allocated()
+	}
+	if i.gzip != nil {
+		return i.gzip.size
+	}
 	return len(i.data)
 }
 
@@ -35,22 +178,108 @@ func (i *FileInode) Size() int {
 func (i *FileInode) ReadAll() []byte {
 	i.rwMutex.RLock()
 	defer i.rwMutex.RUnlock()
-	toReturn := make([]byte, len(i.data))
+	data := i.copyData()
+	i.superblock.metricCounters().countRead(len(data))
+	return data
+}
+
+// Bytes returns all of the FileInode's data without copying it, for callers that repeatedly read
+// large files.  The returned slice aliases the FileInode's storage, so callers must not modify it.
+// In exchange, the slice is a stable snapshot: a subsequent write to the FileInode copies the data
+// before changing it, so the slice keeps holding the data as of the call to Bytes().  Sparse and
+// compressed FileInodes don't store their data contiguously, so Bytes() returns a copy for them.
+func (i *FileInode) Bytes() []byte {
+	i.rwMutex.RLock()
+	defer i.rwMutex.RUnlock()
+	i.superblock.metricCounters().countRead(i.length())
+	if i.extents != nil || i.gzip != nil {
+		return i.copyData()
+	}
+	i.exposed.Store(true)
+	// Cap the slice's capacity so that appending to it can't write into the FileInode's storage
+	return i.data[:len(i.data):len(i.data)]
+}
+
+// copyData returns a copy of all of the FileInode's data.
+//
+// This function is **not thread safe**.  It should only be invoked when a Read- or Write-level lock
+// is held on the FileInode.
+func (i *FileInode) copyData() []byte {
+	if i.gzip != nil {
+		return i.gzip.load()
+	}
+	toReturn := make([]byte, i.length())
+	if i.extents != nil {
+		i.extents.readAt(toReturn, 0)
+		return toReturn
+	}
 	copy(toReturn, i.data)
 	return toReturn
 }
 
+// Checksum writes the FileInode's data into h and returns the resulting sum.  The data are hashed
+// while a Read-level lock is held, so the sum reflects a consistent snapshot of the file.
+func (i *FileInode) Checksum(h hash.Hash) []byte {
+	i.rwMutex.RLock()
+	defer i.rwMutex.RUnlock()
+	i.superblock.metricCounters().countRead(i.length())
+	// hash.Hash's Write never returns an error
+	if i.extents != nil {
+		i.extents.writeTo(h)
+	} else if i.gzip != nil {
+		i.gzip.writeTo(h)
+	} else {
+		h.Write(i.data)
+	}
+	return h.Sum(nil)
+}
+
 // TruncateAndWriteAll replaces the FileInode's data with those of d
 func (i *FileInode) TruncateAndWriteAll(d []byte) error {
 	if d == nil {
 		return errors.Wrapf(fserrors.EInval, "buffer is nil")
 	}
+	if err := i.injectFault(FaultWrite); err != nil {
+		return err
+	}
+	defer i.Superblock().beginMutation()()
 	i.rwMutex.Lock()
 	defer i.rwMutex.Unlock()
-	i.data = d
+	if err := i.reserve(len(d) - i.allocated()); err != nil {
+		return err
+	}
+	i.storeData(d)
+	i.markModified()
+	i.superblock.metricCounters().countWrite(len(d))
 	return nil
 }
 
+// CompareAndSwapAll replaces the FileInode's data with those of replacement, like
+// TruncateAndWriteAll(), but only if its current data are equal to expected.  It returns true if the
+// data were replaced.  The comparison and the replacement happen under a single Write-level lock, so
+// when several callers race to swap out the same data, exactly one of them succeeds.
+func (i *FileInode) CompareAndSwapAll(expected, replacement []byte) (bool, error) {
+	if replacement == nil {
+		return false, errors.Wrapf(fserrors.EInval, "buffer is nil")
+	}
+	if err := i.injectFault(FaultWrite); err != nil {
+		return false, err
+	}
+	defer i.Superblock().beginMutation()()
+	i.rwMutex.Lock()
+	defer i.rwMutex.Unlock()
+	if i.length() != len(expected) || !bytes.Equal(i.copyData(), expected) {
+		return false, nil
+	}
+	if err := i.reserve(len(replacement) - i.allocated()); err != nil {
+		return false, err
+	}
+	i.storeData(replacement)
+	i.markModified()
+	i.superblock.metricCounters().countWrite(len(replacement))
+	return true, nil
+}
+
 // ReadAt tries to copy len(p) bytes at offset off from the file into p.  If there are fewer than
 // len(p) bytes between the offset and the end of the file, then the error will be non-nil and
 // equal to io.EOF.
@@ -61,18 +290,30 @@ func (i *FileInode) ReadAt(p []byte, off int64) (int, error) {
 	if off < 0 {
 		return 0, errors.Wrapf(fserrors.EInval, "negative offset")
 	}
-	// Edge case: since `off` is int64 and len(i.data) is `int`, we can only ever read from an offset
+	// Edge case: since `off` is int64 and the file's length is `int`, we can only ever read from an offset
 	// as large as math.MaxInt
 	if off > int64(math.MaxInt) {
 		return 0, io.EOF
 	}
 	intOff := int(off)
+	if err := i.injectFault(FaultRead); err != nil {
+		return 0, err
+	}
 	i.rwMutex.RLock()
 	defer i.rwMutex.RUnlock()
-	bytesAfterOffset := utils.Max(len(i.data)-intOff, 0)
+	bytesAfterOffset := utils.Max(i.length()-intOff, 0)
 	numBytesRequested := len(p)
 	numBytesToRead := utils.Min(bytesAfterOffset, numBytesRequested)
-	copy(p, i.data[intOff:intOff+numBytesToRead])
+	switch {
+	case numBytesToRead == 0:
+		// Don't slice the data at an offset that may be past its end (or even its capacity)
+	case i.extents != nil:
+		i.extents.readAt(p[:numBytesToRead], intOff)
+	case i.gzip != nil:
+		copy(p, i.gzip.load()[intOff:intOff+numBytesToRead])
+	default:
+		copy(p, i.data[intOff:intOff+numBytesToRead])
+	}
 	var err error = error(nil)
 	// If the number of bytes read is fewer than the number requested, then we need to return EOF
 	if numBytesToRead < numBytesRequested {
@@ -80,9 +321,60 @@ func (i *FileInode) ReadAt(p []byte, off int64) (int, error) {
 		// trying to implement
 		err = io.EOF
 	}
+	i.superblock.metricCounters().countRead(numBytesToRead)
 	return numBytesToRead, err
 }
 
+// ReadAtInto behaves exactly like ReadAt(), copying the bytes at offset off into dst.  It exists
+// so that performance-sensitive callers can name the copying read explicitly, and so that it can be
+// optimized independently of ReadAt(), which must honor the io.ReaderAt contract.
+func (i *FileInode) ReadAtInto(dst []byte, off int64) (int, error) {
+	return i.ReadAt(dst, off)
+}
+
+// PeekAt returns up to n bytes of the FileInode's data, starting at offset off, without copying
+// them, for callers that want to avoid ReadAt()'s copy.  As with Bytes(), the returned slice
+// aliases the FileInode's storage, so callers must not modify it, and it is a stable snapshot:
+// subsequent writes to the FileInode copy the data before changing it.  As with ReadAt(), if there
+// are fewer than n bytes between the offset and the end of the file, then PeekAt returns the bytes
+// that there are along with io.EOF.  Sparse and compressed FileInodes return a copy.
+func (i *FileInode) PeekAt(off int64, n int) ([]byte, error) {
+	if n < 0 {
+		return nil, errors.Wrapf(fserrors.EInval, "negative length")
+	}
+	if off < 0 {
+		return nil, errors.Wrapf(fserrors.EInval, "negative offset")
+	}
+	if err := i.injectFault(FaultRead); err != nil {
+		return nil, err
+	}
+	i.rwMutex.RLock()
+	defer i.rwMutex.RUnlock()
+	length := i.length()
+	if off > int64(length) {
+		return []byte{}, io.EOF
+	}
+	intOff := int(off)
+	end := intOff + utils.Min(length-intOff, n)
+	var err error
+	if end-intOff < n {
+		err = io.EOF
+	}
+	i.superblock.metricCounters().countRead(end - intOff)
+	if i.extents != nil || i.gzip != nil {
+		peeked := make([]byte, end-intOff)
+		if i.extents != nil {
+			i.extents.readAt(peeked, intOff)
+		} else {
+			copy(peeked, i.gzip.load()[intOff:end])
+		}
+		return peeked, err
+	}
+	i.exposed.Store(true)
+	// Cap the slice's capacity so that appending to it can't write into the FileInode's storage
+	return i.data[intOff:end:end], err
+}
+
 // WriteAt attempts copying len(p) bytes from p into the FileInode's data at offset off.  If off is
 // beyond the end of the file, then the file is extended with zero bytes up to the offset before
 // copying begins.  It returns the number of bytes that were copied, or 0 and an error.
@@ -93,7 +385,7 @@ func (i *FileInode) WriteAt(p []byte, off int64) (n int, err error) {
 	if off < 0 {
 		return 0, errors.Wrapf(fserrors.EInval, "negative offset")
 	}
-	// Edge case: since `off` is int64 and len(i.data) is `int`, we can only ever write to an offset
+	// Edge case: since `off` is int64 and the file's length is `int`, we can only ever write to an offset
 	// as large as math.MaxInt
 	if off+int64(len(p)) > int64(math.MaxInt) {
 		return 0, errors.Wrapf(fserrors.ENoSpace, "cannot write beyond max file size")
@@ -103,19 +395,185 @@ func (i *FileInode) WriteAt(p []byte, off int64) (n int, err error) {
 	if off+int64(len(p)) < 0 {
 		return 0, errors.Wrapf(fserrors.ENoSpace, "cannot write beyond max file size")
 	}
+	if err := i.injectFault(FaultWrite); err != nil {
+		return 0, err
+	}
 	intOff := int(off)
+	defer i.Superblock().beginMutation()()
 	i.rwMutex.Lock()
 	defer i.rwMutex.Unlock()
 
+	// A sparse FileInode only allocates the bytes that are written, not the hole before them
+	if i.extents != nil {
+		if err := i.reserve(i.extents.growth(intOff, len(p))); err != nil {
+			return 0, err
+		}
+		i.extents.writeAt(p, intOff)
+		i.markModified()
+		i.superblock.metricCounters().countWrite(len(p))
+		return len(p), nil
+	}
+
 	// If (intOff + len(p)) is beyond the end of the file, then we need to pad with zero bytes up to
 	// that length
 	zeroesToAppend := 0
-	if (intOff + len(p)) > len(i.data) {
-		zeroesToAppend = intOff + len(p) - len(i.data)
+	if (intOff + len(p)) > i.length() {
+		zeroesToAppend = intOff + len(p) - i.length()
+	}
+	if err := i.reserve(zeroesToAppend); err != nil {
+		return 0, err
+	}
+	// A compressed FileInode's data is decompressed, modified, and compressed again
+	var data []byte
+	if i.gzip != nil {
+		data = i.gzip.load()
+	} else {
+		i.unshare()
+		data = i.data
 	}
-	i.data = append(i.data, make([]byte, zeroesToAppend)...)
+	// append() grows data's capacity geometrically, and the compiler extends it in place rather
+	// than allocating the zero bytes separately, so a series of small appends is amortized O(1)
+	data = append(data, make([]byte, zeroesToAppend)...)
 	// Do the data copy
-	copy(i.data[intOff:intOff+len(p)], p)
+	copy(data[intOff:intOff+len(p)], p)
+	if i.gzip != nil {
+		i.gzip.store(data)
+	} else {
+		i.data = data
+	}
+	i.markModified()
+	i.superblock.metricCounters().countWrite(len(p))
 
 	return len(p), nil
 }
+
+// AppendAll atomically appends all of p to the end of the FileInode's data, as a write to a file
+// opened with O_APPEND does.  It returns the number of bytes appended and the offset at which they
+// were written.
+func (i *FileInode) AppendAll(p []byte) (int, int64, error) {
+	return i.AppendAllWithLimit(p, -1)
+}
+
+// AppendAllWithLimit is like AppendAll, except that it will not grow the FileInode's data beyond
+// limit bytes.  It appends as many bytes of p as fit below limit, so the number of bytes appended
+// may be less than len(p) even if the error is nil.  A negative limit means that there is no limit.
+func (i *FileInode) AppendAllWithLimit(p []byte, limit int64) (int, int64, error) {
+	if p == nil {
+		return 0, 0, errors.Wrapf(fserrors.EInval, "buffer is nil")
+	}
+	if err := i.injectFault(FaultWrite); err != nil {
+		return 0, 0, err
+	}
+	defer i.Superblock().beginMutation()()
+	i.rwMutex.Lock()
+	defer i.rwMutex.Unlock()
+	start := i.length()
+	toAppend := p
+	if limit >= 0 && int64(start)+int64(len(p)) > limit {
+		toAppend = p[:utils.Max(int(limit)-start, 0)]
+	}
+	if start+len(toAppend) < start {
+		return 0, int64(start), errors.Wrapf(fserrors.ENoSpace, "cannot write beyond max file size")
+	}
+	if err := i.reserve(len(toAppend)); err != nil {
+		return 0, int64(start), err
+	}
+	i.appendData(toAppend)
+	i.superblock.metricCounters().countWrite(len(toAppend))
+	return len(toAppend), int64(start), nil
+}
+
+// appendData appends p to the end of the FileInode's data, which must already have been reserved.
+//
+// This function is **not thread safe**.  It should only be invoked when a Write-level lock is held
+// on the FileInode.
+func (i *FileInode) appendData(p []byte) {
+	i.markModified()
+	if i.extents != nil {
+		i.extents.writeAt(p, i.extents.size)
+		return
+	}
+	if i.gzip != nil {
+		i.gzip.store(append(i.gzip.load(), p...))
+		return
+	}
+	i.unshare()
+	i.data = append(i.data, p...)
+}
+
+// Clone returns a new FileInode that holds a copy of i's data, extended attributes, timestamps,
+// ownership, and permissions.  The clone is sparse or compressed if i is.  It does not belong to
+// any filesystem.
+func (i *FileInode) Clone() *FileInode {
+	clone := NewFileInode()
+	i.rwMutex.RLock()
+	if i.extents != nil {
+		clone.extents = i.extents.clone()
+	} else if i.gzip != nil {
+		clone.gzip = i.gzip.clone()
+	} else {
+		clone.data = append([]byte{}, i.data...)
+	}
+	i.rwMutex.RUnlock()
+	clone.xattrs = i.copyXattrs()
+	clone.copyTimes(&i.basicInode)
+	clone.copyOwner(&i.basicInode)
+	clone.copyMode(&i.basicInode)
+	return clone
+}
+
+// reserve accounts for the file's data growing (or shrinking, if delta is negative) by delta bytes.
+// It returns ENOSPC if the growth would exceed the filesystem's quota.
+//
+// This function is **not thread safe**.  It should only be invoked when a Write-level lock is held
+// on the FileInode.
+func (i *FileInode) reserve(delta int) error {
+	if i.parent == nil || delta == 0 {
+		return nil
+	}
+	return i.superblock.reserve(int64(delta))
+}
+
+// setParent records that the FileInode has been moved into the directory parent
+func (i *FileInode) setParent(parent *DirectoryInode) {
+	i.rwMutex.Lock()
+	defer i.rwMutex.Unlock()
+	i.parent = parent
+}
+
+// IsDeleted returns true if the FileInode is no longer linked into any directory (e.g. because the
+// file was deleted, or replaced by a rename).  Handles that are still open on it keep working.
+func (i *FileInode) IsDeleted() bool {
+	i.rwMutex.RLock()
+	defer i.rwMutex.RUnlock()
+	return i.parent == nil
+}
+
+// unlink is called when the FileInode is removed from its directory.  The file's data no longer
+// counts against the filesystem's quota, though the FileInode remains usable by any open handles.
+func (i *FileInode) unlink() {
+	i.rwMutex.Lock()
+	defer i.rwMutex.Unlock()
+	if i.parent == nil {
+		return
+	}
+	i.superblock.charge(-int64(i.allocated()))
+	i.parent = nil
+	// Open files are closed by garbage collection, so a deleted file that is still open must not
+	// keep its share of a deduplicated buffer.  The buffer is immutable, so the FileInode can keep
+	// reading it, as long as it is treated like a buffer returned by Bytes() and copied before any
+	// write (see unshare()).
+	if i.shared != nil {
+		i.exposed.Store(true)
+		i.superblock.dedupTable().release(i.shared)
+		i.shared = nil
+	}
+}
+
+// attach links the FileInode, which must not yet be reachable by any other goroutine, into the
+// directory parent, unconditionally charging its data against the quota of parent's filesystem
+func (i *FileInode) attach(parent *DirectoryInode) {
+	i.superblock = parent.superblock
+	i.parent = parent
+	i.superblock.charge(int64(i.allocated()))
+}
@@ -2,6 +2,7 @@ package inode_test
 
 import (
 	"io"
+	"sync"
 	"testing"
 
 	"github.com/manderson5192/memfs/fserrors"
@@ -150,6 +151,168 @@ func (s *FileInodeTestSuite) TestWriteAtOverwrite() {
 	assert.Equal(s.T(), "hello, nobody", string(data))
 }
 
+func (s *FileInodeTestSuite) TestReadAtInto() {
+	assert.Nil(s.T(), s.FileInode.TruncateAndWriteAll([]byte("hello, world")))
+	dst := make([]byte, 5)
+	n, err := s.FileInode.ReadAtInto(dst, 7)
+	assert.Equal(s.T(), 5, n)
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), "world", string(dst))
+	n, err = s.FileInode.ReadAtInto(dst, 10)
+	assert.Equal(s.T(), 2, n)
+	assert.ErrorIs(s.T(), err, io.EOF)
+	_, err = s.FileInode.ReadAtInto(dst, -1)
+	assert.ErrorIs(s.T(), err, fserrors.EInval)
+}
+
+func (s *FileInodeTestSuite) TestReadAtIntoPastEOF() {
+	assert.Nil(s.T(), s.FileInode.TruncateAndWriteAll([]byte("12345678")))
+	dst := make([]byte, 5)
+	for _, off := range []int64{8, 9, 100} {
+		n, err := s.FileInode.ReadAtInto(dst, off)
+		assert.Equal(s.T(), 0, n, "offset %d", off)
+		assert.ErrorIs(s.T(), err, io.EOF, "offset %d", off)
+	}
+	n, err := s.FileInode.ReadAtInto([]byte{}, 100)
+	assert.Equal(s.T(), 0, n)
+	assert.Nil(s.T(), err)
+}
+
+func (s *FileInodeTestSuite) TestPeekAt() {
+	assert.Nil(s.T(), s.FileInode.TruncateAndWriteAll([]byte("hello, world")))
+	peeked, err := s.FileInode.PeekAt(7, 5)
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), "world", string(peeked))
+	peeked, err = s.FileInode.PeekAt(0, 0)
+	assert.Nil(s.T(), err)
+	assert.Empty(s.T(), peeked)
+
+	// Peeking past the end of the file returns the available bytes and io.EOF
+	peeked, err = s.FileInode.PeekAt(10, 5)
+	assert.ErrorIs(s.T(), err, io.EOF)
+	assert.Equal(s.T(), "ld", string(peeked))
+	peeked, err = s.FileInode.PeekAt(12, 1)
+	assert.ErrorIs(s.T(), err, io.EOF)
+	assert.Empty(s.T(), peeked)
+	peeked, err = s.FileInode.PeekAt(100, 1)
+	assert.ErrorIs(s.T(), err, io.EOF)
+	assert.Empty(s.T(), peeked)
+
+	_, err = s.FileInode.PeekAt(-1, 1)
+	assert.ErrorIs(s.T(), err, fserrors.EInval)
+	_, err = s.FileInode.PeekAt(0, -1)
+	assert.ErrorIs(s.T(), err, fserrors.EInval)
+}
+
+func (s *FileInodeTestSuite) TestPeekAtIsStableSnapshot() {
+	assert.Nil(s.T(), s.FileInode.TruncateAndWriteAll([]byte("hello, world")))
+	peeked, err := s.FileInode.PeekAt(0, 5)
+	assert.Nil(s.T(), err)
+
+	// Writers that race with a reader of the peeked bytes copy the data rather than changing them
+	wg := sync.WaitGroup{}
+	for n := 0; n < 4; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := s.FileInode.WriteAt([]byte("HELLO"), 0)
+			assert.Nil(s.T(), err)
+		}()
+	}
+	for n := 0; n < 100; n++ {
+		assert.Equal(s.T(), "hello", string(peeked))
+	}
+	wg.Wait()
+	assert.Equal(s.T(), "HELLO, world", string(s.FileInode.ReadAll()))
+	assert.Equal(s.T(), "hello", string(peeked))
+}
+
+func TestPeekAtSparseFileInode(t *testing.T) {
+	sb := inode.NewSuperblock()
+	sb.EnableSparse()
+	root := inode.NewRootDirectoryInodeWithSuperblock(sb)
+	i, err := root.CreateFileInodeEntry("sparse", true)
+	assert.Nil(t, err)
+	_, err = i.WriteAt([]byte("data"), 4)
+	assert.Nil(t, err)
+	peeked, err := i.PeekAt(2, 4)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{0, 0, 'd', 'a'}, peeked)
+}
+
 func TestFileInodeTestSuite(t *testing.T) {
 	suite.Run(t, new(FileInodeTestSuite))
 }
+
+func (s *FileInodeTestSuite) TestAppendAll() {
+	n, start, err := s.FileInode.AppendAll([]byte("hello"))
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), 5, n)
+	assert.Equal(s.T(), int64(0), start)
+	n, start, err = s.FileInode.AppendAllWithLimit([]byte(", world"), 8)
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), 3, n, "only the bytes below the limit are appended")
+	assert.Equal(s.T(), int64(5), start)
+	assert.Equal(s.T(), "hello, w", string(s.FileInode.ReadAll()))
+	_, _, err = s.FileInode.AppendAll(nil)
+	assert.ErrorIs(s.T(), err, fserrors.EInval)
+}
+
+func (s *FileInodeTestSuite) TestAppendRing() {
+	n, err := s.FileInode.AppendRing([]byte("abcd"), 6)
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), 4, n)
+	assert.Equal(s.T(), "abcd", string(s.FileInode.ReadAll()))
+
+	// Appending past the ring size evicts the oldest bytes
+	n, err = s.FileInode.AppendRing([]byte("efgh"), 6)
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), 4, n)
+	assert.Equal(s.T(), "cdefgh", string(s.FileInode.ReadAll()))
+
+	// A buffer longer than the ring size keeps only its own tail
+	n, err = s.FileInode.AppendRing([]byte("0123456789"), 6)
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), 10, n)
+	assert.Equal(s.T(), "456789", string(s.FileInode.ReadAll()))
+
+	// An empty buffer just trims to the ring size
+	_, err = s.FileInode.AppendRing([]byte{}, 2)
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), "89", string(s.FileInode.ReadAll()))
+
+	_, err = s.FileInode.AppendRing(nil, 6)
+	assert.ErrorIs(s.T(), err, fserrors.EInval)
+	_, err = s.FileInode.AppendRing([]byte("x"), 0)
+	assert.ErrorIs(s.T(), err, fserrors.EInval)
+}
+
+// BenchmarkSingleByteAppends measures appending 100k single bytes to a FileInode, one write at a
+// time.  The FileInode's data grows geometrically, so the number of allocations per iteration is
+// logarithmic in the file's final size rather than linear.
+func BenchmarkSingleByteAppends(b *testing.B) {
+	const numAppends = 100_000
+	p := []byte("x")
+	b.Run("WriteAt", func(b *testing.B) {
+		b.ReportAllocs()
+		for n := 0; n < b.N; n++ {
+			i := inode.NewFileInode()
+			for off := int64(0); off < numAppends; off++ {
+				if _, err := i.WriteAt(p, off); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+	b.Run("AppendAll", func(b *testing.B) {
+		b.ReportAllocs()
+		for n := 0; n < b.N; n++ {
+			i := inode.NewFileInode()
+			for off := 0; off < numAppends; off++ {
+				if _, _, err := i.AppendAll(p); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+}
@@ -0,0 +1,96 @@
+package inode
+
+import (
+	"io/fs"
+	"time"
+)
+
+// Freeze makes the filesystem immutable until Unfreeze() is called: every operation that would
+// mutate an inode in the filesystem (writing file data, adding, removing, or renaming entries, or
+// setting extended attributes, timestamps, ownership, or permissions) blocks until the filesystem
+// is unfrozen, while reads proceed normally.  Freeze waits for mutations that are already in
+// progress to finish, so once it returns, readers observe a consistent view of the filesystem
+// without needing to copy it.
+//
+// As with sync.RWMutex, a frozen filesystem must not be frozen again before it is unfrozen, and a
+// goroutine must not mutate the filesystem while it is frozen, since it would block forever.
+func (sb *Superblock) Freeze() {
+	if sb == nil {
+		return
+	}
+	sb.freezeMutex.Lock()
+}
+
+// Unfreeze allows the mutations that were blocked by Freeze() to proceed.  It panics if the
+// filesystem is not frozen.
+func (sb *Superblock) Unfreeze() {
+	if sb == nil {
+		return
+	}
+	sb.freezeMutex.Unlock()
+}
+
+// beginMutation blocks while the filesystem is frozen, and then prevents it from being frozen until
+// the returned function is called.  It must be called before taking any inode locks, and it must
+// not be called again before the returned function is called, since a goroutine that is waiting in
+// Freeze() would block both calls.
+func (sb *Superblock) beginMutation() func() {
+	if sb == nil {
+		return noMutation
+	}
+	sb.freezeMutex.RLock()
+	return sb.endMutation
+}
+
+// noMutation is returned by beginMutation() for inodes that don't belong to any filesystem
+func noMutation() {}
+
+func (i *FileInode) SetXattr(name string, value []byte) error {
+	defer i.Superblock().beginMutation()()
+	return i.basicInode.SetXattr(name, value)
+}
+
+func (i *FileInode) RemoveXattr(name string) error {
+	defer i.Superblock().beginMutation()()
+	return i.basicInode.RemoveXattr(name)
+}
+
+func (i *FileInode) SetTimes(accessTime, modTime time.Time) {
+	defer i.Superblock().beginMutation()()
+	i.basicInode.SetTimes(accessTime, modTime)
+}
+
+func (i *FileInode) Chown(uid, gid int) {
+	defer i.Superblock().beginMutation()()
+	i.basicInode.Chown(uid, gid)
+}
+
+func (i *FileInode) Chmod(mode fs.FileMode) {
+	defer i.Superblock().beginMutation()()
+	i.basicInode.Chmod(mode)
+}
+
+func (i *DirectoryInode) SetXattr(name string, value []byte) error {
+	defer i.superblock.beginMutation()()
+	return i.basicInode.SetXattr(name, value)
+}
+
+func (i *DirectoryInode) RemoveXattr(name string) error {
+	defer i.superblock.beginMutation()()
+	return i.basicInode.RemoveXattr(name)
+}
+
+func (i *DirectoryInode) SetTimes(accessTime, modTime time.Time) {
+	defer i.superblock.beginMutation()()
+	i.basicInode.SetTimes(accessTime, modTime)
+}
+
+func (i *DirectoryInode) Chown(uid, gid int) {
+	defer i.superblock.beginMutation()()
+	i.basicInode.Chown(uid, gid)
+}
+
+func (i *DirectoryInode) Chmod(mode fs.FileMode) {
+	defer i.superblock.beginMutation()()
+	i.basicInode.Chmod(mode)
+}
@@ -0,0 +1,161 @@
+package inode
+
+import (
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/manderson5192/memfs/filepath"
+)
+
+// nameIndex maps each entry name in a filesystem to the directories that have an entry by that
+// name, so that entries can be found by name without walking the filesystem's tree.  It records
+// parent directories rather than paths so that renaming a directory doesn't invalidate the
+// entries of its descendants.
+type nameIndex struct {
+	mutex   sync.Mutex // synchronizes access to parents; never held while acquiring another lock
+	parents map[string]map[*DirectoryInode]struct{}
+}
+
+func newNameIndex() *nameIndex {
+	return &nameIndex{
+		parents: map[string]map[*DirectoryInode]struct{}{},
+	}
+}
+
+// add records that parent has an entry called name
+func (idx *nameIndex) add(name string, parent *DirectoryInode) {
+	idx.mutex.Lock()
+	defer idx.mutex.Unlock()
+	parents, exists := idx.parents[name]
+	if !exists {
+		parents = map[*DirectoryInode]struct{}{}
+		idx.parents[name] = parents
+	}
+	parents[parent] = struct{}{}
+}
+
+// remove records that parent no longer has an entry called name
+func (idx *nameIndex) remove(name string, parent *DirectoryInode) {
+	idx.mutex.Lock()
+	defer idx.mutex.Unlock()
+	parents := idx.parents[name]
+	delete(parents, parent)
+	if len(parents) == 0 {
+		delete(idx.parents, name)
+	}
+}
+
+// lookup returns the directories that have an entry called name
+func (idx *nameIndex) lookup(name string) []*DirectoryInode {
+	idx.mutex.Lock()
+	defer idx.mutex.Unlock()
+	parents := make([]*DirectoryInode, 0, len(idx.parents[name]))
+	for parent := range idx.parents[name] {
+		parents = append(parents, parent)
+	}
+	return parents
+}
+
+// EnableIndex makes the filesystem maintain an index of its entries by name, so that
+// DirectoryInode.FindEntries() doesn't have to walk the tree.  It must be called before any entries
+// are added to the filesystem, since existing entries are not indexed.
+func (sb *Superblock) EnableIndex() {
+	sb.mutex.Lock()
+	defer sb.mutex.Unlock()
+	if sb.index == nil {
+		sb.index = newNameIndex()
+	}
+}
+
+// IsIndexed returns true if the filesystem maintains an index of its entries by name
+func (sb *Superblock) IsIndexed() bool {
+	return sb.nameIndex() != nil
+}
+
+// nameIndex returns the filesystem's nameIndex, or nil if the filesystem is not indexed
+func (sb *Superblock) nameIndex() *nameIndex {
+	if sb == nil {
+		return nil
+	}
+	sb.mutex.Lock()
+	defer sb.mutex.Unlock()
+	return sb.index
+}
+
+// indexEntry records i's entry called name in the filesystem's index, if it has one.  It should be
+// called whenever an entry is added to i's entry table.
+func (i *DirectoryInode) indexEntry(name string) {
+	if idx := i.superblock.nameIndex(); idx != nil {
+		idx.add(name, i)
+	}
+}
+
+// unindexEntry removes i's entry called name from the filesystem's index, if it has one.  It should
+// be called whenever an entry is removed from i's entry table.
+func (i *DirectoryInode) unindexEntry(name string) {
+	if idx := i.superblock.nameIndex(); idx != nil {
+		idx.remove(name, i)
+	}
+}
+
+// FindEntries returns the paths, relative to i, of every entry called name in i's subtree, in the
+// order in which a lexical, depth-first walk of the subtree would visit them.  The paths use the
+// default path syntax.  It returns false if the filesystem can't answer from its index, because it
+// isn't indexed (see Superblock.EnableIndex()), or because it has bind mounts or faults that a
+// walk of the tree would observe but the index doesn't; the caller must then walk the tree.
+//
+// Each path was valid at some point during the call, but entries that are concurrently created,
+// removed, or renamed may or may not be reported.
+func (i *DirectoryInode) FindEntries(name string) ([]string, bool) {
+	idx := i.superblock.nameIndex()
+	if idx == nil || len(i.superblock.copyMounts()) > 0 || i.superblock.getFaults() != nil {
+		return nil, false
+	}
+	// relativePaths memoizes the parts of each directory's path relative to i, or nil if the
+	// directory is not in i's subtree
+	relativePaths := map[*DirectoryInode][]string{i: {}}
+	var pathParts func(dir *DirectoryInode) []string
+	pathParts = func(dir *DirectoryInode) []string {
+		if parts, memoized := relativePaths[dir]; memoized {
+			return parts
+		}
+		var parts []string
+		if !dir.IsRootDirectoryInode() {
+			parent := dir.Parent()
+			// A directory that has been removed from its parent is not in any subtree
+			if entry, err := parent.ReverseLookupEntry(dir); err == nil {
+				if parentParts := pathParts(parent); parentParts != nil {
+					parts = append(slices.Clone(parentParts), entry)
+				}
+			}
+		}
+		relativePaths[dir] = parts
+		return parts
+	}
+	matches := [][]string{}
+	for _, parent := range idx.lookup(name) {
+		if !parent.hasEntry(name) {
+			continue
+		}
+		if parts := pathParts(parent); parts != nil {
+			matches = append(matches, append(slices.Clone(parts), name))
+		}
+	}
+	// Comparing paths part by part puts each directory before its descendants, and siblings in
+	// lexical order, just as a walk would
+	slices.SortFunc(matches, slices.Compare[[]string])
+	paths := make([]string, 0, len(matches))
+	for _, parts := range matches {
+		paths = append(paths, strings.Join(parts, filepath.PathSeparator))
+	}
+	return paths, true
+}
+
+// hasEntry returns true if i has an entry called name
+func (i *DirectoryInode) hasEntry(name string) bool {
+	i.rwMutex.RLock()
+	defer i.rwMutex.RUnlock()
+	_, exists := i.contents[name]
+	return exists
+}
@@ -1,6 +1,11 @@
 package inode
 
-import "sync"
+import (
+	"io/fs"
+	"sync"
+	"sync/atomic"
+	"time"
+)
 
 // InodeType is an enum that indicates whether an inode is a file or a directory
 type InodeType int
@@ -18,10 +23,52 @@ type Inode interface {
 	// Size will return the number of bytes in a FileInode's data buffer or the number of entries
 	// in a DirectoryInode's entry table
 	Size() int
+	// Ino returns the inode's number, which is unique among all inodes (across every filesystem) and
+	// never changes, even if the inode is renamed
+	Ino() uint64
+	XattrInode
+	TimesInode
+	OwnerInode
+	ModeInode
 }
 
 type basicInode struct {
 	rwMutex sync.RWMutex
+	// id is a number that uniquely identifies the inode.  It is assigned at creation and never
+	// changes, so it can be used to impose a total order on inodes (e.g. for lock acquisition).
+	id uint64
+	// xattrs holds the inode's extended attributes (see XattrInode).  It is nil until the first
+	// attribute is set.
+	xattrs map[string][]byte
+	// accessTime, modTime, and changeTime are the inode's timestamps (see TimesInode)
+	accessTime time.Time
+	modTime    time.Time
+	changeTime time.Time
+	// uid and gid identify the inode's owner (see OwnerInode)
+	uid int
+	gid int
+	// mode holds the inode's permission bits (see ModeInode)
+	mode fs.FileMode
+}
+
+// lastInodeID is the id most recently assigned to an inode
+var lastInodeID uint64
+
+// newBasicInode returns a basicInode with a new, unique id and permission bits perm, whose
+// timestamps are the current time
+func newBasicInode(perm fs.FileMode) basicInode {
+	createdAt := now()
+	return basicInode{
+		id:         atomic.AddUint64(&lastInodeID, 1),
+		mode:       perm,
+		accessTime: createdAt,
+		modTime:    createdAt,
+		changeTime: createdAt,
+	}
+}
+
+func (i *basicInode) Ino() uint64 {
+	return i.id
 }
 
 func (i InodeType) String() string {
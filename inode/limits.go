@@ -0,0 +1,129 @@
+package inode
+
+import (
+	"github.com/manderson5192/memfs/filepath"
+	"github.com/manderson5192/memfs/fserrors"
+	"github.com/manderson5192/memfs/utils"
+	"github.com/pkg/errors"
+)
+
+// Limits models a real filesystem's limits on names and paths, like NAME_MAX and PATH_MAX.  The
+// zero value imposes no limits.
+type Limits struct {
+	// MaxNameLength is the maximum length, in bytes, of an entry's name, or zero for no limit.
+	// Creating or renaming an entry to a longer name fails with ENAMETOOLONG.
+	MaxNameLength int
+	// MaxPathDepth is the maximum number of entries in the absolute path of any entry in the
+	// filesystem (e.g. "/a/b" has a depth of 2), or zero for no limit.  Creating, renaming, or
+	// looking up an entry any deeper fails with ENAMETOOLONG, as does renaming a directory if any
+	// entry in its subtree would end up any deeper.
+	MaxPathDepth int
+}
+
+// SetLimits configures the limits that the filesystem places on names and paths.  They only apply
+// to subsequent operations: existing entries that exceed them are not affected.
+func (sb *Superblock) SetLimits(limits Limits) {
+	sb.mutex.Lock()
+	defer sb.mutex.Unlock()
+	sb.limits = limits
+}
+
+// Limits returns the limits that the filesystem places on names and paths
+func (sb *Superblock) Limits() Limits {
+	if sb == nil {
+		return Limits{}
+	}
+	sb.mutex.Lock()
+	defer sb.mutex.Unlock()
+	return sb.limits
+}
+
+// depth returns the number of entries in i's absolute path, where the root directory has a depth
+// of zero.  It must not be called while holding any lock on i or its ancestors.
+func (i *DirectoryInode) depth() int {
+	return len(i.selfAndAncestors()) - 1
+}
+
+// checkDepth returns ENAMETOOLONG if depth exceeds the filesystem's MaxPathDepth
+func (sb *Superblock) checkDepth(depth int) error {
+	if maxDepth := sb.Limits().MaxPathDepth; maxDepth > 0 && depth > maxDepth {
+		return errors.Wrapf(fserrors.ENameTooLong, "path depth %d exceeds the maximum of %d", depth, maxDepth)
+	}
+	return nil
+}
+
+// CheckLookupDepth returns ENAMETOOLONG if looking up i's entry called name would descend deeper
+// than the filesystem's MaxPathDepth.  The self and parent entries never descend.  It must not be
+// called while holding any lock on i or its ancestors.
+func (i *DirectoryInode) CheckLookupDepth(name string) error {
+	if name == filepath.SelfDirectoryEntry || name == filepath.ParentDirectoryEntry {
+		return nil
+	}
+	if i.superblock.Limits().MaxPathDepth > 0 {
+		return i.superblock.checkDepth(i.depth() + 1)
+	}
+	return nil
+}
+
+// height returns the number of entries in the longest path from i to an entry in its subtree, so
+// an empty directory has a height of zero and a directory that only contains files has a height of
+// one.  It stops descending once the height exceeds limit, since the caller only needs to know
+// that it does.
+//
+// This function takes Read-level locks on i and its descendants, one directory at a time and from
+// the top down, so it may be called while holding locks on i's ancestors but not on i's subtree.
+func (i *DirectoryInode) height(limit int) int {
+	if limit < 0 {
+		return 0
+	}
+	i.rwMutex.RLock()
+	defer i.rwMutex.RUnlock()
+	height := 0
+	for name, child := range i.contents {
+		if name == filepath.SelfDirectoryEntry || name == filepath.ParentDirectoryEntry {
+			continue
+		}
+		childHeight := 1
+		if subdir, ok := child.(*DirectoryInode); ok {
+			childHeight += subdir.height(limit - 1)
+		}
+		height = utils.Max(height, childHeight)
+		if height > limit {
+			break
+		}
+	}
+	return height
+}
+
+// checkMovedDepth returns ENAMETOOLONG if moving moved into the directory whose selfAndAncestors()
+// are dstAncestors would put it, or any entry in its subtree, deeper than the filesystem's
+// MaxPathDepth.
+//
+// This function is **not thread safe**.  It should only be invoked when locks are held on the
+// parent directories of the move, but not on moved's subtree.
+func (sb *Superblock) checkMovedDepth(moved Inode, dstAncestors []*DirectoryInode) error {
+	maxDepth := sb.Limits().MaxPathDepth
+	if maxDepth <= 0 {
+		return nil
+	}
+	// dstAncestors holds the destination directory and its ancestors, so its length is the depth of
+	// moved once it has been moved
+	depth := len(dstAncestors)
+	if dir, ok := moved.(*DirectoryInode); ok {
+		depth += dir.height(maxDepth - depth)
+	}
+	return sb.checkDepth(depth)
+}
+
+// checkNewEntry returns an error if an entry called name can't be added to i, because of its name
+// (see checkNewEntryName()) or because it would be deeper than the filesystem's MaxPathDepth.  It
+// must not be called while holding any lock on i or its ancestors.
+func (i *DirectoryInode) checkNewEntry(name string) error {
+	if err := i.superblock.checkNewEntryName(name); err != nil {
+		return err
+	}
+	if i.superblock.Limits().MaxPathDepth > 0 {
+		return i.superblock.checkDepth(i.depth() + 1)
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package inode
+
+import (
+	"sync/atomic"
+)
+
+// Metrics counts the operations that have been performed on a filesystem since its metrics were
+// enabled (see Superblock.EnableMetrics()).  Only operations that succeed are counted.
+type Metrics struct {
+	// Opens is the number of times that a file was opened, including by creating it
+	Opens int64
+	// Creates is the number of files that were created
+	Creates int64
+	// Mkdirs is the number of directories that were created
+	Mkdirs int64
+	// Deletes is the number of files and directories that were removed.  An entry that is replaced
+	// by a rename is not counted, since the rename is counted in Renames.
+	Deletes int64
+	// Renames is the number of renames, counting an exchange of two entries as one rename
+	Renames int64
+	// Reads is the number of reads of file data, and BytesRead is the number of bytes that they
+	// returned
+	Reads     int64
+	BytesRead int64
+	// Writes is the number of writes of file data, and BytesWritten is the number of bytes that they
+	// wrote.  Truncating a file when it is opened, and evicting a ring file's excess data when it is
+	// opened, each count as a write of zero bytes.
+	Writes       int64
+	BytesWritten int64
+}
+
+// metricCounters accumulates a filesystem's Metrics.  A nil *metricCounters counts nothing.
+type metricCounters struct {
+	opens        atomic.Int64
+	creates      atomic.Int64
+	mkdirs       atomic.Int64
+	deletes      atomic.Int64
+	renames      atomic.Int64
+	reads        atomic.Int64
+	bytesRead    atomic.Int64
+	writes       atomic.Int64
+	bytesWritten atomic.Int64
+}
+
+// EnableMetrics makes the filesystem count the operations performed on it (see Metrics())
+func (sb *Superblock) EnableMetrics() {
+	sb.metrics.CompareAndSwap(nil, &metricCounters{})
+}
+
+// HasMetrics returns true if the filesystem counts the operations performed on it
+func (sb *Superblock) HasMetrics() bool {
+	return sb.metricCounters() != nil
+}
+
+// Metrics returns the current values of the filesystem's operation counters.  They are all zero if
+// metrics are not enabled.  Each counter is read atomically, but the counters are not read as a
+// single atomic snapshot, so operations that run concurrently may be counted in some counters but
+// not yet in others.
+func (sb *Superblock) Metrics() Metrics {
+	m := sb.metricCounters()
+	if m == nil {
+		return Metrics{}
+	}
+	return Metrics{
+		Opens:        m.opens.Load(),
+		Creates:      m.creates.Load(),
+		Mkdirs:       m.mkdirs.Load(),
+		Deletes:      m.deletes.Load(),
+		Renames:      m.renames.Load(),
+		Reads:        m.reads.Load(),
+		BytesRead:    m.bytesRead.Load(),
+		Writes:       m.writes.Load(),
+		BytesWritten: m.bytesWritten.Load(),
+	}
+}
+
+// CountOpen records that a file in the filesystem was opened.  Files are opened above the inode
+// layer, so the caller that opens them is responsible for counting them.
+func (sb *Superblock) CountOpen() {
+	if m := sb.metricCounters(); m != nil {
+		m.opens.Add(1)
+	}
+}
+
+// metricCounters returns the filesystem's metricCounters, or nil if metrics are not enabled.  It
+// takes no lock, so it is cheap enough to call on every read and write.
+func (sb *Superblock) metricCounters() *metricCounters {
+	if sb == nil {
+		return nil
+	}
+	return sb.metrics.Load()
+}
+
+func (m *metricCounters) countCreate() {
+	if m != nil {
+		m.creates.Add(1)
+	}
+}
+
+func (m *metricCounters) countMkdir() {
+	if m != nil {
+		m.mkdirs.Add(1)
+	}
+}
+
+func (m *metricCounters) countDelete() {
+	if m != nil {
+		m.deletes.Add(1)
+	}
+}
+
+func (m *metricCounters) countRename() {
+	if m != nil {
+		m.renames.Add(1)
+	}
+}
+
+// countRead records a read that returned n bytes
+func (m *metricCounters) countRead(n int) {
+	if m != nil {
+		m.reads.Add(1)
+		m.bytesRead.Add(int64(n))
+	}
+}
+
+// countWrite records a write of n bytes
+func (m *metricCounters) countWrite(n int) {
+	if m != nil {
+		m.writes.Add(1)
+		m.bytesWritten.Add(int64(n))
+	}
+}
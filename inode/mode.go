@@ -0,0 +1,44 @@
+package inode
+
+import "io/fs"
+
+const (
+	// defaultFilePerm and defaultDirectoryPerm are the permission bits of a new FileInode and a new
+	// DirectoryInode, respectively.  Like the modes that open(2) and mkdir(2) are usually called
+	// with, they are meant to be masked by a umask when the inode is created (see
+	// directory.Directory.WithUmask()).
+	defaultFilePerm      fs.FileMode = 0666
+	defaultDirectoryPerm fs.FileMode = 0777
+)
+
+// ModeInode is implemented by every Inode.  It records the inode's permission bits (fs.ModePerm),
+// which are 0666 for a new FileInode and 0777 for a new DirectoryInode.  Like ownership (see
+// OwnerInode), permissions are only stored and reported: they don't restrict access to the inode.
+type ModeInode interface {
+	// Mode returns the inode's permission bits
+	Mode() fs.FileMode
+	// Chmod sets the inode's permission bits to those of mode, like chmod(2), ignoring any other bits
+	// of mode.  It updates the change time to the current time (see TimesInode).
+	Chmod(mode fs.FileMode)
+}
+
+func (i *basicInode) Mode() fs.FileMode {
+	i.rwMutex.RLock()
+	defer i.rwMutex.RUnlock()
+	return i.mode
+}
+
+func (i *basicInode) Chmod(mode fs.FileMode) {
+	i.rwMutex.Lock()
+	defer i.rwMutex.Unlock()
+	i.mode = mode.Perm()
+	i.markChanged()
+}
+
+// copyMode copies the permission bits of src into i, which must not yet be reachable by any other
+// goroutine
+func (i *basicInode) copyMode(src *basicInode) {
+	src.rwMutex.RLock()
+	defer src.rwMutex.RUnlock()
+	i.mode = src.mode
+}
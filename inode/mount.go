@@ -0,0 +1,229 @@
+package inode
+
+import (
+	"slices"
+
+	"github.com/manderson5192/memfs/filepath"
+	"github.com/manderson5192/memfs/fserrors"
+	"github.com/pkg/errors"
+)
+
+// BindMount makes the directory at entry of targetParent (the mount point) resolve to source, like a
+// bind mount: looking up the mount point's entry yields source instead, so source's subtree appears
+// at both locations and changes made through either are visible through both.  The mount point
+// keeps its own (hidden) entries.  Until the bind mount is removed (see Unmount()), neither the
+// mount point nor source can be removed or replaced (EBUSY), although either may be renamed, unless
+// the rename would make a directory reachable from itself (EINVAL; see MoveEntry()).
+//
+// Since source's ".." entry still refers to source's own parent, a ".." that follows the mount
+// point in a single path lookup leads back to the mount point's parent (see
+// LookupSubdirectoryWithin()), but a ".." looked up directly in source (e.g. at the start of a
+// lookup from a directory inside the bind mount) leads to source's parent.  Reverse path lookups
+// report paths beneath source.
+//
+// BindMount returns EINVAL if the mount point is the root directory or if the bind mount would make
+// the mount point reachable from itself, ENOTDIR if the mount point is a file, and EBUSY if it is
+// already a mount point.  Bind mounts are not captured by CloneTree().
+func BindMount(source, targetParent *DirectoryInode, targetEntry string) error {
+	if targetEntry == filepath.SelfDirectoryEntry || targetEntry == filepath.ParentDirectoryEntry ||
+		targetParent.superblock.Parser().ContainsSeparator(targetEntry) {
+		return errors.Wrapf(fserrors.EInval, "cannot mount on entry '%s'", targetEntry)
+	}
+	sb := targetParent.superblock
+	if sb == nil || sb != source.superblock {
+		return errors.Wrapf(fserrors.EInval, "source and mount point must belong to the same filesystem")
+	}
+	defer sb.beginMutation()()
+	// Hold the rename lock so that the tree's shape can't change during the cycle check
+	sb.renameMutex.Lock()
+	defer sb.renameMutex.Unlock()
+	targetParent.rwMutex.RLock()
+	targetInode, exists := targetParent.contents[targetEntry]
+	targetParent.rwMutex.RUnlock()
+	if !exists {
+		return errors.Wrapf(fserrors.ENoEnt, "mount point '%s' does not exist", targetEntry)
+	}
+	target, ok := targetInode.(*DirectoryInode)
+	if !ok {
+		return errors.Wrapf(fserrors.ENotDir, "mount point '%s' is not a directory", targetEntry)
+	}
+	// sb.mutex must not be held while inodes are locked, so the cycle check uses a copy of the mount
+	// table.  It can't change in the meantime, since BindMount() holds the rename lock.
+	mounts := sb.mountGraph()
+	if _, mounted := mounts.sources[target]; mounted {
+		return errors.Wrapf(fserrors.EBusy, "'%s' is already a mount point", targetEntry)
+	}
+	if mounts.reachable(source, target.selfAndAncestors()) {
+		return errors.Wrapf(fserrors.EInval, "cannot mount a directory beneath itself")
+	}
+	sb.mutex.Lock()
+	defer sb.mutex.Unlock()
+	if sb.mounts == nil {
+		sb.mounts = map[*DirectoryInode]*DirectoryInode{}
+	}
+	sb.mounts[target] = source
+	return nil
+}
+
+// Unmount removes the bind mount on the directory at entry of targetParent (see BindMount()), so
+// that the mount point's own entries are visible again.  It returns EINVAL if the directory is not a
+// mount point.
+func Unmount(targetParent *DirectoryInode, targetEntry string) error {
+	sb := targetParent.superblock
+	defer sb.beginMutation()()
+	// Hold the rename lock so that the mount table doesn't change during a concurrent BindMount()'s
+	// cycle check
+	renameLock := sb.renameLock()
+	renameLock.Lock()
+	defer renameLock.Unlock()
+	targetParent.rwMutex.RLock()
+	targetInode, exists := targetParent.contents[targetEntry]
+	targetParent.rwMutex.RUnlock()
+	if !exists {
+		return errors.Wrapf(fserrors.ENoEnt, "mount point '%s' does not exist", targetEntry)
+	}
+	target, ok := targetInode.(*DirectoryInode)
+	if !ok || sb == nil {
+		return errors.Wrapf(fserrors.EInval, "'%s' is not a mount point", targetEntry)
+	}
+	sb.mutex.Lock()
+	defer sb.mutex.Unlock()
+	if _, mounted := sb.mounts[target]; !mounted {
+		return errors.Wrapf(fserrors.EInval, "'%s' is not a mount point", targetEntry)
+	}
+	delete(sb.mounts, target)
+	return nil
+}
+
+// dropMounts removes the bind mounts whose mount point or source is dir, which has been unlinked
+// from the tree (e.g. by RestoreFrom()), since Unmount() could no longer reach them by path
+func (sb *Superblock) dropMounts(dir *DirectoryInode) {
+	if sb == nil {
+		return
+	}
+	sb.mutex.Lock()
+	defer sb.mutex.Unlock()
+	for mountpoint, source := range sb.mounts {
+		if mountpoint == dir || source == dir {
+			delete(sb.mounts, mountpoint)
+		}
+	}
+}
+
+// copyMounts returns a copy of the filesystem's mount table
+func (sb *Superblock) copyMounts() map[*DirectoryInode]*DirectoryInode {
+	sb.mutex.Lock()
+	defer sb.mutex.Unlock()
+	mounts := make(map[*DirectoryInode]*DirectoryInode, len(sb.mounts))
+	for mountpoint, source := range sb.mounts {
+		mounts[mountpoint] = source
+	}
+	return mounts
+}
+
+// mountGraph is a copy of a filesystem's mount table, along with the self and ancestors of each
+// mount point (see selfAndAncestors()), so that it can be consulted while directories are locked.
+// It is only accurate while the filesystem's renameLock() is held.
+type mountGraph struct {
+	// sources maps each mount point to the directory that is mounted on it
+	sources map[*DirectoryInode]*DirectoryInode
+	// ancestors maps each mount point to the result of its selfAndAncestors()
+	ancestors map[*DirectoryInode][]*DirectoryInode
+}
+
+// mountGraph returns the filesystem's current mountGraph, or nil if sb is nil.  It must not be
+// called while any directory is locked, since selfAndAncestors() takes Read-level locks.
+func (sb *Superblock) mountGraph() *mountGraph {
+	if sb == nil {
+		return nil
+	}
+	mounts := &mountGraph{
+		sources:   sb.copyMounts(),
+		ancestors: map[*DirectoryInode][]*DirectoryInode{},
+	}
+	for mountpoint := range mounts.sources {
+		mounts.ancestors[mountpoint] = mountpoint.selfAndAncestors()
+	}
+	return mounts
+}
+
+// reachable returns true if the directory whose selfAndAncestors() are targetAncestors is from or
+// beneath from, either in the directory tree or through the bind mounts in m
+func (m *mountGraph) reachable(from *DirectoryInode, targetAncestors []*DirectoryInode) bool {
+	return m.reachableWithin(from, targetAncestors, len(m.sources))
+}
+
+// reachableWithin implements reachable().  depth bounds the number of bind mounts that are
+// followed.
+func (m *mountGraph) reachableWithin(from *DirectoryInode, targetAncestors []*DirectoryInode, depth int) bool {
+	if slices.Contains(targetAncestors, from) {
+		return true
+	}
+	if depth == 0 {
+		return false
+	}
+	for mountpoint, source := range m.sources {
+		if slices.Contains(m.ancestors[mountpoint], from) && m.reachableWithin(source, targetAncestors, depth-1) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkMountCycle returns EINVAL if moving moved (which may be any inode) into the directory whose
+// selfAndAncestors() are dstAncestors would make that directory reachable from itself through a
+// bind mount, e.g. by moving a mount point beneath the directory that is mounted on it
+func (m *mountGraph) checkMountCycle(moved Inode, entry string, dstAncestors []*DirectoryInode) error {
+	movedDir, ok := moved.(*DirectoryInode)
+	if !ok || m == nil || len(m.sources) == 0 {
+		return nil
+	}
+	if m.reachable(movedDir, dstAncestors) {
+		return errors.Wrapf(fserrors.EInval, "cannot move directory '%s' beneath a bind mount of itself", entry)
+	}
+	return nil
+}
+
+// resolveMount returns the directory that is mounted on inode if it is a mount point (see
+// BindMount()), and inode itself otherwise
+func (sb *Superblock) resolveMount(inode Inode) Inode {
+	if sb == nil {
+		return inode
+	}
+	dirInode, ok := inode.(*DirectoryInode)
+	if !ok {
+		return inode
+	}
+	sb.mutex.Lock()
+	defer sb.mutex.Unlock()
+	// A mounted directory may itself have become a mount point since it was mounted
+	for hops := 0; hops <= len(sb.mounts); hops++ {
+		source, mounted := sb.mounts[dirInode]
+		if !mounted {
+			break
+		}
+		dirInode = source
+	}
+	return dirInode
+}
+
+// checkNotMounted returns EBUSY if i is a mount point or the source of a bind mount (see
+// BindMount()), since removing or replacing it would leave the bind mount dangling.  entry is i's
+// name, for the error message.
+func (i *DirectoryInode) checkNotMounted(entry string) error {
+	sb := i.superblock
+	if sb == nil {
+		return nil
+	}
+	sb.mutex.Lock()
+	defer sb.mutex.Unlock()
+	if _, mounted := sb.mounts[i]; mounted {
+		return errors.Wrapf(fserrors.EBusy, "directory entry '%s' is a mount point", entry)
+	}
+	for _, source := range sb.mounts {
+		if source == i {
+			return errors.Wrapf(fserrors.EBusy, "directory entry '%s' is mounted elsewhere", entry)
+		}
+	}
+	return nil
+}
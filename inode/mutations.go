@@ -0,0 +1,67 @@
+package inode
+
+import (
+	"sync/atomic"
+)
+
+// MutationCounter counts the mutations of a filesystem that are attempted through the handles that
+// carry it, so that whoever owns those handles can tell its own mutations apart from everyone
+// else's (see Superblock.CountMutation()).  Its zero value is ready to use.
+type MutationCounter struct {
+	count atomic.Int64
+}
+
+// Count returns the number of mutations that have been counted in c
+func (c *MutationCounter) Count() int64 {
+	if c == nil {
+		return 0
+	}
+	return c.count.Load()
+}
+
+// MutationCounts are a filesystem's counts of the mutations that have been attempted through its
+// handles (see Superblock.CountMutation())
+type MutationCounts struct {
+	// Started is the number of mutations that have begun, and Finished is the number of them that
+	// have finished, whether or not they succeeded
+	Started  int64
+	Finished int64
+}
+
+// CountMutation records that a mutation of the filesystem is about to be attempted through a handle
+// that carries own (which may be nil), and returns a function that records that the attempt is over
+// (e.g. `defer sb.CountMutation(own)()`).  Mutations are counted above the inode layer, by the
+// directory and file handles that make them, so that each call to a handle's method counts once no
+// matter how many inodes it mutates.  own is incremented before the filesystem's own counts, so a
+// mutation through an owner's handles is never mistaken for someone else's.
+//
+// While the filesystem is frozen (see Freeze()), every mutation that is counted in Finished has
+// taken effect, so any mutation that takes effect after the filesystem is unfrozen isn't.
+func (sb *Superblock) CountMutation(own *MutationCounter) func() {
+	if sb == nil {
+		return noMutation
+	}
+	if own != nil {
+		own.count.Add(1)
+	}
+	sb.mutationsStarted.Add(1)
+	return sb.finishMutation
+}
+
+func (sb *Superblock) countFinishedMutation() {
+	sb.mutationsFinished.Add(1)
+}
+
+// MutationCounts returns the filesystem's counts of the mutations that have been attempted through
+// its handles (see CountMutation())
+func (sb *Superblock) MutationCounts() MutationCounts {
+	if sb == nil {
+		return MutationCounts{}
+	}
+	// Load Finished first, so that it never exceeds Started
+	finished := sb.mutationsFinished.Load()
+	return MutationCounts{
+		Started:  sb.mutationsStarted.Load(),
+		Finished: finished,
+	}
+}
@@ -0,0 +1,46 @@
+package inode
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/manderson5192/memfs/fserrors"
+	"github.com/pkg/errors"
+)
+
+// RejectControlCharacters makes the filesystem refuse to create entries whose names contain control
+// characters (such as newlines, tabs, or escape sequences), which cause problems when names are
+// displayed or serialized.  Names containing a NUL byte are always refused.
+func (sb *Superblock) RejectControlCharacters() {
+	sb.mutex.Lock()
+	defer sb.mutex.Unlock()
+	sb.rejectControlChars = true
+}
+
+// RejectsControlCharacters returns true if the filesystem refuses to create entries whose names
+// contain control characters (see RejectControlCharacters())
+func (sb *Superblock) RejectsControlCharacters() bool {
+	if sb == nil {
+		return false
+	}
+	sb.mutex.Lock()
+	defer sb.mutex.Unlock()
+	return sb.rejectControlChars
+}
+
+// checkNewEntryName returns EINVAL if name can't be the name of a new entry in the filesystem:
+// because it contains a NUL byte, like in POSIX, or because it contains another control character
+// and the filesystem rejects them.  It returns ENAMETOOLONG if name is longer than the filesystem's
+// MaxNameLength.
+func (sb *Superblock) checkNewEntryName(name string) error {
+	if maxLength := sb.Limits().MaxNameLength; maxLength > 0 && len(name) > maxLength {
+		return errors.Wrapf(fserrors.ENameTooLong, "name %q is longer than %d bytes", name, maxLength)
+	}
+	if strings.IndexByte(name, 0) >= 0 {
+		return errors.Wrapf(fserrors.EInval, "name %q contains a NUL byte", name)
+	}
+	if sb.RejectsControlCharacters() && strings.IndexFunc(name, unicode.IsControl) >= 0 {
+		return errors.Wrapf(fserrors.EInval, "name %q contains a control character", name)
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package inode
+
+// OwnerInode is implemented by every Inode.  It records the user and group that own the inode,
+// which are both 0 for a new inode.  Ownership is only stored and reported: it doesn't restrict
+// access to the inode.
+type OwnerInode interface {
+	// Owner returns the ids of the user and group that own the inode
+	Owner() (uid, gid int)
+	// Chown sets the ids of the user and group that own the inode, like chown(2).  It updates the
+	// change time to the current time (see TimesInode).
+	Chown(uid, gid int)
+}
+
+func (i *basicInode) Owner() (int, int) {
+	i.rwMutex.RLock()
+	defer i.rwMutex.RUnlock()
+	return i.uid, i.gid
+}
+
+func (i *basicInode) Chown(uid, gid int) {
+	i.rwMutex.Lock()
+	defer i.rwMutex.Unlock()
+	i.uid = uid
+	i.gid = gid
+	i.markChanged()
+}
+
+// copyOwner copies the ownership of src into i, which must not yet be reachable by any other
+// goroutine
+func (i *basicInode) copyOwner(src *basicInode) {
+	src.rwMutex.RLock()
+	defer src.rwMutex.RUnlock()
+	i.uid = src.uid
+	i.gid = src.gid
+}
@@ -0,0 +1,58 @@
+package inode
+
+import (
+	"github.com/manderson5192/memfs/fserrors"
+	"github.com/pkg/errors"
+)
+
+// AppendRing appends p to the end of the FileInode's data, like AppendAll, and then evicts bytes
+// from the front of the data so that at most maxBytes remain, like a ring buffer that retains the
+// most recent maxBytes bytes written to it.  If p alone is longer than maxBytes, then only its last
+// maxBytes bytes are retained.  It returns the number of bytes of p that were written, which is
+// always len(p) unless the error is non-nil.  An empty p just evicts bytes beyond maxBytes.
+//
+// The append and the eviction are a single atomic step, so concurrent calls never interleave.
+func (i *FileInode) AppendRing(p []byte, maxBytes int) (int, error) {
+	if p == nil {
+		return 0, errors.Wrapf(fserrors.EInval, "buffer is nil")
+	}
+	if maxBytes <= 0 {
+		return 0, errors.Wrapf(fserrors.EInval, "ring size must be positive, not %d", maxBytes)
+	}
+	if err := i.injectFault(FaultWrite); err != nil {
+		return 0, err
+	}
+	defer i.Superblock().beginMutation()()
+	i.rwMutex.Lock()
+	defer i.rwMutex.Unlock()
+	length := i.length()
+	if length+len(p) <= maxBytes {
+		// Nothing needs to be evicted
+		if err := i.reserve(len(p)); err != nil {
+			return 0, err
+		}
+		if len(p) > 0 {
+			i.appendData(p)
+		}
+		i.superblock.metricCounters().countWrite(len(p))
+		return len(p), nil
+	}
+	// Build the retained window from the tail of the current data followed by the tail of p
+	retained := make([]byte, maxBytes)
+	fromP := len(p)
+	if fromP > maxBytes {
+		fromP = maxBytes
+	}
+	fromData := maxBytes - fromP
+	if fromData > 0 {
+		copy(retained, i.copyData()[length-fromData:])
+	}
+	copy(retained[fromData:], p[len(p)-fromP:])
+	if err := i.reserve(len(retained) - i.allocated()); err != nil {
+		return 0, err
+	}
+	i.storeData(retained)
+	i.markModified()
+	i.superblock.metricCounters().countWrite(len(p))
+	return len(p), nil
+}
@@ -0,0 +1,157 @@
+package inode
+
+import (
+	"io"
+	"sort"
+)
+
+// extent is a run of file data that has actually been written, starting at offset off
+type extent struct {
+	off  int
+	data []byte
+}
+
+func (e *extent) end() int {
+	return e.off + len(e.data)
+}
+
+// extentMap is the sparse representation of a FileInode's data.  It stores only the extents that
+// have been written, so a hole between extents takes up no memory and reads back as zero bytes.
+// Extents are kept sorted by offset, and extents that overlap or touch are merged, so no two
+// extents are adjacent.
+type extentMap struct {
+	extents []extent
+	// size is the logical length of the file, which may extend beyond the last extent
+	size int
+}
+
+// allocated returns the number of bytes that are actually stored in the extentMap
+func (m *extentMap) allocated() int {
+	total := 0
+	for _, e := range m.extents {
+		total += len(e.data)
+	}
+	return total
+}
+
+// overlapping returns the range [lo, hi) of indices of the extents that overlap or touch the range
+// [off, end) and so must be merged with data written there
+func (m *extentMap) overlapping(off, end int) (int, int) {
+	lo := sort.Search(len(m.extents), func(idx int) bool {
+		return m.extents[idx].end() >= off
+	})
+	hi := lo
+	for hi < len(m.extents) && m.extents[hi].off <= end {
+		hi++
+	}
+	return lo, hi
+}
+
+// growth returns the number of bytes by which writing n bytes at offset off would grow the
+// extentMap's allocation
+func (m *extentMap) growth(off, n int) int {
+	if n == 0 {
+		return 0
+	}
+	lo, hi := m.overlapping(off, off+n)
+	if lo == hi {
+		return n
+	}
+	start, end := off, off+n
+	existing := 0
+	for _, e := range m.extents[lo:hi] {
+		existing += len(e.data)
+		if e.off < start {
+			start = e.off
+		}
+		if e.end() > end {
+			end = e.end()
+		}
+	}
+	return end - start - existing
+}
+
+// writeAt copies p into the extentMap at offset off, extending its logical size if necessary
+func (m *extentMap) writeAt(p []byte, off int) {
+	if off+len(p) > m.size {
+		m.size = off + len(p)
+	}
+	if len(p) == 0 {
+		return
+	}
+	lo, hi := m.overlapping(off, off+len(p))
+	start, end := off, off+len(p)
+	for _, e := range m.extents[lo:hi] {
+		if e.off < start {
+			start = e.off
+		}
+		if e.end() > end {
+			end = e.end()
+		}
+	}
+	merged := extent{off: start, data: make([]byte, end-start)}
+	for _, e := range m.extents[lo:hi] {
+		copy(merged.data[e.off-start:], e.data)
+	}
+	copy(merged.data[off-start:], p)
+	m.extents = append(m.extents[:lo], append([]extent{merged}, m.extents[hi:]...)...)
+}
+
+// readAt fills p with the data at offset off, including zero bytes for holes.  The caller must
+// ensure that p does not extend beyond the extentMap's logical size.
+func (m *extentMap) readAt(p []byte, off int) {
+	for idx := range p {
+		p[idx] = 0
+	}
+	lo, hi := m.overlapping(off, off+len(p))
+	for _, e := range m.extents[lo:hi] {
+		if e.off >= off+len(p) || e.end() <= off {
+			// e only touches the range
+			continue
+		}
+		if e.off >= off {
+			copy(p[e.off-off:], e.data)
+		} else {
+			copy(p, e.data[off-e.off:])
+		}
+	}
+}
+
+// writeTo writes the extentMap's logical contents, holes included, to w without materializing them
+// in a single buffer
+func (m *extentMap) writeTo(w io.Writer) {
+	var zeroes [4096]byte
+	writeZeroes := func(n int) {
+		for n > 0 {
+			chunk := n
+			if chunk > len(zeroes) {
+				chunk = len(zeroes)
+			}
+			w.Write(zeroes[:chunk])
+			n -= chunk
+		}
+	}
+	pos := 0
+	for _, e := range m.extents {
+		writeZeroes(e.off - pos)
+		w.Write(e.data)
+		pos = e.end()
+	}
+	writeZeroes(m.size - pos)
+}
+
+// set replaces the extentMap's contents with a copy of d
+func (m *extentMap) set(d []byte) {
+	m.extents = nil
+	m.size = 0
+	m.writeAt(d, 0)
+}
+
+// clone returns a deep copy of the extentMap
+func (m *extentMap) clone() *extentMap {
+	clone := &extentMap{size: m.size}
+	for _, e := range m.extents {
+		clone.extents = append(clone.extents, extent{off: e.off, data: append([]byte{}, e.data...)})
+	}
+	return clone
+}
@@ -0,0 +1,269 @@
+package inode
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/manderson5192/memfs/filepath"
+	"github.com/manderson5192/memfs/fserrors"
+	"github.com/manderson5192/memfs/notify"
+	"github.com/pkg/errors"
+)
+
+// Superblock holds the state that is shared by all of the inodes in a single filesystem, such as
+// space accounting.  Every inode records the Superblock of the filesystem that it belongs to at
+// creation time.  A nil *Superblock is valid: it represents a filesystem without any limits, and
+// is used by inodes created outside of any filesystem (e.g. by NewFileInode()).
+type Superblock struct {
+	mutex sync.Mutex // synchronizes access to usedBytes, dedup, index, sparse, compressed, syncHook, rejectControlChars, limits, and mounts
+	// maxBytes is the maximum number of bytes of file data that the filesystem may store, or a
+	// negative number if there is no limit
+	maxBytes  int64
+	usedBytes int64
+	// dedup indexes the filesystem's deduplicated file data, or is nil if deduplication is disabled
+	dedup *dedupTable
+	// index maps entry names to the directories that contain them, or is nil if the filesystem is
+	// not indexed
+	index *nameIndex
+	// metrics counts the operations performed on the filesystem, or holds nil if metrics are
+	// disabled.  Like faults, it is an atomic.Pointer so that reads and writes can load it without
+	// taking mutex.
+	metrics atomic.Pointer[metricCounters]
+	// mutationsStarted and mutationsFinished count the mutations that have been attempted through
+	// the filesystem's handles (see CountMutation())
+	mutationsStarted  atomic.Int64
+	mutationsFinished atomic.Int64
+	// sparse is true if new files in the filesystem store only the extents that are written
+	sparse bool
+	// compressed is true if new files in the filesystem store their data gzip-compressed
+	compressed bool
+	// syncHook is called whenever a file in the filesystem is synced, or is nil
+	syncHook SyncHook
+	// faults decides which operations fail, or holds nil if no faults are configured.  It is an
+	// atomic.Pointer rather than being guarded by mutex, since every read and write of file data
+	// loads it.
+	faults atomic.Pointer[faultInjector]
+	// rejectControlChars is true if the filesystem refuses to create entries whose names contain
+	// control characters
+	rejectControlChars bool
+	// limits caps the lengths of entry names and the depth of paths in the filesystem
+	limits Limits
+	// parser determines the syntax of the filesystem's paths, or holds nil for the default syntax.
+	// Like faults, it is an atomic.Pointer rather than being guarded by mutex, since every path
+	// that is parsed and every entry that is created loads it.
+	parser atomic.Pointer[filepath.Parser]
+	// watchers tracks the watchers that are notified of changes to the filesystem
+	watchers *notify.Registry
+	// mounts maps each bind mount point in the filesystem to the directory that is mounted on it
+	// (see BindMount()).  It is nil until the first bind mount is made.  A bind mount is dropped
+	// when its mount point or source is unlinked from the tree.
+	mounts map[*DirectoryInode]*DirectoryInode
+	// renameMutex serializes the operations that can change the shape of the filesystem's directory
+	// tree, i.e. which directories are ancestors of which: renames between two different directories
+	// and exchanges (see MoveEntry() and ExchangeEntries()), and changes to bind mounts (see
+	// BindMount()).  It is acquired before any inode lock, like Linux's per-superblock
+	// s_vfs_rename_mutex.
+	renameMutex sync.Mutex
+	// freezeMutex is held for reading by every mutation of the filesystem's inodes, and for
+	// writing while the filesystem is frozen (see Freeze())
+	freezeMutex sync.RWMutex
+	// endMutation is freezeMutex.RUnlock, bound once so that beginMutation() doesn't allocate a new
+	// method value for every mutation, and finishMutation is bound once for CountMutation() likewise
+	endMutation    func()
+	finishMutation func()
+}
+
+// NewSuperblock returns a Superblock with no limits
+func NewSuperblock() *Superblock {
+	sb := &Superblock{
+		maxBytes: -1,
+		watchers: notify.NewRegistry(),
+	}
+	sb.endMutation = sb.freezeMutex.RUnlock
+	sb.finishMutation = sb.countFinishedMutation
+	return sb
+}
+
+// NewSuperblockFrom returns a new Superblock with the same configuration as sb, but none of its
+// accounting state.  It is used to create a new filesystem that behaves like an existing one.
+func NewSuperblockFrom(sb *Superblock) *Superblock {
+	newSb := NewSuperblock()
+	if sb != nil {
+		newSb.maxBytes = sb.maxBytes
+		if sb.dedupTable() != nil {
+			newSb.EnableDedup()
+		}
+		if sb.IsIndexed() {
+			newSb.EnableIndex()
+		}
+		if sb.HasMetrics() {
+			newSb.EnableMetrics()
+		}
+		if sb.IsSparse() {
+			newSb.EnableSparse()
+		}
+		if sb.IsCompressed() {
+			newSb.EnableCompression()
+		}
+		newSb.SetSyncHook(sb.getSyncHook())
+		newSb.SetParser(sb.Parser())
+		if sb.RejectsControlCharacters() {
+			newSb.RejectControlCharacters()
+		}
+		newSb.SetLimits(sb.Limits())
+		if faults := sb.getFaults(); faults != nil {
+			newSb.SetFaults(FaultConfig{Faults: faults.faults})
+		}
+	}
+	return newSb
+}
+
+// SetQuota caps the total number of bytes of file data that the filesystem may store.  A negative
+// maxBytes removes the cap.  Writes that would grow the filesystem beyond this cap fail with
+// ENOSPC.
+func (sb *Superblock) SetQuota(maxBytes int64) {
+	sb.mutex.Lock()
+	defer sb.mutex.Unlock()
+	sb.maxBytes = maxBytes
+}
+
+// Quota returns the maximum number of bytes of file data that the filesystem may store, and whether
+// such a limit is configured at all
+func (sb *Superblock) Quota() (int64, bool) {
+	if sb == nil {
+		return 0, false
+	}
+	sb.mutex.Lock()
+	defer sb.mutex.Unlock()
+	return sb.maxBytes, sb.maxBytes >= 0
+}
+
+// UsedBytes returns the number of bytes of file data that are stored in files that are linked into
+// the filesystem's tree
+func (sb *Superblock) UsedBytes() int64 {
+	if sb == nil {
+		return 0
+	}
+	sb.mutex.Lock()
+	defer sb.mutex.Unlock()
+	return sb.usedBytes
+}
+
+// EnableSparse makes the files that are subsequently created in the filesystem sparse: instead of
+// a contiguous buffer, each file stores only the extents that have been written to it.  A write
+// beyond the end of a sparse file leaves a hole that reads back as zero bytes but is not allocated,
+// so it doesn't count against the filesystem's quota.  Sparse files are never deduplicated.
+func (sb *Superblock) EnableSparse() {
+	sb.mutex.Lock()
+	defer sb.mutex.Unlock()
+	sb.sparse = true
+}
+
+// IsSparse returns true if files created in the filesystem are sparse (see EnableSparse())
+func (sb *Superblock) IsSparse() bool {
+	if sb == nil {
+		return false
+	}
+	sb.mutex.Lock()
+	defer sb.mutex.Unlock()
+	return sb.sparse
+}
+
+// EnableCompression makes the files that are subsequently created in the filesystem store their
+// data gzip-compressed, transparently decompressing it for reads and recompressing it after writes.
+// Compressed files are never deduplicated, and if the filesystem is also sparse (see
+// EnableSparse()), then its files are sparse rather than compressed.  Quotas count the logical
+// (uncompressed) size of compressed files.
+func (sb *Superblock) EnableCompression() {
+	sb.mutex.Lock()
+	defer sb.mutex.Unlock()
+	sb.compressed = true
+}
+
+// IsCompressed returns true if files created in the filesystem are compressed (see
+// EnableCompression())
+func (sb *Superblock) IsCompressed() bool {
+	if sb == nil {
+		return false
+	}
+	sb.mutex.Lock()
+	defer sb.mutex.Unlock()
+	return sb.compressed
+}
+
+// SyncHook is called when a file is synced (see FileInode.Sync()) with the file's absolute path,
+// or the empty string if the file has been unlinked.  Its return value is returned by the sync, so
+// it can be used to simulate I/O failures or to observe syncs.
+type SyncHook func(path string) error
+
+// SetSyncHook installs hook as the filesystem's SyncHook, replacing any existing hook.  A nil hook
+// makes syncs no-ops.
+func (sb *Superblock) SetSyncHook(hook SyncHook) {
+	sb.mutex.Lock()
+	defer sb.mutex.Unlock()
+	sb.syncHook = hook
+}
+
+func (sb *Superblock) getSyncHook() SyncHook {
+	if sb == nil {
+		return nil
+	}
+	sb.mutex.Lock()
+	defer sb.mutex.Unlock()
+	return sb.syncHook
+}
+
+// Watchers returns the Registry of the filesystem's watchers, or nil if the filesystem does not
+// support watches
+func (sb *Superblock) Watchers() *notify.Registry {
+	if sb == nil {
+		return nil
+	}
+	return sb.watchers
+}
+
+// SetParser makes the filesystem use parser's path syntax instead of the default syntax (see
+// filepath.Parser).  It should be called before the filesystem is used, since paths that were
+// already reported in the old syntax won't be understood in the new one.
+func (sb *Superblock) SetParser(parser *filepath.Parser) {
+	sb.parser.Store(parser)
+}
+
+// Parser returns the Parser for the filesystem's path syntax, which is filepath.DefaultParser
+// unless SetParser() was called.  It takes no lock.
+func (sb *Superblock) Parser() *filepath.Parser {
+	if sb == nil {
+		return filepath.DefaultParser
+	}
+	if parser := sb.parser.Load(); parser != nil {
+		return parser
+	}
+	return filepath.DefaultParser
+}
+
+// reserve adjusts the filesystem's usage by delta bytes, which may be negative.  If delta is
+// positive and would push usage beyond the quota, then usage is left unchanged and ENOSPC is
+// returned.
+func (sb *Superblock) reserve(delta int64) error {
+	if sb == nil {
+		return nil
+	}
+	sb.mutex.Lock()
+	defer sb.mutex.Unlock()
+	if delta > 0 && sb.maxBytes >= 0 && sb.usedBytes+delta > sb.maxBytes {
+		return errors.Wrapf(fserrors.ENoSpace, "filesystem quota of %d bytes exceeded", sb.maxBytes)
+	}
+	sb.usedBytes += delta
+	return nil
+}
+
+// charge unconditionally adjusts the filesystem's usage by delta bytes, even if doing so exceeds
+// the quota
+func (sb *Superblock) charge(delta int64) {
+	if sb == nil {
+		return
+	}
+	sb.mutex.Lock()
+	defer sb.mutex.Unlock()
+	sb.usedBytes += delta
+}
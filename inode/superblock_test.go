@@ -0,0 +1,39 @@
+package inode_test
+
+import (
+	"testing"
+
+	"github.com/manderson5192/memfs/fserrors"
+	"github.com/manderson5192/memfs/inode"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSuperblockQuota(t *testing.T) {
+	sb := inode.NewSuperblock()
+	_, hasQuota := sb.Quota()
+	assert.False(t, hasQuota)
+	sb.SetQuota(8)
+	maxBytes, hasQuota := sb.Quota()
+	assert.True(t, hasQuota)
+	assert.Equal(t, int64(8), maxBytes)
+
+	root := inode.NewRootDirectoryInodeWithSuperblock(sb)
+	f, err := root.CreateFileInodeEntry("file", true)
+	assert.Nil(t, err)
+	_, err = f.WriteAt([]byte("hello"), 0)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(5), sb.UsedBytes())
+	_, err = f.WriteAt([]byte("hello"), 5)
+	assert.ErrorIs(t, err, fserrors.ENoSpace)
+	assert.Equal(t, int64(5), sb.UsedBytes())
+
+	assert.Nil(t, root.DeleteFile("file"))
+	assert.Equal(t, int64(0), sb.UsedBytes())
+}
+
+func TestNilSuperblock(t *testing.T) {
+	var sb *inode.Superblock
+	_, hasQuota := sb.Quota()
+	assert.False(t, hasQuota)
+	assert.Equal(t, int64(0), sb.UsedBytes())
+}
@@ -0,0 +1,77 @@
+package inode
+
+import "time"
+
+// TimesInode is implemented by every Inode.  It records when the inode's contents were last
+// modified (a file's data, or a directory's entry table), when it was last accessed, and when its
+// contents or metadata (timestamps, extended attributes, ownership, or permissions) were last
+// changed.  Reads do not update the access time, as on a filesystem mounted with noatime, so it
+// only changes when the inode is created or when the times are set explicitly.
+type TimesInode interface {
+	// ModTime returns the time at which the inode's contents were last modified
+	ModTime() time.Time
+	// AccessTime returns the time at which the inode was last accessed
+	AccessTime() time.Time
+	// ChangeTime returns the time at which the inode's contents or metadata were last changed, like
+	// a POSIX ctime.  Unlike the other times, it can't be set explicitly.
+	ChangeTime() time.Time
+	// SetTimes sets the inode's access and modification times, like utimes(2).  It updates the
+	// change time to the current time.
+	SetTimes(accessTime, modTime time.Time)
+}
+
+// now returns the current time.  It is a variable so that tests can control the clock.
+var now = time.Now
+
+func (i *basicInode) ModTime() time.Time {
+	i.rwMutex.RLock()
+	defer i.rwMutex.RUnlock()
+	return i.modTime
+}
+
+func (i *basicInode) AccessTime() time.Time {
+	i.rwMutex.RLock()
+	defer i.rwMutex.RUnlock()
+	return i.accessTime
+}
+
+func (i *basicInode) ChangeTime() time.Time {
+	i.rwMutex.RLock()
+	defer i.rwMutex.RUnlock()
+	return i.changeTime
+}
+
+func (i *basicInode) SetTimes(accessTime, modTime time.Time) {
+	i.rwMutex.Lock()
+	defer i.rwMutex.Unlock()
+	i.accessTime = accessTime
+	i.modTime = modTime
+	i.markChanged()
+}
+
+// markModified records that the inode's contents were just modified, which also changes the inode.
+//
+// This function is **not thread safe**.  It should only be invoked when a Write-level lock is held
+// on the inode.
+func (i *basicInode) markModified() {
+	i.modTime = now()
+	i.changeTime = i.modTime
+}
+
+// markChanged records that the inode's metadata was just changed.
+//
+// This function is **not thread safe**.  It should only be invoked when a Write-level lock is held
+// on the inode.
+func (i *basicInode) markChanged() {
+	i.changeTime = now()
+}
+
+// copyTimes copies the timestamps of src into i, which must not yet be reachable by any other
+// goroutine
+func (i *basicInode) copyTimes(src *basicInode) {
+	src.rwMutex.RLock()
+	defer src.rwMutex.RUnlock()
+	i.accessTime = src.accessTime
+	i.modTime = src.modTime
+	i.changeTime = src.changeTime
+}
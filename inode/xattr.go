@@ -0,0 +1,82 @@
+package inode
+
+import (
+	"sort"
+
+	"github.com/manderson5192/memfs/fserrors"
+	"github.com/pkg/errors"
+)
+
+// XattrInode is implemented by every Inode.  It stores extended attributes: arbitrary name/value
+// pairs that are attached to the inode itself, so they follow it across renames.  Values are copied
+// in and out, so callers never share memory with the inode.
+type XattrInode interface {
+	// SetXattr creates or replaces the named extended attribute.  It returns EINVAL if name is empty.
+	SetXattr(name string, value []byte) error
+	// GetXattr returns a copy of the named extended attribute's value, or ENODATA if it is not set
+	GetXattr(name string) ([]byte, error)
+	// ListXattr returns the names of the inode's extended attributes in lexical order
+	ListXattr() []string
+	// RemoveXattr removes the named extended attribute, or returns ENODATA if it is not set
+	RemoveXattr(name string) error
+}
+
+func (i *basicInode) SetXattr(name string, value []byte) error {
+	if name == "" {
+		return errors.Wrapf(fserrors.EInval, "extended attribute name must not be empty")
+	}
+	i.rwMutex.Lock()
+	defer i.rwMutex.Unlock()
+	if i.xattrs == nil {
+		i.xattrs = map[string][]byte{}
+	}
+	i.xattrs[name] = append([]byte{}, value...)
+	i.markChanged()
+	return nil
+}
+
+func (i *basicInode) GetXattr(name string) ([]byte, error) {
+	i.rwMutex.RLock()
+	defer i.rwMutex.RUnlock()
+	value, ok := i.xattrs[name]
+	if !ok {
+		return nil, errors.Wrapf(fserrors.ENoData, "no extended attribute named '%s'", name)
+	}
+	return append([]byte{}, value...), nil
+}
+
+func (i *basicInode) ListXattr() []string {
+	i.rwMutex.RLock()
+	defer i.rwMutex.RUnlock()
+	names := make([]string, 0, len(i.xattrs))
+	for name := range i.xattrs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (i *basicInode) RemoveXattr(name string) error {
+	i.rwMutex.Lock()
+	defer i.rwMutex.Unlock()
+	if _, ok := i.xattrs[name]; !ok {
+		return errors.Wrapf(fserrors.ENoData, "no extended attribute named '%s'", name)
+	}
+	delete(i.xattrs, name)
+	i.markChanged()
+	return nil
+}
+
+// copyXattrs returns a deep copy of the inode's extended attributes, or nil if it has none
+func (i *basicInode) copyXattrs() map[string][]byte {
+	i.rwMutex.RLock()
+	defer i.rwMutex.RUnlock()
+	if len(i.xattrs) == 0 {
+		return nil
+	}
+	xattrs := make(map[string][]byte, len(i.xattrs))
+	for name, value := range i.xattrs {
+		xattrs[name] = append([]byte{}, value...)
+	}
+	return xattrs
+}
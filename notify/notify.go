@@ -0,0 +1,131 @@
+package notify
+
+import (
+	"strings"
+	"sync"
+)
+
+// Op identifies the kind of change that an Event describes
+type Op int
+
+const (
+	// Create indicates that a file or directory was created at the event's path, including as the
+	// destination of a rename
+	Create Op = iota + 1
+	// Write indicates that a file's contents were modified
+	Write
+	// Remove indicates that a file or directory was removed
+	Remove
+	// Rename indicates that a file or directory was renamed away from the event's path.  It is
+	// followed by a Create event for the entry's new path.
+	Rename
+)
+
+func (o Op) String() string {
+	switch o {
+	case Create:
+		return "Create"
+	case Write:
+		return "Write"
+	case Remove:
+		return "Remove"
+	case Rename:
+		return "Rename"
+	default:
+		return "Invalid"
+	}
+}
+
+// Event describes a single change to the filesystem
+type Event struct {
+	// Path is the absolute path of the file or directory that changed
+	Path string
+	// Op is the kind of change
+	Op Op
+}
+
+// EventBufferSize is the number of events that a watcher's channel can hold before further events
+// are dropped
+const EventBufferSize = 64
+
+type watcher struct {
+	path   string
+	events chan Event
+}
+
+// Registry tracks the watchers of a single filesystem and delivers events to them.  A nil
+// *Registry is valid and has no watchers.
+type Registry struct {
+	mutex    sync.RWMutex
+	watchers map[*watcher]struct{}
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		watchers: map[*watcher]struct{}{},
+	}
+}
+
+// Watch registers a watcher for changes to path or anything beneath it.  path must be a clean,
+// absolute path.  It returns the channel on which events will be delivered and a function that
+// unregisters the watcher and closes the channel.
+//
+// Events are delivered without blocking: if the watcher's channel is full (because its consumer is
+// slow) then the event is dropped for that watcher.
+func (r *Registry) Watch(path string) (<-chan Event, func()) {
+	w := &watcher{
+		path:   path,
+		events: make(chan Event, EventBufferSize),
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.watchers[w] = struct{}{}
+	var once sync.Once
+	unwatch := func() {
+		once.Do(func() {
+			r.mutex.Lock()
+			defer r.mutex.Unlock()
+			delete(r.watchers, w)
+			close(w.events)
+		})
+	}
+	return w.events, unwatch
+}
+
+// HasWatchers returns true if there is at least one registered watcher.  Publishers can use it to
+// avoid the cost of computing an event's path when nobody is listening.
+func (r *Registry) HasWatchers() bool {
+	if r == nil {
+		return false
+	}
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return len(r.watchers) > 0
+}
+
+// Publish delivers e to every watcher whose watched path is e.Path or one of its ancestors
+func (r *Registry) Publish(e Event) {
+	if r == nil {
+		return
+	}
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	for w := range r.watchers {
+		if !isSameOrAncestor(w.path, e.Path) {
+			continue
+		}
+		select {
+		case w.events <- e:
+		default:
+			// The consumer is too slow, so drop the event rather than block the filesystem
+		}
+	}
+}
+
+// isSameOrAncestor returns true if path is the same as, or a descendant of, ancestor
+func isSameOrAncestor(ancestor, path string) bool {
+	if ancestor == "/" || ancestor == path {
+		return true
+	}
+	return strings.HasPrefix(path, ancestor+"/")
+}
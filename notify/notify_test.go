@@ -0,0 +1,51 @@
+package notify_test
+
+import (
+	"testing"
+
+	"github.com/manderson5192/memfs/notify"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type NotifyTestSuite struct {
+	suite.Suite
+	registry *notify.Registry
+}
+
+func (s *NotifyTestSuite) SetupTest() {
+	s.registry = notify.NewRegistry()
+}
+
+func (s *NotifyTestSuite) TestPublishMatchesAncestors() {
+	root, unwatchRoot := s.registry.Watch("/")
+	defer unwatchRoot()
+	dir, unwatchDir := s.registry.Watch("/a/b")
+	defer unwatchDir()
+
+	for _, path := range []string{"/a/b", "/a/b/c", "/a/bc", "/a"} {
+		s.registry.Publish(notify.Event{Path: path, Op: notify.Write})
+	}
+	assert.Len(s.T(), root, 4)
+	assert.Len(s.T(), dir, 2)
+	assert.Equal(s.T(), notify.Event{Path: "/a/b", Op: notify.Write}, <-dir)
+	assert.Equal(s.T(), notify.Event{Path: "/a/b/c", Op: notify.Write}, <-dir)
+}
+
+func (s *NotifyTestSuite) TestHasWatchers() {
+	assert.False(s.T(), s.registry.HasWatchers())
+	_, unwatch := s.registry.Watch("/")
+	assert.True(s.T(), s.registry.HasWatchers())
+	unwatch()
+	assert.False(s.T(), s.registry.HasWatchers())
+}
+
+func (s *NotifyTestSuite) TestNilRegistry() {
+	var registry *notify.Registry
+	assert.False(s.T(), registry.HasWatchers())
+	registry.Publish(notify.Event{Path: "/", Op: notify.Create})
+}
+
+func TestNotifyTestSuite(t *testing.T) {
+	suite.Run(t, new(NotifyTestSuite))
+}
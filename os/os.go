@@ -61,3 +61,27 @@ func IsExclusiveMode(mode int) bool {
 	// O_EXCL is only applicable when O_CREATE is set
 	return IsCreateMode(mode) && checkMode(mode, O_EXCL)
 }
+
+// FileMode represents a file's mode and permission bits, as in Go's os module
+type FileMode = golang_os.FileMode
+
+const (
+	// ModeDir is the FileMode bit that indicates a directory
+	ModeDir = golang_os.ModeDir
+	// ModePerm is the mask for a FileMode's Unix permission bits
+	ModePerm = golang_os.ModePerm
+	// DefaultUmask is the umask of a new process, which clears the group and other write bits of
+	// newly created files and directories
+	DefaultUmask FileMode = 022
+)
+
+// AccessMode is a combination of the permissions that an access check tests for, like the mode
+// argument to access(2).  F_OK tests only whether the file exists.
+type AccessMode int
+
+const (
+	F_OK AccessMode = 0
+	X_OK AccessMode = 1
+	W_OK AccessMode = 2
+	R_OK AccessMode = 4
+)
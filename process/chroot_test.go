@@ -0,0 +1,111 @@
+package process_test
+
+import (
+	"github.com/manderson5192/memfs/directory"
+	"github.com/manderson5192/memfs/fserrors"
+	"github.com/manderson5192/memfs/os"
+	"github.com/stretchr/testify/assert"
+)
+
+func (s *ProcessTestSuite) TestChrootAbsolutePaths() {
+	chrooted, err := s.p.Chroot("/a/b")
+	assert.Nil(s.T(), err)
+	workdir, err := chrooted.WorkingDirectory()
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), "/", workdir)
+
+	// "/c" in the chroot is "/a/b/c" in the filesystem
+	info, err := chrooted.Stat("/c")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), directory.DirectoryType, info.Type)
+	info, err = chrooted.Stat("/")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), "/", info.Name)
+	assert.Nil(s.T(), chrooted.WriteFile("/c/file", []byte("data"), 0))
+	data, err := s.p.ReadFile("/a/b/c/file")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []byte("data"), data)
+
+	// Entries outside of the chroot are unreachable
+	_, err = chrooted.Stat("/a/foobar_file")
+	assert.ErrorIs(s.T(), err, fserrors.ENoEnt)
+
+	entries, err := chrooted.ListDirectorySorted("/")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []directory.DirectoryEntry{
+		{Name: "a", Type: directory.DirectoryType},
+		{Name: "c", Type: directory.DirectoryType},
+	}, entries)
+}
+
+func (s *ProcessTestSuite) TestChrootParentDirectoryCannotEscape() {
+	chrooted, err := s.p.Chroot("/a/b")
+	assert.Nil(s.T(), err)
+
+	assert.Nil(s.T(), chrooted.ChangeDirectory("/.."))
+	workdir, err := chrooted.WorkingDirectory()
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), "/", workdir)
+
+	assert.Nil(s.T(), chrooted.ChangeDirectory("c/../../../c"))
+	workdir, err = chrooted.WorkingDirectory()
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), "/c", workdir)
+
+	// ".." at the root refers to the root itself, even as the final entry of a path
+	_, err = chrooted.Stat("../foobar_file")
+	assert.ErrorIs(s.T(), err, fserrors.ENoEnt)
+	same, err := chrooted.SameFile("/..", "/")
+	assert.Nil(s.T(), err)
+	assert.True(s.T(), same)
+	info, err := chrooted.Stat("/..")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), 2, info.Size, "'/..' is the chroot, which has entries 'a' and 'c'")
+
+	// Renames can't move entries out of the chroot either
+	assert.Nil(s.T(), chrooted.Rename("/c", "/../../c_moved"))
+	_, err = s.p.Stat("/a/b/c_moved")
+	assert.Nil(s.T(), err)
+}
+
+func (s *ProcessTestSuite) TestChrootWorkingDirectoryIsIndependent() {
+	chrooted, err := s.p.Chroot("/a")
+	assert.Nil(s.T(), err)
+	assert.Nil(s.T(), chrooted.ChangeDirectory("/b/c"))
+	workdir, err := chrooted.WorkingDirectory()
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), "/b/c", workdir)
+	s.assertWorkingDirectory("/")
+
+	// Clones and nested chroots stay confined
+	clone := chrooted.Clone()
+	assert.Nil(s.T(), clone.ChangeDirectory("/../../.."))
+	workdir, err = clone.WorkingDirectory()
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), "/", workdir)
+	nested, err := chrooted.Chroot("/b")
+	assert.Nil(s.T(), err)
+	_, err = nested.Stat("/../zzz")
+	assert.ErrorIs(s.T(), err, fserrors.ENoEnt)
+}
+
+func (s *ProcessTestSuite) TestChrootFileParentOutsideRoot() {
+	chrooted, err := s.p.Chroot("/a/b")
+	assert.Nil(s.T(), err)
+	f, err := chrooted.OpenFile("/c/file", os.CombineModes(os.O_RDWR, os.O_CREATE))
+	assert.Nil(s.T(), err)
+	assert.Nil(s.T(), chrooted.ChangeDirectoryToFileParent(f))
+	workdir, err := chrooted.WorkingDirectory()
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), "/c", workdir)
+
+	// Once the file is moved out of the chroot, its parent can't be reached
+	assert.Nil(s.T(), s.p.Rename("/a/b/c/file", "/a/file"))
+	err = chrooted.ChangeDirectoryToFileParent(f)
+	assert.ErrorIs(s.T(), err, fserrors.ENoEnt)
+}
+
+func (s *ProcessTestSuite) TestChrootOnFile() {
+	_, err := s.p.Chroot("/a/foobar_file")
+	assert.ErrorIs(s.T(), err, fserrors.ENotDir)
+}
@@ -0,0 +1,84 @@
+package process_test
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/manderson5192/memfs/fserrors"
+	"github.com/manderson5192/memfs/process"
+	"github.com/stretchr/testify/assert"
+)
+
+func (s *ProcessTestSuite) TestCloneHasIndependentWorkingDirectory() {
+	assert.Nil(s.T(), s.p.ChangeDirectory("/a/b"))
+	assert.Nil(s.T(), s.p.Pushd("c"))
+	clone := s.p.Clone()
+	workdir, err := clone.WorkingDirectory()
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), "/a/b/c", workdir)
+
+	// Changing directories in the clone doesn't affect the original, and vice versa
+	assert.Nil(s.T(), clone.ChangeDirectory("/a/zzz"))
+	s.assertWorkingDirectory("/a/b/c")
+	assert.Nil(s.T(), s.p.ChangeDirectory("/"))
+	workdir, err = clone.WorkingDirectory()
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), "/a/zzz", workdir)
+
+	// The directory stack was copied, not shared
+	assert.Nil(s.T(), clone.Popd())
+	assert.Nil(s.T(), s.p.Popd())
+	assert.ErrorIs(s.T(), clone.Popd(), fserrors.EInval)
+	s.assertWorkingDirectory("/a/b")
+}
+
+func (s *ProcessTestSuite) TestCloneSharesFilesystem() {
+	clone := s.p.Clone()
+	assert.Nil(s.T(), clone.WriteFile("/a/from_clone", []byte("hi"), 0))
+	data, err := s.p.ReadFile("/a/from_clone")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []byte("hi"), data)
+}
+
+func (s *ProcessTestSuite) TestConcurrentContexts() {
+	const numFiles = 200
+	writer := s.p.Clone()
+	reader := process.NewProcessFilesystemContext(s.fs)
+	created := make(chan string, numFiles)
+	wg := sync.WaitGroup{}
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		defer close(created)
+		assert.Nil(s.T(), writer.ChangeDirectory("/a/b"))
+		for i := 0; i < numFiles; i++ {
+			name := fmt.Sprintf("file%d", i)
+			assert.Nil(s.T(), writer.WriteFile(name, []byte(name), 0))
+			created <- "/a/b/" + name
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for path := range created {
+			// Each file is visible to the other context as soon as it is created...
+			data, err := reader.ReadFile(path)
+			assert.Nil(s.T(), err)
+			assert.Equal(s.T(), path[len("/a/b/"):], string(data))
+			// ...while the other context's working directory changes have no effect on this one
+			assert.Nil(s.T(), reader.ChangeDirectory("/a/zzz"))
+			assert.Nil(s.T(), reader.ChangeDirectory(".."))
+		}
+	}()
+	wg.Wait()
+
+	workdir, err := writer.WorkingDirectory()
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), "/a/b", workdir)
+	workdir, err = reader.WorkingDirectory()
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), "/a", workdir)
+	s.assertWorkingDirectory("/")
+	entries, err := s.p.ListDirectory("/a/b")
+	assert.Nil(s.T(), err)
+	assert.Len(s.T(), entries, numFiles+2)
+}
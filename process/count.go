@@ -0,0 +1,30 @@
+package process
+
+import (
+	"github.com/manderson5192/memfs/directory"
+	"github.com/pkg/errors"
+)
+
+func (p *processContext) CountEntries(path string) (int, int, error) {
+	files, dirs := 0, 0
+	// Walk() visits the starting path first
+	isRoot := true
+	walkFunc := func(path string, fileInfo *directory.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		switch {
+		case fileInfo.IsDir():
+			dirs++
+		case !isRoot:
+			// The starting path is only counted if it is a directory
+			files++
+		}
+		isRoot = false
+		return nil
+	}
+	if err := p.Walk(path, walkFunc); err != nil {
+		return 0, 0, errors.Wrapf(err, "could not count the entries in '%s'", path)
+	}
+	return files, dirs, nil
+}
@@ -0,0 +1,38 @@
+package process_test
+
+import (
+	"github.com/manderson5192/memfs/fserrors"
+	"github.com/stretchr/testify/assert"
+)
+
+func (s *ProcessTestSuite) TestCountEntries() {
+	// The fixture tree holds the directories /, /a, /a/b, /a/zzz, /a/b/c, and /a/b/a, and the file
+	// /a/foobar_file
+	files, dirs, err := s.p.CountEntries("/")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), 1, files)
+	assert.Equal(s.T(), 6, dirs)
+
+	s.createFiles("/a/b/c/file1", "/a/b/file2")
+	files, dirs, err = s.p.CountEntries("/a/b")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), 2, files)
+	assert.Equal(s.T(), 3, dirs)
+
+	assert.Nil(s.T(), s.p.ChangeDirectory("/a"))
+	files, dirs, err = s.p.CountEntries("zzz")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), 0, files)
+	assert.Equal(s.T(), 1, dirs)
+
+	// A file at the starting path isn't counted
+	files, dirs, err = s.p.CountEntries("foobar_file")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), 0, files)
+	assert.Equal(s.T(), 0, dirs)
+}
+
+func (s *ProcessTestSuite) TestCountEntriesNoExist() {
+	_, _, err := s.p.CountEntries("/noexist")
+	assert.ErrorIs(s.T(), err, fserrors.ENoEnt)
+}
@@ -4,8 +4,8 @@ import (
 	"strings"
 
 	"github.com/manderson5192/memfs/directory"
-	"github.com/manderson5192/memfs/filepath"
 	"github.com/manderson5192/memfs/fserrors"
+	"github.com/manderson5192/memfs/os"
 	"github.com/pkg/errors"
 )
 
@@ -17,6 +17,14 @@ func (p *processContext) MakeDirectory(path string) error {
 	return nil
 }
 
+func (p *processContext) MakeDirectoryMode(path string, perm os.FileMode) error {
+	relativePath, baseDir := p.toCleanRelativePathAndBaseDir(path)
+	if _, err := baseDir.MkdirMode(relativePath, perm); err != nil {
+		return errors.Wrapf(err, "could not create directory '%s'", path)
+	}
+	return nil
+}
+
 func (p *processContext) ListDirectory(path string) ([]directory.DirectoryEntry, error) {
 	relativePath, baseDir := p.toCleanRelativePathAndBaseDir(path)
 	entries, err := baseDir.ReadDir(relativePath)
@@ -26,6 +34,43 @@ func (p *processContext) ListDirectory(path string) ([]directory.DirectoryEntry,
 	return entries, nil
 }
 
+// NamedFileInfo pairs the name of a directory entry with a FileInfo that describes it (see
+// ReadDirInfo())
+type NamedFileInfo struct {
+	Name string
+	Info *directory.FileInfo
+}
+
+func (p *processContext) ReadDirInfo(path string) ([]NamedFileInfo, error) {
+	entries, err := p.statEntries(path)
+	if err != nil {
+		return nil, err
+	}
+	toReturn := make([]NamedFileInfo, 0, len(entries))
+	for _, entry := range entries {
+		toReturn = append(toReturn, NamedFileInfo{Name: entry.Name, Info: entry})
+	}
+	return toReturn, nil
+}
+
+func (p *processContext) ListDirectorySorted(path string) ([]directory.DirectoryEntry, error) {
+	relativePath, baseDir := p.toCleanRelativePathAndBaseDir(path)
+	entries, err := baseDir.ReadDirSorted(relativePath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not list entries in directory '%s'", path)
+	}
+	return entries, nil
+}
+
+func (p *processContext) OpenDirectory(path string) (directory.Directory, error) {
+	relativePath, baseDir := p.toCleanRelativePathAndBaseDir(path)
+	dir, err := baseDir.LookupSubdirectory(relativePath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not open directory '%s'", path)
+	}
+	return dir.OpenHandle(), nil
+}
+
 func (p *processContext) RemoveDirectory(path string) error {
 	relativePath, baseDir := p.toCleanRelativePathAndBaseDir(path)
 	if err := baseDir.Rmdir(relativePath); err != nil {
@@ -34,24 +79,78 @@ func (p *processContext) RemoveDirectory(path string) error {
 	return nil
 }
 
+func (p *processContext) RemoveOpenDirectory(path string) error {
+	relativePath, baseDir := p.toCleanRelativePathAndBaseDir(path)
+	if err := baseDir.RmdirOpen(relativePath); err != nil {
+		return errors.Wrapf(err, "could not remove directory '%s'", path)
+	}
+	return nil
+}
+
+func (p *processContext) EmptyDir(path string) error {
+	fileInfo, err := p.Stat(path)
+	if err != nil {
+		return errors.Wrapf(err, "could not empty directory '%s'", path)
+	}
+	if fileInfo.Type != directory.DirectoryType {
+		return errors.Wrapf(fserrors.ENotDir, "could not empty '%s'", path)
+	}
+	// Visiting each entry after its descendants means that every directory is empty by the time it
+	// is removed
+	walkFunc := func(entryPath string, entryInfo *directory.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if entryPath == path {
+			return nil
+		}
+		if entryInfo.Type == directory.DirectoryType {
+			return p.RemoveDirectory(entryPath)
+		}
+		return p.DeleteFile(entryPath)
+	}
+	if err := p.WalkPostOrder(path, walkFunc); err != nil {
+		return errors.Wrapf(err, "could not empty directory '%s'", path)
+	}
+	return nil
+}
+
 func (p *processContext) MakeDirectoryWithAncestors(path string) error {
+	_, err := p.MakeDirectoryAllReturn(path)
+	return err
+}
+
+func (p *processContext) MakeDirectoryAllReturn(path string) (directory.Directory, error) {
 	relativePath, baseDir := p.toCleanRelativePathAndBaseDir(path)
+	ancestorPrefix := ""
+	parser := p.parser()
+	if parser.IsAbsolutePath(path) {
+		ancestorPrefix = p.separator()
+	}
 	// Iterate over each part of the path, creating the directory for that part and then looking
 	// up the result.  We can ignore errors on directory creation (as would happen if the ancestor
 	// directory already existed) so long as the subsequent lookup works
-	pathParts := strings.Split(relativePath, filepath.PathSeparator)
+	pathParts := strings.Split(relativePath, p.separator())
 	for idx, pathPart := range pathParts {
+		// Empty parts come from trailing (or repeated) path separators and refer to the directory
+		// that has already been looked up
+		if pathPart == "" {
+			continue
+		}
 		var lookupErr error
 		_, mkdirErr := baseDir.Mkdir(pathPart)
 		baseDir, lookupErr = baseDir.LookupSubdirectory(pathPart)
 		if lookupErr != nil {
+			ancestor := ancestorPrefix + parser.Join(pathParts[0:idx+1]...)
+			if errors.Is(lookupErr, fserrors.ENotDir) {
+				return nil, errors.Wrapf(fserrors.ENotDir, "ancestor '%s' of path '%s' is not a directory", ancestor, path)
+			}
 			errToWrap := mkdirErr
 			if errors.Is(mkdirErr, fserrors.EExist) {
 				errToWrap = lookupErr
 			}
-			ancestor := filepath.Join(pathParts[0 : idx+1]...)
-			return errors.Wrapf(errToWrap, "could not create ancestor '%s' of path '%s'", ancestor, path)
+			return nil, errors.Wrapf(errToWrap, "could not create ancestor '%s' of path '%s'", ancestor, path)
 		}
 	}
-	return nil
+	return baseDir, nil
 }
@@ -62,6 +62,34 @@ func (s *ProcessTestSuite) TestRemoveDirectoryOnFile() {
 	assert.ErrorIs(s.T(), err, fserrors.ENotDir)
 }
 
+func (s *ProcessTestSuite) TestEmptyDir() {
+	s.createFiles("/a/b/file", "/a/b/c/file", "/a/b/c/other")
+	assert.Nil(s.T(), s.p.MakeDirectoryWithAncestors("/a/b/c/d/e"))
+
+	assert.Nil(s.T(), s.p.EmptyDir("/a/b/"))
+	isDir, err := s.p.IsDir("/a/b")
+	assert.Nil(s.T(), err)
+	assert.True(s.T(), isDir)
+	entries, err := s.p.ListDirectory("/a/b")
+	assert.Nil(s.T(), err)
+	assert.Empty(s.T(), entries)
+	info, err := s.p.Stat("/a/b")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), 0, info.Size)
+
+	// The rest of the tree is untouched, and an empty directory can be emptied again
+	assert.True(s.T(), s.p.Exists("/a/foobar_file"))
+	assert.True(s.T(), s.p.Exists("/a/zzz"))
+	assert.Nil(s.T(), s.p.ChangeDirectory("/a"))
+	assert.Nil(s.T(), s.p.EmptyDir("b"))
+}
+
+func (s *ProcessTestSuite) TestEmptyDirOnFile() {
+	assert.ErrorIs(s.T(), s.p.EmptyDir("/a/foobar_file"), fserrors.ENotDir)
+	assert.True(s.T(), s.p.Exists("/a/foobar_file"))
+	assert.ErrorIs(s.T(), s.p.EmptyDir("/a/missing"), fserrors.ENoEnt)
+}
+
 func (s *ProcessTestSuite) TestMakeDirectoryWithAncestorExistingDirectory() {
 	err := s.p.MakeDirectoryWithAncestors("/a/b/c")
 	assert.Nil(s.T(), err)
@@ -89,4 +117,126 @@ func (s *ProcessTestSuite) TestMakeDirectoryWithAncestorAncestorIsFile() {
 	err := s.p.MakeDirectoryWithAncestors("/a/foobar_file/subdir")
 	assert.NotNil(s.T(), err)
 	assert.ErrorIs(s.T(), err, fserrors.ENotDir)
+	assert.Contains(s.T(), err.Error(), "'/a/foobar_file'")
+}
+
+func (s *ProcessTestSuite) TestMakeDirectoryWithAncestorPathIsFile() {
+	err := s.p.MakeDirectoryWithAncestors("/a/foobar_file")
+	assert.ErrorIs(s.T(), err, fserrors.ENotDir)
+}
+
+func (s *ProcessTestSuite) TestMakeDirectoryWithAncestorTrailingSlash() {
+	assert.Nil(s.T(), s.p.MakeDirectoryWithAncestors("/x/y/"))
+	assert.Nil(s.T(), s.p.MakeDirectoryWithAncestors("x//y//z/"))
+	info, err := s.p.Stat("/x/y/z")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), directory.DirectoryType, info.Type)
+}
+
+func (s *ProcessTestSuite) TestMakeDirectoryAllReturn() {
+	dir, err := s.p.MakeDirectoryAllReturn("/a/b/x/y")
+	assert.Nil(s.T(), err)
+	path, err := dir.ReversePathLookup()
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), "/a/b/x/y", path)
+
+	// An existing directory is returned as-is
+	existing, err := s.p.MakeDirectoryAllReturn("/a/b/")
+	assert.Nil(s.T(), err)
+	path, err = existing.ReversePathLookup()
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), "/a/b", path)
+
+	root, err := s.p.MakeDirectoryAllReturn("/")
+	assert.Nil(s.T(), err)
+	assert.True(s.T(), root.Equals(s.fs.RootDirectory()))
+}
+
+func (s *ProcessTestSuite) TestListDirectorySorted() {
+	entries, err := s.p.ListDirectorySorted("/a")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []directory.DirectoryEntry{
+		{Name: "b", Type: directory.DirectoryType},
+		{Name: "foobar_file", Type: directory.FileType},
+		{Name: "zzz", Type: directory.DirectoryType},
+	}, entries)
+}
+
+func (s *ProcessTestSuite) TestOpenDirectory() {
+	dir, err := s.p.OpenDirectory("/a/b")
+	assert.Nil(s.T(), err)
+	defer dir.Close()
+	path, err := dir.ReversePathLookup()
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), "/a/b", path)
+
+	// The handle resolves paths relative to the opened directory
+	_, err = dir.Mkdir("d")
+	assert.Nil(s.T(), err)
+	isDir, err := s.p.IsDir("/a/b/d")
+	assert.Nil(s.T(), err)
+	assert.True(s.T(), isDir)
+	info, err := dir.Stat("c")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), directory.DirectoryType, info.Type)
+
+	// Relative paths are resolved against the working directory
+	assert.Nil(s.T(), s.p.ChangeDirectory("/a"))
+	relativeDir, err := s.p.OpenDirectory("b/c")
+	assert.Nil(s.T(), err)
+	defer relativeDir.Close()
+	path, err = relativeDir.ReversePathLookup()
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), "/a/b/c", path)
+
+	_, err = s.p.OpenDirectory("/a/foobar_file")
+	assert.ErrorIs(s.T(), err, fserrors.ENotDir)
+	_, err = s.p.OpenDirectory("/a/noexist")
+	assert.ErrorIs(s.T(), err, fserrors.ENoEnt)
+}
+
+func (s *ProcessTestSuite) TestRemoveDirectoryWithOpenHandle() {
+	dir, err := s.p.OpenDirectory("/a/b")
+	assert.Nil(s.T(), err)
+	defer dir.Close()
+
+	// An open handle doesn't let RemoveDirectory() remove a non-empty directory
+	assert.ErrorIs(s.T(), s.p.RemoveDirectory("/a/b"), fserrors.ENotEmpty)
+	assert.True(s.T(), s.p.Exists("/a/b/c"))
+
+	// RemoveOpenDirectory() does, but only while a handle is open
+	assert.ErrorIs(s.T(), s.p.RemoveOpenDirectory("/a"), fserrors.ENotEmpty)
+	assert.Nil(s.T(), s.p.RemoveOpenDirectory("/a/b"))
+	assert.False(s.T(), s.p.Exists("/a/b"))
+	entries, err := dir.ReadDirSorted("")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []directory.DirectoryEntry{
+		{Name: "a", Type: directory.DirectoryType},
+		{Name: "c", Type: directory.DirectoryType},
+	}, entries)
+}
+
+func (s *ProcessTestSuite) TestReadDirInfo() {
+	assert.Nil(s.T(), s.p.WriteFile("/a/b/file", []byte("contents"), 0))
+	infos, err := s.p.ReadDirInfo("/a/b")
+	assert.Nil(s.T(), err)
+	names := []string{}
+	for _, info := range infos {
+		names = append(names, info.Name)
+		stat, err := s.p.Stat("/a/b/" + info.Name)
+		assert.Nil(s.T(), err)
+		assert.Equal(s.T(), stat, info.Info)
+	}
+	assert.Equal(s.T(), []string{"a", "c", "file"}, names)
+	assert.Equal(s.T(), directory.FileType, infos[2].Info.Type)
+	assert.Equal(s.T(), len("contents"), infos[2].Info.Size)
+
+	assert.Nil(s.T(), s.p.ChangeDirectory("/a"))
+	infos, err = s.p.ReadDirInfo("zzz")
+	assert.Nil(s.T(), err)
+	assert.Empty(s.T(), infos)
+	_, err = s.p.ReadDirInfo("foobar_file")
+	assert.ErrorIs(s.T(), err, fserrors.ENotDir)
+	_, err = s.p.ReadDirInfo("noexist")
+	assert.ErrorIs(s.T(), err, fserrors.ENoEnt)
 }
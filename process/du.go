@@ -0,0 +1,61 @@
+package process
+
+import (
+	"sort"
+
+	"github.com/manderson5192/memfs/directory"
+	"github.com/pkg/errors"
+)
+
+// DuEntry is the disk usage of one entry of a directory (see DuBreakdown())
+type DuEntry struct {
+	Path string
+	Type directory.DirectoryEntryType
+	// Size is the total size, in bytes, of the files in the entry's subtree, or the file's size if
+	// the entry is a file
+	Size int64
+}
+
+func (p *processContext) DiskUsage(path string) (int64, error) {
+	var totalBytes int64
+	walkFunc := func(path string, fileInfo *directory.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if fileInfo.Type == directory.FileType {
+			totalBytes += int64(fileInfo.Size)
+		}
+		return nil
+	}
+	if err := p.Walk(path, walkFunc); err != nil {
+		return 0, errors.Wrapf(err, "could not compute disk usage of '%s'", path)
+	}
+	return totalBytes, nil
+}
+
+func (p *processContext) DuBreakdown(path string) ([]DuEntry, error) {
+	entries, err := p.ListDirectory(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not compute disk usage of '%s'", path)
+	}
+	breakdown := make([]DuEntry, 0, len(entries))
+	for _, entry := range entries {
+		entryPath := p.parser().Join(path, entry.Name)
+		size, err := p.DiskUsage(entryPath)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not compute disk usage of '%s'", path)
+		}
+		breakdown = append(breakdown, DuEntry{
+			Path: entryPath,
+			Type: entry.Type,
+			Size: size,
+		})
+	}
+	sort.Slice(breakdown, func(i, j int) bool {
+		if breakdown[i].Size != breakdown[j].Size {
+			return breakdown[i].Size > breakdown[j].Size
+		}
+		return breakdown[i].Path < breakdown[j].Path
+	})
+	return breakdown, nil
+}
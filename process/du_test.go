@@ -0,0 +1,74 @@
+package process_test
+
+import (
+	"github.com/manderson5192/memfs/directory"
+	"github.com/manderson5192/memfs/filesys"
+	"github.com/manderson5192/memfs/fserrors"
+	"github.com/manderson5192/memfs/process"
+	"github.com/stretchr/testify/assert"
+)
+
+func (s *ProcessTestSuite) TestDiskUsage() {
+	f, err := s.p.CreateFile("/a/b/c/more")
+	assert.Nil(s.T(), err)
+	assert.Nil(s.T(), f.TruncateAndWriteAll([]byte("more data")))
+
+	total, err := s.p.DiskUsage("/")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), int64(len("hello!")+len("more data")), total)
+
+	total, err = s.p.DiskUsage("/a/b")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), int64(len("more data")), total)
+
+	total, err = s.p.DiskUsage("/a/foobar_file")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), int64(len("hello!")), total)
+}
+
+func (s *ProcessTestSuite) TestDiskUsageEmptyFilesystem() {
+	total, err := process.NewProcessFilesystemContext(filesys.NewFileSystem()).DiskUsage("/")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), int64(0), total)
+}
+
+func (s *ProcessTestSuite) TestDiskUsageNoExist() {
+	_, err := s.p.DiskUsage("/noexist")
+	assert.ErrorIs(s.T(), err, fserrors.ENoEnt)
+}
+
+func (s *ProcessTestSuite) TestDuBreakdown() {
+	s.createFiles("/a/b/c/deep_file", "/a/b/a/x", "/a/zzz/z")
+	assert.Nil(s.T(), s.p.MakeDirectory("/a/empty"))
+	breakdown, err := s.p.DuBreakdown("/a")
+	assert.Nil(s.T(), err)
+	// createFiles() writes each file's path into it
+	assert.Equal(s.T(), []process.DuEntry{
+		{Path: "/a/b", Type: directory.DirectoryType, Size: int64(len("/a/b/c/deep_file") + len("/a/b/a/x"))},
+		{Path: "/a/zzz", Type: directory.DirectoryType, Size: int64(len("/a/zzz/z"))},
+		{Path: "/a/foobar_file", Type: directory.FileType, Size: int64(len("hello!"))},
+		{Path: "/a/empty", Type: directory.DirectoryType, Size: 0},
+	}, breakdown)
+
+	// The entries add up to the directory's total
+	total, err := s.p.DiskUsage("/a")
+	assert.Nil(s.T(), err)
+	var sum int64
+	for _, entry := range breakdown {
+		sum += entry.Size
+	}
+	assert.Equal(s.T(), total, sum)
+
+	// Relative paths stay relative
+	assert.Nil(s.T(), s.p.ChangeDirectory("/a"))
+	breakdown, err = s.p.DuBreakdown("zzz")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []process.DuEntry{{Path: "zzz/z", Type: directory.FileType, Size: int64(len("/a/zzz/z"))}}, breakdown)
+}
+
+func (s *ProcessTestSuite) TestDuBreakdownErrors() {
+	_, err := s.p.DuBreakdown("/a/foobar_file")
+	assert.ErrorIs(s.T(), err, fserrors.ENotDir)
+	_, err = s.p.DuBreakdown("/noexist")
+	assert.ErrorIs(s.T(), err, fserrors.ENoEnt)
+}
@@ -1,7 +1,11 @@
 package process
 
 import (
+	"crypto/sha256"
+
+	"github.com/manderson5192/memfs/directory"
 	"github.com/manderson5192/memfs/file"
+	"github.com/manderson5192/memfs/fserrors"
 	"github.com/manderson5192/memfs/os"
 	"github.com/pkg/errors"
 )
@@ -12,7 +16,46 @@ func (p *processContext) OpenFile(path string, mode int) (file.File, error) {
 	if err != nil {
 		return nil, errors.Wrapf(err, "could not open file '%s'", path)
 	}
-	return f, nil
+	return p.track(f), nil
+}
+
+func (p *processContext) OpenAt(dir directory.Directory, path string, mode int) (file.File, error) {
+	relativePath, baseDir := p.toCleanRelativePathAndBaseDir(path)
+	if dir != nil && p.parser().IsRelativePath(path) {
+		baseDir = dir
+	}
+	f, err := baseDir.OpenFile(relativePath, mode)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not open file '%s'", path)
+	}
+	return p.track(f), nil
+}
+
+func (p *processContext) OpenFileWithLimit(path string, mode int, maxBytes int64) (file.File, error) {
+	relativePath, baseDir := p.toCleanRelativePathAndBaseDir(path)
+	f, err := baseDir.OpenFileWithLimit(relativePath, mode, maxBytes)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not open file '%s'", path)
+	}
+	return p.track(f), nil
+}
+
+func (p *processContext) OpenRingFile(path string, maxBytes int) (file.File, error) {
+	relativePath, baseDir := p.toCleanRelativePathAndBaseDir(path)
+	f, err := baseDir.OpenRingFile(relativePath, maxBytes)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not open ring file '%s'", path)
+	}
+	return p.track(f), nil
+}
+
+func (p *processContext) OpenFileBuffered(path string, mode int, bufSize int) (file.File, error) {
+	relativePath, baseDir := p.toCleanRelativePathAndBaseDir(path)
+	f, err := baseDir.OpenFileBuffered(relativePath, mode, bufSize)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not open buffered file '%s'", path)
+	}
+	return p.track(f), nil
 }
 
 func (p *processContext) CreateFile(path string) (file.File, error) {
@@ -23,6 +66,15 @@ func (p *processContext) CreateFile(path string) (file.File, error) {
 	return f, nil
 }
 
+func (p *processContext) CreateExclusive(path string) (file.File, bool, error) {
+	relativePath, baseDir := p.toCleanRelativePathAndBaseDir(path)
+	f, created, err := baseDir.CreateExclusive(relativePath)
+	if err != nil {
+		return nil, false, errors.Wrapf(err, "could not create file '%s'", path)
+	}
+	return p.track(f), created, nil
+}
+
 func (p *processContext) DeleteFile(path string) error {
 	relativePath, baseDir := p.toCleanRelativePathAndBaseDir(path)
 	if err := baseDir.DeleteFile(relativePath); err != nil {
@@ -30,3 +82,75 @@ func (p *processContext) DeleteFile(path string) error {
 	}
 	return nil
 }
+
+func (p *processContext) ReadFile(path string) ([]byte, error) {
+	f, err := p.OpenFile(path, os.O_RDONLY)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not read file '%s'", path)
+	}
+	data, err := f.ReadAll()
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not read file '%s'", path)
+	}
+	return data, nil
+}
+
+func (p *processContext) WriteFile(path string, data []byte, mode int) error {
+	f, err := p.OpenFile(path, os.CombineModes(os.O_WRONLY, os.O_CREATE, os.O_TRUNC, mode))
+	if err != nil {
+		return errors.Wrapf(err, "could not write file '%s'", path)
+	}
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		return errors.Wrapf(err, "could not write file '%s'", path)
+	}
+	if err := f.Close(); err != nil {
+		return errors.Wrapf(err, "could not write file '%s'", path)
+	}
+	return nil
+}
+
+func (p *processContext) Checksum(path string) ([]byte, error) {
+	f, err := p.OpenFile(path, os.O_RDONLY)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not checksum file '%s'", path)
+	}
+	sum, err := f.Checksum(sha256.New())
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not checksum file '%s'", path)
+	}
+	return sum, nil
+}
+
+func (p *processContext) WriteFileAtomic(path string, data []byte) error {
+	pathInfo := p.parser().ParsePath(path)
+	if pathInfo.MustBeDir {
+		return errors.Wrapf(fserrors.EInval, "could not write file '%s': path specifies a directory", path)
+	}
+	parentPath := pathInfo.ParentPath
+	if !pathInfo.IsRelative && parentPath == "" {
+		parentPath = p.separator()
+	}
+	f, tempPath, err := p.CreateTemp(parentPath, "."+pathInfo.Entry+".*.tmp")
+	if err != nil {
+		return errors.Wrapf(err, "could not write file '%s'", path)
+	}
+	if err := p.commitTempFile(f, tempPath, path, data); err != nil {
+		// Clean up the temporary file.  The original error is more interesting than any error from
+		// the cleanup, which would only fail if something else already removed the file.
+		_ = p.DeleteFile(tempPath)
+		return errors.Wrapf(err, "could not write file '%s'", path)
+	}
+	return nil
+}
+
+// commitTempFile writes data to f, syncs it, and then renames it from tempPath to path
+func (p *processContext) commitTempFile(f file.File, tempPath, path string, data []byte) error {
+	if err := f.TruncateAndWriteAll(data); err != nil {
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		return err
+	}
+	return p.Rename(tempPath, path)
+}
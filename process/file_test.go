@@ -1,11 +1,17 @@
 package process_test
 
 import (
+	"bytes"
 	"io"
+	"sync"
+	"sync/atomic"
+	"testing"
 
 	"github.com/manderson5192/memfs/directory"
+	"github.com/manderson5192/memfs/filesys"
 	"github.com/manderson5192/memfs/fserrors"
 	"github.com/manderson5192/memfs/os"
+	"github.com/manderson5192/memfs/process"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -153,3 +159,342 @@ func (s *ProcessTestSuite) TestOpenFileCreateExclusiveFileExists() {
 	_, err := s.p.OpenFile("/a/foobar_file", os.O_RDWR|os.O_CREATE|os.O_EXCL)
 	assert.ErrorIs(s.T(), err, fserrors.EExist)
 }
+
+func (s *ProcessTestSuite) TestCreateExclusiveFileDNE() {
+	f, created, err := s.p.CreateExclusive("/a/does_not_exist.txt")
+	assert.Nil(s.T(), err)
+	assert.True(s.T(), created)
+	assert.Equal(s.T(), 0, f.Size())
+}
+
+func (s *ProcessTestSuite) TestCreateExclusiveFileExists() {
+	f, created, err := s.p.CreateExclusive("/a/foobar_file")
+	assert.Nil(s.T(), err)
+	assert.False(s.T(), created)
+	data, err := f.ReadAll()
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), "hello!", string(data))
+}
+
+func (s *ProcessTestSuite) TestCreateExclusiveOnDirectory() {
+	_, created, err := s.p.CreateExclusive("/a/b")
+	assert.False(s.T(), created)
+	assert.ErrorIs(s.T(), err, fserrors.EIsDir)
+}
+
+func (s *ProcessTestSuite) TestCreateExclusiveConcurrent() {
+	var wg sync.WaitGroup
+	var numCreated int32
+	for idx := 0; idx < 50; idx++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, created, err := s.p.CreateExclusive("/a/contended")
+			assert.Nil(s.T(), err)
+			if created {
+				atomic.AddInt32(&numCreated, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(s.T(), int32(1), numCreated, "exactly one caller creates the file")
+}
+
+func (s *ProcessTestSuite) TestOpenFileWithLimit() {
+	f, err := s.p.OpenFileWithLimit("/a/foobar_file", os.O_RDWR, 10)
+	assert.Nil(s.T(), err)
+
+	// A write that fits entirely below the limit succeeds
+	_, err = f.Seek(0, io.SeekEnd)
+	assert.Nil(s.T(), err)
+	n, err := f.Write([]byte("!!"))
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), 2, n)
+
+	// A write that crosses the limit is cut short
+	n, err = f.Write([]byte("abcdef"))
+	assert.ErrorIs(s.T(), err, fserrors.ENoSpace)
+	assert.Equal(s.T(), 2, n)
+	offset, err := f.Seek(0, io.SeekCurrent)
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), int64(10), offset)
+
+	// Writes at or beyond the limit write nothing
+	n, err = f.Write([]byte("x"))
+	assert.ErrorIs(s.T(), err, fserrors.ENoSpace)
+	assert.Equal(s.T(), 0, n)
+	n, err = f.WriteAt([]byte("x"), 100)
+	assert.ErrorIs(s.T(), err, fserrors.ENoSpace)
+	assert.Equal(s.T(), 0, n)
+	assert.ErrorIs(s.T(), f.TruncateAndWriteAll([]byte("0123456789a")), fserrors.ENoSpace)
+
+	data, err := f.ReadAll()
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), "hello!!!ab", string(data))
+
+	// Other handles to the same file are unaffected by the limit
+	other, err := s.p.OpenFile("/a/foobar_file", os.O_RDWR)
+	assert.Nil(s.T(), err)
+	n, err = other.WriteAt([]byte("cdef"), 10)
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), 4, n)
+}
+
+func (s *ProcessTestSuite) TestOpenRingFile() {
+	const maxBytes = 16
+	f, err := s.p.OpenRingFile("/a/log", maxBytes)
+	assert.Nil(s.T(), err)
+
+	// Write 3*maxBytes bytes, one line at a time
+	var written bytes.Buffer
+	for i := 0; i < 3*maxBytes/4; i++ {
+		line := []byte{byte('a' + i), byte('a' + i), byte('a' + i), '\n'}
+		written.Write(line)
+		n, err := f.Write(line)
+		assert.Nil(s.T(), err)
+		assert.Equal(s.T(), len(line), n)
+		assert.LessOrEqual(s.T(), f.Size(), maxBytes)
+	}
+	assert.Equal(s.T(), 3*maxBytes, written.Len())
+
+	// Only the last maxBytes bytes remain, in order
+	assert.Equal(s.T(), maxBytes, f.Size())
+	data, err := s.p.ReadFile("/a/log")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), written.Bytes()[2*maxBytes:], data)
+
+	// Positional writes and truncation are rejected
+	_, err = f.WriteAt([]byte("x"), 0)
+	assert.ErrorIs(s.T(), err, fserrors.EInval)
+	assert.ErrorIs(s.T(), f.TruncateAndWriteAll([]byte("x")), fserrors.EInval)
+
+	// Reopening with a smaller size evicts the oldest bytes immediately
+	f, err = s.p.OpenRingFile("/a/log", 4)
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), 4, f.Size())
+	data, err = s.p.ReadFile("/a/log")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), written.Bytes()[3*maxBytes-4:], data)
+
+	_, err = s.p.OpenRingFile("/a/log", 0)
+	assert.ErrorIs(s.T(), err, fserrors.EInval)
+	_, err = s.p.OpenRingFile("/a/b", maxBytes)
+	assert.ErrorIs(s.T(), err, fserrors.EIsDir)
+}
+
+func (s *ProcessTestSuite) TestReadFile() {
+	data, err := s.p.ReadFile("/a/foobar_file")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []byte("hello!"), data)
+
+	_, err = s.p.ReadFile("/a/b")
+	assert.ErrorIs(s.T(), err, fserrors.EIsDir)
+
+	_, err = s.p.ReadFile("/a/noexist")
+	assert.ErrorIs(s.T(), err, fserrors.ENoEnt)
+}
+
+func (s *ProcessTestSuite) TestWriteFile() {
+	// Overwrite an existing file
+	assert.Nil(s.T(), s.p.WriteFile("/a/foobar_file", []byte("bye"), 0))
+	data, err := s.p.ReadFile("/a/foobar_file")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []byte("bye"), data)
+
+	// Create a new file
+	assert.Nil(s.T(), s.p.WriteFile("/a/b/new_file", []byte("new data"), 0))
+	data, err = s.p.ReadFile("/a/b/new_file")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []byte("new data"), data)
+
+	// Additional flags are honored
+	err = s.p.WriteFile("/a/b/new_file", []byte("other data"), os.O_EXCL)
+	assert.ErrorIs(s.T(), err, fserrors.EExist)
+
+	err = s.p.WriteFile("/a/b", []byte("data"), 0)
+	assert.ErrorIs(s.T(), err, fserrors.EIsDir)
+}
+
+func (s *ProcessTestSuite) TestChecksum() {
+	assert.Nil(s.T(), s.p.WriteFile("/a/copy", []byte("hello!"), 0))
+	sum, err := s.p.Checksum("/a/foobar_file")
+	assert.Nil(s.T(), err)
+	copySum, err := s.p.Checksum("/a/copy")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), sum, copySum, "identical contents have identical sums")
+
+	// Changing one byte changes the sum
+	f, err := s.p.OpenFile("/a/copy", os.O_RDWR)
+	assert.Nil(s.T(), err)
+	_, err = f.WriteAt([]byte("?"), 5)
+	assert.Nil(s.T(), err)
+	copySum, err = s.p.Checksum("/a/copy")
+	assert.Nil(s.T(), err)
+	assert.NotEqual(s.T(), sum, copySum)
+
+	_, err = s.p.Checksum("/a/b")
+	assert.ErrorIs(s.T(), err, fserrors.EIsDir)
+}
+
+func (s *ProcessTestSuite) TestWriteFileAtomic() {
+	assert.Nil(s.T(), s.p.WriteFileAtomic("/a/foobar_file", []byte("goodbye!")))
+	data, err := s.p.ReadFile("/a/foobar_file")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []byte("goodbye!"), data)
+
+	// Create a new file with a relative path
+	assert.Nil(s.T(), s.p.ChangeDirectory("/a/b"))
+	assert.Nil(s.T(), s.p.WriteFileAtomic("new_file", []byte("new data")))
+	data, err = s.p.ReadFile("/a/b/new_file")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []byte("new data"), data)
+
+	// No temporary files are left behind
+	entries, err := s.p.ListDirectorySorted("/a")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []directory.DirectoryEntry{
+		{Name: "b", Type: directory.DirectoryType},
+		{Name: "foobar_file", Type: directory.FileType},
+		{Name: "zzz", Type: directory.DirectoryType},
+	}, entries)
+}
+
+func (s *ProcessTestSuite) TestWriteFileAtomicCleansUpOnFailure() {
+	// A file cannot replace a directory
+	err := s.p.WriteFileAtomic("/a/b", []byte("data"))
+	assert.ErrorIs(s.T(), err, fserrors.EIsDir)
+	entries, err := s.p.ListDirectorySorted("/a")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []directory.DirectoryEntry{
+		{Name: "b", Type: directory.DirectoryType},
+		{Name: "foobar_file", Type: directory.FileType},
+		{Name: "zzz", Type: directory.DirectoryType},
+	}, entries)
+
+	err = s.p.WriteFileAtomic("/a/foobar_file/", []byte("data"))
+	assert.ErrorIs(s.T(), err, fserrors.EInval)
+}
+
+func (s *ProcessTestSuite) TestWriteFileAtomicConcurrentReaders() {
+	old := bytes.Repeat([]byte("o"), 4096)
+	updated := bytes.Repeat([]byte("n"), 8192)
+	assert.Nil(s.T(), s.p.WriteFile("/a/foobar_file", old, 0))
+
+	var done int32
+	wg := sync.WaitGroup{}
+	for reader := 0; reader < 4; reader++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for atomic.LoadInt32(&done) == 0 {
+				data, err := s.p.ReadFile("/a/foobar_file")
+				assert.Nil(s.T(), err)
+				if !bytes.Equal(data, old) && !bytes.Equal(data, updated) {
+					assert.Fail(s.T(), "reader observed a partially-written file", "%d bytes", len(data))
+					return
+				}
+			}
+		}()
+	}
+	for i := 0; i < 100; i++ {
+		contents := updated
+		if i%2 == 1 {
+			contents = old
+		}
+		assert.Nil(s.T(), s.p.WriteFileAtomic("/a/foobar_file", contents))
+	}
+	atomic.StoreInt32(&done, 1)
+	wg.Wait()
+}
+
+func (s *ProcessTestSuite) TestOpenFileBuffered() {
+	f, err := s.p.OpenFileBuffered("/a/buffered", os.CombineModes(os.O_RDWR, os.O_CREATE), 1024)
+	assert.Nil(s.T(), err)
+	other, err := s.p.OpenFile("/a/buffered", os.O_RDONLY)
+	assert.Nil(s.T(), err)
+	for _, word := range []string{"many ", "small ", "writes"} {
+		_, err := f.Write([]byte(word))
+		assert.Nil(s.T(), err)
+	}
+
+	// The writing handle (and its duplicates) see the buffered writes, but other handles don't
+	data, err := f.ReadAll()
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), "many small writes", string(data))
+	data, err = f.Dup().ReadAll()
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), "many small writes", string(data))
+	data, err = other.ReadAll()
+	assert.Nil(s.T(), err)
+	assert.Empty(s.T(), data)
+
+	assert.Nil(s.T(), f.Sync())
+	data, err = other.ReadAll()
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), "many small writes", string(data))
+
+	// Close flushes too
+	_, err = f.Write([]byte("!"))
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), 17, other.Size())
+	assert.Nil(s.T(), f.Close())
+	data, err = s.p.ReadFile("/a/buffered")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), "many small writes!", string(data))
+
+	_, err = s.p.OpenFileBuffered("/a/buffered", os.O_RDWR, 0)
+	assert.ErrorIs(s.T(), err, fserrors.EInval)
+	_, err = s.p.OpenFileBuffered("/a/noexist", os.O_RDWR, 16)
+	assert.ErrorIs(s.T(), err, fserrors.ENoEnt)
+}
+
+func (s *ProcessTestSuite) TestOpenAt() {
+	dir, err := s.p.OpenDirectory("/a")
+	assert.Nil(s.T(), err)
+	defer dir.Close()
+	viaHandle, err := s.p.OpenAt(dir, "foobar_file", os.O_RDONLY)
+	assert.Nil(s.T(), err)
+	viaPath, err := s.p.OpenFile("/a/foobar_file", os.O_RDONLY)
+	assert.Nil(s.T(), err)
+	assert.True(s.T(), viaHandle.Equals(viaPath))
+
+	// The handle keeps working after the directory is renamed
+	assert.Nil(s.T(), s.p.Rename("/a", "/renamed"))
+	f, err := s.p.OpenAt(dir, "b/../foobar_file", os.O_RDWR)
+	assert.Nil(s.T(), err)
+	assert.True(s.T(), f.Equals(viaPath))
+	created, err := s.p.OpenAt(dir, "b/new_file", os.CombineModes(os.O_RDWR, os.O_CREATE))
+	assert.Nil(s.T(), err)
+	viaPath, err = s.p.OpenFile("/renamed/b/new_file", os.O_RDONLY)
+	assert.Nil(s.T(), err)
+	assert.True(s.T(), created.Equals(viaPath))
+
+	// Absolute paths ignore the handle, and a nil handle refers to the working directory
+	assert.Nil(s.T(), s.p.ChangeDirectory("/renamed/b"))
+	f, err = s.p.OpenAt(dir, "/renamed/foobar_file", os.O_RDONLY)
+	assert.Nil(s.T(), err)
+	assert.True(s.T(), f.Equals(viaHandle))
+	f, err = s.p.OpenAt(nil, "new_file", os.O_RDONLY)
+	assert.Nil(s.T(), err)
+	assert.True(s.T(), f.Equals(created))
+
+	_, err = s.p.OpenAt(dir, "noexist", os.O_RDONLY)
+	assert.ErrorIs(s.T(), err, fserrors.ENoEnt)
+}
+
+// BenchmarkCreateDeleteFile measures the cost (and allocations) of a workload that repeatedly
+// creates, writes, and deletes small files
+func BenchmarkCreateDeleteFile(b *testing.B) {
+	p := process.NewProcessFilesystemContext(filesys.NewFileSystem())
+	data := []byte("small file")
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		if err := p.WriteFile("/file", data, 0); err != nil {
+			b.Fatal(err)
+		}
+		if err := p.DeleteFile("/file"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -5,15 +5,17 @@ import (
 	"regexp"
 
 	"github.com/manderson5192/memfs/directory"
-	"github.com/manderson5192/memfs/filepath"
 	"github.com/manderson5192/memfs/fserrors"
 	"github.com/pkg/errors"
 )
 
 func (p *processContext) FindAll(subtreePath, name string) ([]string, error) {
+	if paths, ok := p.findAllIndexed(subtreePath, name); ok {
+		return paths, nil
+	}
 	paths := make([]string, 0)
 	walkFunc := func(path string, fileInfo *directory.FileInfo, err error) error {
-		pathInfo := filepath.ParsePath(path)
+		pathInfo := p.parser().ParsePath(path)
 		if pathInfo.Entry == name {
 			paths = append(paths, path)
 		}
@@ -25,6 +27,51 @@ func (p *processContext) FindAll(subtreePath, name string) ([]string, error) {
 	return paths, nil
 }
 
+// findAllIndexed implements FindAll() with the filesystem's index, if it has one (see
+// directory.Directory.FindEntries()).  It returns false if FindAll() must walk the subtree instead.
+func (p *processContext) findAllIndexed(subtreePath, name string) ([]string, bool) {
+	parser := p.parser()
+	if name == parser.SelfEntry || name == parser.ParentEntry || parser.ContainsSeparator(name) {
+		return nil, false
+	}
+	relativePath, baseDir := p.toCleanRelativePathAndBaseDir(subtreePath)
+	dir, err := baseDir.LookupSubdirectory(relativePath)
+	if err != nil {
+		// Let Walk() deal with files and missing paths
+		return nil, false
+	}
+	entries, ok := dir.FindEntries(name)
+	if !ok {
+		return nil, false
+	}
+	paths := make([]string, 0, len(entries)+1)
+	// Like Walk(), visit the subtree's root first
+	if parser.ParsePath(subtreePath).Entry == name {
+		paths = append(paths, subtreePath)
+	}
+	for _, entry := range entries {
+		paths = append(paths, parser.Join(subtreePath, entry))
+	}
+	return paths, true
+}
+
+func (p *processContext) Find(subtreePath string, pred func(path string, info *directory.FileInfo) bool) ([]string, error) {
+	paths := make([]string, 0)
+	walkFunc := func(path string, fileInfo *directory.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if pred(path, fileInfo) {
+			paths = append(paths, path)
+		}
+		return nil
+	}
+	if err := p.Walk(subtreePath, walkFunc); err != nil {
+		return nil, errors.Wrapf(err, "failed to find files and directories under '%s'", subtreePath)
+	}
+	return paths, nil
+}
+
 func (p *processContext) FindFirstMatchingFile(subtreePath string, regex string) (string, error) {
 	matchingPath := ""
 	matchFound := false
@@ -36,7 +83,7 @@ func (p *processContext) FindFirstMatchingFile(subtreePath string, regex string)
 			// Skip everything once our match has been found
 			return SkipDir
 		}
-		pathInfo := filepath.ParsePath(path)
+		pathInfo := p.parser().ParsePath(path)
 		matches, err := regexp.MatchString(regex, pathInfo.Entry)
 		if err != nil {
 			// Propagate regex errors to the return value of Walk()
@@ -1,6 +1,9 @@
 package process_test
 
 import (
+	"strings"
+
+	"github.com/manderson5192/memfs/directory"
 	"github.com/manderson5192/memfs/fserrors"
 	"github.com/stretchr/testify/assert"
 )
@@ -11,6 +14,38 @@ func (s *ProcessTestSuite) TestFindAll() {
 	assert.Equal(s.T(), []string{"a", "a/b/a"}, paths)
 }
 
+func (s *ProcessTestSuite) TestFindLargeFiles() {
+	assert.Nil(s.T(), s.p.WriteFile("/a/b/big", []byte(strings.Repeat("x", 100)), 0))
+	assert.Nil(s.T(), s.p.WriteFile("/a/b/c/bigger", []byte(strings.Repeat("x", 200)), 0))
+	assert.Nil(s.T(), s.p.WriteFile("/a/b/c/small", []byte("x"), 0))
+	paths, err := s.p.Find("/a", func(path string, info *directory.FileInfo) bool {
+		return info.Type == directory.FileType && info.Size >= 100
+	})
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []string{"/a/b/big", "/a/b/c/bigger"}, paths)
+}
+
+func (s *ProcessTestSuite) TestFindDirectories() {
+	paths, err := s.p.Find("a", func(path string, info *directory.FileInfo) bool {
+		return info.Type == directory.DirectoryType
+	})
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []string{"a", "a/b", "a/b/a", "a/b/c", "a/zzz"}, paths)
+
+	// Nothing matches
+	paths, err = s.p.Find("/a", func(string, *directory.FileInfo) bool { return false })
+	assert.Nil(s.T(), err)
+	assert.Empty(s.T(), paths)
+}
+
+func (s *ProcessTestSuite) TestFindInvalidPath() {
+	paths, err := s.p.Find("/path/does/not/exist", func(string, *directory.FileInfo) bool {
+		return true
+	})
+	assert.ErrorIs(s.T(), err, fserrors.ENoEnt)
+	assert.Nil(s.T(), paths)
+}
+
 func (s *ProcessTestSuite) TestFindFirstMatchingFile() {
 	path, err := s.p.FindFirstMatchingFile("/", "foo.*")
 	assert.Nil(s.T(), err)
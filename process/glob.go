@@ -0,0 +1,84 @@
+package process
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/manderson5192/memfs/filepath"
+	"github.com/pkg/errors"
+)
+
+func (p *processContext) Glob(pattern string) ([]string, error) {
+	parser := p.parser()
+	// Match() treats backslashes as escapes, so validate the pattern in the default syntax
+	if _, err := filepath.Match(parser.ToSlash(pattern), ""); err != nil {
+		return nil, errors.Wrapf(err, "malformed pattern '%s'", pattern)
+	}
+	components := make([]string, 0)
+	for _, component := range strings.Split(parser.Clean(pattern), p.separator()) {
+		if component != "" {
+			components = append(components, component)
+		}
+	}
+	matches := []string{""}
+	if parser.IsAbsolutePath(pattern) {
+		matches = []string{p.separator()}
+	} else if len(components) == 0 {
+		return []string{}, nil
+	}
+	for _, component := range components {
+		expanded := make([]string, 0, len(matches))
+		for _, match := range matches {
+			if !hasGlobMeta(component) {
+				// Existence is checked once every component has been expanded
+				expanded = append(expanded, p.globChild(match, component))
+				continue
+			}
+			dir := match
+			if dir == "" {
+				dir = parser.SelfEntry
+			}
+			entries, err := p.ListDirectorySorted(dir)
+			if err != nil {
+				continue
+			}
+			for _, entry := range entries {
+				// The pattern was validated above, so Match() can't fail
+				if matched, _ := filepath.Match(component, entry.Name); matched {
+					expanded = append(expanded, p.globChild(match, entry.Name))
+				}
+			}
+		}
+		matches = expanded
+	}
+	mustBeDir := strings.HasSuffix(pattern, p.separator())
+	existing := make([]string, 0, len(matches))
+	for _, match := range matches {
+		info, err := p.Stat(match)
+		if err != nil || (mustBeDir && !info.IsDir()) {
+			continue
+		}
+		existing = append(existing, match)
+	}
+	sort.Strings(existing)
+	return existing, nil
+}
+
+// hasGlobMeta returns true if component contains any of the special characters recognized by
+// filepath.Match()
+func hasGlobMeta(component string) bool {
+	return strings.ContainsAny(component, `*?[\`)
+}
+
+// globChild returns the path of the entry name within the directory at path, which is either "" (the
+// working directory), the root, or a path without a trailing separator
+func (p *processContext) globChild(path, name string) string {
+	switch path {
+	case "":
+		return name
+	case p.separator():
+		return p.separator() + name
+	default:
+		return path + p.separator() + name
+	}
+}
@@ -0,0 +1,71 @@
+package process_test
+
+import (
+	"github.com/manderson5192/memfs/filepath"
+	"github.com/stretchr/testify/assert"
+)
+
+func (s *ProcessTestSuite) TestGlobAbsolute() {
+	matches, err := s.p.Glob("/a/*")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []string{"/a/b", "/a/foobar_file", "/a/zzz"}, matches)
+
+	matches, err = s.p.Glob("/a/foo*")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []string{"/a/foobar_file"}, matches)
+
+	// Non-wildcard components must exist
+	matches, err = s.p.Glob("/a/b")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []string{"/a/b"}, matches)
+	matches, err = s.p.Glob("/a/noexist")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []string{}, matches)
+}
+
+func (s *ProcessTestSuite) TestGlobMultipleComponents() {
+	matches, err := s.p.Glob("/*/b/?")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []string{"/a/b/a", "/a/b/c"}, matches)
+
+	matches, err = s.p.Glob("/a/*/[a-b]")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []string{"/a/b/a"}, matches)
+
+	// A wildcard never matches across a path separator
+	matches, err = s.p.Glob("/a/*/c/*")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []string{}, matches)
+	matches, err = s.p.Glob("/*")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []string{"/a"}, matches)
+
+	// A trailing separator matches only directories
+	matches, err = s.p.Glob("/a/*/")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []string{"/a/b", "/a/zzz"}, matches)
+}
+
+func (s *ProcessTestSuite) TestGlobRelative() {
+	assert.Nil(s.T(), s.p.ChangeDirectory("/a"))
+	matches, err := s.p.Glob("b/*")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []string{"b/a", "b/c"}, matches)
+
+	matches, err = s.p.Glob("*")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []string{"b", "foobar_file", "zzz"}, matches)
+
+	matches, err = s.p.Glob("../a/z*")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []string{"../a/zzz"}, matches)
+}
+
+func (s *ProcessTestSuite) TestGlobMalformedPattern() {
+	_, err := s.p.Glob("/a/[b")
+	assert.ErrorIs(s.T(), err, filepath.ErrBadPattern)
+
+	matches, err := s.p.Glob("/nothing/*")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []string{}, matches)
+}
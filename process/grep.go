@@ -0,0 +1,75 @@
+package process
+
+import (
+	"bytes"
+	"regexp"
+
+	"github.com/manderson5192/memfs/directory"
+	"github.com/pkg/errors"
+)
+
+// Match is a line of a file that matched the pattern passed to Grep()
+type Match struct {
+	// Path is the path of the file, joined onto the subtree's path
+	Path string
+	// Line is the 1-based number of the matching line within the file
+	Line int
+	// Text is the matching line, without its trailing newline
+	Text string
+}
+
+// GrepOptions configures GrepWithOptions().  The zero value configures the behavior of Grep().
+type GrepOptions struct {
+	// IncludeBinary makes binary files (files that contain a NUL byte) searchable.  They are skipped
+	// by default.
+	IncludeBinary bool
+	// OnReadError, if non-nil, is called with the path of each file that is skipped because it
+	// can't be read, and the error that reading it returned
+	OnReadError func(path string, err error)
+}
+
+func (p *processContext) Grep(subtreePath, pattern string) ([]Match, error) {
+	return p.GrepWithOptions(subtreePath, pattern, GrepOptions{})
+}
+
+func (p *processContext) GrepWithOptions(subtreePath, pattern string, opts GrepOptions) ([]Match, error) {
+	regex, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid pattern '%s'", pattern)
+	}
+	matches := make([]Match, 0)
+	walkFunc := func(path string, fileInfo *directory.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if fileInfo.Type != directory.FileType {
+			return nil
+		}
+		data, err := p.ReadFile(path)
+		if err != nil {
+			// The file may have been removed since it was listed, or it may be write-only
+			if opts.OnReadError != nil {
+				opts.OnReadError(path, err)
+			}
+			return nil
+		}
+		if !opts.IncludeBinary && bytes.IndexByte(data, 0) >= 0 {
+			return nil
+		}
+		lines := bytes.Split(data, []byte("\n"))
+		if len(lines[len(lines)-1]) == 0 {
+			// The file ends with a newline (or is empty), so there is no final line to search
+			lines = lines[:len(lines)-1]
+		}
+		for idx, line := range lines {
+			if regex.Match(line) {
+				matches = append(matches, Match{Path: path, Line: idx + 1, Text: string(line)})
+			}
+		}
+		return nil
+	}
+	if err := p.Walk(subtreePath, walkFunc); err != nil {
+		return nil, errors.Wrapf(err, "failed to search '%s' for '%s'", subtreePath, pattern)
+	}
+	return matches, nil
+}
@@ -0,0 +1,59 @@
+package process_test
+
+import (
+	"github.com/manderson5192/memfs/filesys"
+	"github.com/manderson5192/memfs/fserrors"
+	"github.com/manderson5192/memfs/process"
+	"github.com/stretchr/testify/assert"
+)
+
+func (s *ProcessTestSuite) TestGrep() {
+	assert.Nil(s.T(), s.p.WriteFile("/a/b/notes", []byte("TODO: one\ndone\n  TODO: two\n"), 0))
+	assert.Nil(s.T(), s.p.WriteFile("/a/b/c/code", []byte("x := 1 // TODO: three\nreturn x"), 0))
+	assert.Nil(s.T(), s.p.WriteFile("/a/b/c/binary", []byte("TODO: \x00binary"), 0))
+
+	matches, err := s.p.Grep("/a/b", "TODO: [a-z]+$")
+	assert.Nil(s.T(), err)
+	assert.ElementsMatch(s.T(), []process.Match{
+		{Path: "/a/b/notes", Line: 1, Text: "TODO: one"},
+		{Path: "/a/b/notes", Line: 3, Text: "  TODO: two"},
+		{Path: "/a/b/c/code", Line: 1, Text: "x := 1 // TODO: three"},
+	}, matches)
+
+	// Binary files are only searched on request
+	matches, err = s.p.GrepWithOptions("/a/b/c", "binary", process.GrepOptions{IncludeBinary: true})
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []process.Match{
+		{Path: "/a/b/c/binary", Line: 1, Text: "TODO: \x00binary"},
+	}, matches)
+
+	// Paths are relative if the subtree's path is
+	assert.Nil(s.T(), s.p.ChangeDirectory("/a/b/c"))
+	matches, err = s.p.Grep(".", "^return")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []process.Match{{Path: "code", Line: 2, Text: "return x"}}, matches)
+}
+
+func (s *ProcessTestSuite) TestGrepErrors() {
+	_, err := s.p.Grep("/", "(unclosed")
+	assert.NotNil(s.T(), err)
+	_, err = s.p.Grep("/noexist", "x")
+	assert.ErrorIs(s.T(), err, fserrors.ENoEnt)
+
+	// Files that can't be read are skipped.  (The first open, which creates the file, succeeds.)
+	p := process.NewProcessFilesystemContext(filesys.NewFileSystemWithFaults(filesys.FaultConfig{
+		Faults: []filesys.Fault{{Op: filesys.FaultOpen, Path: "/unreadable", AfterN: 1}},
+	}))
+	assert.Nil(s.T(), p.WriteFile("/readable", []byte("match"), 0))
+	assert.Nil(s.T(), p.WriteFile("/unreadable", []byte("match"), 0))
+	skipped := []string{}
+	matches, err := p.GrepWithOptions("/", "match", process.GrepOptions{
+		OnReadError: func(path string, err error) {
+			assert.ErrorIs(s.T(), err, fserrors.EIO)
+			skipped = append(skipped, path)
+		},
+	})
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []process.Match{{Path: "/readable", Line: 1, Text: "match"}}, matches)
+	assert.Equal(s.T(), []string{"/unreadable"}, skipped)
+}
@@ -0,0 +1,199 @@
+package process_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/manderson5192/memfs/filesys"
+	"github.com/manderson5192/memfs/process"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+// IndexedFindTestSuite checks that FindAll() on an indexed filesystem agrees with FindAll() on a
+// filesystem that isn't indexed (which walks the tree) as both filesystems are mutated identically
+type IndexedFindTestSuite struct {
+	suite.Suite
+	indexedFs filesys.FileSystem
+	indexed   process.ProcessFilesystemContext
+	walking   process.ProcessFilesystemContext
+}
+
+func (s *IndexedFindTestSuite) SetupTest() {
+	s.indexedFs = filesys.NewIndexedFileSystem()
+	s.indexed = process.NewProcessFilesystemContext(s.indexedFs)
+	s.walking = process.NewProcessFilesystemContext(filesys.NewFileSystem())
+	s.both(func(p process.ProcessFilesystemContext) error {
+		return p.MakeDirectoryWithAncestors("/a/b/a/target")
+	})
+	s.both(func(p process.ProcessFilesystemContext) error {
+		return p.MakeDirectoryWithAncestors("/a/zzz/target")
+	})
+	s.both(func(p process.ProcessFilesystemContext) error {
+		return p.WriteFile("/a/b/target", []byte("file"), 0)
+	})
+	s.both(func(p process.ProcessFilesystemContext) error {
+		return p.WriteFile("/target", []byte("file"), 0)
+	})
+}
+
+// both makes the same change to both filesystems
+func (s *IndexedFindTestSuite) both(change func(p process.ProcessFilesystemContext) error) {
+	assert.Nil(s.T(), change(s.indexed))
+	assert.Nil(s.T(), change(s.walking))
+}
+
+// assertFindsSame asserts that FindAll() gives the same answer on both filesystems, and returns it
+func (s *IndexedFindTestSuite) assertFindsSame(subtreePath, name string) []string {
+	want, err := s.walking.FindAll(subtreePath, name)
+	assert.Nil(s.T(), err)
+	got, err := s.indexed.FindAll(subtreePath, name)
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), want, got, "FindAll(%q, %q)", subtreePath, name)
+	return got
+}
+
+func (s *IndexedFindTestSuite) TestFindsInSubtrees() {
+	assert.Equal(s.T(), []string{"/a/b/a/target", "/a/b/target", "/a/zzz/target", "/target"},
+		s.assertFindsSame("/", "target"))
+	assert.Equal(s.T(), []string{"/a", "/a/b/a"}, s.assertFindsSame("/a", "a"))
+	s.assertFindsSame("/a/b/", "target")
+	s.assertFindsSame("/a/b/a/target", "target")
+	s.assertFindsSame("/a/b/target", "target")
+	s.assertFindsSame("/missing", "target")
+	s.assertFindsSame("/", "missing")
+	s.assertFindsSame("/", ".")
+	s.assertFindsSame("/", "")
+
+	// Relative paths are relative to the working directory
+	s.both(func(p process.ProcessFilesystemContext) error { return p.ChangeDirectory("/a/b") })
+	assert.Equal(s.T(), []string{"a/target", "target"}, s.assertFindsSame(".", "target"))
+	s.assertFindsSame("..", "target")
+	s.assertFindsSame("a/../..", "a")
+}
+
+func (s *IndexedFindTestSuite) TestStaysConsistentAcrossMutations() {
+	check := func() {
+		s.assertFindsSame("/", "target")
+		s.assertFindsSame("/", "moved")
+		s.assertFindsSame("/a", "a")
+	}
+	// Renaming a directory moves its whole subtree
+	s.both(func(p process.ProcessFilesystemContext) error { return p.Rename("/a/b", "/a/zzz/moved") })
+	check()
+	s.both(func(p process.ProcessFilesystemContext) error {
+		return p.Rename("/a/zzz/moved/target", "/a/zzz/target/target")
+	})
+	check()
+	// Replacing a file
+	s.both(func(p process.ProcessFilesystemContext) error { return p.WriteFile("/x", []byte{}, 0) })
+	s.both(func(p process.ProcessFilesystemContext) error { return p.Rename("/x", "/target") })
+	check()
+	s.both(func(p process.ProcessFilesystemContext) error {
+		return p.RenameExchange("/a/zzz/moved/a", "/target")
+	})
+	check()
+	s.both(func(p process.ProcessFilesystemContext) error { return p.DeleteFile("/a/zzz/moved/a") })
+	check()
+	s.both(func(p process.ProcessFilesystemContext) error { return p.EmptyDir("/a/zzz") })
+	check()
+	s.both(func(p process.ProcessFilesystemContext) error { return p.RemoveDirectory("/target/target") })
+	check()
+}
+
+func (s *IndexedFindTestSuite) TestIgnoresRemovedDirectoriesWithOpenHandles() {
+	dir, err := s.indexed.OpenDirectory("/a/b/a")
+	assert.Nil(s.T(), err)
+	walkingDir, err := s.walking.OpenDirectory("/a/b/a")
+	assert.Nil(s.T(), err)
+	s.both(func(p process.ProcessFilesystemContext) error {
+		return p.Rename("/a/zzz", "/a/b/a/target/zzz")
+	})
+	assert.Nil(s.T(), s.indexedFs.RootDirectory().RmdirOpen("a/b/a"))
+	assert.Nil(s.T(), s.walking.RemoveOpenDirectory("/a/b/a"))
+	s.assertFindsSame("/", "target")
+
+	// The removed directory's entries can still be found through its handle
+	found, ok := dir.FindEntries("target")
+	assert.True(s.T(), ok)
+	assert.Equal(s.T(), []string{"target", "target/zzz/target"}, found)
+	assert.Nil(s.T(), dir.Close())
+	assert.Nil(s.T(), walkingDir.Close())
+	s.assertFindsSame("/", "target")
+}
+
+func (s *IndexedFindTestSuite) TestSnapshots() {
+	snapshot, err := filesys.TakeSnapshot(s.indexedFs)
+	assert.Nil(s.T(), err)
+	s.both(func(p process.ProcessFilesystemContext) error { return p.EmptyDir("/") })
+	s.assertFindsSame("/", "target")
+
+	// Restoring the snapshot restores the index
+	assert.Nil(s.T(), snapshot.Restore(s.indexedFs))
+	found, err := s.indexed.FindAll("/", "target")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []string{"/a/b/a/target", "/a/b/target", "/a/zzz/target", "/target"}, found)
+
+	// So does forking it
+	fork := process.NewProcessFilesystemContext(snapshot.Fork())
+	assert.Nil(s.T(), fork.DeleteFile("/target"))
+	found, err = fork.FindAll("/", "target")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []string{"/a/b/a/target", "/a/b/target", "/a/zzz/target"}, found)
+}
+
+func (s *IndexedFindTestSuite) TestBindMounts() {
+	assert.Nil(s.T(), filesys.BindMount(s.indexedFs, "/a/b", "/a/zzz/target"))
+	found, err := s.indexed.FindAll("/", "target")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []string{
+		"/a/b/a/target", "/a/b/target", "/a/zzz/target", "/a/zzz/target/a/target",
+		"/a/zzz/target/target", "/target",
+	}, found)
+}
+
+func TestIndexedFindTestSuite(t *testing.T) {
+	suite.Run(t, new(IndexedFindTestSuite))
+}
+
+// newBenchmarkTree returns a process for a filesystem with 10,000 entries: 100 directories of 99
+// files each, where every directory has one file called "needle"
+func newBenchmarkTree(b *testing.B, fs filesys.FileSystem) process.ProcessFilesystemContext {
+	p := process.NewProcessFilesystemContext(fs)
+	for dirNum := 0; dirNum < 100; dirNum++ {
+		dir := fmt.Sprintf("/dir%d", dirNum)
+		if err := p.MakeDirectory(dir); err != nil {
+			b.Fatal(err)
+		}
+		for fileNum := 0; fileNum < 99; fileNum++ {
+			name := fmt.Sprintf("%s/file%d", dir, fileNum)
+			if fileNum == 0 {
+				name = dir + "/needle"
+			}
+			if err := p.WriteFile(name, []byte{}, 0); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+	return p
+}
+
+func BenchmarkFindAllWalking(b *testing.B) {
+	p := newBenchmarkTree(b, filesys.NewFileSystem())
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		if _, err := p.FindAll("/", "needle"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkFindAllIndexed(b *testing.B) {
+	p := newBenchmarkTree(b, filesys.NewIndexedFileSystem())
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		if _, err := p.FindAll("/", "needle"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package process
+
+import (
+	"github.com/manderson5192/memfs/directory"
+	"github.com/manderson5192/memfs/fserrors"
+	"github.com/pkg/errors"
+)
+
+// MergePolicy decides what MergeMove() does when an entry in the source directory collides with an
+// entry in the destination directory and they aren't both directories
+type MergePolicy int
+
+const (
+	// MergeError stops the merge with EEXIST
+	MergeError MergePolicy = iota
+	// MergeSkip leaves both entries where they are, like `mv -n`
+	MergeSkip
+	// MergeOverwrite replaces the destination's entry with the source's, like Rename()
+	MergeOverwrite
+)
+
+func (p *processContext) MergeMove(srcDir, dstDir string, policy MergePolicy) error {
+	if policy != MergeError && policy != MergeSkip && policy != MergeOverwrite {
+		return errors.Wrapf(fserrors.EInval, "invalid merge policy %d", policy)
+	}
+	src, err := p.lookupDirectory(srcDir)
+	if err != nil {
+		return errors.Wrapf(err, "could not merge '%s' into '%s'", srcDir, dstDir)
+	}
+	dst, err := p.lookupDirectory(dstDir)
+	if err != nil {
+		return errors.Wrapf(err, "could not merge '%s' into '%s'", srcDir, dstDir)
+	}
+	// Merging a directory into itself or its own subtree would never finish
+	for ancestor := dst; ; {
+		if ancestor.Equals(src) {
+			return errors.Wrapf(fserrors.EInval, "could not merge '%s' into itself or its subtree '%s'", srcDir, dstDir)
+		}
+		parent, err := ancestor.LookupSubdirectory(p.parser().ParentEntry)
+		if err != nil {
+			return errors.Wrapf(err, "could not merge '%s' into '%s'", srcDir, dstDir)
+		}
+		if parent.Equals(ancestor) {
+			break
+		}
+		ancestor = parent
+	}
+	if _, err := p.mergeMove(srcDir, dstDir, policy); err != nil {
+		return errors.Wrapf(err, "could not merge '%s' into '%s'", srcDir, dstDir)
+	}
+	return nil
+}
+
+// lookupDirectory returns the Directory at path
+func (p *processContext) lookupDirectory(path string) (directory.Directory, error) {
+	relativePath, baseDir := p.toCleanRelativePathAndBaseDir(path)
+	return baseDir.LookupSubdirectory(relativePath)
+}
+
+// mergeMove moves the entries of the directory srcDir into the directory dstDir, recursing into the
+// subdirectories that both contain, and then removes srcDir.  It returns false if srcDir was left in
+// place because policy skipped some of its entries.
+func (p *processContext) mergeMove(srcDir, dstDir string, policy MergePolicy) (bool, error) {
+	entries, err := p.ListDirectorySorted(srcDir)
+	if err != nil {
+		return false, err
+	}
+	skipped := false
+	for _, entry := range entries {
+		srcPath := p.parser().Join(srcDir, entry.Name)
+		dstPath := p.parser().Join(dstDir, entry.Name)
+		dstInfo, err := p.Stat(dstPath)
+		if errors.Is(err, fserrors.ENoEnt) {
+			// Don't replace an entry that was created at dstPath since it was checked
+			if err := p.RenameNoReplace(srcPath, dstPath); err != nil {
+				return false, err
+			}
+			continue
+		} else if err != nil {
+			return false, err
+		}
+		if entry.Type == directory.DirectoryType && dstInfo.IsDir() {
+			removed, err := p.mergeMove(srcPath, dstPath, policy)
+			if err != nil {
+				return false, err
+			}
+			skipped = skipped || !removed
+			continue
+		}
+		switch policy {
+		case MergeSkip:
+			skipped = true
+		case MergeOverwrite:
+			if err := p.Rename(srcPath, dstPath); err != nil {
+				return false, err
+			}
+		default:
+			return false, errors.Wrapf(fserrors.EExist, "'%s' collides with '%s'", srcPath, dstPath)
+		}
+	}
+	if skipped {
+		return false, nil
+	}
+	if err := p.RemoveDirectory(srcDir); err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,76 @@
+package process_test
+
+import (
+	"github.com/manderson5192/memfs/fserrors"
+	"github.com/manderson5192/memfs/process"
+	"github.com/stretchr/testify/assert"
+)
+
+// createMergeTrees creates two partially-overlapping trees: /src and /dst both contain the file
+// common and the directory shared, which both contain the file both
+func (s *ProcessTestSuite) createMergeTrees() {
+	assert.Nil(s.T(), s.p.MakeDirectoryWithAncestors("/src/shared/src_only_dir"))
+	assert.Nil(s.T(), s.p.MakeDirectoryWithAncestors("/dst/shared"))
+	s.createFiles(
+		"/src/common", "/src/src_only", "/src/shared/both", "/src/shared/src_only_dir/deep",
+		"/dst/common", "/dst/dst_only", "/dst/shared/both",
+	)
+}
+
+func (s *ProcessTestSuite) TestMergeMoveOverwrite() {
+	s.createMergeTrees()
+	assert.Nil(s.T(), s.p.MergeMove("/src", "/dst", process.MergeOverwrite))
+	assert.False(s.T(), s.p.Exists("/src"))
+	assert.Equal(s.T(), []string{"common", "dst_only", "shared", "src_only"}, s.listNames("/dst"))
+	assert.Equal(s.T(), []string{"both", "src_only_dir"}, s.listNames("/dst/shared"))
+	s.assertFileContents("/dst/common", "/src/common")
+	s.assertFileContents("/dst/dst_only", "/dst/dst_only")
+	s.assertFileContents("/dst/src_only", "/src/src_only")
+	s.assertFileContents("/dst/shared/both", "/src/shared/both")
+	s.assertFileContents("/dst/shared/src_only_dir/deep", "/src/shared/src_only_dir/deep")
+}
+
+func (s *ProcessTestSuite) TestMergeMoveSkip() {
+	s.createMergeTrees()
+	assert.Nil(s.T(), s.p.MergeMove("/src", "/dst", process.MergeSkip))
+	assert.Equal(s.T(), []string{"common", "dst_only", "shared", "src_only"}, s.listNames("/dst"))
+	s.assertFileContents("/dst/common", "/dst/common")
+	s.assertFileContents("/dst/shared/both", "/dst/shared/both")
+	s.assertFileContents("/dst/src_only", "/src/src_only")
+	s.assertFileContents("/dst/shared/src_only_dir/deep", "/src/shared/src_only_dir/deep")
+
+	// Skipped entries (and the directories that contain them) stay in the source
+	assert.Equal(s.T(), []string{"common", "shared"}, s.listNames("/src"))
+	assert.Equal(s.T(), []string{"both"}, s.listNames("/src/shared"))
+}
+
+func (s *ProcessTestSuite) TestMergeMoveError() {
+	s.createMergeTrees()
+	err := s.p.MergeMove("/src", "/dst", process.MergeError)
+	assert.ErrorIs(s.T(), err, fserrors.EExist)
+	// Entries are merged in lexical order, so the merge stops at the first collision
+	assert.Equal(s.T(), []string{"common", "shared", "src_only"}, s.listNames("/src"))
+	s.assertFileContents("/dst/common", "/dst/common")
+
+	// Without collisions, the whole source is moved
+	assert.Nil(s.T(), s.p.DeleteFile("/src/common"))
+	assert.Nil(s.T(), s.p.DeleteFile("/src/shared/both"))
+	assert.Nil(s.T(), s.p.MergeMove("/src", "/dst", process.MergeError))
+	assert.False(s.T(), s.p.Exists("/src"))
+	assert.Equal(s.T(), []string{"common", "dst_only", "shared", "src_only"}, s.listNames("/dst"))
+	assert.Equal(s.T(), []string{"both", "src_only_dir"}, s.listNames("/dst/shared"))
+}
+
+func (s *ProcessTestSuite) TestMergeMoveErrors() {
+	assert.ErrorIs(s.T(), s.p.MergeMove("/a/b", "/a/foobar_file", process.MergeError), fserrors.ENotDir)
+	assert.ErrorIs(s.T(), s.p.MergeMove("/a/foobar_file", "/a/b", process.MergeError), fserrors.ENotDir)
+	assert.ErrorIs(s.T(), s.p.MergeMove("/a/b", "/noexist", process.MergeError), fserrors.ENoEnt)
+	assert.ErrorIs(s.T(), s.p.MergeMove("/a", "/a", process.MergeError), fserrors.EInval)
+	assert.ErrorIs(s.T(), s.p.MergeMove("/a", "/a/b/c", process.MergeError), fserrors.EInval)
+	assert.ErrorIs(s.T(), s.p.MergeMove("/a/b", "/a/zzz", process.MergePolicy(42)), fserrors.EInval)
+
+	// Relative paths are resolved against the working directory
+	assert.Nil(s.T(), s.p.ChangeDirectory("/a"))
+	assert.Nil(s.T(), s.p.MergeMove("zzz", "b/c", process.MergeError))
+	assert.False(s.T(), s.p.Exists("/a/zzz"))
+}
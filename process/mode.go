@@ -0,0 +1,46 @@
+package process
+
+import (
+	"github.com/manderson5192/memfs/directory"
+	"github.com/manderson5192/memfs/fserrors"
+	"github.com/manderson5192/memfs/os"
+	"github.com/pkg/errors"
+)
+
+func (p *processContext) Chmod(path string, mode os.FileMode) error {
+	relativePath, baseDir := p.toCleanRelativePathAndBaseDir(path)
+	if err := baseDir.Chmod(relativePath, mode); err != nil {
+		return errors.Wrapf(err, "could not change mode of '%s'", path)
+	}
+	return nil
+}
+
+func (p *processContext) SetUmask(mask os.FileMode) os.FileMode {
+	previous := p.root.Umask()
+	// Every Directory that the context derives from these shares their umask (see
+	// directory.Directory.WithUmask())
+	p.root = p.root.WithUmask(mask)
+	p.workdir = p.workdir.WithUmask(mask)
+	dirStack := make([]directory.Directory, 0, len(p.dirStack))
+	for _, dir := range p.dirStack {
+		dirStack = append(dirStack, dir.WithUmask(mask))
+	}
+	p.dirStack = dirStack
+	return previous
+}
+
+func (p *processContext) Access(path string, mode os.AccessMode) error {
+	if mode&^(os.R_OK|os.W_OK|os.X_OK) != 0 {
+		return errors.Wrapf(fserrors.EInval, "invalid access mode %d", mode)
+	}
+	info, err := p.Stat(path)
+	if err != nil {
+		return errors.Wrapf(err, "could not access '%s'", path)
+	}
+	// The owner permission bits are the high-order triplet
+	ownerPerm := os.AccessMode(info.Perm>>6) & (os.R_OK | os.W_OK | os.X_OK)
+	if mode&^ownerPerm != 0 {
+		return errors.Wrapf(fserrors.EAccess, "could not access '%s'", path)
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package process_test
+
+import (
+	"github.com/manderson5192/memfs/fserrors"
+	"github.com/manderson5192/memfs/os"
+	"github.com/stretchr/testify/assert"
+)
+
+func (s *ProcessTestSuite) assertPerm(path string, perm os.FileMode) {
+	info, err := s.p.Stat(path)
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), perm, info.Perm, "permissions of '%s'", path)
+}
+
+func (s *ProcessTestSuite) TestDefaultUmask() {
+	s.assertPerm("/a", 0755)
+	s.assertPerm("/a/foobar_file", 0644)
+	assert.Equal(s.T(), os.DefaultUmask, s.p.SetUmask(os.DefaultUmask))
+}
+
+func (s *ProcessTestSuite) TestUmaskMasksNewEntries() {
+	assert.Equal(s.T(), os.FileMode(022), s.p.SetUmask(027))
+	assert.Nil(s.T(), s.p.WriteFile("/a/new_file", []byte("new"), 0))
+	s.assertPerm("/a/new_file", 0640)
+	assert.Nil(s.T(), s.p.MakeDirectoryWithAncestors("/a/new_dir/sub"))
+	s.assertPerm("/a/new_dir", 0750)
+	s.assertPerm("/a/new_dir/sub", 0750)
+
+	// The umask applies to relative paths, including after changing directories
+	assert.Nil(s.T(), s.p.Pushd("/a/b"))
+	_, err := s.p.CreateFile("relative_file")
+	assert.Nil(s.T(), err)
+	s.assertPerm("/a/b/relative_file", 0640)
+	assert.Nil(s.T(), s.p.Popd())
+	assert.Nil(s.T(), s.p.Touch("touched_file"))
+	s.assertPerm("/touched_file", 0640)
+}
+
+func (s *ProcessTestSuite) TestUmaskOnlyAffectsLaterEntries() {
+	assert.Nil(s.T(), s.p.Pushd("/a/b"))
+	assert.Equal(s.T(), os.FileMode(022), s.p.SetUmask(077))
+	assert.Nil(s.T(), s.p.MakeDirectory("private"))
+	s.assertPerm("/a/b/private", 0700)
+	// A directory saved by Pushd() before the umask changed uses the new umask too
+	assert.Nil(s.T(), s.p.Popd())
+	assert.Nil(s.T(), s.p.MakeDirectory("private"))
+	s.assertPerm("/private", 0700)
+
+	assert.Equal(s.T(), os.FileMode(077), s.p.SetUmask(0))
+	assert.Nil(s.T(), s.p.WriteFile("/public", []byte{}, 0))
+	s.assertPerm("/public", 0666)
+	// Existing entries keep their permissions, and opening an existing file doesn't change them
+	s.assertPerm("/a/b/private", 0700)
+	s.assertPerm("/a/foobar_file", 0644)
+	assert.Nil(s.T(), s.p.WriteFile("/a/foobar_file", []byte("overwritten"), 0))
+	s.assertPerm("/a/foobar_file", 0644)
+}
+
+func (s *ProcessTestSuite) TestUmaskIsPerContext() {
+	s.p.SetUmask(027)
+	clone := s.p.Clone()
+	chrooted, err := s.p.Chroot("/a")
+	assert.Nil(s.T(), err)
+	s.p.SetUmask(0)
+
+	assert.Nil(s.T(), clone.WriteFile("/from_clone", []byte{}, 0))
+	s.assertPerm("/from_clone", 0640)
+	assert.Nil(s.T(), chrooted.MakeDirectory("/from_chroot"))
+	s.assertPerm("/a/from_chroot", 0750)
+	assert.Nil(s.T(), s.p.WriteFile("/from_original", []byte{}, 0))
+	s.assertPerm("/from_original", 0666)
+}
+
+func (s *ProcessTestSuite) TestMakeDirectoryMode() {
+	assert.Nil(s.T(), s.p.MakeDirectoryMode("/a/private", 0700))
+	s.assertPerm("/a/private", 0700)
+	// The permission bits are masked by the umask, and only the permission bits are kept
+	assert.Nil(s.T(), s.p.MakeDirectoryMode("/a/b/shared", os.ModeDir|0777))
+	s.assertPerm("/a/b/shared", 0755)
+	s.p.SetUmask(027)
+	assert.Nil(s.T(), s.p.ChangeDirectory("/a/b"))
+	assert.Nil(s.T(), s.p.MakeDirectoryMode("group", 0770))
+	s.assertPerm("/a/b/group", 0750)
+
+	assert.ErrorIs(s.T(), s.p.MakeDirectoryMode("/a/private", 0700), fserrors.EExist)
+	assert.ErrorIs(s.T(), s.p.MakeDirectoryMode("/noexist/dir", 0700), fserrors.ENoEnt)
+}
+
+func (s *ProcessTestSuite) TestChmod() {
+	s.p.SetUmask(077)
+	assert.Nil(s.T(), s.p.Chmod("/a/foobar_file", 0755))
+	s.assertPerm("/a/foobar_file", 0755)
+	// Only the permission bits are kept
+	assert.Nil(s.T(), s.p.Chmod("/a/b", os.ModeDir|os.ModePerm))
+	s.assertPerm("/a/b", 0777)
+	info, err := s.p.Stat("/a/b")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), os.ModeDir|os.ModePerm, info.Mode())
+}
+
+func (s *ProcessTestSuite) TestAccess() {
+	assert.Nil(s.T(), s.p.Access("/a/foobar_file", os.F_OK))
+	assert.Nil(s.T(), s.p.Access("/a/foobar_file", os.R_OK|os.W_OK))
+	assert.ErrorIs(s.T(), s.p.Access("/a/foobar_file", os.X_OK), fserrors.EAccess)
+	assert.Nil(s.T(), s.p.Access("/a/b", os.R_OK|os.W_OK|os.X_OK))
+
+	// Only the owner permission bits are checked
+	assert.Nil(s.T(), s.p.Chmod("/a/foobar_file", 0477))
+	assert.Nil(s.T(), s.p.Access("/a/foobar_file", os.R_OK))
+	assert.ErrorIs(s.T(), s.p.Access("/a/foobar_file", os.W_OK), fserrors.EAccess)
+	assert.ErrorIs(s.T(), s.p.Access("/a/foobar_file", os.R_OK|os.X_OK), fserrors.EAccess)
+	assert.Nil(s.T(), s.p.Chmod("/a/foobar_file", 0300))
+	assert.Nil(s.T(), s.p.Access("/a/foobar_file", os.W_OK|os.X_OK))
+	assert.ErrorIs(s.T(), s.p.Access("/a/foobar_file", os.R_OK), fserrors.EAccess)
+	assert.Nil(s.T(), s.p.Chmod("/a/foobar_file", 0))
+	assert.Nil(s.T(), s.p.Access("/a/foobar_file", os.F_OK))
+	assert.ErrorIs(s.T(), s.p.Access("/a/foobar_file", os.R_OK), fserrors.EAccess)
+}
+
+func (s *ProcessTestSuite) TestAccessErrors() {
+	assert.ErrorIs(s.T(), s.p.Access("/a/noexist", os.F_OK), fserrors.ENoEnt)
+	assert.ErrorIs(s.T(), s.p.Access("/a/noexist", os.R_OK), fserrors.ENoEnt)
+	assert.ErrorIs(s.T(), s.p.Access("/a/foobar_file", 8), fserrors.EInval)
+}
@@ -0,0 +1,40 @@
+package process_test
+
+import (
+	"github.com/manderson5192/memfs/filesys"
+	"github.com/manderson5192/memfs/fserrors"
+	"github.com/manderson5192/memfs/process"
+	"github.com/stretchr/testify/assert"
+)
+
+func (s *ProcessTestSuite) TestEntryNamesRejectNul() {
+	badName := "/a/bad\x00name"
+	assert.ErrorIs(s.T(), s.p.MakeDirectory(badName), fserrors.EInval)
+	_, err := s.p.CreateFile(badName)
+	assert.ErrorIs(s.T(), err, fserrors.EInval)
+	assert.ErrorIs(s.T(), s.p.Rename("/a/foobar_file", badName), fserrors.EInval)
+	assert.True(s.T(), s.p.Exists("/a/foobar_file"))
+
+	// Names with spaces, unicode, and (by default) other control characters are fine
+	for _, name := range []string{"/a/name with spaces", "/a/ünïcødé ☃", "/a/tab\tname"} {
+		assert.Nil(s.T(), s.p.MakeDirectory(name), name)
+		assert.True(s.T(), s.p.Exists(name), name)
+	}
+	assert.Nil(s.T(), s.p.Rename("/a/foobar_file", "/a/new\nline"))
+}
+
+func (s *ProcessTestSuite) TestEntryNamesRejectControlCharacters() {
+	fs := filesys.NewFileSystemWithOptions(filesys.Options{RejectControlChars: true})
+	p := process.NewProcessFilesystemContext(fs)
+	assert.Nil(s.T(), p.MakeDirectory("/dir"))
+	assert.Nil(s.T(), p.WriteFile("/file", []byte("hi"), 0))
+
+	for _, name := range []string{"/tab\tname", "/new\nline", "/esc\x1bname", "/del\x7fname"} {
+		assert.ErrorIs(s.T(), p.MakeDirectory(name), fserrors.EInval, name)
+		_, err := p.CreateFile(name)
+		assert.ErrorIs(s.T(), err, fserrors.EInval, name)
+		assert.ErrorIs(s.T(), p.Rename("/file", name), fserrors.EInval, name)
+	}
+	assert.Nil(s.T(), p.MakeDirectory("/dir/ünïcødé name"))
+	assert.Nil(s.T(), p.Rename("/file", "/dir/renamed file"))
+}
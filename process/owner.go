@@ -0,0 +1,16 @@
+package process
+
+import "github.com/pkg/errors"
+
+func (p *processContext) Chown(path string, uid, gid int) error {
+	relativePath, baseDir := p.toCleanRelativePathAndBaseDir(path)
+	if err := baseDir.Chown(relativePath, uid, gid); err != nil {
+		return errors.Wrapf(err, "could not change owner of '%s'", path)
+	}
+	return nil
+}
+
+func (p *processContext) Lchown(path string, uid, gid int) error {
+	// There are no symbolic links, so there is never a link to change instead of its target
+	return p.Chown(path, uid, gid)
+}
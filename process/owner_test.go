@@ -0,0 +1,49 @@
+package process_test
+
+import (
+	"github.com/manderson5192/memfs/fserrors"
+	"github.com/stretchr/testify/assert"
+)
+
+func (s *ProcessTestSuite) assertOwner(path string, uid, gid int) {
+	info, err := s.p.Stat(path)
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), uid, info.Uid, "uid of '%s'", path)
+	assert.Equal(s.T(), gid, info.Gid, "gid of '%s'", path)
+}
+
+func (s *ProcessTestSuite) TestChownFile() {
+	s.assertOwner("/a/foobar_file", 0, 0)
+	assert.Nil(s.T(), s.p.Chtimes("/a/foobar_file", longAgo, longAgo))
+	before, err := s.p.Stat("/a/foobar_file")
+	assert.Nil(s.T(), err)
+
+	assert.Nil(s.T(), s.p.Chown("/a/foobar_file", 1000, 100))
+	s.assertOwner("/a/foobar_file", 1000, 100)
+	after, err := s.p.Stat("/a/foobar_file")
+	assert.Nil(s.T(), err)
+	assert.False(s.T(), after.ChangeTime.Before(before.ChangeTime), "chown updates the change time")
+	assert.True(s.T(), longAgo.Equal(after.ModTime), "chown doesn't update the modification time")
+	assert.True(s.T(), longAgo.Equal(after.AccessTime), "chown doesn't update the access time")
+
+	// Ownership follows the file across renames
+	assert.Nil(s.T(), s.p.Rename("/a/foobar_file", "/a/b/renamed"))
+	s.assertOwner("/a/b/renamed", 1000, 100)
+}
+
+func (s *ProcessTestSuite) TestChownDirectory() {
+	assert.Nil(s.T(), s.p.ChangeDirectory("/a"))
+	assert.Nil(s.T(), s.p.Chown("b", 42, 7))
+	s.assertOwner("/a/b", 42, 7)
+	// The directory's entries keep their own ownership
+	s.assertOwner("/a/b/c", 0, 0)
+	s.assertOwner("/a", 0, 0)
+
+	assert.Nil(s.T(), s.p.Lchown("/a/b", 0, 7))
+	s.assertOwner("/a/b", 0, 7)
+}
+
+func (s *ProcessTestSuite) TestChownErrors() {
+	assert.ErrorIs(s.T(), s.p.Chown("/a/noexist", 1, 1), fserrors.ENoEnt)
+	assert.ErrorIs(s.T(), s.p.Lchown("/a/foobar_file/x", 1, 1), fserrors.ENotDir)
+}
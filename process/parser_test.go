@@ -0,0 +1,124 @@
+package process_test
+
+import (
+	"testing"
+
+	"github.com/manderson5192/memfs/directory"
+	"github.com/manderson5192/memfs/filepath"
+	"github.com/manderson5192/memfs/filesys"
+	"github.com/manderson5192/memfs/fserrors"
+	"github.com/manderson5192/memfs/process"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type BackslashPathTestSuite struct {
+	suite.Suite
+	p process.ProcessFilesystemContext
+}
+
+func (s *BackslashPathTestSuite) SetupTest() {
+	fs := filesys.NewFileSystemWithParser(filepath.NewParser('\\', ".", ".."))
+	s.p = process.NewProcessFilesystemContext(fs)
+	assert.Nil(s.T(), s.p.MakeDirectoryWithAncestors(`\a\b\c`))
+	assert.Nil(s.T(), s.p.WriteFile(`\a\b\file`, []byte("hello"), 0))
+}
+
+func (s *BackslashPathTestSuite) TestResolvesPaths() {
+	data, err := s.p.ReadFile(`\a\.\b\c\..\file`)
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), "hello", string(data))
+	isDir, err := s.p.IsDir(`\\a\\b\\c\\`)
+	assert.Nil(s.T(), err)
+	assert.True(s.T(), isDir)
+
+	// Relative paths resolve against the working directory
+	assert.Nil(s.T(), s.p.ChangeDirectory(`\a\b\c`))
+	data, err = s.p.ReadFile(`..\file`)
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), "hello", string(data))
+
+	// A file can't be used as a directory
+	_, err = s.p.Stat(`\a\b\file\`)
+	assert.ErrorIs(s.T(), err, fserrors.ENotDir)
+	_, err = s.p.ReadFile(`\a\b\file\x`)
+	assert.ErrorIs(s.T(), err, fserrors.ENotDir)
+}
+
+func (s *BackslashPathTestSuite) TestReportsPaths() {
+	assert.Nil(s.T(), s.p.ChangeDirectory(`\a\b\c`))
+	wd, err := s.p.WorkingDirectory()
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), `\a\b\c`, wd)
+
+	info, err := s.p.Stat(`\`)
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), `\`, info.Name)
+
+	f, err := s.p.OpenFile(`\a\b\file`, 0)
+	assert.Nil(s.T(), err)
+	name, err := f.Name()
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), `\a\b\file`, name)
+
+	paths := []string{}
+	assert.Nil(s.T(), s.p.Walk(`\a`, func(path string, info *directory.FileInfo, err error) error {
+		paths = append(paths, path)
+		return err
+	}))
+	assert.ElementsMatch(s.T(), []string{`\a`, `\a\b`, `\a\b\c`, `\a\b\file`}, paths)
+
+	matches, err := s.p.Glob(`\a\*\f*`)
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []string{`\a\b\file`}, matches)
+}
+
+func (s *BackslashPathTestSuite) TestJoinsPaths() {
+	assert.Nil(s.T(), s.p.Rename(`\a\b\file`, `\a\renamed`))
+	assert.False(s.T(), s.p.Exists(`\a\b\file`))
+	assert.True(s.T(), s.p.Exists(`\a\renamed`))
+
+	// A relative path is joined to the working directory when the other path is absolute
+	assert.Nil(s.T(), s.p.ChangeDirectory(`\a`))
+	assert.Nil(s.T(), s.p.Rename("renamed", `\a\b\c\moved`))
+	assert.True(s.T(), s.p.Exists(`b\c\moved`))
+	dir, err := s.p.MakeDirectoryAllReturn(`b\c\d\e`)
+	assert.Nil(s.T(), err)
+	path, err := dir.ReversePathLookup()
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), `\a\b\c\d\e`, path)
+
+	// Neither separator may appear in an entry name
+	assert.ErrorIs(s.T(), s.p.MakeDirectory(`\a\x/y`), fserrors.EInval)
+	_, err = s.p.Stat(`\a\b\c\moved/`)
+	assert.ErrorIs(s.T(), err, fserrors.EInval)
+}
+
+func TestBackslashPathTestSuite(t *testing.T) {
+	suite.Run(t, new(BackslashPathTestSuite))
+}
+
+func TestColonPathsRejectDefaultSyntax(t *testing.T) {
+	fs := filesys.NewFileSystemWithParser(filepath.NewParser(':', "@", "^"))
+	p := process.NewProcessFilesystemContext(fs)
+	assert.Nil(t, p.MakeDirectory(":a"))
+
+	// A '/' inside a part doesn't separate it into directories
+	_, err := p.CreateFile(":a/b")
+	assert.ErrorIs(t, err, fserrors.EInval)
+	assert.ErrorIs(t, p.WriteFile("a/b", []byte("data"), 0), fserrors.EInval)
+	entries, err := p.ListDirectory(":a")
+	assert.Nil(t, err)
+	assert.Empty(t, entries)
+	_, err = p.ListDirectory(":a/")
+	assert.ErrorIs(t, err, fserrors.EInval)
+
+	// The default self and parent entries aren't self and parent entries in this syntax
+	_, err = p.Stat(":a:..")
+	assert.ErrorIs(t, err, fserrors.EInval)
+	assert.ErrorIs(t, p.MakeDirectory(":a:."), fserrors.EInval)
+	assert.ErrorIs(t, filesys.BindMount(fs, ":a", ":a:.."), fserrors.EInval)
+	info, err := p.Stat(":a:@:^")
+	assert.Nil(t, err)
+	assert.Equal(t, ":", info.Name)
+}
@@ -1,37 +1,137 @@
 package process
 
 import (
+	"iter"
+	"time"
+
 	"github.com/manderson5192/memfs/directory"
 	"github.com/manderson5192/memfs/file"
 	"github.com/manderson5192/memfs/filepath"
 	"github.com/manderson5192/memfs/filesys"
+	"github.com/manderson5192/memfs/notify"
+	"github.com/manderson5192/memfs/os"
 )
 
 // ProcessFilesystemContext is an interface that closely resembles the POSIX filesystem interface
-// that is available to Linux processes
+// that is available to Linux processes.
+//
+// Like a process, each ProcessFilesystemContext has its own working directory (and directory
+// stack), but it shares the underlying FileSystem with every other context created over it: a change
+// made through one context is immediately visible through all of them, while ChangeDirectory() in one
+// context has no effect on the others.  Many contexts may be used concurrently over one FileSystem,
+// but a single context is meant to be used by one goroutine at a time.
 type ProcessFilesystemContext interface {
+	// Clone returns a new ProcessFilesystemContext over the same FileSystem that starts out with
+	// this context's working directory and directory stack, like a forked process.  Thereafter the
+	// two contexts' working directories change independently.
+	Clone() ProcessFilesystemContext
+	// Chroot returns a new ProcessFilesystemContext over the same FileSystem whose root directory is
+	// the specified directory, like chroot(2), and whose working directory is that new root.  In the
+	// new context, absolute paths are resolved against the new root, ".." in the new root refers to
+	// the new root itself (so no path can escape from its subtree), and WorkingDirectory() reports
+	// paths relative to the new root.  Changes made through the new context are visible through this
+	// one and vice versa.  Note that Watch() events still carry paths in the underlying FileSystem.
+	Chroot(path string) (ProcessFilesystemContext, error)
 	// WorkingDirectory gets the process's current working directory
 	WorkingDirectory() (string, error)
 	// ChangeDirectory changes the working directory to the specified directory.  Accepts absolute
-	// or relative paths.  Returns nil if successful, an error otherwise
+	// or relative paths.  Returns nil if successful, an error otherwise.  The error satisfies
+	// errors.Is(err, fserrors.ENotDir) if path (or one of its ancestors) is a file, and
+	// errors.Is(err, fserrors.ENoEnt) if it does not exist.
 	ChangeDirectory(path string) error
+	// ChangeDirectoryToFileParent changes the working directory to the directory that currently
+	// contains the open file f, even if f has been moved since it was opened.  Returns an error if
+	// f has since been deleted.
+	ChangeDirectoryToFileParent(f file.File) error
+	// GetDirectoryHandle returns the working directory as a Directory, so that it can be restored
+	// later with ChangeDirectoryToHandle() even if it has since been moved.  (Restoring it looks up
+	// the directory's current path again from the process's root.)  Unlike OpenDirectory(), the
+	// handle doesn't keep the directory's entries reachable if it is removed, so it needn't be
+	// closed.
+	GetDirectoryHandle() directory.Directory
+	// ChangeDirectoryToHandle changes the working directory to the directory d, even if d has been
+	// moved since the handle was obtained (e.g. from GetDirectoryHandle() or OpenDirectory()).  It
+	// returns ENOENT if d has since been deleted, and EINVAL if d is nil or is not reachable from
+	// this process's root directory by the path that it reports (e.g. because it belongs to another
+	// FileSystem or to a context with a different root).
+	ChangeDirectoryToHandle(d directory.Directory) error
+	// Pushd changes the working directory to the specified directory, like ChangeDirectory(), and
+	// pushes the previous working directory onto the process's directory stack.  If the change
+	// fails, then the stack is left unchanged.
+	Pushd(path string) error
+	// Popd pops the most recently pushed directory off of the process's directory stack and makes it
+	// the working directory.  It returns EINVAL if the stack is empty.  If the popped directory has
+	// since been deleted, then it returns ENOENT and leaves the working directory unchanged; the
+	// deleted directory is still removed from the stack, so a subsequent Popd() can proceed.
+	Popd() error
 	// MakeDirectory creates the specified directory.  Accepts absolute or relative paths.  Returns nil
 	// if successful, an error otherwise
 	MakeDirectory(dir string) error
+	// MakeDirectoryMode behaves like MakeDirectory, except that the new directory's permission bits
+	// are perm, masked by the umask (see SetUmask()), like os.Mkdir(name, perm)
+	MakeDirectoryMode(dir string, perm os.FileMode) error
 	// MakeDirectoryWithAncestors creates the specified path and any ancestor directories that do
 	// not already exists.  Unlike MakeDirectory(), this method will not return an error if the
 	// specific path is a directory already exists.  Returns an error otherwise
 	MakeDirectoryWithAncestors(path string) error
+	// MakeDirectoryAllReturn behaves like MakeDirectoryWithAncestors, except that it also returns
+	// the Directory for path, like directory.Directory.Mkdir() does.  If an existing ancestor of
+	// path (or path itself) is a file, then it returns ENOTDIR.
+	MakeDirectoryAllReturn(path string) (directory.Directory, error)
 	// ListDirectory returns an array of DirectoryEntry in the specified directory.  Accepts
 	// absolute or relative path names.  Returns an array if successful, an error otherwise
 	ListDirectory(dir string) ([]directory.DirectoryEntry, error)
+	// ListDirectorySorted behaves like ListDirectory, except that the entries are guaranteed to be
+	// sorted in lexical order by name.  ListDirectory makes no guarantees about ordering.
+	ListDirectorySorted(dir string) ([]directory.DirectoryEntry, error)
+	// ReadDirInfo returns the name and FileInfo of each entry in the specified directory, sorted by
+	// name, without a separate Stat() per entry.  The entries are taken from a single consistent
+	// snapshot of the directory (see directory.Directory.StatEntries()), so unlike calling Stat() on
+	// each entry returned by ListDirectory(), it never observes an entry that was removed or replaced
+	// in between.  Accepts absolute or relative paths.  Returns ENOTDIR if path is a file.
+	ReadDirInfo(dir string) ([]NamedFileInfo, error)
+	// OpenDirectory returns a Directory handle for the specified directory, like opening it with
+	// O_DIRECTORY.  Paths passed to the handle's methods are relative to the directory, and it has
+	// the same root as this process (see Chroot()).  The handle keeps the directory's entries
+	// reachable if the directory is removed by RemoveOpenDirectory() (see
+	// directory.Directory.OpenHandle()), so it should be closed with Close() when it is no longer
+	// needed.  Accepts absolute or relative paths.  Returns ENOTDIR if path is a file and ENOENT if
+	// it does not exist.
+	OpenDirectory(path string) (directory.Directory, error)
 	// RemoveDirectory removes the specified directory.  Accepts absolute or relative paths.  Returns
-	// nil if successful, an error otherwise
+	// nil if successful, an error otherwise.  The directory must be empty (ENOTEMPTY), even if a
+	// handle to it is open.
 	RemoveDirectory(dir string) error
+	// RemoveOpenDirectory behaves like RemoveDirectory, except that a non-empty directory may also
+	// be removed if a handle to it is open (see OpenDirectory()).  Its entries then remain
+	// reachable through the open handles, and count against the filesystem's quota, until the last
+	// handle is closed.
+	RemoveOpenDirectory(dir string) error
+	// EmptyDir removes all of the files and subdirectories in the specified directory, but leaves
+	// the directory itself in place, e.g. to clear a cache directory.  Accepts absolute or relative
+	// paths.  Returns ENOTDIR if path is a file and ENOENT if it does not exist.  If an entry can't
+	// be removed, then EmptyDir stops and returns the error, leaving the remaining entries in place.
+	EmptyDir(path string) error
 	// CreateFile creates the specified file and returns a reference to it.  Accepts absolute or
 	// relative paths.  Returns nil and an error if unsuccessful.  This call is equivalent to
 	// OpenFile(path, O_RDWR|O_CREATE|O_EXCL)
 	CreateFile(path string) (file.File, error)
+	// CreateExclusive opens the specified file in O_RDWR mode, creating it if it does not exist, and
+	// returns a reference to it along with whether it was newly created (true) or already existed
+	// (false).  Unlike OpenFile(path, O_RDWR|O_CREATE|O_EXCL), an existing file is not an error.
+	// The existence check and the creation happen atomically, so when many callers race to create
+	// the same file exactly one of them observes created == true.  Accepts absolute or relative
+	// paths.
+	CreateExclusive(path string) (f file.File, created bool, err error)
+	// CreateTemp creates a new file in the directory dir, opens it in O_RDWR mode, and returns it
+	// along with its path, like os.CreateTemp().  The file's name is generated from pattern by
+	// replacing its last '*' with a random string, or by appending a random string if pattern has no
+	// '*'.  Names that are already taken are retried with a new random string.  If dir is empty,
+	// then the working directory is used and the returned path is absolute.
+	CreateTemp(dir, pattern string) (file.File, string, error)
+	// MkdirTemp creates a new directory in the directory dir and returns its path, like
+	// os.MkdirTemp().  The directory's name is chosen as in CreateTemp().
+	MkdirTemp(dir, pattern string) (string, error)
 	// OpenFile opens the specified file in the specified mode and returns a reference to it.
 	// Accepts absolute or relative paths.  Returns nil and an error if unsuccessful.  It supports
 	// the following os, which can be OR'd together (as with open(2) in Linux):
@@ -43,14 +143,195 @@ type ProcessFilesystemContext interface {
 	//	* O_TRUNC: if O_WRONLY or O_RDWR then truncat the file to size 0 on open
 	//	* O_EXCL: error if O_CREAT and the file exists
 	OpenFile(path string, mode int) (file.File, error)
+	// OpenFileWithLimit behaves like OpenFile, except that writes through the returned File cannot
+	// grow the file beyond maxBytes bytes.  A write that would cross the limit writes as many bytes
+	// as fit and then returns that count along with fserrors.ENoSpace, like a short write to a full
+	// disk.  The limit only applies to the returned File, not to other handles to the same file.
+	OpenFileWithLimit(path string, mode int, maxBytes int64) (file.File, error)
+	// OpenAt behaves like OpenFile, except that a relative path is resolved against dir instead of
+	// the working directory, like openat(2).  Callers can resolve a directory once (e.g. with
+	// OpenDirectory()) and then open many files beneath it without resolving its path again; the
+	// handle keeps working if the directory is renamed.  An absolute path ignores dir, and a nil dir
+	// refers to the working directory, like AT_FDCWD.
+	OpenAt(dir directory.Directory, path string, mode int) (file.File, error)
+	// OpenRingFile opens the specified file for reading and appending, creating it if it does not
+	// exist, as a ring buffer of maxBytes bytes: once the file is full, each write evicts the
+	// oldest bytes so that reads always see the most recent maxBytes bytes written, in order.  This
+	// is useful for simulating bounded log files.  Positional writes (WriteAt) and truncation are
+	// rejected with EINVAL.  Returns EINVAL if maxBytes is not positive.
+	OpenRingFile(path string, maxBytes int) (file.File, error)
+	// OpenFileBuffered behaves like OpenFile, except that the returned File buffers up to bufSize
+	// bytes of writes in the handle, to reduce lock contention under many small writes.  Buffered
+	// writes are flushed to the file when the buffer fills, when a write isn't contiguous with the
+	// buffered data, and on Sync() or Close().  Reads through the returned File (and its
+	// duplicates) see the buffered data, but other handles only see it once it is flushed.  Returns
+	// EINVAL if bufSize is not positive.
+	OpenFileBuffered(path string, mode int, bufSize int) (file.File, error)
+	// ReadFile opens the specified file in read-only mode and returns all of its contents, like
+	// os.ReadFile().  Accepts absolute or relative paths.  Returns EISDIR if path is a directory.
+	ReadFile(path string) ([]byte, error)
+	// WriteFile writes data to the specified file, creating it if it does not exist and truncating
+	// it otherwise, like os.WriteFile().  The file is opened with O_WRONLY|O_CREATE|O_TRUNC OR'd
+	// with mode, so callers can pass additional flags (e.g. O_EXCL) or 0 for none.  Like
+	// os.WriteFile(), it closes the file once it has written data (see file.File.Close()) and
+	// returns any error from closing it.  Accepts absolute or relative paths.  Returns an error if
+	// unsuccessful.
+	WriteFile(path string, data []byte, mode int) error
+	// WriteFileAtomic replaces the contents of the specified file with data, creating the file if it
+	// does not exist, such that no reader ever observes a partially-written file.  It writes data to
+	// a new temporary file in the same directory, syncs it, and then renames it over path, so path
+	// refers to either the complete old contents or the complete new contents.  If any step fails,
+	// then the temporary file is removed and the old contents are left intact.  Note that path
+	// refers to a new file afterwards: handles opened on the old file still see the old contents.
+	WriteFileAtomic(path string, data []byte) error
+	// Checksum returns the SHA-256 sum of the specified file's contents.  Accepts absolute or
+	// relative paths.  Use file.File.Checksum() to hash with a different algorithm.
+	Checksum(path string) ([]byte, error)
 	// DeleteFile deletes the specified file.  Accepts absolute or relative paths.  Returns an error
 	// if unsuccessful
 	DeleteFile(path string) error
 	// Rename moves the file or directory at srcPath to dstPath.  If dstPath already exists, then
-	// it will attempt to remove that file or directory.  Returns an error if unsuccessful.
+	// it will attempt to remove that file or directory.  As with rename(2), a directory can only
+	// replace an empty directory and a file can only replace a file: replacing a file with a
+	// directory fails with ENOTDIR, and replacing a directory with a file fails with EISDIR.
+	// Returns an error if unsuccessful.
+	//
+	// A replaced file is orphaned, as with unlink(2): handles that were open on it keep reading and
+	// writing its old contents (and report ENOENT from File.Name()), while subsequent opens of
+	// dstPath see the file that was moved from srcPath.
+	//
+	// Rename is safe to call concurrently with any other operation.  Once srcPath and dstPath have
+	// been resolved to their parent directories, the move is atomic with respect to readers of
+	// either parent directory: they observe the entry at exactly one of its old or new locations.
+	// Path resolution itself is not atomic with the move, so a Rename that races with a rename of
+	// one of its paths' ancestors may fail with ENOENT.
 	Rename(srcPath, dstPath string) error
+	// RenameNoReplace behaves like Rename, except that it never replaces an existing entry, like
+	// Linux's RENAME_NOREPLACE flag: if dstPath already exists, then it returns EEXIST and leaves
+	// both paths unchanged.  The check for dstPath is atomic with the move, so an entry that another
+	// goroutine concurrently creates at dstPath is never overwritten.
+	RenameNoReplace(srcPath, dstPath string) error
+	// RenameExchange atomically swaps the files or directories at path1 and path2, like Linux's
+	// RENAME_EXCHANGE flag.  Both paths must exist, but they may be files or directories in any
+	// combination: afterwards, each path refers to what the other path referred to before.  It
+	// returns EINVAL if either path is a directory that is an ancestor of the other path.
+	RenameExchange(path1, path2 string) error
+	// CanRename returns the error that Rename(srcPath, dstPath) would return, or nil if it would
+	// succeed, without moving anything, so that a batch of renames can be validated up front.  The
+	// answer may be invalidated by any mutation that happens before the subsequent Rename.
+	CanRename(srcPath, dstPath string) error
+	// MoveGlob moves every file and directory that matches pattern into the directory destDir,
+	// keeping their names, and returns the paths (as matched) that it moved.  pattern may contain
+	// the wildcards understood by filepath.Match() in any of its components, and a relative pattern
+	// is expanded against the working directory.  Each match is moved with Rename(), so it replaces
+	// any existing entry of the same name in destDir according to Rename()'s rules.  If a move
+	// fails, then MoveGlob stops and returns the paths moved so far along with the error.  It returns
+	// ENOTDIR if destDir is not a directory and filepath.ErrBadPattern if pattern is malformed.
+	MoveGlob(pattern, destDir string) ([]string, error)
+	// MoveGlobNoReplace behaves like MoveGlob, except that it moves each match with
+	// RenameNoReplace(), so it stops with EEXIST instead of replacing an entry in destDir
+	MoveGlobNoReplace(pattern, destDir string) ([]string, error)
+	// MergeMove recursively moves the contents of the directory srcDir into the existing directory
+	// dstDir, like `rsync --remove-source-files`: an entry that exists only in srcDir is moved with
+	// RenameNoReplace(), a subdirectory that exists in both is merged recursively, and any other
+	// collision is resolved by policy.  Each source directory is removed once it is empty, so after
+	// a merge that skips nothing, srcDir no longer exists.  If a step fails, then MergeMove stops
+	// and returns the error, leaving the entries that were already moved in dstDir.  It returns
+	// ENOTDIR if either path is not a directory, EINVAL if dstDir is srcDir or is within it, and
+	// EEXIST for a collision under MergeError.
+	MergeMove(srcDir, dstDir string, policy MergePolicy) error
 	// Stat returns a file.FileInfo for the specified file or directory, or an error.
 	Stat(path string) (*directory.FileInfo, error)
+	// Exists returns true if there is a file or directory at path.  It returns false if Stat()
+	// fails for any reason, not just ENOENT, so a path that can't be resolved (e.g. because one of
+	// its ancestors is a file) is reported as not existing.  Use Stat() to distinguish these cases.
+	Exists(path string) bool
+	// IsDir returns true if path is a directory and false if it is a file.  It returns false and an
+	// error if path cannot be resolved.
+	IsDir(path string) (bool, error)
+	// IsFile returns true if path is a file and false if it is a directory.  It returns false and an
+	// error if path cannot be resolved.
+	IsFile(path string) (bool, error)
+	// Touch updates the access and modification times of the file or directory at path to the
+	// current time, like the touch command, without changing its contents.  If nothing exists at
+	// path, then it creates an empty file there.  It returns ENOENT if path's parent directory does
+	// not exist.
+	Touch(path string) error
+	// Chtimes sets the access and modification times of the file or directory at path, like
+	// os.Chtimes()
+	Chtimes(path string, accessTime, modTime time.Time) error
+	// Chown sets the user and group ids that own the file or directory at path, like os.Chown(), and
+	// updates its change time.  Ownership is recorded and reported by Stat(), but not enforced.
+	Chown(path string, uid, gid int) error
+	// Lchown is like Chown, but it would change the ownership of a symbolic link itself rather than
+	// of its target, like os.Lchown().  Since the filesystem has no symbolic links, it is equivalent
+	// to Chown.
+	Lchown(path string, uid, gid int) error
+	// Chmod sets the permission bits of the file or directory at path to those of mode, like
+	// os.Chmod(), and updates its change time.  The umask doesn't apply.  Permissions are recorded
+	// and reported by Stat(), but not enforced.
+	Chmod(path string, mode os.FileMode) error
+	// SetUmask sets the context's umask to mask and returns the previous umask, like umask(2).  The
+	// permission bits of every file or directory that is subsequently created through the context
+	// are masked by its umask: a new file is created with mode 0666 and a new directory with mode
+	// 0777, so with the default umask of 022 (os.DefaultUmask), they end up 0644 and 0755.  Each
+	// context has its own umask, which Clone() and Chroot() copy.
+	SetUmask(mask os.FileMode) os.FileMode
+	// Access checks whether the process may access the file or directory at path as mode requests,
+	// like access(2): mode is os.F_OK to check only that path exists, or a combination of os.R_OK,
+	// os.W_OK, and os.X_OK.  The process is assumed to own every file, so the owner permission bits
+	// decide.  Access returns nil if the access is allowed, EACCES if it is not, ENOENT if path does
+	// not exist, and EINVAL if mode is invalid.
+	Access(path string, mode os.AccessMode) error
+	// SetXattr creates or replaces the named extended attribute on the file or directory at path.
+	// Extended attributes are arbitrary name/value metadata that belong to the file or directory
+	// itself, so they follow it across renames.  The value is copied, so the caller may reuse it.
+	SetXattr(path, name string, value []byte) error
+	// GetXattr returns a copy of the value of the named extended attribute on the file or directory
+	// at path.  It returns ENODATA if the attribute is not set.
+	GetXattr(path, name string) ([]byte, error)
+	// ListXattr returns the names of the extended attributes on the file or directory at path, in
+	// lexical order
+	ListXattr(path string) ([]string, error)
+	// RemoveXattr removes the named extended attribute from the file or directory at path.  It
+	// returns ENODATA if the attribute is not set.
+	RemoveXattr(path, name string) error
+	// SameFile returns true if path1 and path2 resolve to the same underlying file or directory, like
+	// os.SameFile().  Unlike comparing paths, this detects aliases such as a relative and an
+	// absolute path to the same entry.  Returns an error if either path cannot be resolved.
+	SameFile(path1, path2 string) (bool, error)
+	// DiskUsage returns the total size, in bytes, of all of the files in the subtree rooted at path,
+	// like `du -s`.  If path is a file, then its size is returned.  Returns an error if path cannot
+	// be walked.
+	DiskUsage(path string) (int64, error)
+	// DuBreakdown returns the disk usage (see DiskUsage()) of each immediate child of the directory
+	// at path, like `du -s path/*`, sorted by size with the largest first (and by path among equal
+	// sizes).  Each DuEntry's path is the child's path joined onto path, so it is relative if path
+	// is.  The directory's total is the sum of the entries' sizes.  Returns ENOTDIR if path is a
+	// file.
+	DuBreakdown(path string) ([]DuEntry, error)
+	// CountEntries returns the number of files and the number of directories in the subtree rooted
+	// at path, found by Walk().  The directory at path is counted among the directories, but if path
+	// is a file, then it is not counted at all, so both counts are zero.  Returns an error if path
+	// cannot be walked.
+	CountEntries(path string) (files int, dirs int, err error)
+	// Tree renders the subtree rooted at path like the `tree` command: the first line is path, and
+	// each following line is an entry beneath it, in sorted order, indented beneath its parent
+	// directory and connected to it by box-drawing characters.  Directories are shown with a
+	// trailing path separator.  Each line ends with a newline.  Returns an error if path doesn't
+	// exist or if a directory beneath it can't be listed.
+	Tree(path string) (string, error)
+	// Watch registers a watcher for changes to the file or directory at path and, if it is a
+	// directory, to everything beneath it.  Accepts absolute or relative paths.  It returns a channel
+	// on which events are delivered and a function that stops the watch and closes the channel.
+	//
+	// Every mutating operation (creating, writing, removing, or renaming a file or directory)
+	// publishes an event to each watcher of the changed path or one of its ancestors.  Each event
+	// carries the changed entry's absolute path: a rename publishes notify.Rename for the old path
+	// followed by notify.Create for the new path.  Events are published with a non-blocking send, so
+	// if a consumer is too slow to keep its channel from filling up (see notify.EventBufferSize) then
+	// further events are dropped for that watcher until it catches up.
+	Watch(path string) (<-chan notify.Event, func(), error)
 	// Walk walks the file tree rooted at root, calling fn for each file or directory in the tree,
 	// including root.
 	//
@@ -61,9 +342,46 @@ type ProcessFilesystemContext interface {
 	//
 	// The files are walked in lexical order, which makes the output deterministic.
 	Walk(path string, f WalkFunc) error
+	// WalkSeq returns a sequence of the paths and FileInfos of the files and directories that
+	// Walk() would visit, in the same order, for use in a range loop:
+	//
+	//	for path, info := range p.WalkSeq("/") { ... }
+	//
+	// Breaking out of the loop stops the walk immediately.  The sequence silently ends at the first
+	// error (e.g. if path doesn't exist); use WalkSeqWithErrors() to observe errors.
+	WalkSeq(path string) iter.Seq2[string, *directory.FileInfo]
+	// WalkSeqWithErrors behaves like WalkSeq, except that each file or directory is yielded as a
+	// WalkItem along with a nil error.  If path can't be stat'd, or a directory can't be listed,
+	// then the sequence ends with the affected WalkItem and the error.
+	WalkSeqWithErrors(path string) iter.Seq2[WalkItem, error]
+	// WalkPostOrder is like Walk, except that it visits each directory after all of its descendants
+	// rather than before them, which suits computing sizes bottom-up or deleting a tree.  Siblings
+	// are still visited in lexical order.  Since a directory's descendants have already been visited
+	// by the time fn sees the directory, SkipDir has a different meaning: returning it for any
+	// file or directory skips that entry's remaining siblings (and their subtrees), after which the
+	// walk continues by visiting their parent directory.  If a directory can't be listed, then fn is
+	// called for it once, with the error, and none of its entries are visited.
+	WalkPostOrder(path string, f WalkFunc) error
+	// Glob returns the existing paths that match pattern, in lexical order, like filepath.Glob().
+	// pattern is a path whose components may contain the wildcards understood by filepath.Match()
+	// ('*', '?', and '[...]'), each of which matches within a single component.  A relative pattern
+	// is expanded against the working directory and yields relative paths, while an absolute
+	// pattern yields absolute paths.  A pattern that ends with a path separator matches only
+	// directories.  Glob returns an empty slice if nothing matches; its only possible error is
+	// filepath.ErrBadPattern, since directories that can't be listed simply match nothing.  Unlike
+	// FindAll(), it doesn't search subtrees: each component matches exactly one level.
+	Glob(pattern string) ([]string, error)
 	// FindAll walks the subtree rooted at subtreePath, collecting every path for files and
-	// directories whose names matche the supplied entry name.  It returns these paths or an error
+	// directories whose names matche the supplied entry name.  It returns these paths or an error.
+	// On an indexed filesystem (see filesys.NewIndexedFileSystem()), it finds the same paths with
+	// index lookups instead of walking the subtree.
 	FindAll(subtreePath, name string) ([]string, error)
+	// Find walks the subtree rooted at subtreePath, collecting every path for files and directories
+	// for which pred returns true, in the order in which Walk() visits them.  pred receives each
+	// path along with its FileInfo, so it can select entries by any criterion, e.g. by size, type,
+	// or modification time.  Returns an error if the underlying Walk() call fails, including if a
+	// directory in the subtree can't be listed.
+	Find(subtreePath string, pred func(path string, info *directory.FileInfo) bool) ([]string, error)
 	// FindFirstMatchingFile walks the subtree rooted at subtreePath and returns the path of the
 	// first file whose name matches the supplied regex.  Returns the empty string and an error if
 	// the regex is invalid, if the underlying Walk() call fails, or if no match is found.
@@ -73,11 +391,33 @@ type ProcessFilesystemContext interface {
 	// match for "foobar").  To avoid tricky bugs, clients should make thoughtful use of '^' and '$'
 	// in regexes.
 	FindFirstMatchingFile(subtreePath string, regex string) (string, error)
+	// Grep walks the subtree rooted at subtreePath and returns every line of every file that
+	// matches the regular expression pattern (see Go's regexp package), in the order in which Walk()
+	// visits the files.  Binary files (files that contain a NUL byte) are skipped, as are files that
+	// can't be read.  Returns an error if pattern is invalid or if the underlying Walk() call fails.
+	Grep(subtreePath, pattern string) ([]Match, error)
+	// GrepWithOptions behaves like Grep, except that opts can make it search binary files and report
+	// the files that it skips because they can't be read
+	GrepWithOptions(subtreePath, pattern string, opts GrepOptions) ([]Match, error)
+	// Begin starts a transaction by taking a snapshot of the filesystem's tree (see
+	// filesys.TakeSnapshot()), and returns the Tx through which the transaction's changes are made.
+	// The filesystem is frozen while the snapshot is taken (see filesys.Freeze()), so concurrent
+	// mutations wait for it, and Begin must not be called by a goroutine that has frozen the
+	// filesystem.  It returns EINVAL if the filesystem can't be snapshotted, or if the context was
+	// created by Chroot().
+	Begin() (Tx, error)
 }
 
 type processContext struct {
 	fileSystem filesys.FileSystem
-	workdir    directory.Directory
+	// root is the directory that absolute paths are resolved against: the filesystem's root
+	// directory, unless the context was created by Chroot()
+	root    directory.Directory
+	workdir directory.Directory
+	// dirStack holds the working directories saved by Pushd(), with the most recent last
+	dirStack []directory.Directory
+	// tx is the transaction that the context belongs to, or nil (see Begin())
+	tx *tx
 }
 
 // NewProcessFilesystemContext creates a processContext, which encapsulates a FileSystem, knowledge
@@ -85,21 +425,43 @@ type processContext struct {
 func NewProcessFilesystemContext(fs filesys.FileSystem) ProcessFilesystemContext {
 	return &processContext{
 		fileSystem: fs,
+		root:       fs.RootDirectory(),
 		workdir:    fs.RootDirectory(),
 	}
 }
 
+func (p *processContext) Clone() ProcessFilesystemContext {
+	return &processContext{
+		fileSystem: p.fileSystem,
+		root:       p.root,
+		workdir:    p.workdir,
+		dirStack:   append([]directory.Directory{}, p.dirStack...),
+		tx:         p.tx,
+	}
+}
+
+// parser returns the Parser for the syntax of the paths that the process accepts and reports
+func (p *processContext) parser() *filepath.Parser {
+	return p.root.Parser()
+}
+
+// separator returns the path separator of the process's path syntax
+func (p *processContext) separator() string {
+	return string(p.parser().Separator)
+}
+
 // toCleanRelativePathAndBaseDir examines whether path is absolute or relative and, based on that
 // insight, returns a base directory (either the root directory or the working directory) and a
 // relative (to the base directory) path that is equivalent to path.  It also uses filepath.Path()
 // to cleanup path before examination.
 func (p *processContext) toCleanRelativePathAndBaseDir(path string) (string, directory.Directory) {
 	baseDir := p.workdir
-	path = filepath.Clean(path)
-	if filepath.IsAbsolutePath(path) {
-		baseDir = p.fileSystem.RootDirectory()
+	parser := p.parser()
+	path = parser.Clean(path)
+	if parser.IsAbsolutePath(path) {
+		baseDir = p.root
 		// Trim the leading file separator
-		path = path[1:]
+		path = path[len(p.separator()):]
 	}
 	return path, baseDir
 }
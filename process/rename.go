@@ -1,41 +1,118 @@
 package process
 
 import (
-	"github.com/manderson5192/memfs/filepath"
+	"github.com/manderson5192/memfs/directory"
+	"github.com/manderson5192/memfs/fserrors"
 	"github.com/pkg/errors"
 )
 
 func (p *processContext) Rename(srcPath, dstPath string) error {
+	baseDir, srcPathRelative, dstPathRelative, err := p.toRenamePaths(srcPath, dstPath)
+	if err != nil {
+		return errors.Wrapf(err, "unable to rename %s to %s", srcPath, dstPath)
+	}
+	if err := baseDir.Rename(srcPathRelative, dstPathRelative); err != nil {
+		return errors.Wrapf(err, "could not rename %s to %s", srcPath, dstPath)
+	}
+	return nil
+}
+
+func (p *processContext) RenameNoReplace(srcPath, dstPath string) error {
+	baseDir, srcPathRelative, dstPathRelative, err := p.toRenamePaths(srcPath, dstPath)
+	if err != nil {
+		return errors.Wrapf(err, "unable to rename %s to %s", srcPath, dstPath)
+	}
+	if err := baseDir.RenameNoReplace(srcPathRelative, dstPathRelative); err != nil {
+		return errors.Wrapf(err, "could not rename %s to %s", srcPath, dstPath)
+	}
+	return nil
+}
+
+func (p *processContext) RenameExchange(path1, path2 string) error {
+	baseDir, relativePath1, relativePath2, err := p.toRenamePaths(path1, path2)
+	if err != nil {
+		return errors.Wrapf(err, "unable to exchange %s and %s", path1, path2)
+	}
+	if err := baseDir.RenameExchange(relativePath1, relativePath2); err != nil {
+		return errors.Wrapf(err, "could not exchange %s and %s", path1, path2)
+	}
+	return nil
+}
+
+func (p *processContext) CanRename(srcPath, dstPath string) error {
+	baseDir, srcPathRelative, dstPathRelative, err := p.toRenamePaths(srcPath, dstPath)
+	if err != nil {
+		return errors.Wrapf(err, "unable to rename %s to %s", srcPath, dstPath)
+	}
+	if err := baseDir.CanRename(srcPathRelative, dstPathRelative); err != nil {
+		return errors.Wrapf(err, "could not rename %s to %s", srcPath, dstPath)
+	}
+	return nil
+}
+
+// toRenamePaths converts srcPath and dstPath into paths that are relative to a single base
+// directory, which it also returns
+func (p *processContext) toRenamePaths(srcPath, dstPath string) (directory.Directory, string, string, error) {
 	// If one path is relative but the other is absolute, then use the working directory to make
 	// the relative path into an absolute one.
+	parser := p.parser()
 	baseDir := p.workdir
-	srcPathRelative := filepath.Clean(srcPath)
-	dstPathRelative := filepath.Clean(dstPath)
-	if filepath.IsAbsolutePath(srcPath) && filepath.IsAbsolutePath(dstPath) {
-		baseDir = p.fileSystem.RootDirectory()
+	srcPathRelative := parser.Clean(srcPath)
+	dstPathRelative := parser.Clean(dstPath)
+	if parser.IsAbsolutePath(srcPath) && parser.IsAbsolutePath(dstPath) {
+		baseDir = p.root
 		// Trim the leading file separators
-		srcPathRelative = srcPathRelative[1:]
-		dstPathRelative = dstPathRelative[1:]
-	} else if filepath.IsAbsolutePath(srcPath) != filepath.IsAbsolutePath(dstPath) {
+		srcPathRelative = srcPathRelative[len(p.separator()):]
+		dstPathRelative = dstPathRelative[len(p.separator()):]
+	} else if parser.IsAbsolutePath(srcPath) != parser.IsAbsolutePath(dstPath) {
 		// Convert both paths to be absolute
-		baseDir = p.fileSystem.RootDirectory()
+		baseDir = p.root
 		workdir, err := p.WorkingDirectory()
 		if err != nil {
-			return errors.Wrapf(err, "unable to rename %s to %s", srcPath, dstPath)
+			return nil, "", "", err
 		}
-		if filepath.IsRelativePath(srcPath) {
-			srcPathRelative = filepath.Join(workdir, srcPathRelative)
+		if parser.IsRelativePath(srcPath) {
+			srcPathRelative = parser.Join(workdir, srcPathRelative)
 		}
-		if filepath.IsRelativePath(dstPath) {
-			dstPathRelative = filepath.Join(workdir, dstPathRelative)
+		if parser.IsRelativePath(dstPath) {
+			dstPathRelative = parser.Join(workdir, dstPathRelative)
 		}
 		// Trim the leading file separators
-		srcPathRelative = srcPathRelative[1:]
-		dstPathRelative = dstPathRelative[1:]
+		srcPathRelative = srcPathRelative[len(p.separator()):]
+		dstPathRelative = dstPathRelative[len(p.separator()):]
 	}
-	// Do the rename operation
-	if err := baseDir.Rename(srcPathRelative, dstPathRelative); err != nil {
-		return errors.Wrapf(err, "could not rename %s to %s", srcPath, dstPath)
+	return baseDir, srcPathRelative, dstPathRelative, nil
+}
+
+func (p *processContext) MoveGlob(pattern, destDir string) ([]string, error) {
+	return p.moveGlob(pattern, destDir, p.Rename)
+}
+
+func (p *processContext) MoveGlobNoReplace(pattern, destDir string) ([]string, error) {
+	return p.moveGlob(pattern, destDir, p.RenameNoReplace)
+}
+
+// moveGlob moves every path that matches pattern into destDir with the rename function, returning
+// the paths that it moved
+func (p *processContext) moveGlob(pattern, destDir string, rename func(srcPath, dstPath string) error) ([]string, error) {
+	moved := make([]string, 0)
+	isDir, err := p.IsDir(destDir)
+	if err != nil {
+		return moved, errors.Wrapf(err, "could not move '%s' into '%s'", pattern, destDir)
 	}
-	return nil
+	if !isDir {
+		return moved, errors.Wrapf(fserrors.ENotDir, "could not move '%s' into '%s'", pattern, destDir)
+	}
+	matches, err := p.Glob(pattern)
+	if err != nil {
+		return moved, errors.Wrapf(err, "could not move '%s' into '%s'", pattern, destDir)
+	}
+	for _, match := range matches {
+		dstPath := p.parser().Join(destDir, p.parser().ParsePath(match).Entry)
+		if err := rename(match, dstPath); err != nil {
+			return moved, errors.Wrapf(err, "could not move '%s' into '%s'", pattern, destDir)
+		}
+		moved = append(moved, match)
+	}
+	return moved, nil
 }
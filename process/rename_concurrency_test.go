@@ -0,0 +1,297 @@
+package process_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/manderson5192/memfs/directory"
+	"github.com/manderson5192/memfs/filesys"
+	"github.com/manderson5192/memfs/fserrors"
+	"github.com/manderson5192/memfs/process"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+const (
+	numConcurrentRenamers = 8
+	renamesPerRenamer     = 1000
+	deadlockTimeout       = 10 * time.Second
+)
+
+// RenameConcurrencyTestSuite exercises Rename() from many goroutines at once.  Every test runs its
+// goroutines under a timeout so that a deadlock fails the test instead of hanging it.
+type RenameConcurrencyTestSuite struct {
+	suite.Suite
+	fs filesys.FileSystem
+	p  process.ProcessFilesystemContext
+}
+
+func (s *RenameConcurrencyTestSuite) SetupTest() {
+	s.fs = filesys.NewFileSystem()
+	s.p = process.NewProcessFilesystemContext(s.fs)
+}
+
+// runConcurrently runs each of fns in its own goroutine and waits for all of them to return,
+// failing the test if that takes longer than deadlockTimeout
+func (s *RenameConcurrencyTestSuite) runConcurrently(fns ...func()) {
+	var wg sync.WaitGroup
+	for _, fn := range fns {
+		wg.Add(1)
+		go func(f func()) {
+			defer wg.Done()
+			f()
+		}(fn)
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(deadlockTimeout):
+		s.T().Fatalf("goroutines did not finish within %s: likely deadlock", deadlockTimeout)
+	}
+}
+
+// listNames returns the names of the entries in dir
+func (s *RenameConcurrencyTestSuite) listNames(dir string) []string {
+	entries, err := s.p.ListDirectory(dir)
+	assert.Nil(s.T(), err)
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, entry.Name)
+	}
+	return names
+}
+
+func (s *RenameConcurrencyTestSuite) TestRenamesWithinOneDirectory() {
+	assert.Nil(s.T(), s.p.MakeDirectory("/d"))
+	expected := make([]string, 0, numConcurrentRenamers)
+	fns := make([]func(), 0, numConcurrentRenamers+1)
+	for idx := 0; idx < numConcurrentRenamers; idx++ {
+		name := fmt.Sprintf("/d/file_%d", idx)
+		renamed := fmt.Sprintf("/d/renamed_%d", idx)
+		_, err := s.p.CreateFile(name)
+		assert.Nil(s.T(), err)
+		expected = append(expected, fmt.Sprintf("file_%d", idx))
+		fns = append(fns, func() {
+			for n := 0; n < renamesPerRenamer; n++ {
+				assert.Nil(s.T(), s.p.Rename(name, renamed))
+				assert.Nil(s.T(), s.p.Rename(renamed, name))
+			}
+		})
+	}
+	// A concurrent reader always sees every file under exactly one of its two names
+	stop := make(chan struct{})
+	fns = append(fns, func() {
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			assert.Len(s.T(), s.listNames("/d"), numConcurrentRenamers)
+		}
+	})
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		close(stop)
+	}()
+	s.runConcurrently(fns...)
+	assert.ElementsMatch(s.T(), expected, s.listNames("/d"))
+}
+
+func (s *RenameConcurrencyTestSuite) TestCrissCrossRenamesBetweenTwoDirectories() {
+	assert.Nil(s.T(), s.p.MakeDirectory("/x"))
+	assert.Nil(s.T(), s.p.MakeDirectory("/y"))
+	fns := make([]func(), 0, numConcurrentRenamers)
+	for idx := 0; idx < numConcurrentRenamers; idx++ {
+		// Half of the goroutines start in /x and half start in /y, so that renames happen in both
+		// directions at once
+		from, to := "/x", "/y"
+		if idx%2 == 1 {
+			from, to = to, from
+		}
+		src := fmt.Sprintf("%s/entry_%d", from, idx)
+		dst := fmt.Sprintf("%s/entry_%d", to, idx)
+		// Move files as well as directories, since only directories have their parents updated
+		if idx%4 < 2 {
+			assert.Nil(s.T(), s.p.MakeDirectory(src))
+		} else {
+			_, err := s.p.CreateFile(src)
+			assert.Nil(s.T(), err)
+		}
+		fns = append(fns, func() {
+			for n := 0; n < renamesPerRenamer; n++ {
+				assert.Nil(s.T(), s.p.Rename(src, dst))
+				assert.Nil(s.T(), s.p.Rename(dst, src))
+			}
+		})
+	}
+	s.runConcurrently(fns...)
+	assert.Len(s.T(), s.listNames("/x"), numConcurrentRenamers/2)
+	assert.Len(s.T(), s.listNames("/y"), numConcurrentRenamers/2)
+	s.assertTreeIsConsistent()
+}
+
+func (s *RenameConcurrencyTestSuite) TestRenameNoReplaceHasOneWinner() {
+	assert.Nil(s.T(), s.p.MakeDirectory("/x"))
+	assert.Nil(s.T(), s.p.MakeDirectory("/y"))
+	for round := 0; round < 100; round++ {
+		var mutex sync.Mutex
+		winners := []string{}
+		sources := make([]string, 0, numConcurrentRenamers)
+		fns := make([]func(), 0, numConcurrentRenamers)
+		for idx := 0; idx < numConcurrentRenamers; idx++ {
+			// Half of the sources share the destination's directory, so that both the same-directory
+			// and cross-directory paths race for the destination
+			src := fmt.Sprintf("/x/entry_%d", idx)
+			if idx%2 == 1 {
+				src = fmt.Sprintf("/y/entry_%d", idx)
+			}
+			assert.Nil(s.T(), s.p.WriteFile(src, []byte(src), 0))
+			sources = append(sources, src)
+			fns = append(fns, func() {
+				err := s.p.RenameNoReplace(src, "/y/target")
+				if err == nil {
+					mutex.Lock()
+					winners = append(winners, src)
+					mutex.Unlock()
+				} else {
+					assert.ErrorIs(s.T(), err, fserrors.EExist)
+				}
+			})
+		}
+		s.runConcurrently(fns...)
+		// Exactly one rename succeeded, and every other source is still in place
+		if !assert.Len(s.T(), winners, 1) {
+			return
+		}
+		data, err := s.p.ReadFile("/y/target")
+		assert.Nil(s.T(), err)
+		assert.Equal(s.T(), winners[0], string(data))
+		for _, src := range sources {
+			if src != winners[0] {
+				assert.Nil(s.T(), s.p.DeleteFile(src))
+			}
+		}
+		assert.Nil(s.T(), s.p.DeleteFile("/y/target"))
+	}
+}
+
+func (s *RenameConcurrencyTestSuite) TestRenamesOfOverlappingAncestorsAndDescendants() {
+	assert.Nil(s.T(), s.p.MakeDirectoryWithAncestors("/p/q/r"))
+	assert.Nil(s.T(), s.p.MakeDirectory("/s"))
+	_, err := s.p.CreateFile("/p/q/r/f")
+	assert.Nil(s.T(), err)
+
+	// Each goroutine moves something back and forth.  Because an ancestor may have been moved out
+	// from under it, a goroutine's rename may fail with ENOENT, but it must not fail in any other
+	// way.
+	moveBackAndForth := func(a, b string) func() {
+		return func() {
+			for n := 0; n < renamesPerRenamer; n++ {
+				if err := s.p.Rename(a, b); err != nil {
+					assert.ErrorIs(s.T(), err, fserrors.ENoEnt)
+				}
+				if err := s.p.Rename(b, a); err != nil {
+					assert.ErrorIs(s.T(), err, fserrors.ENoEnt)
+				}
+			}
+		}
+	}
+	s.runConcurrently(
+		// Move q between p and the root
+		moveBackAndForth("/p/q", "/q"),
+		// Move r between q and s, wherever q happens to be
+		moveBackAndForth("/p/q/r", "/s/r"),
+		moveBackAndForth("/q/r", "/s/r"),
+		// Move f between r and q, wherever they happen to be
+		moveBackAndForth("/p/q/r/f", "/p/q/f"),
+		moveBackAndForth("/s/r/f", "/s/f"),
+	)
+	s.assertTreeIsConsistent()
+	files, _ := s.countEntries()
+	assert.Equal(s.T(), 1, files, "f must exist exactly once")
+}
+
+func (s *RenameConcurrencyTestSuite) TestRenamesWhileWalking() {
+	assert.Nil(s.T(), s.p.MakeDirectoryWithAncestors("/a/b/c"))
+	assert.Nil(s.T(), s.p.MakeDirectoryWithAncestors("/a/zzz"))
+	_, err := s.p.CreateFile("/a/b/c/file")
+	assert.Nil(s.T(), err)
+
+	stop := make(chan struct{})
+	renamer := func() {
+		defer close(stop)
+		for n := 0; n < renamesPerRenamer; n++ {
+			assert.Nil(s.T(), s.p.Rename("/a/b", "/a/zzz/b"))
+			assert.Nil(s.T(), s.p.Rename("/a/zzz/b", "/a/b"))
+		}
+	}
+	walker := func() {
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			// A walk that races with a rename may find that an entry it listed has since moved.  It
+			// must never visit a path twice.
+			visited := map[string]bool{}
+			walkErr := s.p.Walk("/", func(path string, fileInfo *directory.FileInfo, err error) error {
+				if err != nil {
+					assert.ErrorIs(s.T(), err, fserrors.ENoEnt)
+					return nil
+				}
+				assert.False(s.T(), visited[path], "path %s visited twice", path)
+				visited[path] = true
+				return nil
+			})
+			assert.Nil(s.T(), walkErr)
+		}
+	}
+	s.runConcurrently(renamer, walker, walker)
+	s.assertTreeIsConsistent()
+}
+
+// countEntries counts the files and directories (including the root) in the tree
+func (s *RenameConcurrencyTestSuite) countEntries() (int, int) {
+	files, dirs := 0, 0
+	err := s.p.Walk("/", func(path string, fileInfo *directory.FileInfo, err error) error {
+		assert.Nil(s.T(), err)
+		if fileInfo.Type == directory.DirectoryType {
+			dirs++
+		} else {
+			files++
+		}
+		return nil
+	})
+	assert.Nil(s.T(), err)
+	return files, dirs
+}
+
+// assertTreeIsConsistent checks that every directory in the tree can be reached by its path and
+// that the reverse path lookup for each directory produces that same path
+func (s *RenameConcurrencyTestSuite) assertTreeIsConsistent() {
+	other := process.NewProcessFilesystemContext(s.fs)
+	err := s.p.Walk("/", func(path string, fileInfo *directory.FileInfo, err error) error {
+		assert.Nil(s.T(), err)
+		if fileInfo.Type != directory.DirectoryType {
+			return nil
+		}
+		assert.Nil(s.T(), other.ChangeDirectory(path))
+		workdir, err := other.WorkingDirectory()
+		assert.Nil(s.T(), err)
+		assert.Equal(s.T(), path, workdir)
+		return nil
+	})
+	assert.Nil(s.T(), err)
+}
+
+func TestRenameConcurrencyTestSuite(t *testing.T) {
+	suite.Run(t, new(RenameConcurrencyTestSuite))
+}
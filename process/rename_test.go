@@ -2,6 +2,9 @@ package process_test
 
 import (
 	"github.com/manderson5192/memfs/directory"
+	"github.com/manderson5192/memfs/filepath"
+	"github.com/manderson5192/memfs/fserrors"
+	"github.com/manderson5192/memfs/os"
 	"github.com/manderson5192/memfs/process"
 	"github.com/stretchr/testify/assert"
 )
@@ -84,3 +87,338 @@ func (s *ProcessTestSuite) TestRenameMixedPaths() {
 		"/a/zzz",
 	}, paths)
 }
+
+func (s *ProcessTestSuite) TestRenameNoReplaceOntoExistingFile() {
+	assert.Nil(s.T(), s.p.WriteFile("/a/other_file", []byte("other"), 0))
+	err := s.p.RenameNoReplace("/a/foobar_file", "/a/other_file")
+	assert.ErrorIs(s.T(), err, fserrors.EExist)
+	// Both files are unchanged
+	data, err := s.p.ReadFile("/a/foobar_file")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []byte("hello!"), data)
+	data, err = s.p.ReadFile("/a/other_file")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []byte("other"), data)
+
+	// The same holds across directories and for directories
+	assert.ErrorIs(s.T(), s.p.RenameNoReplace("/a/other_file", "/a/b/c"), fserrors.EExist)
+	assert.ErrorIs(s.T(), s.p.RenameNoReplace("/a/b/a", "/a/b/c"), fserrors.EExist)
+	assert.ErrorIs(s.T(), s.p.RenameNoReplace("/a/foobar_file", "/a/foobar_file"), fserrors.EExist)
+}
+
+func (s *ProcessTestSuite) TestRenameNoReplaceToFreeName() {
+	assert.Nil(s.T(), s.p.RenameNoReplace("/a/foobar_file", "/a/renamed_file"))
+	assert.Nil(s.T(), s.p.RenameNoReplace("/a/renamed_file", "/a/b/moved_file"))
+	assert.Nil(s.T(), s.p.RenameNoReplace("/a/b/c", "/a/new_c"))
+	assert.False(s.T(), s.p.Exists("/a/foobar_file"))
+	data, err := s.p.ReadFile("/a/b/moved_file")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []byte("hello!"), data)
+	isDir, err := s.p.IsDir("/a/new_c")
+	assert.Nil(s.T(), err)
+	assert.True(s.T(), isDir)
+}
+
+func (s *ProcessTestSuite) TestRenameExchangeFiles() {
+	assert.Nil(s.T(), s.p.WriteFile("/a/b/other_file", []byte("other"), 0))
+	f, err := s.p.OpenFile("/a/foobar_file", os.O_RDONLY)
+	assert.Nil(s.T(), err)
+	assert.Nil(s.T(), s.p.RenameExchange("/a/foobar_file", "/a/b/other_file"))
+
+	data, err := s.p.ReadFile("/a/foobar_file")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []byte("other"), data)
+	data, err = s.p.ReadFile("/a/b/other_file")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []byte("hello!"), data)
+	// The open file followed its inode to the other directory
+	name, err := f.Name()
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), "/a/b/other_file", name)
+
+	// Exchanging within a single directory works too
+	assert.Nil(s.T(), s.p.WriteFile("/a/b/third_file", []byte("third"), 0))
+	assert.Nil(s.T(), s.p.RenameExchange("/a/b/other_file", "/a/b/third_file"))
+	data, err = s.p.ReadFile("/a/b/third_file")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []byte("hello!"), data)
+}
+
+func (s *ProcessTestSuite) TestRenameExchangeDirectories() {
+	assert.Nil(s.T(), s.p.ChangeDirectory("/a/b/c"))
+	assert.Nil(s.T(), s.p.RenameExchange("/a/b", "/a/zzz"))
+	// The working directory moved along with its ancestor
+	s.assertWorkingDirectory("/a/zzz/c")
+	entries, err := s.p.ListDirectorySorted("/a/zzz")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []directory.DirectoryEntry{
+		{Name: "a", Type: directory.DirectoryType},
+		{Name: "c", Type: directory.DirectoryType},
+	}, entries)
+	entries, err = s.p.ListDirectorySorted("/a/b")
+	assert.Nil(s.T(), err)
+	assert.Empty(s.T(), entries)
+
+	// Directories can also be exchanged across parents
+	assert.Nil(s.T(), s.p.RenameExchange("/a/zzz/c", "/a/b"))
+	s.assertWorkingDirectory("/a/b")
+}
+
+func (s *ProcessTestSuite) TestRenameExchangeFileWithDirectory() {
+	assert.Nil(s.T(), s.p.ChangeDirectory("/a/b/c"))
+	assert.Nil(s.T(), s.p.RenameExchange("/a/foobar_file", "/a/b"))
+	s.assertWorkingDirectory("/a/foobar_file/c")
+	data, err := s.p.ReadFile("/a/b")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []byte("hello!"), data)
+	isDir, err := s.p.IsDir("/a/foobar_file")
+	assert.Nil(s.T(), err)
+	assert.True(s.T(), isDir)
+}
+
+func (s *ProcessTestSuite) TestRenameExchangeErrors() {
+	assert.ErrorIs(s.T(), s.p.RenameExchange("/a/foobar_file", "/a/missing"), fserrors.ENoEnt)
+	assert.ErrorIs(s.T(), s.p.RenameExchange("/a/missing", "/a/foobar_file"), fserrors.ENoEnt)
+	// A directory can't be exchanged with one of its descendants
+	assert.ErrorIs(s.T(), s.p.RenameExchange("/a/b", "/a/b/c"), fserrors.EInval)
+	assert.ErrorIs(s.T(), s.p.RenameExchange("/a/b/c", "/a/b"), fserrors.EInval)
+	// Nothing changed
+	isDir, err := s.p.IsDir("/a/b/c")
+	assert.Nil(s.T(), err)
+	assert.True(s.T(), isDir)
+}
+
+// createFiles creates a file at each of paths whose contents are its own path
+func (s *ProcessTestSuite) createFiles(paths ...string) {
+	for _, path := range paths {
+		f, err := s.p.CreateFile(path)
+		assert.Nil(s.T(), err)
+		assert.Nil(s.T(), f.TruncateAndWriteAll([]byte(path)))
+	}
+}
+
+func (s *ProcessTestSuite) assertFileContents(path, expected string) {
+	f, err := s.p.OpenFile(path, os.O_RDONLY)
+	assert.Nil(s.T(), err)
+	if err != nil {
+		return
+	}
+	data, err := f.ReadAll()
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), expected, string(data))
+}
+
+func (s *ProcessTestSuite) listNames(dir string) []string {
+	entries, err := s.p.ListDirectorySorted(dir)
+	assert.Nil(s.T(), err)
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, entry.Name)
+	}
+	return names
+}
+
+func (s *ProcessTestSuite) TestMoveGlob() {
+	s.createFiles("/a/b/one.txt", "/a/b/two.txt", "/a/b/three.md")
+	assert.Nil(s.T(), s.p.ChangeDirectory("/a/b"))
+	moved, err := s.p.MoveGlob("*.txt", "/a/zzz")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []string{"one.txt", "two.txt"}, moved)
+	assert.Equal(s.T(), []string{"a", "c", "three.md"}, s.listNames("/a/b"))
+	assert.Equal(s.T(), []string{"one.txt", "two.txt"}, s.listNames("/a/zzz"))
+	s.assertFileContents("/a/zzz/one.txt", "/a/b/one.txt")
+
+	// Matching nothing is not an error
+	moved, err = s.p.MoveGlob("*.txt", "/a/zzz")
+	assert.Nil(s.T(), err)
+	assert.Empty(s.T(), moved)
+}
+
+func (s *ProcessTestSuite) TestMoveGlobMultipleComponents() {
+	s.createFiles("/a/b/a/x.txt", "/a/b/c/y.txt", "/a/b/c/z.md")
+	moved, err := s.p.MoveGlob("/a/b/?/*.txt", "/a")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []string{"/a/b/a/x.txt", "/a/b/c/y.txt"}, moved)
+	assert.Equal(s.T(), []string{"b", "foobar_file", "x.txt", "y.txt", "zzz"}, s.listNames("/a"))
+
+	// Directories are moved too
+	moved, err = s.p.MoveGlob("/a/b/*", "/a/zzz")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []string{"/a/b/a", "/a/b/c"}, moved)
+	assert.Equal(s.T(), []string{"a", "c"}, s.listNames("/a/zzz"))
+	assert.Equal(s.T(), []string{"z.md"}, s.listNames("/a/zzz/c"))
+}
+
+func (s *ProcessTestSuite) TestMoveGlobCollision() {
+	s.createFiles("/a/b/one.txt", "/a/b/two.txt", "/a/zzz/two.txt")
+
+	// MoveGlobNoReplace stops at the collision, having moved only the matches before it
+	moved, err := s.p.MoveGlobNoReplace("/a/b/*.txt", "/a/zzz")
+	assert.ErrorIs(s.T(), err, fserrors.EExist)
+	assert.Equal(s.T(), []string{"/a/b/one.txt"}, moved)
+	assert.Equal(s.T(), []string{"a", "c", "two.txt"}, s.listNames("/a/b"))
+	s.assertFileContents("/a/zzz/two.txt", "/a/zzz/two.txt")
+
+	// MoveGlob replaces the existing file
+	moved, err = s.p.MoveGlob("/a/b/*.txt", "/a/zzz")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []string{"/a/b/two.txt"}, moved)
+	assert.Equal(s.T(), []string{"one.txt", "two.txt"}, s.listNames("/a/zzz"))
+	s.assertFileContents("/a/zzz/two.txt", "/a/b/two.txt")
+}
+
+func (s *ProcessTestSuite) TestMoveGlobErrors() {
+	moved, err := s.p.MoveGlob("/a/*", "/a/foobar_file")
+	assert.ErrorIs(s.T(), err, fserrors.ENotDir)
+	assert.Empty(s.T(), moved)
+
+	moved, err = s.p.MoveGlob("/a/*", "/nonexistent")
+	assert.ErrorIs(s.T(), err, fserrors.ENoEnt)
+	assert.Empty(s.T(), moved)
+
+	moved, err = s.p.MoveGlob("/a/[", "/a/zzz")
+	assert.ErrorIs(s.T(), err, filepath.ErrBadPattern)
+	assert.Empty(s.T(), moved)
+
+	// A directory can't be moved into itself
+	moved, err = s.p.MoveGlob("/a/z*", "/a/zzz")
+	assert.ErrorIs(s.T(), err, fserrors.EInval)
+	assert.Empty(s.T(), moved)
+}
+
+func (s *ProcessTestSuite) TestFileNameTracksRenames() {
+	f, err := s.p.OpenFile("/a/foobar_file", os.O_RDONLY)
+	assert.Nil(s.T(), err)
+	name, err := f.Name()
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), "/a/foobar_file", name)
+
+	// Renaming the file itself
+	assert.Nil(s.T(), s.p.Rename("/a/foobar_file", "/a/b/renamed"))
+	name, err = f.Name()
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), "/a/b/renamed", name)
+
+	// Renaming one of the file's ancestors
+	assert.Nil(s.T(), s.p.Rename("/a/b", "/a/zzz/moved"))
+	name, err = f.Name()
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), "/a/zzz/moved/renamed", name)
+
+	// A deleted file has no name
+	assert.Nil(s.T(), s.p.DeleteFile("/a/zzz/moved/renamed"))
+	_, err = f.Name()
+	assert.ErrorIs(s.T(), err, fserrors.ENoEnt)
+}
+
+func (s *ProcessTestSuite) TestRenameOverOpenFileOrphansIt() {
+	for _, dst := range []string{"/a/overwritten", "/a/b/overwritten"} {
+		assert.Nil(s.T(), s.p.WriteFile("/a/src_file", []byte("new content"), 0))
+		assert.Nil(s.T(), s.p.WriteFile(dst, []byte("old content"), 0))
+		oldHandle, err := s.p.OpenFile(dst, os.O_RDWR)
+		assert.Nil(s.T(), err)
+		assert.Nil(s.T(), s.p.Rename("/a/src_file", dst))
+
+		// The handle keeps reading and writing the overwritten file, which no longer has a name
+		data, err := oldHandle.ReadAll()
+		assert.Nil(s.T(), err)
+		assert.Equal(s.T(), "old content", string(data))
+		_, err = oldHandle.Name()
+		assert.ErrorIs(s.T(), err, fserrors.ENoEnt)
+		_, err = oldHandle.WriteAt([]byte("OLD"), 0)
+		assert.Nil(s.T(), err)
+		data, err = oldHandle.ReadAll()
+		assert.Nil(s.T(), err)
+		assert.Equal(s.T(), "OLD content", string(data))
+
+		// The path refers to the renamed file, which the orphaned handle's writes don't affect
+		data, err = s.p.ReadFile(dst)
+		assert.Nil(s.T(), err)
+		assert.Equal(s.T(), "new content", string(data))
+		newHandle, err := s.p.OpenFile(dst, os.O_RDONLY)
+		assert.Nil(s.T(), err)
+		data, err = newHandle.ReadAll()
+		assert.Nil(s.T(), err)
+		assert.Equal(s.T(), "new content", string(data))
+		assert.False(s.T(), s.p.Exists("/a/src_file"))
+		assert.Nil(s.T(), oldHandle.Close())
+		assert.Nil(s.T(), newHandle.Close())
+	}
+}
+
+func (s *ProcessTestSuite) TestRenameAcrossTypesLeavesOpenFileAlone() {
+	f, err := s.p.OpenFile("/a/foobar_file", os.O_RDONLY)
+	assert.Nil(s.T(), err)
+	defer f.Close()
+
+	// Neither a file nor a directory can replace the other, so the open file stays linked
+	assert.ErrorIs(s.T(), s.p.Rename("/a/zzz", "/a/foobar_file"), fserrors.ENotDir)
+	assert.Nil(s.T(), s.p.WriteFile("/a/src_file", []byte("new content"), 0))
+	assert.ErrorIs(s.T(), s.p.Rename("/a/src_file", "/a/zzz"), fserrors.EIsDir)
+	name, err := f.Name()
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), "/a/foobar_file", name)
+	data, err := f.ReadAll()
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), "hello!", string(data))
+	assert.True(s.T(), s.p.Exists("/a/src_file"))
+	isDir, err := s.p.IsDir("/a/zzz")
+	assert.Nil(s.T(), err)
+	assert.True(s.T(), isDir)
+}
+
+func (s *ProcessTestSuite) TestCanRenameMatchesRename() {
+	s.createFiles("/a/b/c/file", "/a/zzz/file")
+	assert.Nil(s.T(), s.p.MakeDirectory("/a/empty"))
+	for _, tc := range []struct {
+		src, dst string
+		err      error
+	}{
+		{"/a/missing", "/a/x", fserrors.ENoEnt},
+		{"/a/missing/x", "/a/x", fserrors.ENoEnt},
+		{"/a/b", "/a/missing/b", fserrors.ENoEnt},
+		{"/a/foobar_file/", "/a/x", fserrors.ENotDir},
+		{"/a/foobar_file", "/a/x/", fserrors.ENotDir},
+		{"/a/foobar_file/x", "/a/x", fserrors.ENotDir},
+		{"/a/b", "/a/b/c/b", fserrors.EInval},
+		{"/a/b", "/a/b/b", fserrors.EInval},
+		{"/a/b/..", "/a/x", fserrors.EInval},
+		{"/a/b", "/a/b/c/.", fserrors.EInval},
+		{"/a/b", "/a/zzz", fserrors.ENotEmpty},
+		{"/a/b/c", "/a/b", fserrors.ENotEmpty},
+		{"/a/b/c", "/a", fserrors.ENotEmpty},
+		{"/a/b", "/a/foobar_file", fserrors.ENotDir},
+		{"/a/foobar_file", "/a/empty", fserrors.EIsDir},
+		{"/a/foobar_file", "/a/x\x00", fserrors.EInval},
+	} {
+		err := s.p.CanRename(tc.src, tc.dst)
+		assert.ErrorIs(s.T(), err, tc.err, "CanRename(%q, %q)", tc.src, tc.dst)
+		assert.Equal(s.T(), err.Error(), s.p.Rename(tc.src, tc.dst).Error(), "Rename(%q, %q)", tc.src, tc.dst)
+	}
+
+	// Nothing was moved by the checks or the failed renames
+	s.assertFileContents("/a/foobar_file", "hello!")
+	assert.True(s.T(), s.p.Exists("/a/b/c/file"))
+	assert.Equal(s.T(), []string{"b", "empty", "foobar_file", "zzz"}, s.listNames("/a"))
+}
+
+func (s *ProcessTestSuite) TestCanRenameDoesNotMove() {
+	for _, tc := range [][2]string{
+		{"/a/foobar_file", "/a/b/renamed"},
+		{"/a/foobar_file", "/a/foobar_file"},
+		{"/a/b", "/a/zzz"},
+		{"/a/b/c", "/a/b/a"},
+		{"a/foobar_file", "/a/renamed"},
+	} {
+		assert.Nil(s.T(), s.p.CanRename(tc[0], tc[1]), "CanRename(%q, %q)", tc[0], tc[1])
+	}
+	assert.Equal(s.T(), []string{"b", "foobar_file", "zzz"}, s.listNames("/a"))
+	assert.Equal(s.T(), []string{"a", "c"}, s.listNames("/a/b"))
+
+	// A check reflects the tree at the time it is made
+	s.createFiles("/a/zzz/file")
+	assert.ErrorIs(s.T(), s.p.CanRename("/a/b", "/a/zzz"), fserrors.ENotEmpty)
+	assert.Nil(s.T(), s.p.DeleteFile("/a/zzz/file"))
+	assert.Nil(s.T(), s.p.CanRename("/a/b", "/a/zzz"))
+	assert.Nil(s.T(), s.p.Rename("/a/b", "/a/zzz"))
+}
@@ -0,0 +1,39 @@
+package process
+
+import (
+	"github.com/manderson5192/memfs/directory"
+	"github.com/manderson5192/memfs/file"
+	"github.com/manderson5192/memfs/os"
+	"github.com/pkg/errors"
+)
+
+func (p *processContext) SameFile(path1, path2 string) (bool, error) {
+	dir1, file1, err := p.lookupEntry(path1)
+	if err != nil {
+		return false, errors.Wrapf(err, "could not compare '%s' and '%s'", path1, path2)
+	}
+	dir2, file2, err := p.lookupEntry(path2)
+	if err != nil {
+		return false, errors.Wrapf(err, "could not compare '%s' and '%s'", path1, path2)
+	}
+	if dir1 != nil {
+		return dir1.Equals(dir2), nil
+	}
+	return file1.Equals(file2), nil
+}
+
+// lookupEntry resolves path to either a Directory or a read-only File (whichever it is), so that the
+// caller can compare the underlying inode with Equals()
+func (p *processContext) lookupEntry(path string) (directory.Directory, file.File, error) {
+	info, err := p.Stat(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	relativePath, baseDir := p.toCleanRelativePathAndBaseDir(path)
+	if info.Type == directory.DirectoryType {
+		dir, err := baseDir.LookupSubdirectory(relativePath)
+		return dir, nil, err
+	}
+	f, err := baseDir.OpenFile(relativePath, os.O_RDONLY)
+	return nil, f, err
+}
@@ -0,0 +1,41 @@
+package process_test
+
+import (
+	"github.com/manderson5192/memfs/fserrors"
+	"github.com/stretchr/testify/assert"
+)
+
+func (s *ProcessTestSuite) TestSameFileAliasedPaths() {
+	assert.Nil(s.T(), s.p.ChangeDirectory("/a/b"))
+	same, err := s.p.SameFile("/a/foobar_file", "../foobar_file")
+	assert.Nil(s.T(), err)
+	assert.True(s.T(), same)
+
+	same, err = s.p.SameFile("/a/b/c", "c/../../b/c/")
+	assert.Nil(s.T(), err)
+	assert.True(s.T(), same)
+}
+
+func (s *ProcessTestSuite) TestSameFileDistinctFilesWithSameContents() {
+	assert.Nil(s.T(), s.p.WriteFile("/a/copy", []byte("hello!"), 0))
+	same, err := s.p.SameFile("/a/foobar_file", "/a/copy")
+	assert.Nil(s.T(), err)
+	assert.False(s.T(), same)
+}
+
+func (s *ProcessTestSuite) TestSameFileFileAndDirectory() {
+	same, err := s.p.SameFile("/a/foobar_file", "/a/b")
+	assert.Nil(s.T(), err)
+	assert.False(s.T(), same)
+
+	same, err = s.p.SameFile("/a/b", "/a/b/a")
+	assert.Nil(s.T(), err)
+	assert.False(s.T(), same)
+}
+
+func (s *ProcessTestSuite) TestSameFileNoExist() {
+	_, err := s.p.SameFile("/a/foobar_file", "/a/noexist")
+	assert.ErrorIs(s.T(), err, fserrors.ENoEnt)
+	_, err = s.p.SameFile("/a/noexist", "/a/foobar_file")
+	assert.ErrorIs(s.T(), err, fserrors.ENoEnt)
+}
@@ -13,3 +13,24 @@ func (p *processContext) Stat(path string) (*directory.FileInfo, error) {
 	}
 	return fileInfo, nil
 }
+
+func (p *processContext) Exists(path string) bool {
+	_, err := p.Stat(path)
+	return err == nil
+}
+
+func (p *processContext) IsDir(path string) (bool, error) {
+	info, err := p.Stat(path)
+	if err != nil {
+		return false, err
+	}
+	return info.Type == directory.DirectoryType, nil
+}
+
+func (p *processContext) IsFile(path string) (bool, error) {
+	info, err := p.Stat(path)
+	if err != nil {
+		return false, err
+	}
+	return info.Type == directory.FileType, nil
+}
@@ -1,6 +1,8 @@
 package process_test
 
 import (
+	"io/fs"
+
 	"github.com/manderson5192/memfs/directory"
 	"github.com/manderson5192/memfs/fserrors"
 	"github.com/stretchr/testify/assert"
@@ -9,36 +11,68 @@ import (
 func (s *ProcessTestSuite) TestStatRootDir() {
 	info, err := s.p.Stat("/")
 	assert.Nil(s.T(), err)
+	assert.NotZero(s.T(), info.Ino)
+	assert.False(s.T(), info.ModTime.IsZero())
 	assert.Equal(s.T(), directory.FileInfo{
-		Size: 1,
-		Type: directory.DirectoryType,
+		Name:       "/",
+		Size:       1,
+		Type:       directory.DirectoryType,
+		Ino:        info.Ino,
+		ModTime:    info.ModTime,
+		AccessTime: info.AccessTime,
+		ChangeTime: info.ChangeTime,
+		Perm:       0777,
 	}, *info)
 }
 
 func (s *ProcessTestSuite) TestStatOnDir() {
 	info, err := s.p.Stat("/a")
 	assert.Nil(s.T(), err)
+	assert.NotZero(s.T(), info.Ino)
+	assert.False(s.T(), info.ModTime.IsZero())
 	assert.Equal(s.T(), directory.FileInfo{
-		Size: 3,
-		Type: directory.DirectoryType,
+		Name:       "a",
+		Size:       3,
+		Type:       directory.DirectoryType,
+		Ino:        info.Ino,
+		ModTime:    info.ModTime,
+		AccessTime: info.AccessTime,
+		ChangeTime: info.ChangeTime,
+		Perm:       0755,
 	}, *info)
 }
 
 func (s *ProcessTestSuite) TestStatOnDirTrailingSlash() {
 	info, err := s.p.Stat("/a/")
 	assert.Nil(s.T(), err)
+	assert.NotZero(s.T(), info.Ino)
+	assert.False(s.T(), info.ModTime.IsZero())
 	assert.Equal(s.T(), directory.FileInfo{
-		Size: 3,
-		Type: directory.DirectoryType,
+		Name:       "a",
+		Size:       3,
+		Type:       directory.DirectoryType,
+		Ino:        info.Ino,
+		ModTime:    info.ModTime,
+		AccessTime: info.AccessTime,
+		ChangeTime: info.ChangeTime,
+		Perm:       0755,
 	}, *info)
 }
 
 func (s *ProcessTestSuite) TestStatOnFile() {
 	info, err := s.p.Stat("/a/foobar_file")
 	assert.Nil(s.T(), err)
+	assert.NotZero(s.T(), info.Ino)
+	assert.False(s.T(), info.ModTime.IsZero())
 	assert.Equal(s.T(), directory.FileInfo{
-		Size: 6,
-		Type: directory.FileType,
+		Name:       "foobar_file",
+		Size:       6,
+		Type:       directory.FileType,
+		Ino:        info.Ino,
+		ModTime:    info.ModTime,
+		AccessTime: info.AccessTime,
+		ChangeTime: info.ChangeTime,
+		Perm:       0644,
 	}, *info)
 }
 
@@ -46,6 +80,17 @@ func (s *ProcessTestSuite) TestStatOnFileTrailingSlash() {
 	_, err := s.p.Stat("/a/foobar_file/")
 	assert.NotNil(s.T(), err)
 	assert.ErrorIs(s.T(), err, fserrors.ENotDir)
+	_, err = s.p.Stat("/a/foobar_file//")
+	assert.ErrorIs(s.T(), err, fserrors.ENotDir)
+	assert.Nil(s.T(), s.p.ChangeDirectory("/a"))
+	_, err = s.p.Stat("foobar_file/")
+	assert.ErrorIs(s.T(), err, fserrors.ENotDir)
+
+	// The process layer preserves the Directory's error
+	_, err = s.fs.RootDirectory().Stat("a/foobar_file/")
+	assert.ErrorIs(s.T(), err, fserrors.ENotDir)
+	_, err = s.p.Stat("foobar_file")
+	assert.Nil(s.T(), err)
 }
 
 func (s *ProcessTestSuite) TestStatNoExist() {
@@ -54,3 +99,113 @@ func (s *ProcessTestSuite) TestStatNoExist() {
 	assert.NotNil(s.T(), err)
 	assert.ErrorIs(s.T(), err, fserrors.ENoEnt)
 }
+
+func (s *ProcessTestSuite) TestExists() {
+	assert.True(s.T(), s.p.Exists("/a"))
+	assert.True(s.T(), s.p.Exists("/a/foobar_file"))
+	assert.False(s.T(), s.p.Exists("/a/noexist"))
+	assert.False(s.T(), s.p.Exists("/a/foobar_file/noexist"))
+}
+
+func (s *ProcessTestSuite) TestIsDir() {
+	isDir, err := s.p.IsDir("/a/b")
+	assert.Nil(s.T(), err)
+	assert.True(s.T(), isDir)
+
+	isDir, err = s.p.IsDir("/a/foobar_file")
+	assert.Nil(s.T(), err)
+	assert.False(s.T(), isDir)
+
+	isDir, err = s.p.IsDir("/a/noexist")
+	assert.ErrorIs(s.T(), err, fserrors.ENoEnt)
+	assert.False(s.T(), isDir)
+}
+
+func (s *ProcessTestSuite) TestIsFile() {
+	isFile, err := s.p.IsFile("/a/foobar_file")
+	assert.Nil(s.T(), err)
+	assert.True(s.T(), isFile)
+
+	isFile, err = s.p.IsFile("/a/b")
+	assert.Nil(s.T(), err)
+	assert.False(s.T(), isFile)
+
+	isFile, err = s.p.IsFile("/a/noexist")
+	assert.ErrorIs(s.T(), err, fserrors.ENoEnt)
+	assert.False(s.T(), isFile)
+}
+
+func (s *ProcessTestSuite) TestInoIsStableAcrossRenames() {
+	before, err := s.p.Stat("/a/foobar_file")
+	assert.Nil(s.T(), err)
+	assert.Nil(s.T(), s.p.Rename("/a/foobar_file", "/renamed_file"))
+	after, err := s.p.Stat("/renamed_file")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), before.Ino, after.Ino)
+
+	dirBefore, err := s.p.Stat("/a")
+	assert.Nil(s.T(), err)
+	assert.Nil(s.T(), s.p.Rename("/a", "/renamed_dir"))
+	dirAfter, err := s.p.Stat("/renamed_dir")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), dirBefore.Ino, dirAfter.Ino)
+}
+
+func (s *ProcessTestSuite) TestInoIsDistinctAcrossInodes() {
+	seen := map[uint64]string{}
+	for _, path := range []string{"/", "/a", "/a/foobar_file"} {
+		info, err := s.p.Stat(path)
+		assert.Nil(s.T(), err)
+		_, duplicate := seen[info.Ino]
+		assert.False(s.T(), duplicate, "%s has the same Ino as %s", path, seen[info.Ino])
+		seen[info.Ino] = path
+	}
+	// Recreating a file yields a new inode
+	before, err := s.p.Stat("/a/foobar_file")
+	assert.Nil(s.T(), err)
+	assert.Nil(s.T(), s.p.DeleteFile("/a/foobar_file"))
+	assert.Nil(s.T(), s.p.WriteFile("/a/foobar_file", []byte("foobar"), 0))
+	after, err := s.p.Stat("/a/foobar_file")
+	assert.Nil(s.T(), err)
+	assert.NotEqual(s.T(), before.Ino, after.Ino)
+}
+
+func (s *ProcessTestSuite) TestStatNameOfRelativeDirectoryEntries() {
+	assert.Nil(s.T(), s.p.ChangeDirectory("/a"))
+	info, err := s.p.Stat(".")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), "a", info.Name)
+	info, err = s.p.Stat("..")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), "/", info.Name)
+}
+
+func (s *ProcessTestSuite) TestFileInfoMethodsOnFile() {
+	info, err := s.p.Stat("/a/foobar_file")
+	assert.Nil(s.T(), err)
+	assert.False(s.T(), info.IsDir())
+	assert.Equal(s.T(), fs.FileMode(0644), info.Mode())
+	assert.True(s.T(), info.Mode().IsRegular())
+
+	stdInfo := info.StdFileInfo()
+	assert.Equal(s.T(), "foobar_file", stdInfo.Name())
+	assert.Equal(s.T(), int64(6), stdInfo.Size())
+	assert.False(s.T(), stdInfo.IsDir())
+	assert.Equal(s.T(), info.Mode(), stdInfo.Mode())
+	assert.Equal(s.T(), info.ModTime, stdInfo.ModTime())
+	assert.Equal(s.T(), info, stdInfo.Sys())
+}
+
+func (s *ProcessTestSuite) TestFileInfoMethodsOnDirectory() {
+	info, err := s.p.Stat("/a")
+	assert.Nil(s.T(), err)
+	assert.True(s.T(), info.IsDir())
+	assert.Equal(s.T(), fs.ModeDir|0755, info.Mode())
+
+	stdInfo := info.StdFileInfo()
+	assert.Equal(s.T(), "a", stdInfo.Name())
+	assert.Equal(s.T(), int64(3), stdInfo.Size())
+	assert.True(s.T(), stdInfo.IsDir())
+	assert.True(s.T(), stdInfo.Mode().IsDir())
+	assert.Equal(s.T(), info, stdInfo.Sys())
+}
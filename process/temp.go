@@ -0,0 +1,82 @@
+package process
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"strconv"
+	"strings"
+
+	"github.com/manderson5192/memfs/file"
+	"github.com/manderson5192/memfs/fserrors"
+	"github.com/manderson5192/memfs/os"
+	"github.com/pkg/errors"
+)
+
+// maxTempAttempts bounds the number of names that CreateTemp() and MkdirTemp() will try before
+// giving up, in case every name they generate is somehow taken
+const maxTempAttempts = 10000
+
+func (p *processContext) CreateTemp(dir, pattern string) (file.File, string, error) {
+	var f file.File
+	path, err := p.createTempEntry(dir, pattern, func(path string) error {
+		var err error
+		f, err = p.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL)
+		return err
+	})
+	if err != nil {
+		return nil, "", errors.Wrapf(err, "could not create temporary file")
+	}
+	return f, path, nil
+}
+
+func (p *processContext) MkdirTemp(dir, pattern string) (string, error) {
+	path, err := p.createTempEntry(dir, pattern, p.MakeDirectory)
+	if err != nil {
+		return "", errors.Wrapf(err, "could not create temporary directory")
+	}
+	return path, nil
+}
+
+// createTempEntry repeatedly calls create with a new path in dir that is generated from pattern
+// until create succeeds, returning that path.  create must fail with EEXIST if the path is taken.
+func (p *processContext) createTempEntry(dir, pattern string, create func(path string) error) (string, error) {
+	if p.parser().ContainsSeparator(pattern) {
+		return "", errors.Wrapf(fserrors.EInval, "pattern '%s' contains a path separator", pattern)
+	}
+	prefix, suffix := pattern, ""
+	if idx := strings.LastIndex(pattern, "*"); idx != -1 {
+		prefix, suffix = pattern[:idx], pattern[idx+1:]
+	}
+	if dir == "" {
+		workdir, err := p.WorkingDirectory()
+		if err != nil {
+			return "", err
+		}
+		dir = workdir
+	}
+	for attempt := 0; attempt < maxTempAttempts; attempt++ {
+		random, err := randomTempString()
+		if err != nil {
+			return "", err
+		}
+		path := p.parser().Join(dir, prefix+random+suffix)
+		err = create(path)
+		if err == nil {
+			return path, nil
+		}
+		if !errors.Is(err, fserrors.EExist) {
+			return "", err
+		}
+	}
+	return "", errors.Wrapf(fserrors.EExist, "could not find an unused name for pattern '%s' in '%s'", pattern, dir)
+}
+
+// randomTempString returns a random string for use in a temporary entry's name.  It uses a
+// cryptographically secure source so that the names are hard to predict.
+func randomTempString() (string, error) {
+	var buf [8]byte
+	if _, err := rand.Read(buf[:]); err != nil {
+		return "", errors.Wrapf(err, "could not generate a random name")
+	}
+	return strconv.FormatUint(binary.LittleEndian.Uint64(buf[:]), 36), nil
+}
@@ -0,0 +1,71 @@
+package process_test
+
+import (
+	"strings"
+
+	"github.com/manderson5192/memfs/directory"
+	"github.com/manderson5192/memfs/fserrors"
+	"github.com/stretchr/testify/assert"
+)
+
+func (s *ProcessTestSuite) TestCreateTemp() {
+	f, path, err := s.p.CreateTemp("/a/b", "log-*.txt")
+	assert.Nil(s.T(), err)
+	assert.True(s.T(), strings.HasPrefix(path, "/a/b/log-"), path)
+	assert.True(s.T(), strings.HasSuffix(path, ".txt"), path)
+	assert.Greater(s.T(), len(path), len("/a/b/log-.txt"))
+
+	// The returned File refers to the file at the returned path
+	_, err = f.Write([]byte("hello"))
+	assert.Nil(s.T(), err)
+	fileInfo, err := s.p.Stat(path)
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), directory.FileType, fileInfo.Type)
+	assert.Equal(s.T(), 5, fileInfo.Size)
+}
+
+func (s *ProcessTestSuite) TestCreateTempDistinctNames() {
+	seen := map[string]bool{}
+	for n := 0; n < 100; n++ {
+		_, path, err := s.p.CreateTemp("/a", "tmp")
+		assert.Nil(s.T(), err)
+		assert.True(s.T(), strings.HasPrefix(path, "/a/tmp"), path)
+		assert.False(s.T(), seen[path], "path %s was chosen twice", path)
+		seen[path] = true
+	}
+	entries, err := s.p.ListDirectory("/a")
+	assert.Nil(s.T(), err)
+	assert.Len(s.T(), entries, 100+3)
+}
+
+func (s *ProcessTestSuite) TestCreateTempInWorkingDirectory() {
+	assert.Nil(s.T(), s.p.ChangeDirectory("/a/b/c"))
+	_, path, err := s.p.CreateTemp("", "*")
+	assert.Nil(s.T(), err)
+	assert.True(s.T(), strings.HasPrefix(path, "/a/b/c/"), path)
+	_, err = s.p.Stat(path)
+	assert.Nil(s.T(), err)
+}
+
+func (s *ProcessTestSuite) TestMkdirTemp() {
+	first, err := s.p.MkdirTemp("/a", "dir-*")
+	assert.Nil(s.T(), err)
+	second, err := s.p.MkdirTemp("/a", "dir-*")
+	assert.Nil(s.T(), err)
+	assert.NotEqual(s.T(), first, second)
+	for _, path := range []string{first, second} {
+		assert.True(s.T(), strings.HasPrefix(path, "/a/dir-"), path)
+		fileInfo, err := s.p.Stat(path)
+		assert.Nil(s.T(), err)
+		assert.Equal(s.T(), directory.DirectoryType, fileInfo.Type)
+	}
+}
+
+func (s *ProcessTestSuite) TestTempErrors() {
+	_, _, err := s.p.CreateTemp("/a", "x/*")
+	assert.ErrorIs(s.T(), err, fserrors.EInval)
+	_, err = s.p.MkdirTemp("/noexist", "*")
+	assert.ErrorIs(s.T(), err, fserrors.ENoEnt)
+	_, _, err = s.p.CreateTemp("/a/foobar_file", "*")
+	assert.ErrorIs(s.T(), err, fserrors.ENotDir)
+}
@@ -0,0 +1,33 @@
+package process
+
+import (
+	"time"
+
+	"github.com/manderson5192/memfs/fserrors"
+	"github.com/pkg/errors"
+)
+
+func (p *processContext) Touch(path string) error {
+	relativePath, baseDir := p.toCleanRelativePathAndBaseDir(path)
+	_, created, err := baseDir.CreateExclusive(relativePath)
+	if err != nil && !errors.Is(err, fserrors.EIsDir) {
+		return errors.Wrapf(err, "could not touch '%s'", path)
+	}
+	if created {
+		// A new file's timestamps are already the current time
+		return nil
+	}
+	now := time.Now()
+	if err := baseDir.Chtimes(relativePath, now, now); err != nil {
+		return errors.Wrapf(err, "could not touch '%s'", path)
+	}
+	return nil
+}
+
+func (p *processContext) Chtimes(path string, accessTime, modTime time.Time) error {
+	relativePath, baseDir := p.toCleanRelativePathAndBaseDir(path)
+	if err := baseDir.Chtimes(relativePath, accessTime, modTime); err != nil {
+		return errors.Wrapf(err, "could not change times of '%s'", path)
+	}
+	return nil
+}
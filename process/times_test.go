@@ -0,0 +1,113 @@
+package process_test
+
+import (
+	"time"
+
+	"github.com/manderson5192/memfs/fserrors"
+	"github.com/manderson5192/memfs/os"
+	"github.com/stretchr/testify/assert"
+)
+
+// longAgo is a time that is well before any test runs
+var longAgo = time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+func (s *ProcessTestSuite) assertTimesAfter(path string, t time.Time) {
+	info, err := s.p.Stat(path)
+	assert.Nil(s.T(), err)
+	assert.True(s.T(), info.ModTime.After(t), "mtime of '%s' should be after %v", path, t)
+	assert.True(s.T(), info.AccessTime.After(t), "atime of '%s' should be after %v", path, t)
+}
+
+func (s *ProcessTestSuite) TestTouchCreatesFile() {
+	before := time.Now().Add(-time.Second)
+	assert.Nil(s.T(), s.p.Touch("/a/new_file"))
+	data, err := s.p.ReadFile("/a/new_file")
+	assert.Nil(s.T(), err)
+	assert.Empty(s.T(), data)
+	s.assertTimesAfter("/a/new_file", before)
+
+	// Relative paths are resolved against the working directory
+	assert.Nil(s.T(), s.p.ChangeDirectory("/a/b"))
+	assert.Nil(s.T(), s.p.Touch("relative_file"))
+	assert.True(s.T(), s.p.Exists("/a/b/relative_file"))
+}
+
+func (s *ProcessTestSuite) TestTouchExistingFile() {
+	assert.Nil(s.T(), s.p.Chtimes("/a/foobar_file", longAgo, longAgo))
+	info, err := s.p.Stat("/a/foobar_file")
+	assert.Nil(s.T(), err)
+	assert.True(s.T(), longAgo.Equal(info.ModTime))
+	assert.True(s.T(), longAgo.Equal(info.AccessTime))
+
+	assert.Nil(s.T(), s.p.Touch("/a/foobar_file"))
+	s.assertTimesAfter("/a/foobar_file", longAgo)
+	data, err := s.p.ReadFile("/a/foobar_file")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []byte("hello!"), data, "touching a file doesn't change its contents")
+}
+
+func (s *ProcessTestSuite) TestTouchDirectory() {
+	assert.Nil(s.T(), s.p.Chtimes("/a/b", longAgo, longAgo))
+	assert.Nil(s.T(), s.p.Touch("/a/b"))
+	s.assertTimesAfter("/a/b", longAgo)
+	entries, err := s.p.ListDirectory("/a/b")
+	assert.Nil(s.T(), err)
+	assert.Len(s.T(), entries, 2)
+}
+
+func (s *ProcessTestSuite) TestTouchErrors() {
+	err := s.p.Touch("/nonexistent/file")
+	assert.ErrorIs(s.T(), err, fserrors.ENoEnt)
+	err = s.p.Touch("/a/foobar_file/file")
+	assert.ErrorIs(s.T(), err, fserrors.ENotDir)
+	err = s.p.Chtimes("/nonexistent", longAgo, longAgo)
+	assert.ErrorIs(s.T(), err, fserrors.ENoEnt)
+}
+
+func (s *ProcessTestSuite) TestWritesUpdateModTime() {
+	assert.Nil(s.T(), s.p.Chtimes("/a/foobar_file", longAgo, longAgo))
+	f, err := s.p.OpenFile("/a/foobar_file", os.O_RDWR)
+	assert.Nil(s.T(), err)
+
+	// Reads don't update the times
+	_, err = f.ReadAll()
+	assert.Nil(s.T(), err)
+	info, err := s.p.Stat("/a/foobar_file")
+	assert.Nil(s.T(), err)
+	assert.True(s.T(), longAgo.Equal(info.ModTime))
+
+	// Writes update the modification time, but not the access time
+	_, err = f.Write([]byte("goodbye"))
+	assert.Nil(s.T(), err)
+	info, err = s.p.Stat("/a/foobar_file")
+	assert.Nil(s.T(), err)
+	assert.True(s.T(), info.ModTime.After(longAgo))
+	assert.True(s.T(), longAgo.Equal(info.AccessTime))
+}
+
+func (s *ProcessTestSuite) TestEntryChangesUpdateDirectoryModTime() {
+	modTime := func(path string) time.Time {
+		info, err := s.p.Stat(path)
+		assert.Nil(s.T(), err)
+		return info.ModTime
+	}
+	operations := []func() error{
+		func() error { return s.p.MakeDirectory("/a/b/new_dir") },
+		func() error { return s.p.RemoveDirectory("/a/b/new_dir") },
+		func() error { return s.p.Touch("/a/b/new_file") },
+		func() error { return s.p.Rename("/a/b/new_file", "/a/b/renamed_file") },
+		func() error { return s.p.DeleteFile("/a/b/renamed_file") },
+	}
+	for _, operation := range operations {
+		assert.Nil(s.T(), s.p.Chtimes("/a/b", longAgo, longAgo))
+		assert.Nil(s.T(), operation())
+		assert.True(s.T(), modTime("/a/b").After(longAgo))
+	}
+
+	// A rename between directories updates both of them
+	assert.Nil(s.T(), s.p.Chtimes("/a/b", longAgo, longAgo))
+	assert.Nil(s.T(), s.p.Chtimes("/a/zzz", longAgo, longAgo))
+	assert.Nil(s.T(), s.p.Rename("/a/b/c", "/a/zzz/c"))
+	assert.True(s.T(), modTime("/a/b").After(longAgo))
+	assert.True(s.T(), modTime("/a/zzz").After(longAgo))
+}
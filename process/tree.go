@@ -0,0 +1,61 @@
+package process
+
+import (
+	"strings"
+
+	"github.com/manderson5192/memfs/directory"
+	"github.com/pkg/errors"
+)
+
+// The connectors that Tree() draws in front of each entry, and the prefixes that it draws in front
+// of the entries beneath it
+const (
+	treeBranch     = "├── "
+	treeLastBranch = "└── "
+	treeContinue   = "│   "
+	treeBlank      = "    "
+)
+
+func (p *processContext) Tree(path string) (string, error) {
+	info, err := p.Stat(path)
+	if err != nil {
+		return "", errors.Wrapf(err, "could not render the tree at '%s'", path)
+	}
+	var builder strings.Builder
+	builder.WriteString(path)
+	if info.IsDir() && !strings.HasSuffix(path, p.separator()) {
+		builder.WriteString(p.separator())
+	}
+	builder.WriteString("\n")
+	if info.IsDir() {
+		if err := p.renderTree(&builder, path, ""); err != nil {
+			return "", errors.Wrapf(err, "could not render the tree at '%s'", path)
+		}
+	}
+	return builder.String(), nil
+}
+
+// renderTree writes a line to builder for each entry beneath the directory at path, in sorted order,
+// preceded by prefix and the appropriate connector
+func (p *processContext) renderTree(builder *strings.Builder, path, prefix string) error {
+	entries, err := p.ListDirectorySorted(path)
+	if err != nil {
+		return err
+	}
+	for idx, entry := range entries {
+		connector, childPrefix := treeBranch, prefix+treeContinue
+		if idx == len(entries)-1 {
+			connector, childPrefix = treeLastBranch, prefix+treeBlank
+		}
+		builder.WriteString(prefix + connector + entry.Name)
+		if entry.Type != directory.DirectoryType {
+			builder.WriteString("\n")
+			continue
+		}
+		builder.WriteString(p.separator() + "\n")
+		if err := p.renderTree(builder, p.parser().Join(path, entry.Name), childPrefix); err != nil {
+			return err
+		}
+	}
+	return nil
+}
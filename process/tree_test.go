@@ -0,0 +1,38 @@
+package process_test
+
+import (
+	"github.com/manderson5192/memfs/fserrors"
+	"github.com/stretchr/testify/assert"
+)
+
+func (s *ProcessTestSuite) TestTree() {
+	s.createFiles("/a/b/c/deep_file", "/a/b/file", "/a/zzz/z")
+	tree, err := s.p.Tree("/a")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), `/a/
+├── b/
+│   ├── a/
+│   ├── c/
+│   │   └── deep_file
+│   └── file
+├── foobar_file
+└── zzz/
+    └── z
+`, tree)
+
+	assert.Nil(s.T(), s.p.ChangeDirectory("/a"))
+	tree, err = s.p.Tree("zzz")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), "zzz/\n└── z\n", tree)
+	tree, err = s.p.Tree("b/a/")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), "b/a/\n", tree)
+	tree, err = s.p.Tree("foobar_file")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), "foobar_file\n", tree)
+}
+
+func (s *ProcessTestSuite) TestTreeNoExist() {
+	_, err := s.p.Tree("/noexist")
+	assert.ErrorIs(s.T(), err, fserrors.ENoEnt)
+}
@@ -0,0 +1,192 @@
+package process
+
+import (
+	"sync"
+
+	"github.com/manderson5192/memfs/directory"
+	"github.com/manderson5192/memfs/file"
+	"github.com/manderson5192/memfs/filesys"
+	"github.com/manderson5192/memfs/fserrors"
+	"github.com/manderson5192/memfs/inode"
+	"github.com/pkg/errors"
+)
+
+// Tx is a transaction begun by ProcessFilesystemContext.Begin().  It is a ProcessFilesystemContext
+// (initially a Clone() of the one that began it) through which the transaction's changes are made,
+// and which ends the transaction by either committing or rolling back those changes.
+//
+// Transactions are lightweight rather than isolated: changes made through a Tx are visible to every
+// other context as soon as they are made, and rolling back restores the whole tree from the
+// snapshot taken by Begin().  (The snapshot itself is a deep copy of the tree, so beginning and
+// rolling back a transaction cost time and memory in proportion to the tree's size; see
+// filesys.Snapshot.)  So that rolling back never discards anyone else's changes, the Tx
+// counts the mutations made through it and through the Directories and Files obtained from it (see
+// filesys.Mutations()), and Rollback() refuses to restore the tree if any other mutation of the
+// filesystem has been made since Begin().  As with filesys.Snapshot.Restore(), handles that were
+// opened before Rollback() refer to inodes that are no longer reachable by path afterwards.
+type Tx interface {
+	ProcessFilesystemContext
+	// Commit ends the transaction and keeps its changes.  It returns EINVAL if the transaction has
+	// already ended.
+	Commit() error
+	// Rollback ends the transaction and reverts the filesystem's tree to its state when Begin() was
+	// called.  The filesystem is frozen while it is restored (see filesys.Freeze()), so concurrent
+	// mutations wait for it, and Rollback must not be called by a goroutine that has frozen the
+	// filesystem.  Every File that was opened through the Tx (or through a Clone() or Chroot() of
+	// it) is revoked (see file.File.Revoke()), so it can no longer be read or written.  The Tx's
+	// working directory is looked up again by path, so it continues to refer to the restored tree.
+	// Rollback doesn't change the context that began the transaction, which may be in use by another
+	// goroutine, so that context's working directory and directory stack still refer to the
+	// directories that were replaced; it should change its working directory again (e.g. with
+	// ChangeDirectory()) to use the restored tree.  It returns EINVAL if the transaction has already
+	// ended, and EBUSY if anything other than the transaction has mutated the filesystem since
+	// Begin(), in which case the transaction is left open (so it may still be committed).
+	Rollback() error
+}
+
+// tx is the state of a transaction that is shared by every context that belongs to it
+type tx struct {
+	snapshot filesys.Snapshot
+	// mutationsAtBegin are the filesystem's mutation counts when snapshot was taken, and mutations
+	// counts the mutations that have been made through the transaction's contexts since then
+	mutationsAtBegin filesys.MutationCounts
+	mutations        inode.MutationCounter
+	mutex            sync.Mutex // synchronizes access to the fields below
+	// files are the Files that have been opened through the transaction's contexts
+	files []file.File
+	ended bool
+}
+
+type txContext struct {
+	*processContext
+}
+
+func (p *processContext) Begin() (Tx, error) {
+	if !p.root.Equals(p.fileSystem.RootDirectory()) {
+		return nil, errors.Wrapf(fserrors.EInval, "cannot begin a transaction in a chrooted context")
+	}
+	// Freeze the filesystem while it is copied, so that the snapshot is a point-in-time copy even if
+	// other contexts mutate it concurrently (e.g. renaming an entry between directories that the
+	// copy has and hasn't reached yet), and so that every mutation that the mutation counts say has
+	// finished is in the snapshot
+	if err := filesys.Freeze(p.fileSystem); err != nil {
+		return nil, errors.Wrapf(err, "could not begin a transaction")
+	}
+	t := &tx{}
+	snapshot, err := filesys.TakeSnapshot(p.fileSystem)
+	if err == nil {
+		t.snapshot = snapshot
+		t.mutationsAtBegin, err = filesys.Mutations(p.fileSystem)
+	}
+	if unfreezeErr := filesys.Unfreeze(p.fileSystem); err == nil {
+		err = unfreezeErr
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not begin a transaction")
+	}
+	ctx := p.Clone().(*processContext)
+	ctx.tx = t
+	ctx.countMutationsIn(&t.mutations)
+	return &txContext{
+		processContext: ctx,
+	}, nil
+}
+
+// countMutationsIn makes the mutations that are made through p, and through every Directory and
+// File that it derives from its directories, count in c (see
+// directory.Directory.WithMutationCounter())
+func (p *processContext) countMutationsIn(c *inode.MutationCounter) {
+	p.root = p.root.WithMutationCounter(c)
+	p.workdir = p.workdir.WithMutationCounter(c)
+	dirStack := make([]directory.Directory, 0, len(p.dirStack))
+	for _, dir := range p.dirStack {
+		dirStack = append(dirStack, dir.WithMutationCounter(c))
+	}
+	p.dirStack = dirStack
+}
+
+// track records that f was opened through p, so that it can be revoked if p's transaction is
+// rolled back.  It returns f.
+func (p *processContext) track(f file.File) file.File {
+	if p.tx == nil {
+		return f
+	}
+	p.tx.mutex.Lock()
+	defer p.tx.mutex.Unlock()
+	if p.tx.ended {
+		return f
+	}
+	p.tx.files = append(p.tx.files, f)
+	return f
+}
+
+// end marks the transaction as ended and returns the Files that were opened through it.  If check
+// is not nil, then the transaction is only ended if check returns nil; otherwise, end returns
+// check's error.
+func (t *tx) end(check func() error) ([]file.File, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.ended {
+		return nil, errors.Wrapf(fserrors.EInval, "transaction has already ended")
+	}
+	if check != nil {
+		if err := check(); err != nil {
+			return nil, err
+		}
+	}
+	t.ended = true
+	files := t.files
+	t.files = nil
+	return files, nil
+}
+
+func (c *txContext) Commit() error {
+	if _, err := c.tx.end(nil); err != nil {
+		return errors.Wrapf(err, "could not commit transaction")
+	}
+	return nil
+}
+
+func (c *txContext) Rollback() error {
+	workdir, _ := c.WorkingDirectory()
+	// Freeze the filesystem so that no other mutation can slip in between checking that only the
+	// transaction has mutated it and restoring the snapshot
+	if err := filesys.Freeze(c.fileSystem); err != nil {
+		return errors.Wrapf(err, "could not roll back transaction")
+	}
+	files, err := c.tx.end(c.checkOnlyMutator)
+	if err == nil {
+		err = c.tx.snapshot.RestoreFrozen(c.fileSystem)
+	}
+	if unfreezeErr := filesys.Unfreeze(c.fileSystem); err == nil {
+		err = unfreezeErr
+	}
+	if err != nil {
+		return errors.Wrapf(err, "could not roll back transaction")
+	}
+	// Revoke the files only once the filesystem is unfrozen, since a write through one of them may
+	// be waiting for the freeze while it holds the file's lock
+	for _, f := range files {
+		f.Revoke()
+	}
+	// A working directory that doesn't exist in the restored tree is left as it was
+	if workdir != "" {
+		_ = c.ChangeDirectory(workdir)
+	}
+	return nil
+}
+
+// checkOnlyMutator returns EBUSY if any mutation of the filesystem that wasn't made through the
+// transaction's contexts has started since the transaction began, or hadn't yet finished when it
+// began.  The filesystem must be frozen, so that every mutation that it doesn't count yet will take
+// effect after the snapshot is restored.
+func (c *txContext) checkOnlyMutator() error {
+	counts, err := filesys.Mutations(c.fileSystem)
+	if err != nil {
+		return err
+	}
+	if counts.Started-c.tx.mutationsAtBegin.Finished > c.tx.mutations.Count() {
+		return errors.Wrapf(fserrors.EBusy, "the filesystem has been mutated outside of the transaction")
+	}
+	return nil
+}
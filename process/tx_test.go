@@ -0,0 +1,268 @@
+package process_test
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/manderson5192/memfs/file"
+	"github.com/manderson5192/memfs/filesys"
+	"github.com/manderson5192/memfs/fserrors"
+	"github.com/manderson5192/memfs/os"
+	"github.com/manderson5192/memfs/process"
+	"github.com/stretchr/testify/assert"
+)
+
+// assertUnchangedSince asserts that s.fs's tree matches the tree captured by snapshot
+func (s *ProcessTestSuite) assertUnchangedSince(snapshot filesys.Snapshot) {
+	diff, err := filesys.Diff(snapshot.Fork(), s.fs)
+	assert.Nil(s.T(), err)
+	assert.Empty(s.T(), diff)
+}
+
+func (s *ProcessTestSuite) TestTxRollbackRestoresTree() {
+	s.createFiles("/a/b/c/file", "/a/zzz/file")
+	before, err := filesys.TakeSnapshot(s.fs)
+	assert.Nil(s.T(), err)
+	assert.Nil(s.T(), s.p.ChangeDirectory("/a/b"))
+
+	tx, err := s.p.Begin()
+	assert.Nil(s.T(), err)
+	assert.Nil(s.T(), tx.WriteFile("c/new_file", []byte("new"), 0))
+	assert.Nil(s.T(), tx.WriteFile("/a/foobar_file", []byte("changed"), 0))
+	assert.Nil(s.T(), tx.DeleteFile("/a/zzz/file"))
+	assert.Nil(s.T(), tx.RemoveDirectory("/a/zzz"))
+	assert.Nil(s.T(), tx.MakeDirectoryWithAncestors("/x/y/z"))
+	assert.Nil(s.T(), tx.Rename("/a/b/c", "/x/c"))
+	assert.Nil(s.T(), tx.ChangeDirectory("/x/y"))
+	// Changes made through the transaction are visible elsewhere right away
+	assert.True(s.T(), s.p.Exists("/x/c/new_file"))
+
+	assert.Nil(s.T(), tx.Rollback())
+	s.assertUnchangedSince(before)
+	assert.False(s.T(), s.p.Exists("/x"))
+
+	// The context that began the transaction is left in the replaced working directory until it
+	// changes directory again
+	_, err = s.p.WorkingDirectory()
+	assert.ErrorIs(s.T(), err, fserrors.ENoEnt)
+	assert.Nil(s.T(), s.p.ChangeDirectory("/a/b"))
+	s.assertFileContents("c/file", "/a/b/c/file")
+	assert.Nil(s.T(), s.p.WriteFile("c/after", []byte("after"), 0))
+	assert.True(s.T(), s.p.Exists("/a/b/c/after"))
+
+	// The transaction's working directory no longer exists, so it is left as it was
+	_, err = tx.WorkingDirectory()
+	assert.ErrorIs(s.T(), err, fserrors.ENoEnt)
+}
+
+func (s *ProcessTestSuite) TestTxRollbackRevokesFiles() {
+	tx, err := s.p.Begin()
+	assert.Nil(s.T(), err)
+	created, err := tx.CreateFile("/a/created")
+	assert.Nil(s.T(), err)
+	existing, err := tx.OpenFile("/a/foobar_file", os.O_RDWR)
+	assert.Nil(s.T(), err)
+	dup := existing.Dup()
+	cloned, err := tx.Clone().OpenFile("/a/foobar_file", os.O_RDONLY)
+	assert.Nil(s.T(), err)
+	outside, err := s.p.OpenFile("/a/foobar_file", os.O_RDONLY)
+	assert.Nil(s.T(), err)
+	_, err = created.Write([]byte("data"))
+	assert.Nil(s.T(), err)
+
+	assert.Nil(s.T(), tx.Rollback())
+	for _, f := range []file.File{created, existing, dup, cloned} {
+		_, err := f.ReadAll()
+		assert.ErrorIs(s.T(), err, fserrors.EBadF)
+	}
+	_, err = existing.WriteAt([]byte("x"), 0)
+	assert.ErrorIs(s.T(), err, fserrors.EBadF)
+	s.assertFileContents("/a/foobar_file", "hello!")
+	assert.False(s.T(), s.p.Exists("/a/created"))
+
+	// A file opened outside of the transaction still works, but it refers to the replaced inode
+	data, err := outside.ReadAll()
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []byte("hello!"), data)
+	assert.True(s.T(), outside.IsDeleted())
+
+	// Files opened through the transaction's context after it ends are not revoked
+	assert.ErrorIs(s.T(), tx.Rollback(), fserrors.EInval)
+	after, err := tx.OpenFile("/a/foobar_file", os.O_RDONLY)
+	assert.Nil(s.T(), err)
+	data, err = after.ReadAll()
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []byte("hello!"), data)
+}
+
+func (s *ProcessTestSuite) TestTxRollbackReleasesLocks() {
+	outside, err := s.p.OpenFile("/a/foobar_file", os.O_RDONLY)
+	assert.Nil(s.T(), err)
+	tx, err := s.p.Begin()
+	assert.Nil(s.T(), err)
+	locked, err := tx.OpenFile("/a/foobar_file", os.O_RDWR)
+	assert.Nil(s.T(), err)
+	assert.Nil(s.T(), locked.Lock())
+	acquired, err := outside.TryLock()
+	assert.Nil(s.T(), err)
+	assert.False(s.T(), acquired)
+
+	// Revoking the transaction's handle releases its lock, so it no longer blocks other handles
+	assert.Nil(s.T(), tx.Rollback())
+	acquired, err = outside.TryLock()
+	assert.Nil(s.T(), err)
+	assert.True(s.T(), acquired)
+	assert.Nil(s.T(), outside.Unlock())
+
+	// The revoked handle can't lock or unlock
+	assert.ErrorIs(s.T(), locked.Unlock(), fserrors.EBadF)
+	assert.ErrorIs(s.T(), locked.Lock(), fserrors.EBadF)
+	_, err = locked.TryLock()
+	assert.ErrorIs(s.T(), err, fserrors.EBadF)
+	assert.ErrorIs(s.T(), locked.RLock(), fserrors.EBadF)
+	acquired, err = outside.TryLock()
+	assert.Nil(s.T(), err)
+	assert.True(s.T(), acquired)
+}
+
+func (s *ProcessTestSuite) TestTxCommitKeepsChanges() {
+	tx, err := s.p.Begin()
+	assert.Nil(s.T(), err)
+	f, err := tx.CreateFile("/a/created")
+	assert.Nil(s.T(), err)
+	assert.Nil(s.T(), tx.DeleteFile("/a/foobar_file"))
+	assert.Nil(s.T(), tx.Commit())
+
+	assert.True(s.T(), s.p.Exists("/a/created"))
+	assert.False(s.T(), s.p.Exists("/a/foobar_file"))
+	_, err = f.Write([]byte("data"))
+	assert.Nil(s.T(), err)
+	s.assertFileContents("/a/created", "data")
+
+	// A transaction ends only once
+	assert.ErrorIs(s.T(), tx.Commit(), fserrors.EInval)
+	assert.ErrorIs(s.T(), tx.Rollback(), fserrors.EInval)
+	assert.True(s.T(), s.p.Exists("/a/created"))
+}
+
+func (s *ProcessTestSuite) TestTxRollbackDuringConcurrentRenames() {
+	// Directories with many entries make copying the tree slow enough for renames to interleave
+	for _, dir := range []string{"/a/b/c", "/a/zzz"} {
+		for i := 0; i < 200; i++ {
+			s.createFiles(fmt.Sprintf("%s/file_%d", dir, i))
+		}
+	}
+	// Rename the file back and forth between sibling subtrees, since a copy of the tree holds
+	// locks on a directory's ancestors while it copies the directory
+	assert.Nil(s.T(), s.p.Rename("/a/foobar_file", "/a/zzz/foobar_file"))
+	renamer := s.p.Clone()
+	// Renames are held off while each transaction is rolled back and the result is inspected
+	var renaming sync.Mutex
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		// The file may be in either directory, so try both directions in turn
+		src, dst := "/a/zzz/foobar_file", "/a/b/c/foobar_file"
+		for ; ; src, dst = dst, src {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			renaming.Lock()
+			_ = renamer.Rename(src, dst)
+			renaming.Unlock()
+		}
+	}()
+	defer func() {
+		close(stop)
+		<-done
+	}()
+	for i := 0; i < 20; i++ {
+		tx, err := s.p.Begin()
+		assert.Nil(s.T(), err)
+		renaming.Lock()
+		// Rolling back would discard any renames that weren't in the snapshot, so it is refused
+		// unless there were none
+		if err := tx.Rollback(); err != nil {
+			renaming.Unlock()
+			assert.ErrorIs(s.T(), err, fserrors.EBusy)
+			assert.Nil(s.T(), tx.Commit())
+			continue
+		}
+		// The snapshot contains the file exactly once, wherever the renames had left it
+		matches, err := s.p.FindAll("/", "foobar_file")
+		renaming.Unlock()
+		assert.Nil(s.T(), err)
+		assert.Len(s.T(), matches, 1)
+	}
+}
+
+func (s *ProcessTestSuite) TestTxRollbackRefusesOtherChanges() {
+	tx, err := s.p.Begin()
+	assert.Nil(s.T(), err)
+	f, err := tx.CreateFile("/a/created")
+	assert.Nil(s.T(), err)
+	assert.Nil(s.T(), s.p.WriteFile("/a/outside", []byte("outside"), 0))
+
+	// Rolling back would discard the other context's file, so the transaction is left open
+	assert.ErrorIs(s.T(), tx.Rollback(), fserrors.EBusy)
+	s.assertFileContents("/a/outside", "outside")
+	_, err = f.Write([]byte("data"))
+	assert.Nil(s.T(), err)
+	s.assertFileContents("/a/created", "data")
+	assert.ErrorIs(s.T(), tx.Rollback(), fserrors.EBusy)
+	assert.Nil(s.T(), tx.Commit())
+	assert.True(s.T(), s.p.Exists("/a/created"))
+
+	// A mutation through a File that was opened before the transaction began counts too
+	before, err := s.p.OpenFile("/a/foobar_file", os.O_RDWR)
+	assert.Nil(s.T(), err)
+	tx, err = s.p.Begin()
+	assert.Nil(s.T(), err)
+	_, err = before.WriteAt([]byte("H"), 0)
+	assert.Nil(s.T(), err)
+	assert.ErrorIs(s.T(), tx.Rollback(), fserrors.EBusy)
+	assert.Nil(s.T(), tx.Commit())
+	s.assertFileContents("/a/foobar_file", "Hello!")
+}
+
+func (s *ProcessTestSuite) TestTxRollbackAllowsOwnChanges() {
+	assert.Nil(s.T(), s.p.Pushd("/a"))
+	tx, err := s.p.Begin()
+	assert.Nil(s.T(), err)
+	// Mutations through the transaction's clones, chroots, directory stack, directory handles, and
+	// files all belong to the transaction
+	assert.Nil(s.T(), tx.Clone().WriteFile("/a/cloned", []byte("cloned"), 0))
+	chrooted, err := tx.Chroot("/a")
+	assert.Nil(s.T(), err)
+	assert.Nil(s.T(), chrooted.MakeDirectory("/chrooted"))
+	assert.Nil(s.T(), tx.Popd())
+	assert.Nil(s.T(), tx.DeleteFile("a/foobar_file"))
+	_, err = tx.GetDirectoryHandle().Mkdir("a/handle")
+	assert.Nil(s.T(), err)
+	assert.Nil(s.T(), tx.Chmod("/a/b", 0o700))
+	f, err := tx.OpenFileBuffered("/a/buffered", os.CombineModes(os.O_RDWR, os.O_CREATE), 16)
+	assert.Nil(s.T(), err)
+	_, err = f.Dup().Write([]byte("data"))
+	assert.Nil(s.T(), err)
+	assert.Nil(s.T(), f.Sync())
+
+	assert.Nil(s.T(), tx.Rollback())
+	s.assertFileContents("/a/foobar_file", "hello!")
+	for _, path := range []string{"/a/cloned", "/a/chrooted", "/a/handle", "/a/buffered"} {
+		assert.False(s.T(), s.p.Exists(path))
+	}
+}
+
+func (s *ProcessTestSuite) TestTxBeginErrors() {
+	chrooted, err := s.p.Chroot("/a")
+	assert.Nil(s.T(), err)
+	_, err = chrooted.Begin()
+	assert.ErrorIs(s.T(), err, fserrors.EInval)
+
+	overlay := process.NewProcessFilesystemContext(filesys.NewOverlay(s.fs, filesys.NewFileSystem()))
+	_, err = overlay.Begin()
+	assert.ErrorIs(s.T(), err, fserrors.EInval)
+}
@@ -2,10 +2,9 @@ package process
 
 import (
 	"fmt"
-	"sort"
 
 	"github.com/manderson5192/memfs/directory"
-	"github.com/manderson5192/memfs/filepath"
+	"github.com/pkg/errors"
 )
 
 // SkipDir is a sentinel error whose meaning is described in the comment on WalkFunc
@@ -51,19 +50,13 @@ func (p *processContext) Walk(path string, f WalkFunc) error {
 	return err
 }
 
-type byEntry []directory.DirectoryEntry
-
-func (b byEntry) Len() int           { return len(b) }
-func (b byEntry) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
-func (b byEntry) Less(i, j int) bool { return b[i].Name < b[j].Name }
-
 func (p *processContext) walk(path string, fileInfo *directory.FileInfo, f WalkFunc) error {
 	// No further recursion on files, so simply call the WalkFunc and return
 	if fileInfo.Type != directory.DirectoryType {
 		return f(path, fileInfo, nil)
 	}
-	// Get the entries in the directory
-	entries, err := p.ListDirectory(path)
+	// Get a consistent snapshot of the entries in the directory
+	entries, err := p.statEntries(path)
 	walkFnErr := f(path, fileInfo, err)
 	// Three cases are possible here:
 	// 	(1) err is nil and walkFnErr is nil: call walk() on all items under this directory
@@ -76,42 +69,75 @@ func (p *processContext) walk(path string, fileInfo *directory.FileInfo, f WalkF
 	if err != nil || walkFnErr != nil {
 		return walkFnErr
 	}
-	// Sort the entries lexicographically
-	sort.Sort(byEntry(entries))
-	// Iterate over the entries in lexicographic order
-	for _, entry := range entries {
+	// Iterate over the entries, which are already in lexicographic order
+	for _, fileInfo := range entries {
 		// Construct the path for this entry
-		newPath := filepath.Join(path, entry.Name)
-		// Stat this entry
-		fileInfo, err := p.Stat(newPath)
+		newPath := p.parser().Join(path, fileInfo.Name)
+		err = p.walk(newPath, fileInfo, f)
 		if err != nil {
-			// We couldn't stat() newPath, so we can't walk() newPath.  We have to call WalkFunc and
-			// act on the error that it returns:
-			//	(1) no error: continue iterating to the next entry in path.
-			//	(2) error is SkipDir: we failed to stat() the directory, so we can't walk() newPath
-			//		regardless.  Continue iterating to the next entry in path.
-			//	(3) error is something other than SkipDir: Walk() needs to be halted and we need to
-			//		return this error up the call stack.
-			if err := f(newPath, nil, err); err != nil && err != SkipDir {
+			// walk() returned an error.  Here are the possible interpretations:
+			//	(1) err is SkipDir and newPath is a file.  WalkFunc has indicated that it is
+			//		time to stop iterating over path's directory.  Percolate the SkipDir up the
+			//		call stack.
+			//	(2) err is SkipDir and newPath is a directory.  WalkFunc wants to skip newPath's
+			//		directory, which we're already done with at this point, so just keep on
+			//		iterating.
+			//	(3) err is not SkipDir: at some point WalkFunc returned not-SkipDir, which means
+			//		that it is time to stop iterating and pass the error up the call stack.
+			if fileInfo.Type != directory.DirectoryType || err != SkipDir {
 				return err
 			}
-		} else {
-			err = p.walk(newPath, fileInfo, f)
-			if err != nil {
-				// walk() returned an error.  Here are the possible interpretations:
-				//	(1) err is SkipDir and newPath is a file.  WalkFunc has indicated that it is
-				//		time to stop iterating over path's directory.  Percolate the SkipDir up the
-				//		call stack.
-				//	(2) err is SkipDir and newPath is a directory.  WalkFunc wants to skip newPath's
-				//		directory, which we're already done with at this point, so just keep on
-				//		iterating.
-				//	(3) err is not SkipDir: at some point WalkFunc returned not-SkipDir, which means
-				//		that it is time to stop iterating and pass the error up the call stack.
-				if fileInfo.Type != directory.DirectoryType || err != SkipDir {
-					return err
-				}
-			}
 		}
 	}
 	return nil
 }
+
+// WalkPostOrder walks the file tree rooted at root like Walk, except that each directory is visited
+// after its entries rather than before them.  See ProcessFilesystemContext.WalkPostOrder() for how
+// SkipDir is interpreted.
+func (p *processContext) WalkPostOrder(path string, f WalkFunc) error {
+	fileInfo, err := p.Stat(path)
+	if err != nil {
+		err = f(path, nil, err)
+	} else {
+		err = p.walkPostOrder(path, fileInfo, f)
+	}
+	if err == SkipDir {
+		return nil
+	}
+	return err
+}
+
+func (p *processContext) walkPostOrder(path string, fileInfo *directory.FileInfo, f WalkFunc) error {
+	if fileInfo.Type != directory.DirectoryType {
+		return f(path, fileInfo, nil)
+	}
+	entries, err := p.statEntries(path)
+	if err != nil {
+		// We can't visit the directory's entries, so visit the directory itself with the error
+		return f(path, fileInfo, err)
+	}
+	for _, entryInfo := range entries {
+		newPath := p.parser().Join(path, entryInfo.Name)
+		err := p.walkPostOrder(newPath, entryInfo, f)
+		// SkipDir for this entry (which is visited last in its subtree) ends the iteration over
+		// this directory's entries.  Any other error ends the walk.
+		if err == SkipDir {
+			break
+		} else if err != nil {
+			return err
+		}
+	}
+	return f(path, fileInfo, nil)
+}
+
+// statEntries returns a FileInfo for each entry of the directory at path, sorted by name, from a
+// single consistent snapshot of the directory (see directory.Directory.StatEntries())
+func (p *processContext) statEntries(path string) ([]*directory.FileInfo, error) {
+	relativePath, baseDir := p.toCleanRelativePathAndBaseDir(path)
+	entries, err := baseDir.StatEntries(relativePath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not list entries in directory '%s'", path)
+	}
+	return entries, nil
+}
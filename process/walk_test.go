@@ -2,8 +2,10 @@ package process_test
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/manderson5192/memfs/directory"
+	"github.com/manderson5192/memfs/fserrors"
 	"github.com/manderson5192/memfs/process"
 	"github.com/stretchr/testify/assert"
 )
@@ -94,3 +96,153 @@ func (s *ProcessTestSuite) TestWalkWalkFuncSkipsFooBarFile() {
 		"/a/b/c",
 	}, paths)
 }
+
+func (s *ProcessTestSuite) TestWalkPostOrder() {
+	paths := make([]string, 0)
+	walkFn := process.WalkFunc(func(path string, fileInfo *directory.FileInfo, err error) error {
+		assert.Nil(s.T(), err, "WalkFunc shouldn't receive any errors")
+		assert.NotNil(s.T(), fileInfo, "fileInfo should be populated on all calls to WalkFunc")
+		paths = append(paths, path)
+		return nil
+	})
+	err := s.p.WalkPostOrder("/", walkFn)
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []string{
+		"/a/b/a",
+		"/a/b/c",
+		"/a/b",
+		"/a/foobar_file",
+		"/a/zzz",
+		"/a",
+		"/",
+	}, paths)
+	// Every directory is visited after all of its descendants
+	for idx, path := range paths {
+		for _, later := range paths[idx+1:] {
+			assert.False(s.T(), strings.HasPrefix(later, path+"/"), "%s was visited after %s", later, path)
+		}
+	}
+}
+
+func (s *ProcessTestSuite) TestWalkPostOrderDeletesTree() {
+	walkFn := process.WalkFunc(func(path string, fileInfo *directory.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if fileInfo.IsDir() {
+			return s.p.RemoveDirectory(path)
+		}
+		return s.p.DeleteFile(path)
+	})
+	assert.Nil(s.T(), s.p.WalkPostOrder("/a", walkFn))
+	assert.False(s.T(), s.p.Exists("/a"))
+}
+
+func (s *ProcessTestSuite) TestWalkPostOrderSkipDirSkipsRemainingSiblings() {
+	paths := make([]string, 0)
+	walkFn := process.WalkFunc(func(path string, fileInfo *directory.FileInfo, err error) error {
+		assert.Nil(s.T(), err, "WalkFunc shouldn't receive any errors")
+		paths = append(paths, path)
+		// /a/b's subtree has been visited, but /a/foobar_file and /a/zzz will be skipped
+		if path == "/a/b" {
+			return process.SkipDir
+		}
+		return nil
+	})
+	err := s.p.WalkPostOrder("/", walkFn)
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []string{
+		"/a/b/a",
+		"/a/b/c",
+		"/a/b",
+		"/a",
+		"/",
+	}, paths)
+}
+
+func (s *ProcessTestSuite) TestWalkPostOrderStopsOnError() {
+	walkFuncErr := fmt.Errorf("this error stops the WalkFunc")
+	paths := make([]string, 0)
+	walkFn := process.WalkFunc(func(path string, fileInfo *directory.FileInfo, err error) error {
+		paths = append(paths, path)
+		if path == "/a/b/c" {
+			return walkFuncErr
+		}
+		return nil
+	})
+	assert.Equal(s.T(), walkFuncErr, s.p.WalkPostOrder("/", walkFn))
+	assert.Equal(s.T(), []string{"/a/b/a", "/a/b/c"}, paths)
+}
+
+func (s *ProcessTestSuite) TestWalkPostOrderNonexistentRoot() {
+	called := false
+	walkFn := process.WalkFunc(func(path string, fileInfo *directory.FileInfo, err error) error {
+		called = true
+		assert.Nil(s.T(), fileInfo)
+		assert.ErrorIs(s.T(), err, fserrors.ENoEnt)
+		return nil
+	})
+	assert.Nil(s.T(), s.p.WalkPostOrder("/missing", walkFn))
+	assert.True(s.T(), called)
+}
+
+func (s *ProcessTestSuite) TestWalkWhileMutating() {
+	assert.Nil(s.T(), s.p.MakeDirectory("/m"))
+	_, err := s.p.CreateFile("/m/r1")
+	assert.Nil(s.T(), err)
+	mutator := s.p.Clone()
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		// Create and delete entries, and rename "r1" to "r2" and back, as fast as possible
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			switch i % 3 {
+			case 0:
+				_, _ = mutator.CreateFile("/m/f")
+				_ = mutator.DeleteFile("/m/f")
+			case 1:
+				_ = mutator.MakeDirectory("/m/d")
+				_ = mutator.MakeDirectory("/m/d/e")
+				_ = mutator.RemoveDirectory("/m/d/e")
+				_ = mutator.RemoveDirectory("/m/d")
+			case 2:
+				_ = mutator.Rename("/m/r1", "/m/r2")
+				_ = mutator.Rename("/m/r2", "/m/r1")
+			}
+		}
+	}()
+	for i := 0; i < 200; i++ {
+		seen := map[string]bool{}
+		err := s.p.Walk("/", func(path string, fileInfo *directory.FileInfo, err error) error {
+			assert.False(s.T(), seen[path], "'%s' was visited twice", path)
+			seen[path] = true
+			if err != nil {
+				// Only a directory that was removed after it was listed can fail
+				assert.ErrorIs(s.T(), err, fserrors.ENoEnt)
+			}
+			if !assert.NotNil(s.T(), fileInfo) {
+				return nil
+			}
+			if path != "/" {
+				assert.Equal(s.T(), path[strings.LastIndex(path, "/")+1:], fileInfo.Name)
+			}
+			if fileInfo.Name == "d" || fileInfo.Name == "e" {
+				assert.True(s.T(), fileInfo.IsDir(), "'%s' should be a directory", path)
+			} else if strings.HasPrefix(path, "/m/") {
+				assert.False(s.T(), fileInfo.IsDir(), "'%s' should be a file", path)
+			}
+			return nil
+		})
+		assert.Nil(s.T(), err)
+		// Exactly one of the renamed file's names is listed
+		assert.NotEqual(s.T(), seen["/m/r1"], seen["/m/r2"])
+	}
+	close(stop)
+	<-done
+}
@@ -0,0 +1,43 @@
+package process
+
+import (
+	"fmt"
+	"iter"
+
+	"github.com/manderson5192/memfs/directory"
+)
+
+// WalkItem is a file or directory that was visited by WalkSeqWithErrors()
+type WalkItem struct {
+	Path string
+	// Info describes the file or directory, or is nil if it could not be stat'd
+	Info *directory.FileInfo
+}
+
+// errStopWalk is returned by the WalkFuncs of WalkSeq() and WalkSeqWithErrors() to stop Walk()
+// once the caller breaks out of its range loop
+var errStopWalk = fmt.Errorf("walk stopped")
+
+func (p *processContext) WalkSeq(path string) iter.Seq2[string, *directory.FileInfo] {
+	return func(yield func(string, *directory.FileInfo) bool) {
+		for item, err := range p.WalkSeqWithErrors(path) {
+			if err != nil || !yield(item.Path, item.Info) {
+				return
+			}
+		}
+	}
+}
+
+func (p *processContext) WalkSeqWithErrors(path string) iter.Seq2[WalkItem, error] {
+	return func(yield func(WalkItem, error) bool) {
+		walkFunc := func(path string, fileInfo *directory.FileInfo, err error) error {
+			if !yield(WalkItem{Path: path, Info: fileInfo}, err) || err != nil {
+				// Walk() can't continue past an error, so the sequence ends with it
+				return errStopWalk
+			}
+			return nil
+		}
+		// Walk() only fails with errors returned by walkFunc, which have already been yielded
+		_ = p.Walk(path, walkFunc)
+	}
+}
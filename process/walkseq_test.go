@@ -0,0 +1,62 @@
+package process_test
+
+import (
+	"github.com/manderson5192/memfs/directory"
+	"github.com/manderson5192/memfs/fserrors"
+	"github.com/manderson5192/memfs/process"
+	"github.com/stretchr/testify/assert"
+)
+
+func (s *ProcessTestSuite) TestWalkSeq() {
+	walked := []string{}
+	assert.Nil(s.T(), s.p.Walk("/a", func(path string, fileInfo *directory.FileInfo, err error) error {
+		walked = append(walked, path)
+		return err
+	}))
+	ranged := []string{}
+	for path, info := range s.p.WalkSeq("/a") {
+		assert.NotNil(s.T(), info)
+		ranged = append(ranged, path)
+	}
+	assert.Equal(s.T(), walked, ranged)
+	assert.Equal(s.T(), "/a/foobar_file", ranged[4])
+}
+
+func (s *ProcessTestSuite) TestWalkSeqBreak() {
+	// If the walk continued after the break, then the runtime would panic when it called yield
+	// again
+	ranged := []string{}
+	for path := range s.p.WalkSeq("/") {
+		ranged = append(ranged, path)
+		if len(ranged) == 3 {
+			break
+		}
+	}
+	assert.Equal(s.T(), []string{"/", "/a", "/a/b"}, ranged)
+
+	items := []process.WalkItem{}
+	for item, err := range s.p.WalkSeqWithErrors("/a") {
+		assert.Nil(s.T(), err)
+		items = append(items, item)
+		break
+	}
+	assert.Len(s.T(), items, 1)
+	assert.Equal(s.T(), "/a", items[0].Path)
+	assert.Equal(s.T(), directory.DirectoryType, items[0].Info.Type)
+}
+
+func (s *ProcessTestSuite) TestWalkSeqErrors() {
+	count := 0
+	for range s.p.WalkSeq("/noexist") {
+		count++
+	}
+	assert.Zero(s.T(), count)
+
+	for item, err := range s.p.WalkSeqWithErrors("/noexist") {
+		count++
+		assert.Equal(s.T(), "/noexist", item.Path)
+		assert.Nil(s.T(), item.Info)
+		assert.ErrorIs(s.T(), err, fserrors.ENoEnt)
+	}
+	assert.Equal(s.T(), 1, count)
+}
@@ -0,0 +1,15 @@
+package process
+
+import (
+	"github.com/manderson5192/memfs/notify"
+	"github.com/pkg/errors"
+)
+
+func (p *processContext) Watch(path string) (<-chan notify.Event, func(), error) {
+	relativePath, baseDir := p.toCleanRelativePathAndBaseDir(path)
+	events, unwatch, err := baseDir.Watch(relativePath)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "could not watch %s", path)
+	}
+	return events, unwatch, nil
+}
@@ -0,0 +1,131 @@
+package process_test
+
+import (
+	"github.com/manderson5192/memfs/fserrors"
+	"github.com/manderson5192/memfs/notify"
+	"github.com/manderson5192/memfs/os"
+	"github.com/stretchr/testify/assert"
+)
+
+// drainEvents returns all of the events that are currently buffered in events
+func drainEvents(events <-chan notify.Event) []notify.Event {
+	drained := []notify.Event{}
+	for {
+		select {
+		case e := <-events:
+			drained = append(drained, e)
+		default:
+			return drained
+		}
+	}
+}
+
+func (s *ProcessTestSuite) TestWatchDirectory() {
+	events, unwatch, err := s.p.Watch("/a")
+	assert.Nil(s.T(), err)
+	defer unwatch()
+
+	assert.Nil(s.T(), s.p.MakeDirectory("/a/b/new_dir"))
+	f, err := s.p.CreateFile("/a/b/new_file")
+	assert.Nil(s.T(), err)
+	_, err = f.Write([]byte("data"))
+	assert.Nil(s.T(), err)
+	assert.Nil(s.T(), s.p.Rename("/a/b/new_file", "/a/renamed_file"))
+	assert.Nil(s.T(), s.p.DeleteFile("/a/renamed_file"))
+	assert.Nil(s.T(), s.p.RemoveDirectory("/a/b/new_dir"))
+
+	assert.Equal(s.T(), []notify.Event{
+		{Path: "/a/b/new_dir", Op: notify.Create},
+		{Path: "/a/b/new_file", Op: notify.Create},
+		{Path: "/a/b/new_file", Op: notify.Write},
+		{Path: "/a/b/new_file", Op: notify.Rename},
+		{Path: "/a/renamed_file", Op: notify.Create},
+		{Path: "/a/renamed_file", Op: notify.Remove},
+		{Path: "/a/b/new_dir", Op: notify.Remove},
+	}, drainEvents(events))
+}
+
+func (s *ProcessTestSuite) TestWatchIgnoresUnrelatedPaths() {
+	events, unwatch, err := s.p.Watch("/a/b")
+	assert.Nil(s.T(), err)
+	defer unwatch()
+
+	// Neither a sibling directory nor a sibling whose name has the watched path as a prefix count
+	assert.Nil(s.T(), s.p.MakeDirectory("/a/zzz/c"))
+	assert.Nil(s.T(), s.p.MakeDirectory("/a/bb"))
+	_, err = s.p.CreateFile("/a/b/c/file")
+	assert.Nil(s.T(), err)
+
+	assert.Equal(s.T(), []notify.Event{
+		{Path: "/a/b/c/file", Op: notify.Create},
+	}, drainEvents(events))
+}
+
+func (s *ProcessTestSuite) TestWatchFile() {
+	events, unwatch, err := s.p.Watch("/a/foobar_file")
+	assert.Nil(s.T(), err)
+	defer unwatch()
+
+	// Opening without O_TRUNC doesn't modify the file, but truncating and writing both do
+	f, err := s.p.OpenFile("/a/foobar_file", os.O_RDWR)
+	assert.Nil(s.T(), err)
+	_, err = s.p.OpenFile("/a/foobar_file", os.O_RDWR|os.O_TRUNC)
+	assert.Nil(s.T(), err)
+	assert.Nil(s.T(), f.TruncateAndWriteAll([]byte("new data")))
+
+	assert.Equal(s.T(), []notify.Event{
+		{Path: "/a/foobar_file", Op: notify.Write},
+		{Path: "/a/foobar_file", Op: notify.Write},
+	}, drainEvents(events))
+}
+
+func (s *ProcessTestSuite) TestWatchRelativePath() {
+	assert.Nil(s.T(), s.p.ChangeDirectory("/a/b"))
+	events, unwatch, err := s.p.Watch("c")
+	assert.Nil(s.T(), err)
+	defer unwatch()
+	_, err = s.p.CreateFile("c/file")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []notify.Event{
+		{Path: "/a/b/c/file", Op: notify.Create},
+	}, drainEvents(events))
+}
+
+func (s *ProcessTestSuite) TestWatchDropsEventsForSlowConsumer() {
+	events, unwatch, err := s.p.Watch("/")
+	assert.Nil(s.T(), err)
+	defer unwatch()
+	f, err := s.p.OpenFile("/a/foobar_file", os.O_RDWR)
+	assert.Nil(s.T(), err)
+
+	// Nobody is reading events, so writes beyond the buffer's capacity must neither block nor fail
+	for n := 0; n < 2*notify.EventBufferSize; n++ {
+		_, err := f.Write([]byte("x"))
+		assert.Nil(s.T(), err)
+	}
+	assert.Len(s.T(), drainEvents(events), notify.EventBufferSize)
+
+	// Once the consumer has caught up, events are delivered again
+	assert.Nil(s.T(), s.p.MakeDirectory("/later"))
+	assert.Equal(s.T(), []notify.Event{
+		{Path: "/later", Op: notify.Create},
+	}, drainEvents(events))
+}
+
+func (s *ProcessTestSuite) TestUnwatchClosesChannel() {
+	events, unwatch, err := s.p.Watch("/")
+	assert.Nil(s.T(), err)
+	unwatch()
+	// Calling unwatch again is harmless
+	unwatch()
+	assert.Nil(s.T(), s.p.MakeDirectory("/new"))
+	_, open := <-events
+	assert.False(s.T(), open)
+}
+
+func (s *ProcessTestSuite) TestWatchNoExist() {
+	_, _, err := s.p.Watch("/noexist")
+	assert.ErrorIs(s.T(), err, fserrors.ENoEnt)
+	_, _, err = s.p.Watch("/a/foobar_file/")
+	assert.ErrorIs(s.T(), err, fserrors.ENotDir)
+}
@@ -1,6 +1,13 @@
 package process
 
-import "github.com/pkg/errors"
+import (
+	"strings"
+
+	"github.com/manderson5192/memfs/directory"
+	"github.com/manderson5192/memfs/file"
+	"github.com/manderson5192/memfs/fserrors"
+	"github.com/pkg/errors"
+)
 
 func (p *processContext) WorkingDirectory() (string, error) {
 	return p.workdir.ReversePathLookup()
@@ -10,8 +17,112 @@ func (p *processContext) ChangeDirectory(path string) error {
 	relativePath, baseDir := p.toCleanRelativePathAndBaseDir(path)
 	newDir, lookupErr := baseDir.LookupSubdirectory(relativePath)
 	if lookupErr != nil {
-		return errors.Wrapf(lookupErr, "could not change directories")
+		return errors.Wrapf(lookupErr, "could not change directories to '%s'", path)
 	}
 	p.workdir = newDir
 	return nil
 }
+
+func (p *processContext) ChangeDirectoryToFileParent(f file.File) error {
+	name, err := f.Name()
+	if err != nil {
+		return errors.Wrapf(err, "could not change directories to the file's parent")
+	}
+	parentDir, err := p.lookupFilesystemPath(p.parser().ParsePath(name).ParentPath)
+	if err != nil {
+		return errors.Wrapf(err, "could not change directories to the file's parent")
+	}
+	p.workdir = parentDir
+	return nil
+}
+
+// lookupFilesystemPath returns the Directory at path, an absolute path in the filesystem that
+// ignores any chroot (e.g. from file.File.Name()), with the same root as the process.  It returns
+// ENOENT if the directory is not in the subtree of the process's root.
+func (p *processContext) lookupFilesystemPath(path string) (directory.Directory, error) {
+	parser := p.parser()
+	var parts []string
+	for _, part := range strings.Split(parser.Clean(path), p.separator()) {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	// Find the prefix of path that names the process's root, and then resolve the rest of path
+	// from the root
+	fsRoot := p.fileSystem.RootDirectory()
+	for idx := 0; idx <= len(parts); idx++ {
+		dir, err := fsRoot.LookupSubdirectory(parser.Join(parts[:idx]...))
+		if err != nil {
+			return nil, err
+		}
+		if dir.Equals(p.root) {
+			return p.root.LookupSubdirectory(parser.Join(parts[idx:]...))
+		}
+	}
+	return nil, errors.Wrapf(fserrors.ENoEnt, "directory '%s' is not reachable from the process's root", path)
+}
+
+func (p *processContext) GetDirectoryHandle() directory.Directory {
+	return p.workdir
+}
+
+func (p *processContext) ChangeDirectoryToHandle(d directory.Directory) error {
+	if d == nil {
+		return errors.Wrapf(fserrors.EInval, "could not change directories to a nil directory")
+	}
+	if !d.IsValid() {
+		return errors.Wrapf(fserrors.ENoEnt, "could not change directories to a deleted directory")
+	}
+	// A directory that has been deleted along with an ancestor no longer has a path
+	path, err := d.ReversePathLookup()
+	if err != nil {
+		return errors.Wrapf(err, "could not change directories to a directory that no longer exists")
+	}
+	// Resolve the path from this process's root, so that the new working directory has the same
+	// root and umask as the rest of the context
+	relativePath, baseDir := p.toCleanRelativePathAndBaseDir(path)
+	dir, err := baseDir.LookupSubdirectory(relativePath)
+	if err != nil || !dir.Equals(d) {
+		return errors.Wrapf(fserrors.EInval, "directory '%s' is not reachable from the process's root", path)
+	}
+	p.workdir = dir
+	return nil
+}
+
+func (p *processContext) Chroot(path string) (ProcessFilesystemContext, error) {
+	relativePath, baseDir := p.toCleanRelativePathAndBaseDir(path)
+	newRoot, err := baseDir.LookupSubdirectory(relativePath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not change root directory to '%s'", path)
+	}
+	newRoot = newRoot.Chroot()
+	return &processContext{
+		fileSystem: p.fileSystem,
+		root:       newRoot,
+		workdir:    newRoot,
+		tx:         p.tx,
+	}, nil
+}
+
+func (p *processContext) Pushd(path string) error {
+	previous := p.workdir
+	if err := p.ChangeDirectory(path); err != nil {
+		return errors.Wrapf(err, "could not push directory '%s'", path)
+	}
+	p.dirStack = append(p.dirStack, previous)
+	return nil
+}
+
+func (p *processContext) Popd() error {
+	if len(p.dirStack) == 0 {
+		return errors.Wrapf(fserrors.EInval, "directory stack is empty")
+	}
+	top := p.dirStack[len(p.dirStack)-1]
+	p.dirStack = p.dirStack[:len(p.dirStack)-1]
+	// A directory that has been deleted no longer has a path
+	if _, err := top.ReversePathLookup(); err != nil {
+		return errors.Wrapf(err, "could not pop to a directory that no longer exists")
+	}
+	p.workdir = top
+	return nil
+}
@@ -1,6 +1,12 @@
 package process_test
 
-import "github.com/stretchr/testify/assert"
+import (
+	"github.com/manderson5192/memfs/filesys"
+	"github.com/manderson5192/memfs/fserrors"
+	"github.com/manderson5192/memfs/os"
+	"github.com/manderson5192/memfs/process"
+	"github.com/stretchr/testify/assert"
+)
 
 func (s *ProcessTestSuite) TestWorkingDirectory() {
 	workdir, err := s.p.WorkingDirectory()
@@ -14,3 +20,155 @@ func (s *ProcessTestSuite) TestWorkingDirectory() {
 	assert.Nil(s.T(), err)
 	assert.Equal(s.T(), "/a/b/a", workdir)
 }
+
+func (s *ProcessTestSuite) TestChangeDirectoryToFileParent() {
+	f, err := s.p.OpenFile("/a/foobar_file", os.O_RDONLY)
+	assert.Nil(s.T(), err)
+	err = s.p.ChangeDirectoryToFileParent(f)
+	assert.Nil(s.T(), err)
+	workdir, err := s.p.WorkingDirectory()
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), "/a", workdir)
+}
+
+func (s *ProcessTestSuite) TestChangeDirectoryToFileParentAfterRename() {
+	f, err := s.p.OpenFile("/a/foobar_file", os.O_RDONLY)
+	assert.Nil(s.T(), err)
+	assert.Nil(s.T(), s.p.Rename("/a/foobar_file", "/a/b/c/moved_file"))
+	err = s.p.ChangeDirectoryToFileParent(f)
+	assert.Nil(s.T(), err)
+	workdir, err := s.p.WorkingDirectory()
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), "/a/b/c", workdir)
+}
+
+func (s *ProcessTestSuite) TestChangeDirectoryToFileParentAfterDelete() {
+	f, err := s.p.OpenFile("/a/foobar_file", os.O_RDONLY)
+	assert.Nil(s.T(), err)
+	assert.Nil(s.T(), s.p.DeleteFile("/a/foobar_file"))
+	err = s.p.ChangeDirectoryToFileParent(f)
+	assert.ErrorIs(s.T(), err, fserrors.ENoEnt)
+	workdir, err := s.p.WorkingDirectory()
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), "/", workdir, "working directory is unchanged")
+}
+
+func (s *ProcessTestSuite) TestChangeDirectoryToFile() {
+	err := s.p.ChangeDirectory("/a/foobar_file")
+	assert.ErrorIs(s.T(), err, fserrors.ENotDir)
+	assert.NotErrorIs(s.T(), err, fserrors.ENoEnt)
+
+	err = s.p.ChangeDirectory("/a/foobar_file/subdir")
+	assert.ErrorIs(s.T(), err, fserrors.ENotDir)
+
+	workdir, err := s.p.WorkingDirectory()
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), "/", workdir, "working directory is unchanged")
+}
+
+func (s *ProcessTestSuite) TestChangeDirectoryNoExist() {
+	err := s.p.ChangeDirectory("/a/noexist")
+	assert.ErrorIs(s.T(), err, fserrors.ENoEnt)
+	assert.NotErrorIs(s.T(), err, fserrors.ENotDir)
+}
+
+func (s *ProcessTestSuite) assertWorkingDirectory(expected string) {
+	workdir, err := s.p.WorkingDirectory()
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), expected, workdir)
+}
+
+func (s *ProcessTestSuite) TestPushdPopd() {
+	assert.Nil(s.T(), s.p.Pushd("/a/b"))
+	s.assertWorkingDirectory("/a/b")
+	assert.Nil(s.T(), s.p.Pushd("c"))
+	s.assertWorkingDirectory("/a/b/c")
+	assert.Nil(s.T(), s.p.Popd())
+	s.assertWorkingDirectory("/a/b")
+	assert.Nil(s.T(), s.p.Popd())
+	s.assertWorkingDirectory("/")
+
+	err := s.p.Popd()
+	assert.ErrorIs(s.T(), err, fserrors.EInval)
+	s.assertWorkingDirectory("/")
+}
+
+func (s *ProcessTestSuite) TestPushdFailureLeavesStackUnchanged() {
+	err := s.p.Pushd("/a/foobar_file")
+	assert.ErrorIs(s.T(), err, fserrors.ENotDir)
+	s.assertWorkingDirectory("/")
+	assert.ErrorIs(s.T(), s.p.Popd(), fserrors.EInval)
+}
+
+func (s *ProcessTestSuite) TestPopdToDeletedDirectory() {
+	assert.Nil(s.T(), s.p.ChangeDirectory("/a/zzz"))
+	assert.Nil(s.T(), s.p.Pushd("/a/b"))
+	assert.Nil(s.T(), s.p.Pushd("/a/b/c"))
+	assert.Nil(s.T(), s.p.RemoveDirectory("/a/zzz"))
+
+	// Popping to /a/b still works
+	assert.Nil(s.T(), s.p.Popd())
+	s.assertWorkingDirectory("/a/b")
+
+	// ...but /a/zzz is gone
+	err := s.p.Popd()
+	assert.ErrorIs(s.T(), err, fserrors.ENoEnt)
+	s.assertWorkingDirectory("/a/b")
+	assert.ErrorIs(s.T(), s.p.Popd(), fserrors.EInval)
+}
+
+func (s *ProcessTestSuite) TestDirectoryHandleRoundTrip() {
+	assert.Nil(s.T(), s.p.ChangeDirectory("/a/b"))
+	handle := s.p.GetDirectoryHandle()
+	assert.Nil(s.T(), s.p.ChangeDirectory("/a/zzz"))
+	s.assertWorkingDirectory("/a/zzz")
+
+	assert.Nil(s.T(), s.p.ChangeDirectoryToHandle(handle))
+	s.assertWorkingDirectory("/a/b")
+	assert.True(s.T(), s.p.Exists("c"))
+
+	// The handle follows the directory when it is renamed
+	assert.Nil(s.T(), s.p.ChangeDirectory("/"))
+	assert.Nil(s.T(), s.p.Rename("/a/b", "/a/zzz/moved"))
+	assert.Nil(s.T(), s.p.ChangeDirectoryToHandle(handle))
+	s.assertWorkingDirectory("/a/zzz/moved")
+
+	// A handle from OpenDirectory() works, too
+	opened, err := s.p.OpenDirectory("/a")
+	assert.Nil(s.T(), err)
+	defer opened.Close()
+	assert.Nil(s.T(), s.p.ChangeDirectoryToHandle(opened))
+	s.assertWorkingDirectory("/a")
+}
+
+func (s *ProcessTestSuite) TestChangeDirectoryToDeletedHandle() {
+	assert.Nil(s.T(), s.p.ChangeDirectory("/a/b/c"))
+	handle := s.p.GetDirectoryHandle()
+	assert.Nil(s.T(), s.p.ChangeDirectory("/"))
+	assert.Nil(s.T(), s.p.RemoveDirectory("/a/b/c"))
+	assert.ErrorIs(s.T(), s.p.ChangeDirectoryToHandle(handle), fserrors.ENoEnt)
+	s.assertWorkingDirectory("/")
+
+	// A removed directory that is held open is rejected as well
+	opened, err := s.p.OpenDirectory("/a/b/a")
+	assert.Nil(s.T(), err)
+	defer opened.Close()
+	assert.Nil(s.T(), s.p.RemoveDirectory("/a/b/a"))
+	assert.ErrorIs(s.T(), s.p.ChangeDirectoryToHandle(opened), fserrors.ENoEnt)
+	s.assertWorkingDirectory("/")
+}
+
+func (s *ProcessTestSuite) TestChangeDirectoryToForeignHandle() {
+	assert.ErrorIs(s.T(), s.p.ChangeDirectoryToHandle(nil), fserrors.EInval)
+
+	other := process.NewProcessFilesystemContext(filesys.NewFileSystem())
+	assert.Nil(s.T(), other.MakeDirectoryWithAncestors("/a/b"))
+	assert.Nil(s.T(), other.ChangeDirectory("/a/b"))
+	assert.ErrorIs(s.T(), s.p.ChangeDirectoryToHandle(other.GetDirectoryHandle()), fserrors.EInval)
+
+	chrooted, err := s.p.Chroot("/a")
+	assert.Nil(s.T(), err)
+	assert.Nil(s.T(), chrooted.ChangeDirectory("/b"))
+	assert.ErrorIs(s.T(), s.p.ChangeDirectoryToHandle(chrooted.GetDirectoryHandle()), fserrors.EInval)
+	s.assertWorkingDirectory("/")
+}
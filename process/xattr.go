@@ -0,0 +1,37 @@
+package process
+
+import "github.com/pkg/errors"
+
+func (p *processContext) SetXattr(path, name string, value []byte) error {
+	relativePath, baseDir := p.toCleanRelativePathAndBaseDir(path)
+	if err := baseDir.SetXattr(relativePath, name, value); err != nil {
+		return errors.Wrapf(err, "could not set extended attribute '%s' on '%s'", name, path)
+	}
+	return nil
+}
+
+func (p *processContext) GetXattr(path, name string) ([]byte, error) {
+	relativePath, baseDir := p.toCleanRelativePathAndBaseDir(path)
+	value, err := baseDir.GetXattr(relativePath, name)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not get extended attribute '%s' of '%s'", name, path)
+	}
+	return value, nil
+}
+
+func (p *processContext) ListXattr(path string) ([]string, error) {
+	relativePath, baseDir := p.toCleanRelativePathAndBaseDir(path)
+	names, err := baseDir.ListXattr(relativePath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not list extended attributes of '%s'", path)
+	}
+	return names, nil
+}
+
+func (p *processContext) RemoveXattr(path, name string) error {
+	relativePath, baseDir := p.toCleanRelativePathAndBaseDir(path)
+	if err := baseDir.RemoveXattr(relativePath, name); err != nil {
+		return errors.Wrapf(err, "could not remove extended attribute '%s' from '%s'", name, path)
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package process_test
+
+import (
+	"github.com/manderson5192/memfs/fserrors"
+	"github.com/stretchr/testify/assert"
+)
+
+func (s *ProcessTestSuite) TestXattrSetAndGet() {
+	value := []byte("text/plain")
+	assert.Nil(s.T(), s.p.SetXattr("/a/foobar_file", "user.mime_type", value))
+	// Mutating the caller's buffer doesn't affect the stored value
+	value[0] = 'X'
+	got, err := s.p.GetXattr("/a/foobar_file", "user.mime_type")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []byte("text/plain"), got)
+	// ...and mutating the returned buffer doesn't either
+	got[0] = 'X'
+	got, err = s.p.GetXattr("/a/foobar_file", "user.mime_type")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []byte("text/plain"), got)
+
+	// Overwrite the value
+	assert.Nil(s.T(), s.p.SetXattr("/a/foobar_file", "user.mime_type", []byte("text/html")))
+	got, err = s.p.GetXattr("/a/foobar_file", "user.mime_type")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []byte("text/html"), got)
+}
+
+func (s *ProcessTestSuite) TestXattrOnDirectory() {
+	assert.Nil(s.T(), s.p.SetXattr("/a/b/", "user.owner", []byte("alice")))
+	assert.Nil(s.T(), s.p.ChangeDirectory("/a/b"))
+	got, err := s.p.GetXattr(".", "user.owner")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []byte("alice"), got)
+}
+
+func (s *ProcessTestSuite) TestXattrFollowsRename() {
+	assert.Nil(s.T(), s.p.SetXattr("/a/foobar_file", "user.tag", []byte("1")))
+	assert.Nil(s.T(), s.p.Rename("/a/foobar_file", "/a/b/renamed"))
+	got, err := s.p.GetXattr("/a/b/renamed", "user.tag")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []byte("1"), got)
+}
+
+func (s *ProcessTestSuite) TestListXattr() {
+	names, err := s.p.ListXattr("/a/foobar_file")
+	assert.Nil(s.T(), err)
+	assert.Empty(s.T(), names)
+
+	assert.Nil(s.T(), s.p.SetXattr("/a/foobar_file", "user.c", nil))
+	assert.Nil(s.T(), s.p.SetXattr("/a/foobar_file", "user.a", []byte("a")))
+	assert.Nil(s.T(), s.p.SetXattr("/a/foobar_file", "user.b", []byte("b")))
+	names, err = s.p.ListXattr("/a/foobar_file")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), []string{"user.a", "user.b", "user.c"}, names)
+}
+
+func (s *ProcessTestSuite) TestGetMissingXattr() {
+	_, err := s.p.GetXattr("/a/foobar_file", "user.missing")
+	assert.ErrorIs(s.T(), err, fserrors.ENoData)
+
+	_, err = s.p.GetXattr("/a/noexist", "user.missing")
+	assert.ErrorIs(s.T(), err, fserrors.ENoEnt)
+}
+
+func (s *ProcessTestSuite) TestRemoveXattr() {
+	assert.Nil(s.T(), s.p.SetXattr("/a/foobar_file", "user.tag", []byte("1")))
+	assert.Nil(s.T(), s.p.RemoveXattr("/a/foobar_file", "user.tag"))
+	_, err := s.p.GetXattr("/a/foobar_file", "user.tag")
+	assert.ErrorIs(s.T(), err, fserrors.ENoData)
+	err = s.p.RemoveXattr("/a/foobar_file", "user.tag")
+	assert.ErrorIs(s.T(), err, fserrors.ENoData)
+}
+
+func (s *ProcessTestSuite) TestSetXattrEmptyName() {
+	err := s.p.SetXattr("/a/foobar_file", "", []byte("1"))
+	assert.ErrorIs(s.T(), err, fserrors.EInval)
+}